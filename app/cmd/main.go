@@ -46,6 +46,15 @@ func main() {
 			fmt.Fprintln(os.Stderr, "FAILED")
 			os.Exit(1)
 		}
+	case runLint:
+		ok := app.Lint(appConf, args)
+		if ok {
+			fmt.Fprintln(os.Stderr, "NO PROBLEMS")
+			os.Exit(0)
+		} else {
+			fmt.Fprintln(os.Stderr, "FAILED")
+			os.Exit(1)
+		}
 	case runPackaging:
 		ok := app.Packaging("./", appConf, appConfigPath, args)
 		if ok {
@@ -65,6 +74,7 @@ const (
 	runMain runningMode = iota
 	runTest
 	runPackaging
+	runLint
 )
 
 const appConfigPath = config.ConfigFile
@@ -88,6 +98,7 @@ const (
 	flagNameTest        = "test"
 	flagNameTestTimeout = "test.timeoutsec"
 	flagNamePackaging   = "packaging"
+	flagNameLint        = "lint"
 	flagNameVersion     = "version"
 )
 
@@ -111,6 +122,10 @@ func parseFlags(flags *flag.FlagSet, argv []string) (runningMode, []string) {
 	flags.BoolVar(&packaging, flagNamePackaging, packaging, "run package creation and quit. after given this flag,"+
 		" extra arguments are treated as the additional files to be included in the package.")
 
+	lint := false
+	flags.BoolVar(&lint, flagNameLint, lint, "run static analysis for script files and quit. after given this flag,"+
+		" script files to analyze can be given in the command-line arguments, otherwise all script files are analyzed.")
+
 	showVersion := false
 	flags.BoolVar(&showVersion, flagNameVersion, showVersion, "show version info and quit.")
 
@@ -129,6 +144,9 @@ func parseFlags(flags *flag.FlagSet, argv []string) (runningMode, []string) {
 	if packaging {
 		return runPackaging, flag.Args()
 	}
+	if lint {
+		return runLint, flags.Args()
+	}
 	return runMain, nil
 }
 
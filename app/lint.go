@@ -0,0 +1,38 @@
+package app
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mzki/erago"
+	"github.com/mzki/erago/app/config"
+	"github.com/mzki/erago/util/log"
+)
+
+// Lint analyzes given script files on appConf context, and prints found problems
+// to standard output. If scriptFiles is empty, all of script files are analyzed.
+// It returns true if no problem is found.
+func Lint(appConf *config.Config, scriptFiles []string) bool {
+	if appConf == nil {
+		appConf = config.NewConfig(config.DefaultBaseDir)
+	}
+
+	// returned value must be called once.
+	reset, err := config.SetupLogConfig(appConf)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "log configuration failed: %v\n", err)
+		return false
+	}
+	defer reset()
+
+	issues, err := erago.Lint(appConf.Game, scriptFiles)
+	if err != nil {
+		log.Infoln("Error: app.Lint:", err)
+		fmt.Fprintln(os.Stderr, err)
+		return false
+	}
+	for _, issue := range issues {
+		fmt.Println(issue)
+	}
+	return len(issues) == 0
+}
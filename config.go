@@ -26,6 +26,7 @@ const (
 	DefaultCSVDir          = "CSV"
 	DefaultCSVCharaPattern = "Chara/Chara*"
 	DefaultScriptDir       = "ELA"
+	DefaultStoreDir        = "store"
 )
 
 // construct default Config with the base directory.
@@ -40,6 +41,9 @@ func NewConfig(baseDir string) Config {
 		ScriptConfig: script.Config{
 			LoadDir:             filepath.Join(baseDir, DefaultScriptDir),
 			LoadPattern:         script.LoadPattern,
+			PluginDir:           script.PluginDir,
+			StoreDir:            filepath.Join(baseDir, DefaultStoreDir),
+			StoreSizeLimit:      script.StoreSizeLimit,
 			CallStackSize:       script.CallStackSize,
 			RegistrySize:        script.RegistrySize,
 			IncludeGoStackTrace: true,
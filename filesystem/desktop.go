@@ -79,6 +79,16 @@ func (osfs *OSFileSystem) Store(fpath string) (writer io.WriteCloser, err error)
 	return fp, nil
 }
 
+// Implements FileSystemRename interface
+func (osfs *OSFileSystem) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+// Implements FileSystemRename interface
+func (osfs *OSFileSystem) Remove(fpath string) error {
+	return os.Remove(fpath)
+}
+
 // Implement fs.FS interface
 func (osfs *OSFileSystem) Open(fpath string) (fs.File, error) {
 	ospath := filepath.FromSlash(fpath)
@@ -1,6 +1,8 @@
 package filesystem
 
 import (
+	"errors"
+	"fmt"
 	"io"
 
 	"github.com/mzki/erago/util/log"
@@ -43,6 +45,15 @@ type FileSystemGlobPR interface {
 	PathResolver
 }
 
+// FileSystemRename has ability for replacing and removing files.
+type FileSystemRename interface {
+	FileSystem
+	// Rename renames oldpath to newpath. newpath is replaced if exist.
+	Rename(oldpath, newpath string) error
+	// Remove removes the file.
+	Remove(filepath string) error
+}
+
 // NopPathResolver implements PathResolver interface.
 type NopPathResolver struct{}
 
@@ -93,6 +104,32 @@ func GlobFS(fs FileSystemGlob, pattern string) ([]string, error) {
 	return fs.Glob(pattern)
 }
 
+// CanRename returns whether filesystem.Default implements FileSystemRename.
+func CanRename() bool {
+	_, ok := Default.(FileSystemRename)
+	return ok
+}
+
+// Rename renames oldpath to newpath under filesystem.Default.
+// It returns error wrapping errors.ErrUnsupported if Default does not implement FileSystemRename.
+func Rename(oldpath, newpath string) error {
+	log.Debugf("FileSystem.Rename: %s -> %s", oldpath, newpath)
+	if fsys, ok := Default.(FileSystemRename); ok {
+		return fsys.Rename(oldpath, newpath)
+	}
+	return fmt.Errorf("rename %s: %w", oldpath, errors.ErrUnsupported)
+}
+
+// Remove removes the file under filesystem.Default.
+// It returns error wrapping errors.ErrUnsupported if Default does not implement FileSystemRename.
+func Remove(filepath string) error {
+	log.Debugf("FileSystem.Remove: %s", filepath)
+	if fsys, ok := Default.(FileSystemRename); ok {
+		return fsys.Remove(filepath)
+	}
+	return fmt.Errorf("remove %s: %w", filepath, errors.ErrUnsupported)
+}
+
 // ResolvePath resolve file path under filesystem.Default.
 // if Default also implements PathResolver, use it to resolve path,
 // otherwise returns path itself.
@@ -67,6 +67,22 @@ func (ifs *InteropFileSystem) Exist(path string) bool {
 	}
 }
 
+// implements FileSystemRename interface
+func (ifs *InteropFileSystem) Rename(oldpath, newpath string) error {
+	if fsys, ok := ifs.mustBackend().(FileSystemRename); ok {
+		return fsys.Rename(fsPath(oldpath), fsPath(newpath))
+	}
+	return &fs.PathError{Op: "rename", Path: oldpath, Err: errors.ErrUnsupported}
+}
+
+// implements FileSystemRename interface
+func (ifs *InteropFileSystem) Remove(path string) error {
+	if fsys, ok := ifs.mustBackend().(FileSystemRename); ok {
+		return fsys.Remove(fsPath(path))
+	}
+	return &fs.PathError{Op: "remove", Path: path, Err: errors.ErrUnsupported}
+}
+
 // implements FileSystemGlob interface
 func (ifs *InteropFileSystem) Glob(pattern string) ([]string, error) {
 	if globFS, ok := ifs.mustBackend().(fs.GlobFS); ok {
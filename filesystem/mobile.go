@@ -1,6 +1,7 @@
 package filesystem
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -90,6 +91,36 @@ func (absfs *AbsPathFileSystem) Store(fpath string) (writer io.WriteCloser, err
 	return backend.Store(fpath)
 }
 
+// Implements FileSystemRename interface.
+// It returns error wrapping errors.ErrUnsupported if Backend does not implement it.
+func (absfs *AbsPathFileSystem) Rename(oldpath, newpath string) error {
+	oldpath, err := absfs.ResolvePath(oldpath)
+	if err != nil {
+		return fmt.Errorf("AbsPathFileSystem.Rename() error: %w", err)
+	}
+	newpath, err = absfs.ResolvePath(newpath)
+	if err != nil {
+		return fmt.Errorf("AbsPathFileSystem.Rename() error: %w", err)
+	}
+	if fsys, ok := absfs.mustBackend().(FileSystemRename); ok {
+		return fsys.Rename(oldpath, newpath)
+	}
+	return fmt.Errorf("Rename is not supported at backend filesystem: %w", errors.ErrUnsupported)
+}
+
+// Implements FileSystemRename interface.
+// It returns error wrapping errors.ErrUnsupported if Backend does not implement it.
+func (absfs *AbsPathFileSystem) Remove(fpath string) error {
+	fpath, err := absfs.ResolvePath(fpath)
+	if err != nil {
+		return fmt.Errorf("AbsPathFileSystem.Remove() error: %w", err)
+	}
+	if fsys, ok := absfs.mustBackend().(FileSystemRename); ok {
+		return fsys.Remove(fpath)
+	}
+	return fmt.Errorf("Remove is not supported at backend filesystem: %w", errors.ErrUnsupported)
+}
+
 // implements fs.FS interface.
 func (absfs *AbsPathFileSystem) Open(fpath string) (file fs.File, err error) {
 	fpath, err = absfs.ResolvePath(fpath)
@@ -1,6 +1,7 @@
 package filesystem
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -211,3 +212,33 @@ func TestAbsPathFileSystem_Open(t *testing.T) {
 		})
 	}
 }
+
+func TestAbsPathFileSystem_Rename(t *testing.T) {
+	absfs := &AbsPathFileSystem{CurrentDir: t.TempDir(), Backend: Desktop}
+	w, err := absfs.Store("old.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	if err := absfs.Rename("old.txt", "new.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if absfs.Exist("old.txt") || !absfs.Exist("new.txt") {
+		t.Errorf("old.txt should be renamed to new.txt")
+	}
+	if err := absfs.Remove("new.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if absfs.Exist("new.txt") {
+		t.Errorf("new.txt should be removed")
+	}
+
+	notSupported := &AbsPathFileSystem{CurrentDir: t.TempDir(), Backend: &emptyFileSystem{}}
+	if err := notSupported.Rename("old.txt", "new.txt"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expect unsupported error, got: %v", err)
+	}
+	if err := notSupported.Remove("old.txt"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expect unsupported error, got: %v", err)
+	}
+}
@@ -52,6 +52,10 @@ func (g *Game) Init(ui uiadapter.UI, config Config) error {
 	}
 	g.config = config
 
+	if err := config.SceneConfig.Validate(); err != nil {
+		return err
+	}
+
 	csv_manager := csv.NewCsvManager()
 	if err := csv_manager.Initialize(config.CSVConfig); err != nil {
 		return err
@@ -75,6 +79,11 @@ func (g *Game) Init(ui uiadapter.UI, config Config) error {
 	// register some special scenes
 	g.scene.RegisterSceneFunc(sceneNameBooting, g.sceneBooting)
 
+	// quick save, quick load and rewind requested by user are processed at the scene transitions
+	// and at the input points out of script.
+	g.scene.SetControlRequester(g.uiAdapter)
+	g.uiAdapter.SetControlRequestHandler(g.scene.HandleControlRequests)
+
 	return nil
 }
 
@@ -100,7 +109,12 @@ func (g Game) InputPort() uiadapter.Sender {
 // It returns nil if game quits correctly, otherwise return erorr containing
 // any panic in the flow.
 func (g *Game) Main() error {
-	return withRecoverRun(g.main)
+	err := withRecoverRun(g.main)
+	if err != nil && g.scene != nil {
+		// attach recent scene transitions for debugging flow.
+		err = fmt.Errorf("%w\n%s", err, g.scene.TransitionHistoryDump())
+	}
+	return err
 }
 
 // capture panic as error in this thread
@@ -74,8 +74,8 @@ func (game *Game) sceneBooting() (string, error) {
 	// extract user scripts and register it.
 	err = game.ipr.LoadSystem()
 
-	// next scene is title scene
-	return scene.SceneNameTitle, err
+	// next scene is entry scene, title scene by default.
+	return game.scene.EntrySceneName(), err
 }
 
 func fillStructByMap(dst interface{}, src map[string]string) error {
@@ -2,6 +2,7 @@ package repo
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"os"
 	"testing"
@@ -250,3 +251,226 @@ func BenchmarkDeserializeSystemData(b *testing.B) {
 		}
 	}
 }
+
+func TestCharaHandleAcrossLoad(t *testing.T) {
+	gamestate := state.NewGameState(CSVDB, Repo)
+
+	var charaID int64 = -1
+	for id := range CSVDB.CharaMap {
+		charaID = id
+		break
+	}
+	if charaID < 0 {
+		t.Fatalf("csv has no character IDs, cant test this case")
+	}
+
+	first, err := gamestate.SystemData.Chara.AddID(charaID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := gamestate.SystemData.Chara.AddID(charaID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handle := gamestate.SystemData.Chara.Handle(second)
+	if err := gamestate.SaveSystem(0); err != nil {
+		t.Fatal(err)
+	}
+
+	// modify after save, and then load it.
+	gamestate.SystemData.Chara.Remove(gamestate.SystemData.Chara.FindIndexByUID(first.UID))
+	if err := gamestate.LoadSystem(0); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := handle.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded == second {
+		t.Error("handle should refer the loaded character, not the older one")
+	}
+	if loaded != gamestate.SystemData.Chara.Get(1) {
+		t.Errorf("handle refers wrong character, got uid: %v", loaded.UID)
+	}
+
+	gamestate.SystemData.Chara.Remove(1)
+	if _, err := handle.Get(); !errors.Is(err, state.ErrCharaRemoved) {
+		t.Errorf("removed character should be error, got: %v", err)
+	}
+}
+
+func TestCharaCollectionSaveLoad(t *testing.T) {
+	gamestate := state.NewGameState(CSVDB, Repo)
+
+	var charaID int64 = -1
+	for id := range CSVDB.CharaMap {
+		charaID = id
+		break
+	}
+	if charaID < 0 {
+		t.Fatalf("csv has no character IDs, cant test this case")
+	}
+
+	chara, err := gamestate.SystemData.Chara.AddID(charaID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	party, ok := gamestate.SystemData.Collection("Party")
+	if !ok {
+		t.Fatal("csv has no Party collection, cant test this case")
+	}
+	if err := party.Add(chara); err != nil {
+		t.Fatal(err)
+	}
+	if err := gamestate.SaveSystem(0); err != nil {
+		t.Fatal(err)
+	}
+
+	// modify after save, and then load it.
+	party.Clear()
+	if err := gamestate.LoadSystem(0); err != nil {
+		t.Fatal(err)
+	}
+
+	party, _ = gamestate.SystemData.Collection("Party")
+	if party.Len() != 1 || party.Get(0) != gamestate.SystemData.Chara.Get(0) {
+		t.Errorf("collection is not loaded, got uids: %v", party.UIDs)
+	}
+	if party.Cap() != 4 {
+		t.Errorf("different capacity after loading, got: %v", party.Cap())
+	}
+}
+
+func TestDeclaredCharaReferencesSaveLoad(t *testing.T) {
+	gamestate := state.NewGameState(CSVDB, Repo)
+
+	var charaID int64 = -1
+	for id := range CSVDB.CharaMap {
+		charaID = id
+		break
+	}
+	if charaID < 0 {
+		t.Fatalf("csv has no character IDs, cant test this case")
+	}
+
+	chara, err := gamestate.SystemData.Chara.AddID(charaID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rival, ok := gamestate.SystemData.Reference("Rival")
+	if !ok {
+		t.Fatal("csv has no Rival reference, cant test this case")
+	}
+	if err := rival.Set(1, chara); err != nil {
+		t.Fatal(err)
+	}
+	if err := gamestate.SaveSystem(0); err != nil {
+		t.Fatal(err)
+	}
+
+	// modify after save, and then load it.
+	rival.Clear()
+	if err := gamestate.LoadSystem(0); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := gamestate.SystemData.Reference("Rival"); got != rival {
+		t.Error("declared reference should be kept same after loading")
+	}
+	if rival.Len() != 3 || rival.GetChara(1) != gamestate.SystemData.Chara.Get(0) {
+		t.Errorf("declared reference is not loaded, got uids: %v", rival.UIDs)
+	}
+}
+
+func TestMultiDimVariablesSaveLoad(t *testing.T) {
+	gamestate := state.NewGameState(CSVDB, Repo)
+
+	chara, err := gamestate.SystemData.Chara.AddID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history, ok := chara.GetInt("BaseHistory")
+	if !ok || !history.IsMultiDim() {
+		t.Fatal("csv has no multi-dimensional BaseHistory, cant test this case")
+	}
+	history.Sub(2).Set(0, 100)
+	memo, _ := gamestate.SystemData.GetStr("Memo")
+	memo.Sub(0).Set(1, "memo")
+	if err := gamestate.SaveSystem(0); err != nil {
+		t.Fatal(err)
+	}
+
+	// modify after save, and then load it.
+	history.Fill(0)
+	memo.Fill("")
+	if err := gamestate.LoadSystem(0); err != nil {
+		t.Fatal(err)
+	}
+
+	history, _ = gamestate.SystemData.Chara.Get(0).GetInt("BaseHistory")
+	if v := history.Sub(2).Get(0); v != 100 {
+		t.Errorf("multi-dimensional int value is not loaded, got: %v", v)
+	}
+	if !history.IsMultiDim() || history.Len() != 3 {
+		t.Errorf("shape is not restored, dims: %v", history.Dims())
+	}
+	memo, _ = gamestate.SystemData.GetStr("Memo")
+	if v := memo.Sub(0).Get(1); v != "memo" {
+		t.Errorf("multi-dimensional str value is not loaded, got: %v", v)
+	}
+}
+
+func TestFloatBoolVariablesSaveLoad(t *testing.T) {
+	gamestate := state.NewGameState(CSVDB, Repo)
+
+	chara, err := gamestate.SystemData.Chara.AddID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rate, ok := chara.GetFloat("BaseRate")
+	if !ok {
+		t.Fatal("csv has no float variable BaseRate, cant test this case")
+	}
+	rate.Set(0, 0.75)
+	difficulty, _ := gamestate.SystemData.GetFloat("Difficulty")
+	difficulty.Set(0, 2.5)
+	cleared, ok := gamestate.ShareData.GetBool("Cleared")
+	if !ok {
+		t.Fatal("csv has no bool variable Cleared, cant test this case")
+	}
+	cleared.Set(0, true)
+	if err := gamestate.SaveSystem(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := gamestate.SaveShare(); err != nil {
+		t.Fatal(err)
+	}
+
+	// modify after save, and then load it.
+	rate.Fill(0)
+	difficulty.Fill(0)
+	cleared.Fill(false)
+	if err := gamestate.LoadSystem(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := gamestate.LoadShare(); err != nil {
+		t.Fatal(err)
+	}
+
+	rate, _ = gamestate.SystemData.Chara.Get(0).GetFloat("BaseRate")
+	if v := rate.Get(0); v != 0.75 {
+		t.Errorf("float character value is not loaded, got: %v", v)
+	}
+	if v := rate.Get(1); v != 0.5 {
+		t.Errorf("float character value initialized by csv is not loaded, got: %v", v)
+	}
+	difficulty, _ = gamestate.SystemData.GetFloat("Difficulty")
+	if v := difficulty.Get(0); v != 2.5 {
+		t.Errorf("float system value is not loaded, got: %v", v)
+	}
+	cleared, _ = gamestate.ShareData.GetBool("Cleared")
+	if v := cleared.Get(0); !v {
+		t.Errorf("bool share value is not loaded, got: %v", v)
+	}
+}
@@ -16,6 +16,15 @@ import (
 type Config struct {
 	LoadDir     string
 	LoadPattern string
+	// PluginDir is a plugin directory relative to LoadDir.
+	// Empty disables plugin loading.
+	PluginDir string
+	// StoreDir is a directory to persist era.store data.
+	// Empty disables persistence of era.store.
+	StoreDir string
+	// StoreSizeLimit is a maximum byte size of era.store data.
+	// Zero or negative means no limit.
+	StoreSizeLimit int
 
 	CallStackSize       int
 	RegistrySize        int
@@ -28,6 +37,8 @@ type Config struct {
 var (
 	// default paramters for script VM.
 	LoadPattern               = "init.lua"
+	PluginDir                 = "plugins"
+	StoreSizeLimit            = 1 << 20 // 1MB
 	CallStackSize             = lua.CallStackSize
 	RegistrySize              = lua.RegistrySize
 	InfiniteLoopTimeoutSecond = 10 * time.Second
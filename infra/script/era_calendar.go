@@ -0,0 +1,148 @@
+package script
+
+import (
+	"fmt"
+
+	"github.com/mzki/erago/state"
+	lua "github.com/yuin/gopher-lua"
+)
+
+const eraCalendarModName = "calendar"
+
+func (ip *Interpreter) registerCalendarModule(L *lua.LState) {
+	calendarMod := L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"now":       ip.calendarNow,
+		"setTime":   ip.calendarSetTime,
+		"advance":   ip.calendarAdvance,
+		"eventName": ip.calendarEventName,
+		"timeSlots": ip.calendarTimeSlots,
+	})
+	L.SetMetatable(calendarMod, getStrictTableMetatable(L))
+	ip.eraModule.RawSetString(eraCalendarModName, calendarMod)
+}
+
+// +gendoc "Era Module"
+// * var era.calendar: calendar
+
+// +gendoc "Calendar Module"
+// * date: table = calendar.now()
+//
+// get the current date of the calendar defined by _Calendar.csv.
+// date is a table which has the fields, time, day, slot, slot_name, weekday,
+// weekday_name, season, season_name, year and day_of_season.
+// time is elapsed time slots and day is elapsed days from the start of the calendar.
+// All of numbers start from 0. weekday and season are always 0, and their names
+// are empty string, if those are not defined.
+// The calendar advances a time slot at every turnend scene.
+//
+// _Calendar.csv で定義されたカレンダーの現在の日時を取得します。
+// date は time, day, slot, slot_name, weekday, weekday_name, season, season_name,
+// year, day_of_season のフィールドを持つテーブルです。
+// time はカレンダー開始からの経過時間帯数、day は経過日数です。
+// 数値はすべて 0 から始まります。曜日や季節が定義されていない場合、
+// weekday と season は常に 0 で、その名前は空文字列です。
+// カレンダーは turnend シーンごとに1時間帯進みます。
+//
+// Example:
+//
+//	local date = era.calendar.now()
+//	era.printl(date.day + 1 .. "日目 " .. date.slot_name)
+func (ip *Interpreter) calendarNow(L *lua.LState) int {
+	c := ip.state.Calendar()
+	date := L.CreateTable(0, 10)
+	date.RawSetString("time", lua.LNumber(c.Time()))
+	date.RawSetString("day", lua.LNumber(c.Day()))
+	date.RawSetString("slot", lua.LNumber(c.Slot()))
+	date.RawSetString("slot_name", lua.LString(c.SlotName()))
+	date.RawSetString("weekday", lua.LNumber(c.Weekday()))
+	date.RawSetString("weekday_name", lua.LString(c.WeekdayName()))
+	date.RawSetString("season", lua.LNumber(c.Season()))
+	date.RawSetString("season_name", lua.LString(c.SeasonName()))
+	date.RawSetString("year", lua.LNumber(c.Year()))
+	date.RawSetString("day_of_season", lua.LNumber(c.DayOfSeason()))
+	L.Push(date)
+	return 1
+}
+
+// +gendoc "Calendar Module"
+// * calendar.setTime(time: integer)
+//
+// set elapsed time slots from the start of the calendar.
+// Scheduled events do not occur by this.
+//
+// カレンダー開始からの経過時間帯数を設定します。
+// これによって予定されたイベントは発生しません。
+func (ip *Interpreter) calendarSetTime(L *lua.LState) int {
+	t := L.CheckInt64(1)
+	raiseErrorIf(L, ip.state.Calendar().SetTime(t))
+	return 0
+}
+
+// +gendoc "Calendar Module"
+// * event_ids: integer[] = calendar.advance([n: integer])
+//
+// advance the calendar by n time slots, and return ids of the scheduled events
+// occurred while advancing. The default of n is 1, and n must be in [0, 65536].
+// Use calendar.setTime to move the time further without the events.
+// Unlike the turnend scene, callback turnend_event_calendar is not called,
+// so the caller should handle the returned events.
+//
+// カレンダーを n 時間帯進め、その間に発生した予定イベントのIDの配列を返します。
+// n の既定値は 1 で、n は [0, 65536] の範囲でなければなりません。
+// イベントを発生させずに時刻をさらに進めるには、calendar.setTime を使用してください。
+// turnend シーンとは異なり、コールバック turnend_event_calendar は呼ばれません。
+// 返されたイベントは呼び出し側で処理してください。
+//
+// Example:
+//
+//	for _, id in ipairs(era.calendar.advance(4)) do
+//	  era.printl(era.calendar.eventName(id))
+//	end
+func (ip *Interpreter) calendarAdvance(L *lua.LState) int {
+	n := L.OptInt64(1, 1)
+	if n < 0 || n > state.MaxCalendarAdvance {
+		L.ArgError(1, fmt.Sprintf("must be in [0, %d]", state.MaxCalendarAdvance))
+	}
+	events, err := ip.state.Calendar().Advance(n)
+	raiseErrorIf(L, err)
+	tbl := L.CreateTable(len(events), 0)
+	for _, ev := range events {
+		tbl.Append(lua.LNumber(ev))
+	}
+	L.Push(tbl)
+	return 1
+}
+
+// +gendoc "Calendar Module"
+// * name: string = calendar.eventName(event_id: integer)
+//
+// get name of the scheduled event by event_id, which is passed to
+// turnend_event_calendar or returned by calendar.advance().
+//
+// turnend_event_calendar に渡される、あるいは calendar.advance() が返す
+// event_id に対応する予定イベントの名前を取得します。
+func (ip *Interpreter) calendarEventName(L *lua.LState) int {
+	id := L.CheckInt(1)
+	events := ip.state.CSV.Calendar.Events
+	if id < 0 || id >= len(events) {
+		L.ArgError(1, "event id out of range")
+	}
+	L.Push(lua.LString(events[id].Name))
+	return 1
+}
+
+// +gendoc "Calendar Module"
+// * names: string[] = calendar.timeSlots()
+//
+// get names of the time slots in a day. Its length is the day length.
+//
+// 1日の時間帯の名前の配列を取得します。配列の長さが1日の時間帯数です。
+func (ip *Interpreter) calendarTimeSlots(L *lua.LState) int {
+	slots := ip.state.CSV.Calendar.TimeSlots
+	tbl := L.CreateTable(len(slots), 0)
+	for _, name := range slots {
+		tbl.Append(lua.LString(name))
+	}
+	L.Push(tbl)
+	return 1
+}
@@ -2,8 +2,12 @@ package script
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/mzki/erago/state"
+	"github.com/mzki/erago/util/log"
+	"github.com/mzki/erago/util/strutil"
 	lua "github.com/yuin/gopher-lua"
 )
 
@@ -15,15 +19,19 @@ import (
 // Characters stored in this list should be persisted on saving game state.
 // Modifiying this list is needed to use its method, addXXX, remove and clear.
 // User can get Character from this list by indexing,
-// Note that Character retrived from this list may be expried when loading other game state.
-// User need to treat Character retrived from the list as temporary.
+// Character retrived from this list refers the character by its uid, so that
+// it keeps pointing the same character after sorting, removing other characters
+// or loading other game state, and user can hold it across turns.
+// Accessing Character removed from the list raises error.
 //
 // CharaList はゲーム上で有効なキャラクターの配列です。（以降、キャラクター配列と呼びます）
 // キャラクター配列に格納されているキャラクターはゲームの状態を保存する際に、一緒に保存されます。
 // キャラクター配列の変更は、キャラクター配列自身のメソッド、関数呼び出しによって行います。
 // ユーザーはインデックスを使ってキャラクターを取得することができます。
-// キャラクター配列から取得したキャラクターは、他のゲームの状態をロードした際に、無効値になります。
-// キャラクター配列から取得したキャラクターは一時的なものであることに注意が必要です。
+// キャラクター配列から取得したキャラクターは uid によってキャラクターを参照するため、
+// 並べ替えや他のキャラクターの削除、他のゲームの状態のロードの後も同じキャラクターを指し、
+// ターンをまたいで保持することができます。
+// キャラクター配列から削除されたキャラクターにアクセスするとエラーになります。
 //
 // Example:
 //  local chara = CharaList[0]. -- index is started from 0.
@@ -32,15 +40,21 @@ import (
 // * var era.master: CharaRefList
 // CharaRefList is a array of reference for a Character.
 // It can be used as like array of Character with same length as a number CSV character definition. It is included in saving game state.
-// Note that Character retrived from this list may be expried when loading other game state or removing character from Character List.
-// User need to treat Character retrived from the list as temporary.
+// The reference is uid of the character, so that it keeps pointing the same character
+// after sorting, removing other characters or loading other game state.
+// The reference which is not set refers the first character in Character List,
+// as same as older versions. Indexing returns nil if Character List is empty,
+// and raises error if the referenced character is removed from Character List.
 //
 // CharaRefList はキャラクターへの参照の配列です。
 // CSV で定義したキャラクター数と同じ長さのCharacter の配列のように扱うことができます。ゲーム状態として保存されるデータに含まれます。
 // キャラクター配列の変更は、キャラクター配列自身のメソッド、関数呼び出しによって行います。
 // ユーザーはインデックスを使ってキャラクターを取得することができます。
-// キャラクター参照配列から取得したキャラクターは、他のゲームの状態をロードした際や、キャラクター配列で削除が行われた際に、無効値になります。
-// キャラクター参照配列から取得したキャラクターは一時的なものであることに注意が必要です。
+// 参照はキャラクターの uid であるため、並べ替えや他のキャラクターの削除、
+// 他のゲームの状態のロードの後も同じキャラクターを指します。
+// 参照が設定されていない場合は、以前のバージョンと同様にキャラクター配列の最初のキャラクターを指します。
+// キャラクター配列が空の場合は nil を返し、参照先のキャラクターが
+// キャラクター配列から削除されている場合はエラーになります。
 //
 // Example:
 //  local chara = CharaListRef[0]. -- index is started from 0.
@@ -57,17 +71,53 @@ import (
 // +gendoc
 // * var era.assi: CharaRefList
 // See era.master
+//
+// Besides era.target, era.master, era.player and era.assi, CharaRefList
+// declared in VariableSpec.csv with scope CharaRef is also available as
+// era.<lower-cased name>. Its length is Size in VariableSpec.csv, or a number of CSV character
+// definitions if Size is omitted. The builtin four can be redeclared to change their length.
+//
+// era.target, era.master, era.player, era.assi に加えて、VariableSpec.csv で
+// CharaRef スコープとして宣言された CharaRefList も era.<小文字にした名前> として利用できます。
+// その長さは VariableSpec.csv の Size で、省略した場合は CSV で定義したキャラクター数です。
+// 組み込みの4つも、長さを変更するために再宣言できます。
+//
+// Example:
+//
+//	-- VariableSpec.csv: CharaRef, , Rival, , 3
+//	era.rival[0] = era.chara[0]
+
+// +gendoc
+// * var era.collections: table<string, CharaCollection>
+// era.collections is a table of CharaCollection, named collection of characters
+// declared in VariableSpec.csv with scope CharaList, such as a party or a prison.
+// Unlike CharaList, CharaCollection does not own characters but refers characters
+// in era.chara by uid, so that a character can belong to multiple collections.
+// Collections are included in saving game state.
+//
+// era.collections は VariableSpec.csv で CharaList スコープとして宣言された、
+// パーティーや牢屋のような名前付きのキャラクターの集まり CharaCollection のテーブルです。
+// CharaList と異なり、CharaCollection はキャラクターを所有せず、era.chara 内の
+// キャラクターを uid で参照するため、1人のキャラクターが複数のコレクションに所属できます。
+// コレクションはゲーム状態として保存されるデータに含まれます。
+//
+// Example:
+//
+//	-- VariableSpec.csv: CharaList, , Party, , 4
+//	local party = era.collections.Party
+//	party:add(era.chara[0])
+//	for i, chara in pairs(party) do
+//	  era.printl(chara.name)
+//	end
 
 const (
-	luaCharaListName       = "chara"
-	luaCharaRefsMasterName = "master"
-	luaCharaRefsTargetName = "target"
-	luaCharaRefsPlayerName = "player"
-	luaCharaRefsAssiName   = "assi"
-
-	luaCharaListMetaName = "CharaList"
-	luaCharaRefsMetaName = "CharaRefList"
-	luaCharacterMetaName = "Chara"
+	luaCharaListName        = "chara"
+	luaCharaCollectionsName = "collections"
+
+	luaCharaListMetaName       = "CharaList"
+	luaCharaRefsMetaName       = "CharaRefList"
+	luaCharaCollectionMetaName = "CharaCollection"
+	luaCharacterMetaName       = "Chara"
 )
 
 // register Chara data, method, metatables.
@@ -105,15 +155,37 @@ func registerCharaParams(L *lua.LState, gamestate *state.GameState) {
 			"__metatable": metaProtectObj,
 		})
 
-		for key, lv := range map[string]lua.LValue{
-			luaCharaRefsAssiName:   newUserDataWithMt(L, gamestate.SystemData.Assi, chara_refs_meta),
-			luaCharaRefsMasterName: newUserDataWithMt(L, gamestate.SystemData.Master, chara_refs_meta),
-			luaCharaRefsTargetName: newUserDataWithMt(L, gamestate.SystemData.Target, chara_refs_meta),
-			luaCharaRefsPlayerName: newUserDataWithMt(L, gamestate.SystemData.Player, chara_refs_meta),
-		} {
-			era_module.RawSetString(key, lv)
+		// expose references declared in csv as era.<lower-cased name>.
+		for _, name := range gamestate.SystemData.ReferenceNames() {
+			key := strings.ToLower(name)
+			if lv := era_module.RawGetString(key); lv != lua.LNil {
+				if ud, ok := lv.(*lua.LUserData); !ok || !isCharaReferences(ud) {
+					log.Infof("CharaRef %s is not registered since era.%s is already used", name, key)
+					continue
+				}
+			}
+			cref, _ := gamestate.SystemData.Reference(name)
+			era_module.RawSetString(key, newUserDataWithMt(L, cref, chara_refs_meta))
 		}
 	}
+
+	{ // register chara collections
+		collection_meta := getOrNewMetatable(L, luaCharaCollectionMetaName, map[string]lua.LValue{
+			"__index":     L.NewFunction(getCharaCollectionFields),
+			"__len":       L.NewFunction(lenScalable),
+			"__next":      L.NewFunction(charaCollectionMetaNext),
+			"__ipairs":    metaPairs,
+			"__pairs":     metaPairs,
+			"__metatable": metaProtectObj,
+		})
+		collections := L.NewTable()
+		for name := range gamestate.SystemData.Collections {
+			cc := newLuaCharaCollection(L, gamestate.SystemData, name)
+			collections.RawSetString(name, newUserDataWithMt(L, cc, collection_meta))
+		}
+		L.SetMetatable(collections, getStrictTableMetatable(L))
+		era_module.RawSetString(luaCharaCollectionsName, collections)
+	}
 }
 
 // //  cahracter list or state.Characters
@@ -142,9 +214,11 @@ func newLuaCharaList(L *lua.LState, cs *state.Characters) luaCharaList {
 			"isaddable": L.NewFunction(charaListIsAddable),
 			"remove":    L.NewFunction(charaListRemove),
 			"clear":     L.NewFunction(charaListClear),
+			"query":     L.NewFunction(charaListQuery),
+			"count":     L.NewFunction(charaListCount),
+			"group":     L.NewFunction(charaListGroup),
+			"sort":      L.NewFunction(charaListSort),
 			// TODO: implement charas_methods_table["range"] = L.NewFunction(
-			// TODO: implement charas_methods_table["find"] = L.NewFunction(
-			// TODO: implement charas_methods_table["sort"] = L.NewFunction(
 		},
 	}
 }
@@ -173,14 +247,13 @@ func charaListAdd(L *lua.LState) int {
 	}
 
 	charas := checkLuaCharaList(L, 1)
-	chara_meta := L.GetTypeMetatable(luaCharacterMetaName)
 
 	if n_ids := nargs - 1; n_ids == 1 {
 		c, err := charas.AddID(L.CheckInt64(2))
 		if err != nil {
 			L.ArgError(2, err.Error())
 		}
-		L.Push(newUserDataWithMt(L, c, chara_meta))
+		L.Push(newLCharacter(L, charas.Characters, c))
 		return 1
 	} else {
 		// case: n_ids > 1
@@ -192,8 +265,7 @@ func charaListAdd(L *lua.LState) int {
 				L.ArgError(i, err.Error())
 			}
 
-			ud := newUserDataWithMt(L, c, chara_meta)
-			added_charas.Insert(i-1, ud)
+			added_charas.Insert(i-1, newLCharacter(L, charas.Characters, c))
 		}
 
 		L.Push(added_charas)
@@ -206,8 +278,7 @@ func charaListAdd(L *lua.LState) int {
 func charaListAddEmpty(L *lua.LState) int {
 	charas := checkLuaCharaList(L, 1)
 	empty_chara := charas.AddEmptyCharacter()
-	ud := newUserDataWithMt(L, empty_chara, L.GetTypeMetatable(luaCharacterMetaName))
-	L.Push(ud)
+	L.Push(newLCharacter(L, charas.Characters, empty_chara))
 	return 1
 }
 
@@ -240,6 +311,186 @@ func charaListClear(L *lua.LState) int {
 	return 0
 }
 
+// +gendoc "Characters"
+// * charas: Chara[] = CharaList:query(q: table)
+// It returns array of characters which satisfy all of conditions q.where, ordered by q.order_by.
+// The array is limited to q.limit length if specified.
+// q.where and q.order_by are a string or an array of strings, and can be omitted.
+//
+// The condition is a string "Key Op Value" or "Key". Key is a character field, such as id, uid and name,
+// or a character variable with its index "Var.Index", where Index is a CSV name or a number.
+// "Var" without Index means index 0. Op is one of ==, !=, <, <=, > and >=.
+// The condition "Key" means that Key is not 0 nor empty string.
+// The sort key is also Key, and "-" prefixed Key means descending order.
+// Since the query is done by the game engine, it is much faster than loop in the script.
+//
+// q.where の全ての条件を満たすキャラクターを、q.order_by の順に並べた配列を返します。
+// q.limit が指定された場合、配列の長さは q.limit 以下に制限されます。
+// q.where と q.order_by は文字列、または文字列の配列で、省略可能です。
+//
+// 条件は "Key Op Value" または "Key" の形式の文字列です。Key は id, uid, name のようなキャラクターのフィールドか、
+// "変数名.添字" 形式のキャラクター変数で、添字はCSVで定義された名前か数値です。
+// 添字を省略した場合、添字 0 を意味します。Op は ==, !=, <, <=, >, >= のいずれかです。
+// 条件 "Key" は Key が 0 でも空文字列でもないことを意味します。
+// 並べ替えのキーも Key で指定し、先頭に "-" を付けると降順になります。
+// 検索はゲームエンジンで行われるため、スクリプト内のループよりも高速です。
+//
+// Example:
+//
+//	local charas = era.chara:query{
+//	  where = {"Talent.処女", "Abl.従順 >= 3"},
+//	  order_by = {"-Abl.従順", "id"},
+//	  limit = 5,
+//	}
+func charaListQuery(L *lua.LState) int {
+	charas := checkLuaCharaList(L, 1)
+	q := L.CheckTable(2)
+	query := state.CharaQuery{
+		Where:   toStringList(L, q.RawGetString("where"), 2),
+		OrderBy: toStringList(L, q.RawGetString("order_by"), 2),
+	}
+	if limit, ok := q.RawGetString("limit").(lua.LNumber); ok {
+		query.Limit = int(limit)
+	}
+	selected, err := charas.Select(query)
+	if err != nil {
+		L.ArgError(2, err.Error())
+	}
+	tbl := L.CreateTable(len(selected), 0)
+	for _, c := range selected {
+		tbl.Append(newLCharacter(L, charas.Characters, c))
+	}
+	L.Push(tbl)
+	return 1
+}
+
+// +gendoc "Characters"
+// * n: integer = CharaList:count(...: string)
+// It returns number of characters which satisfy all of given conditions.
+// See CharaList:query() for the condition.
+//
+// 指定された全ての条件を満たすキャラクターの数を返します。
+// 条件については CharaList:query() を参照してください。
+//
+// Example:
+//
+//	local n = era.chara:count("Talent.処女", "Abl.従順 >= 3")
+func charaListCount(L *lua.LState) int {
+	charas := checkLuaCharaList(L, 1)
+	n, err := charas.Count(checkStringArgs(L, 2)...)
+	if err != nil {
+		L.ArgError(2, err.Error())
+	}
+	L.Push(lua.LNumber(n))
+	return 1
+}
+
+// +gendoc "Characters"
+// * groups: table[] = CharaList:group(key: string, ...: string)
+// It groups characters which satisfy all of given conditions by the value of key,
+// and returns array of the groups ordered by the value.
+// The group is a table which has the fields, value and charas.
+// See CharaList:query() for the key and the condition.
+//
+// 指定された全ての条件を満たすキャラクターを key の値によってグループ分けし、
+// 値の順に並べたグループの配列を返します。
+// グループは value と charas のフィールドを持つテーブルです。
+// key と条件については CharaList:query() を参照してください。
+//
+// Example:
+//
+//	for _, g in ipairs(era.chara:group("CFlag.部屋")) do
+//	  era.printl(g.value .. ": " .. #g.charas)
+//	end
+func charaListGroup(L *lua.LState) int {
+	charas := checkLuaCharaList(L, 1)
+	key := L.CheckString(2)
+	groups, err := charas.GroupBy(key, checkStringArgs(L, 3)...)
+	if err != nil {
+		L.ArgError(2, err.Error())
+	}
+	L.Push(newCharaGroupsTable(L, charas.Characters, groups))
+	return 1
+}
+
+// newCharaGroupsTable returns array of the groups as lua table.
+func newCharaGroupsTable(L *lua.LState, cs *state.Characters, groups []state.CharaGroup) *lua.LTable {
+	tbl := L.CreateTable(len(groups), 0)
+	for _, g := range groups {
+		group := L.CreateTable(0, 2)
+		switch v := g.Value.(type) {
+		case int64:
+			group.RawSetString("value", lua.LNumber(v))
+		case string:
+			group.RawSetString("value", lua.LString(v))
+		}
+		members := L.CreateTable(len(g.Charas), 0)
+		for _, c := range g.Charas {
+			members.Append(newLCharacter(L, cs, c))
+		}
+		group.RawSetString("charas", members)
+		tbl.Append(group)
+	}
+	return tbl
+}
+
+// +gendoc "Characters"
+// * CharaList:sort(...: string)
+// It sorts characters in the list by given sort keys. The sort is stable.
+// Characters and character references retrieved before keep pointing same characters.
+// See CharaList:query() for the sort key.
+//
+// 指定されたキーによってキャラクター配列を並べ替えます。並べ替えは安定です。
+// 事前に取得したキャラクターやキャラクター参照は、同じキャラクターを指し続けます。
+// キーについては CharaList:query() を参照してください。
+//
+// Example:
+//
+//	era.chara:sort("-Abl.従順", "id")
+func charaListSort(L *lua.LState) int {
+	charas := checkLuaCharaList(L, 1)
+	keys := checkStringArgs(L, 2)
+	if len(keys) == 0 {
+		L.ArgError(2, "require some sort keys")
+	}
+	if err := charas.SortByKeys(keys...); err != nil {
+		L.ArgError(2, err.Error())
+	}
+	return 0
+}
+
+// checkStringArgs returns string arguments from pos to the last.
+func checkStringArgs(L *lua.LState, pos int) []string {
+	args := make([]string, 0, L.GetTop())
+	for i := pos; i <= L.GetTop(); i++ {
+		args = append(args, L.CheckString(i))
+	}
+	return args
+}
+
+// toStringList converts lv, which is nil, a string or an array of strings, into []string.
+// It raises argument error at pos if lv is other type.
+func toStringList(L *lua.LState, lv lua.LValue, pos int) []string {
+	switch lv := lv.(type) {
+	case *lua.LNilType:
+		return nil
+	case lua.LString:
+		return []string{string(lv)}
+	case *lua.LTable:
+		list := make([]string, 0, lv.Len())
+		for i := 1; i <= lv.Len(); i++ {
+			s, ok := lv.RawGetInt(i).(lua.LString)
+			if !ok {
+				L.ArgError(pos, "require array of strings")
+			}
+			list = append(list, string(s))
+		}
+		return list
+	}
+	L.ArgError(pos, "require string or array of strings")
+	return nil
+}
+
 // Meta method, used internal and not documented
 // // +gendoc "Characters"
 // // * next_index, next_chara = CharaList:next([index])
@@ -251,9 +502,8 @@ func charaListMetaNext(L *lua.LState) int {
 	if theChara == nil {
 		return 0
 	}
-	ud := newUserDataWithMt(L, theChara, L.GetTypeMetatable(luaCharacterMetaName))
 	L.Push(lua.LNumber(nextIdx))
-	L.Push(ud)
+	L.Push(newLCharacter(L, charas.Characters, theChara))
 	return 2
 }
 
@@ -277,8 +527,7 @@ func getCharaListFields(L *lua.LState) int {
 		if chara == nil {
 			L.ArgError(2, indexOutMessage)
 		}
-		ud := newUserDataWithMt(L, chara, L.GetTypeMetatable(luaCharacterMetaName))
-		L.Push(ud)
+		L.Push(newLCharacter(L, charas.Characters, chara))
 		return 1
 
 	default:
@@ -307,6 +556,11 @@ func checkCharaRefereces(L *lua.LState, pos int) *state.CharaReferences {
 	return nil
 }
 
+func isCharaReferences(ud *lua.LUserData) bool {
+	_, ok := ud.Value.(*state.CharaReferences)
+	return ok
+}
+
 func getSetCharaReferences(L *lua.LState) int {
 	refs := checkCharaRefereces(L, 1)
 	index := L.CheckInt(2)
@@ -319,12 +573,19 @@ func getSetCharaReferences(L *lua.LState) int {
 		return 0
 	}
 
-	c := refs.GetChara(index)
-	if c == nil {
+	if index < 0 || index >= refs.Len() {
 		L.ArgError(2, indexOutMessage)
 	}
-	ud := newUserDataWithMt(L, c, L.GetTypeMetatable(luaCharacterMetaName))
-	L.Push(ud)
+	h, ok := refs.GetHandle(index)
+	if !ok {
+		// no reference
+		L.Push(lua.LNil)
+		return 1
+	}
+	if _, err := h.Get(); err != nil {
+		L.ArgError(2, fmt.Sprintf("referenced character at %d: %v", index, err))
+	}
+	L.Push(newUserDataWithMt(L, h, L.GetTypeMetatable(luaCharacterMetaName)))
 	return 1
 }
 
@@ -332,17 +593,266 @@ func getSetCharaReferences(L *lua.LState) int {
 func charaRefsMetaNext(L *lua.LState) int {
 	refs := checkCharaRefereces(L, 1)
 	idx := L.OptInt(2, -1)
-	nextIdx := idx + 1
-	theChara := refs.GetChara(nextIdx)
+	// skip no reference and removed character as like nil in table.
+	for nextIdx := idx + 1; nextIdx < refs.Len(); nextIdx++ {
+		if refs.GetChara(nextIdx) == nil {
+			continue
+		}
+		h, _ := refs.GetHandle(nextIdx)
+		L.Push(lua.LNumber(nextIdx))
+		L.Push(newUserDataWithMt(L, h, L.GetTypeMetatable(luaCharacterMetaName)))
+		return 2
+	}
+	return 0
+}
+
+// // named collections of character: era.collections.XXX
+
+// +gendoc.set_section "Character Collections"
+
+// +gendoc
+// * chara: Chara = CharaCollection:__index(i: integer)
+
+// luaCharaCollection refers the collection by name, since the collection
+// in SystemData may be replaced by loading the game.
+type luaCharaCollection struct {
+	sysdata *state.SystemData
+	name    string
+	methods map[string]*lua.LFunction
+}
+
+func newLuaCharaCollection(L *lua.LState, sysdata *state.SystemData, name string) luaCharaCollection {
+	return luaCharaCollection{
+		sysdata: sysdata,
+		name:    name,
+		methods: map[string]*lua.LFunction{
+			"len":     L.NewFunction(lenScalable),
+			"add":     L.NewFunction(charaCollectionAdd),
+			"remove":  L.NewFunction(charaCollectionRemove),
+			"has":     L.NewFunction(charaCollectionHas),
+			"indexof": L.NewFunction(charaCollectionIndexOf),
+			"clear":   L.NewFunction(charaCollectionClear),
+			"query":   L.NewFunction(charaCollectionQuery),
+			"count":   L.NewFunction(charaCollectionCount),
+			"group":   L.NewFunction(charaCollectionGroup),
+			"sort":    L.NewFunction(charaCollectionSort),
+		},
+	}
+}
+
+// get returns current collection.
+func (lcc luaCharaCollection) get() *state.CharaCollection {
+	cc, ok := lcc.sysdata.Collection(lcc.name)
+	if !ok {
+		panic("CharaCollection " + lcc.name + " is not found")
+	}
+	return cc
+}
+
+// Len implements scalableValues interface.
+func (lcc luaCharaCollection) Len() int { return lcc.get().Len() }
+
+func checkCharaCollection(L *lua.LState, pos int) (*state.CharaCollection, luaCharaCollection) {
+	ud := L.CheckUserData(pos)
+	if lcc, ok := ud.Value.(luaCharaCollection); ok {
+		return lcc.get(), lcc
+	}
+	L.ArgError(pos, "require CharaCollection")
+	return nil, luaCharaCollection{}
+}
+
+// +gendoc "Character Collections"
+// * CharaCollection:add(chara: Chara, ...: Chara)
+// It adds given characters into the end of the collection. The character already
+// in the collection is ignored. It raises error if the collection is full, that is
+// the number of characters reaches Size defined in VariableSpec.csv.
+//
+// 指定されたキャラクターをコレクションの末尾に追加します。既にコレクション内にいる
+// キャラクターは無視されます。コレクションが満杯、つまりキャラクターの数が
+// VariableSpec.csv で定義された Size に達している場合はエラーになります。
+func charaCollectionAdd(L *lua.LState) int {
+	cc, _ := checkCharaCollection(L, 1)
+	nargs := L.GetTop()
+	if nargs < 2 {
+		L.ArgError(2, "require some Character")
+	}
+	for i := 2; i <= nargs; i++ {
+		if err := cc.Add(checkCharacter(L, i)); err != nil {
+			L.ArgError(i, err.Error())
+		}
+	}
+	return 0
+}
+
+// +gendoc "Character Collections"
+// * removed: boolean = CharaCollection:remove(index_or_chara: integer|Chara)
+// It removes the character at index, or the given character from the collection,
+// and returns whether the character is removed.
+// The character itself remains in era.chara.
+//
+// 指定されたインデックスのキャラクター、または指定されたキャラクターをコレクションから取り除き、
+// 取り除かれたかどうかを返します。キャラクター自身は era.chara に残ります。
+func charaCollectionRemove(L *lua.LState) int {
+	cc, _ := checkCharaCollection(L, 1)
+	var removed bool
+	if idx, ok := L.Get(2).(lua.LNumber); ok {
+		removed = cc.Remove(int(idx))
+	} else {
+		removed = cc.RemoveChara(checkCharacter(L, 2))
+	}
+	L.Push(lua.LBool(removed))
+	return 1
+}
+
+// +gendoc "Character Collections"
+// * has: boolean = CharaCollection:has(chara: Chara)
+// It returns whether the character is in the collection.
+//
+// キャラクターがコレクション内にいるかどうかを返します。
+func charaCollectionHas(L *lua.LState) int {
+	cc, _ := checkCharaCollection(L, 1)
+	L.Push(lua.LBool(cc.Has(checkCharacter(L, 2))))
+	return 1
+}
+
+// +gendoc "Character Collections"
+// * index: integer = CharaCollection:indexof(chara: Chara)
+// It returns index of the character in the collection, or -1 if not found.
+//
+// コレクション内のキャラクターのインデックスを返します。見つからない場合は -1 を返します。
+func charaCollectionIndexOf(L *lua.LState) int {
+	cc, _ := checkCharaCollection(L, 1)
+	L.Push(lua.LNumber(cc.IndexOf(checkCharacter(L, 2))))
+	return 1
+}
+
+// +gendoc "Character Collections"
+// * CharaCollection:clear()
+// It removes all characters from the collection. The characters themselves remain in era.chara.
+//
+// コレクションから全てのキャラクターを取り除きます。キャラクター自身は era.chara に残ります。
+func charaCollectionClear(L *lua.LState) int {
+	cc, _ := checkCharaCollection(L, 1)
+	cc.Clear()
+	return 0
+}
+
+// +gendoc "Character Collections"
+// * charas: Chara[] = CharaCollection:query(q: table)
+// Same as CharaList:query() but for characters in the collection.
+//
+// CharaList:query() と同様ですが、コレクション内のキャラクターを対象とします。
+func charaCollectionQuery(L *lua.LState) int {
+	cc, lcc := checkCharaCollection(L, 1)
+	q := L.CheckTable(2)
+	query := state.CharaQuery{
+		Where:   toStringList(L, q.RawGetString("where"), 2),
+		OrderBy: toStringList(L, q.RawGetString("order_by"), 2),
+	}
+	if limit, ok := q.RawGetString("limit").(lua.LNumber); ok {
+		query.Limit = int(limit)
+	}
+	selected, err := cc.Select(query)
+	if err != nil {
+		L.ArgError(2, err.Error())
+	}
+	tbl := L.CreateTable(len(selected), 0)
+	for _, c := range selected {
+		tbl.Append(newLCharacter(L, lcc.sysdata.Chara, c))
+	}
+	L.Push(tbl)
+	return 1
+}
+
+// +gendoc "Character Collections"
+// * n: integer = CharaCollection:count(...: string)
+// Same as CharaList:count() but for characters in the collection.
+//
+// CharaList:count() と同様ですが、コレクション内のキャラクターを対象とします。
+func charaCollectionCount(L *lua.LState) int {
+	cc, _ := checkCharaCollection(L, 1)
+	n, err := cc.Count(checkStringArgs(L, 2)...)
+	if err != nil {
+		L.ArgError(2, err.Error())
+	}
+	L.Push(lua.LNumber(n))
+	return 1
+}
+
+// +gendoc "Character Collections"
+// * groups: table[] = CharaCollection:group(key: string, ...: string)
+// Same as CharaList:group() but for characters in the collection.
+//
+// CharaList:group() と同様ですが、コレクション内のキャラクターを対象とします。
+func charaCollectionGroup(L *lua.LState) int {
+	cc, lcc := checkCharaCollection(L, 1)
+	key := L.CheckString(2)
+	groups, err := cc.GroupBy(key, checkStringArgs(L, 3)...)
+	if err != nil {
+		L.ArgError(2, err.Error())
+	}
+	L.Push(newCharaGroupsTable(L, lcc.sysdata.Chara, groups))
+	return 1
+}
+
+// +gendoc "Character Collections"
+// * CharaCollection:sort(...: string)
+// It sorts characters in the collection by given sort keys. era.chara is not changed.
+// See CharaList:query() for the sort key.
+//
+// 指定されたキーによってコレクション内のキャラクターを並べ替えます。era.chara は変化しません。
+// キーについては CharaList:query() を参照してください。
+func charaCollectionSort(L *lua.LState) int {
+	cc, _ := checkCharaCollection(L, 1)
+	keys := checkStringArgs(L, 2)
+	if len(keys) == 0 {
+		L.ArgError(2, "require some sort keys")
+	}
+	if err := cc.SortByKeys(keys...); err != nil {
+		L.ArgError(2, err.Error())
+	}
+	return 0
+}
+
+// Meta method, used internal and not documented
+func charaCollectionMetaNext(L *lua.LState) int {
+	cc, lcc := checkCharaCollection(L, 1)
+	nextIdx := L.OptInt(2, -1) + 1
+	theChara := cc.Get(nextIdx)
 	if theChara == nil {
 		return 0
 	}
-	ud := newUserDataWithMt(L, theChara, L.GetTypeMetatable(luaCharacterMetaName))
 	L.Push(lua.LNumber(nextIdx))
-	L.Push(ud)
+	L.Push(newLCharacter(L, lcc.sysdata.Chara, theChara))
 	return 2
 }
 
+// get character in the collection or method for the collection.
+func getCharaCollectionFields(L *lua.LState) int {
+	cc, lcc := checkCharaCollection(L, 1)
+	switch key := L.CheckAny(2).(type) {
+	case lua.LString:
+		fn, ok := lcc.methods[key.String()]
+		if !ok {
+			L.ArgError(2, fmt.Sprintf("method %s is not found", key))
+		}
+		L.Push(fn)
+		return 1
+
+	case lua.LNumber:
+		chara := cc.Get(int(key))
+		if chara == nil {
+			L.ArgError(2, indexOutMessage)
+		}
+		L.Push(newLCharacter(L, lcc.sysdata.Chara, chara))
+		return 1
+
+	default:
+		L.ArgError(2, fmt.Sprintf("invalid key %s", key))
+	}
+	return 0
+}
+
 // // lua character
 
 func registerCharaMeta(L *lua.LState) {
@@ -353,10 +863,22 @@ func registerCharaMeta(L *lua.LState) {
 	})
 }
 
+// newLCharacter returns lua value of the character c in the list cs.
+// The value holds state.CharaHandle rather than *state.Character, so that
+// it keeps pointing c across sorting, removing other characters and reloading.
+func newLCharacter(L *lua.LState, cs *state.Characters, c *state.Character) *lua.LUserData {
+	return newUserDataWithMt(L, cs.Handle(c), L.GetTypeMetatable(luaCharacterMetaName))
+}
+
 // check position of L is character?
+// It raises error if the character is removed from the character list.
 func checkCharacter(L *lua.LState, pos int) *state.Character {
 	ud := L.CheckUserData(pos)
-	if chara, ok := ud.Value.(*state.Character); ok {
+	if h, ok := ud.Value.(state.CharaHandle); ok {
+		chara, err := h.Get()
+		if err != nil {
+			L.ArgError(pos, err.Error())
+		}
 		return chara
 	}
 	L.ArgError(pos, "require character")
@@ -414,7 +936,7 @@ func checkCharacter(L *lua.LState, pos int) *state.Character {
 // 呼び名を保持する。読み書き可能な変数である。
 
 // +gendoc
-// * param: (IntParam|StrParam) = Chara:__index(key: string)
+// * param: (IntParam|StrParam|FloatParam|BoolParam) = Chara:__index(key: string)
 
 const (
 	// read only
@@ -469,17 +991,57 @@ func getCharaFields(L *lua.LState) int {
 	// user defined values
 	default:
 		if iparam, ok := c.GetInt(key); ok {
-			L.Push(newLIntParam(L, iparam))
+			L.Push(intParamKind.newLParam(L, iparam))
 			return 1
 		} else if sparam, ok := c.GetStr(key); ok {
-			L.Push(newLStrParam(L, sparam))
+			L.Push(strParamKind.newLParam(L, sparam))
+			return 1
+		} else if fparam, ok := c.GetFloat(key); ok {
+			L.Push(floatParamKind.newLParam(L, fparam))
+			return 1
+		} else if bparam, ok := c.GetBool(key); ok {
+			L.Push(boolParamKind.newLParam(L, bparam))
 			return 1
 		}
-		L.ArgError(2, "unknown character field: "+key)
+		L.ArgError(2, charaFieldNotFoundMessage(c, key))
 	}
 	return 0
 }
 
+// charaFieldNotFoundMessage returns error message for unknown character field,
+// with the suggestion of similar field name if exist.
+func charaFieldNotFoundMessage(c *state.Character, key string) string {
+	fields := make([]string, 0, len(c.IntMap)+len(c.StrMap)+len(c.FloatMap)+len(c.BoolMap))
+	for k := range c.IntMap {
+		fields = append(fields, k)
+	}
+	for k := range c.StrMap {
+		fields = append(fields, k)
+	}
+	for k := range c.FloatMap {
+		fields = append(fields, k)
+	}
+	for k := range c.BoolMap {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields) // to suggest stably.
+	fields = append([]string{
+		characterFieldIDName,
+		characterFieldUIDName,
+		characterFieldIsAssiName,
+		characterFieldNameName,
+		characterFieldMasterNameName,
+		characterFieldNickNameName,
+		characterFieldCallNameName,
+	}, fields...)
+
+	msg := "unknown character field: " + key
+	if name, ok := strutil.Nearest(key, fields, strutil.SuggestDist(key)); ok {
+		msg += fmt.Sprintf(", did you mean %q?", name)
+	}
+	return msg
+}
+
 // case __newindex
 // TODO: replace map[string]func
 func setCharaFields(L *lua.LState) int {
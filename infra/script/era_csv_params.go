@@ -77,9 +77,9 @@ func registerCsvParams(L *lua.LState, CSV *csv.CsvManager) {
 		}
 
 		// register builtin constants, csv item price
-		int_param := state.NewIntParam(CSV.ItemPrices, CSV.Item.NameIndex)
+		int_param := state.NewIntParam(CSV.ItemPrices, CSV.Item)
 		meta := newMetatable(L, csvItemPriceMetaName, map[string]lua.LValue{
-			"__index":     L.NewFunction(intParamMetaIndex),
+			"__index":     L.NewFunction(intParamKind.metaIndex),
 			"__len":       LLenFunction,
 			"__next":      LNextIntFunction,
 			"__ipairs":    LPairsFunction,
@@ -103,13 +103,13 @@ func registerCsvParams(L *lua.LState, CSV *csv.CsvManager) {
 
 		// register csv index deifined by user.
 		for key, c := range CSV.Constants() {
-			ud := newUserDataWithMt(L, c.NameIndex, csv_index_meta)
+			ud := newUserDataWithMt(L, c, csv_index_meta)
 			csv_index_module.RawSetString(key, ud)
 		}
 
 		// register builtin csv index.
-		for key, nidx := range map[string]csv.NameIndex{
-			csvBuiltinItemName: CSV.Item.NameIndex,
+		for key, nidx := range map[string]csv.Constant{
+			csvBuiltinItemName: CSV.Item,
 		} {
 			ud := newUserDataWithMt(L, nidx, csv_index_meta)
 			csv_index_module.RawSetString(key, ud)
@@ -197,13 +197,13 @@ func csvNamesMetaIndex(L *lua.LState) int {
 //
 //  era.csvindex.Base["MP"] --> 1
 
-func checkCsvIndex(L *lua.LState, pos int) csv.NameIndex {
+func checkCsvIndex(L *lua.LState, pos int) csv.Constant {
 	ud := L.CheckUserData(pos)
-	if nidx, ok := ud.Value.(csv.NameIndex); ok {
+	if nidx, ok := ud.Value.(csv.Constant); ok {
 		return nidx
 	}
 	L.ArgError(pos, "require csvindex.* object")
-	return nil
+	return csv.Constant{}
 }
 
 func csvIndexMetaIndex(L *lua.LState) int {
@@ -212,7 +212,7 @@ func csvIndexMetaIndex(L *lua.LState) int {
 
 	index := nidx.GetIndex(key)
 	if index < 0 {
-		L.ArgError(2, key+" is not found")
+		L.ArgError(2, keyNotFoundMessage(key, nidx))
 	}
 	L.Push(lua.LNumber(index))
 	return 1
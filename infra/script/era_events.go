@@ -0,0 +1,198 @@
+package script
+
+import (
+	lua "github.com/yuin/gopher-lua"
+)
+
+const eraEventsModName = "events"
+
+// eventHandler is a script function subscribing an event.
+type eventHandler struct {
+	id       int
+	priority int
+	fn       *lua.LFunction
+}
+
+// eventBus holds event handlers for each event name.
+// Handlers are ordered by priority descending and registered order for same priority.
+type eventBus struct {
+	handlers map[string][]eventHandler
+	lastID   int
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{
+		handlers: make(map[string][]eventHandler, 16),
+	}
+}
+
+// subscribe adds handler fn to the event name and returns its handler id.
+func (bus *eventBus) subscribe(name string, fn *lua.LFunction, priority int) int {
+	bus.lastID += 1
+	h := eventHandler{id: bus.lastID, priority: priority, fn: fn}
+
+	hs := bus.handlers[name]
+	pos := len(hs)
+	for i, other := range hs {
+		if other.priority < priority {
+			pos = i
+			break
+		}
+	}
+	hs = append(hs, eventHandler{})
+	copy(hs[pos+1:], hs[pos:])
+	hs[pos] = h
+	bus.handlers[name] = hs
+	return h.id
+}
+
+// unsubscribe removes handler by the id. It returns whether the handler is removed.
+func (bus *eventBus) unsubscribe(name string, id int) bool {
+	hs := bus.handlers[name]
+	for i, h := range hs {
+		if h.id == id {
+			bus.handlers[name] = append(hs[:i:i], hs[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// handlersOf returns copy of handlers for the event name, so that
+// subscribing or unsubscribing in the handler does not affect current emission.
+func (bus *eventBus) handlersOf(name string) []eventHandler {
+	hs := bus.handlers[name]
+	ret := make([]eventHandler, len(hs))
+	copy(ret, hs)
+	return ret
+}
+
+// clear removes handlers for the event name. Empty name removes all handlers.
+func (bus *eventBus) clear(name string) {
+	if len(name) == 0 {
+		bus.handlers = make(map[string][]eventHandler, 16)
+		return
+	}
+	delete(bus.handlers, name)
+}
+
+func (ip *Interpreter) registerEventsModule(L *lua.LState) {
+	eventsMod := L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"on":    ip.eventsOn,
+		"off":   ip.eventsOff,
+		"emit":  ip.eventsEmit,
+		"clear": ip.eventsClear,
+	})
+	L.SetMetatable(eventsMod, getStrictTableMetatable(L))
+	ip.eraModule.RawSetString(eraEventsModName, eventsMod)
+}
+
+// +gendoc "Era Module"
+// * var era.events: events
+
+// +gendoc "Events Module"
+// * handler_id: integer = events.on(event_name: string, handler: function, [priority: integer])
+//
+// subscribe handler to the event_name, and return id of the handler.
+// Several handlers can subscribe to same event, and these are called in
+// descending order of priority. Handlers having same priority are called in subscribed order.
+// The default priority is 0.
+// Builtin scenes emit events which has the same name as the event callback,
+// such as "turnend_event_start", with the same arguments.
+// The event is emitted before calling the callback.
+// For [scene]_event_start, the event is emitted even if [scene]_scene is defined.
+//
+// イベント event_name にハンドラを登録し、そのハンドラのIDを返します。
+// 同じイベントに複数のハンドラを登録することができ、priority の大きい順に呼び出されます。
+// priority が同じ場合は登録した順に呼び出されます。priority の既定値は 0 です。
+// 組み込みシーンは、"turnend_event_start" のようにイベントコールバックと同名のイベントを、
+// コールバックと同じ引数で発行します。イベントはコールバックを呼び出す前に発行されます。
+// [scene]_event_start については、[scene]_scene が定義されている場合でも発行されます。
+//
+// Example:
+//
+//	era.events.on("turnend_event_start", function() era.printl "turn end" end, 10)
+func (ip *Interpreter) eventsOn(L *lua.LState) int {
+	name := L.CheckString(1)
+	fn := L.CheckFunction(2)
+	priority := L.OptInt(3, 0)
+	id := ip.events.subscribe(name, fn, priority)
+	L.Push(lua.LNumber(id))
+	return 1
+}
+
+// +gendoc "Events Module"
+// * removed: boolean = events.off(event_name: string, handler_id: integer)
+//
+// unsubscribe the handler specified by handler_id from the event_name.
+// It returns true if the handler is removed, otherwise false.
+//
+// event_name から handler_id で指定したハンドラの登録を解除します。
+// 解除された場合は true を、そうでなければ false を返します。
+func (ip *Interpreter) eventsOff(L *lua.LState) int {
+	name := L.CheckString(1)
+	id := L.CheckInt(2)
+	L.Push(lua.LBool(ip.events.unsubscribe(name, id)))
+	return 1
+}
+
+// +gendoc "Events Module"
+// * n_called: integer = events.emit(event_name: string, ...)
+//
+// emit the event_name with arguments to the subscribed handlers, and return
+// number of called handlers. Error in a handler stops calling rest of handlers.
+//
+// event_name のイベントを引数とともに発行し、呼び出されたハンドラの数を返します。
+// ハンドラ内でエラーが起きた場合、残りのハンドラは呼び出されません。
+func (ip *Interpreter) eventsEmit(L *lua.LState) int {
+	name := L.CheckString(1)
+	args := make([]lua.LValue, 0, L.GetTop()-1)
+	for i := 2; i <= L.GetTop(); i++ {
+		args = append(args, L.Get(i))
+	}
+	handlers := ip.events.handlersOf(name)
+	for _, h := range handlers {
+		L.Push(h.fn)
+		for _, arg := range args {
+			L.Push(arg)
+		}
+		L.Call(len(args), 0)
+	}
+	L.Push(lua.LNumber(len(handlers)))
+	return 1
+}
+
+// +gendoc "Events Module"
+// * events.clear([event_name: string])
+//
+// unsubscribe all handlers from event_name. If event_name is omitted,
+// all handlers for all events are unsubscribed.
+//
+// event_name に登録された全てのハンドラを解除します。
+// event_name を省略した場合は、全てのイベントのハンドラを解除します。
+func (ip *Interpreter) eventsClear(L *lua.LState) int {
+	name := L.OptString(1, "")
+	ip.events.clear(name)
+	return 0
+}
+
+// EraEmit emits the event to the handlers subscribing the event name,
+// with given arguments. It returns error of the first failed handler.
+// It implements scene.Scripter interface.
+func (ip Interpreter) EraEmit(name string, args ...int64) error {
+	handlers := ip.events.handlersOf(name)
+	if len(handlers) == 0 {
+		return nil
+	}
+	largs := make([]lua.LValue, 0, len(args))
+	for _, arg := range args {
+		largs = append(largs, lua.LNumber(arg))
+	}
+	for _, h := range handlers {
+		err := ip.callByParam(h.fn, 0, largs...)
+		if err = ip.checkSpecialError(err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package script
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	lua "github.com/yuin/gopher-lua"
@@ -40,12 +41,15 @@ func (ip *Interpreter) registerEraModule(L *lua.LState, gamestate *state.GameSta
 	eraModFuncMap := map[string]lua.LGFunction{
 		// TODO: move to module
 		// state
-		"clearSystem": ft.clearSystem,
-		"saveSystem":  ft.saveSystem,
-		"loadSystem":  ft.loadSystem,
-		"clearShare":  ft.clearShare,
-		"saveShare":   ft.saveShare,
-		"loadShare":   ft.loadShare,
+		"clearSystem":    ft.clearSystem,
+		"saveSystem":     ft.saveSystem,
+		"loadSystem":     ft.loadSystem,
+		"clearShare":     ft.clearShare,
+		"saveShare":      ft.saveShare,
+		"loadShare":      ft.loadShare,
+		"snapshot":       ft.snapshot,
+		"rewind":         ft.rewind,
+		"snapshotLabels": ft.snapshotLabels,
 		// util
 		"paramlv":         ft.paramLv,
 		"explv":           ft.expLv,
@@ -129,6 +133,12 @@ func (ip *Interpreter) registerEraModule(L *lua.LState, gamestate *state.GameSta
 		"getNextScene":    ft.getNextScene,
 		"getCurrentScene": ft.getCurrentScene,
 		"gotoNextScene":   ft.gotoNextScene,
+		"callScene":       ft.callScene,
+		"returnScene":     ft.returnScene,
+		"getSceneStack":   ft.getSceneStack,
+		"history":         ft.history,
+		"historyGraph":    ft.historyGraph,
+		"registerScene":   ip.registerScene,
 		"saveScene":       ft.saveScene,
 		"loadScene":       ft.loadScene,
 		"doTrains":        ft.doTrains,
@@ -233,6 +243,79 @@ func (ft functor) loadSystem(L *lua.LState) int {
 	return 0
 }
 
+// +gendoc "Era Module"
+// * era.snapshot([label: string])
+//
+// it takes a snapshot of system data, values under era.system, in memory
+// with label. The default label is "script".
+// At most 32 snapshots are kept, and the oldest one is removed if exceeded.
+// The snapshot is not taken if system data is not changed since the latest snapshot.
+// Snapshots are also taken at every scene transition if it is enabled by the config.
+//
+// 現在のゲームデータのスナップショットを label とともにメモリ上に保存します。
+// label の既定値は "script" です。スナップショットは最大32個まで保持され、
+// 超えた場合は古いものから削除されます。前回のスナップショットから
+// ゲームデータが変化していない場合は保存されません。
+// 設定によって、シーンの遷移ごとにもスナップショットが保存されます。
+func (ft functor) snapshot(L *lua.LState) int {
+	label := L.OptString(1, "script")
+	raiseErrorIf(L, ft.state.TakeSnapshot(label))
+	return 0
+}
+
+// +gendoc "Era Module"
+// * label: string|nil = era.rewind([n: integer])
+//
+// it restores system data from n-th latest snapshot and returns its label.
+// The default of n is 1, the latest snapshot. The snapshots newer than the
+// restored one are removed. It returns nil and does nothing if the number of
+// snapshots is less than n.
+// Like era.loadSystem, the scene flow is not changed by this.
+//
+// n 個前のスナップショットからゲームデータを復元し、そのラベルを返します。
+// n の既定値は 1 で、最新のスナップショットを意味します。復元したものより
+// 新しいスナップショットは削除されます。スナップショットが n 個に満たない場合は、
+// 何もせず nil を返します。era.loadSystem と同様に、シーンの流れは変化しません。
+//
+// Example:
+//
+//	era.snapshot("before_choice")
+//	-- ... bad choice ...
+//	era.rewind()
+func (ft functor) rewind(L *lua.LState) int {
+	n := L.OptInt(1, 1)
+	if n < 1 {
+		L.ArgError(1, "must be positive")
+	}
+	if n > len(ft.state.SnapshotLabels()) {
+		L.Push(lua.LNil)
+		return 1
+	}
+	label, err := ft.state.Rewind(n)
+	raiseErrorIf(L, err)
+	// re-register restored values, which may be changed its structure
+	registerSystemParams(L, ft.state)
+	registerCharaParams(L, ft.state)
+	L.Push(lua.LString(label))
+	return 1
+}
+
+// +gendoc "Era Module"
+// * labels: string[] = era.snapshotLabels()
+//
+// it returns labels of the snapshots ordered from oldest to newest.
+//
+// スナップショットのラベルの配列を古い順に返します。
+func (ft functor) snapshotLabels(L *lua.LState) int {
+	labels := ft.state.SnapshotLabels()
+	tbl := L.CreateTable(len(labels), 0)
+	for _, label := range labels {
+		tbl.Append(lua.LString(label))
+	}
+	L.Push(tbl)
+	return 1
+}
+
 // +gendoc "Era Module"
 // * era.clearShare()
 //
@@ -1141,6 +1224,184 @@ func (ft functor) gotoNextScene(L *lua.LState) int {
 	return 0
 }
 
+// +gendoc "Flow Module"
+// * flow.callScene(scene_name)
+//
+// go to the scene specified by scene_name imidiately, with pushing current scene
+// into the scene stack. The pushed scene is restarted by flow.returnScene().
+// It is useful for sub-scenes such as menu. The scene stack can hold up to 32 scenes.
+// passing unknown scene_name or exceeding the stack depth will occurs error.
+//
+// 現在のシーンをシーンスタックに積み、scene_nameで指定したシーンを強制的に開始します。
+// 積まれたシーンは flow.returnScene() によって再開されます。
+// メニューなどのサブシーンを実装する際に便利です。シーンスタックには最大32個のシーンを積むことができます。
+// 存在しないシーンの名前を渡した場合や、スタックの深さが上限を超えた場合、エラーを起こします。
+//
+// Example:
+//
+//	era.flow.registerScene("menu", {
+//	  next = function()
+//	    -- do something
+//	    era.flow.returnScene() -- go back to the caller scene.
+//	  end,
+//	})
+//	era.flow.callScene("menu")
+func (ft functor) callScene(L *lua.LState) int {
+	name := L.CheckString(1)
+	if err := ft.game.CallSceneByName(name); err != nil {
+		L.ArgError(1, err.Error())
+	}
+	L.Error(lua.LString(ScriptGoToNextSceneMessage), 0)
+	return 0
+}
+
+// +gendoc "Flow Module"
+// * flow.returnScene()
+//
+// go back to the scene which is popped from the scene stack imidiately.
+// The popped scene is restarted from the beginning.
+// calling it with empty scene stack will occurs error.
+//
+// シーンスタックから取り出したシーンを強制的に開始し、呼び出し元のシーンに戻ります。
+// 取り出したシーンは最初から再開されます。
+// シーンスタックが空の場合はエラーを起こします。
+func (ft functor) returnScene(L *lua.LState) int {
+	if err := ft.game.ReturnScene(); err != nil {
+		L.RaiseError("%v", err)
+	}
+	L.Error(lua.LString(ScriptGoToNextSceneMessage), 0)
+	return 0
+}
+
+// +gendoc "Flow Module"
+// * scene_names: string[] = flow.getSceneStack()
+//
+// it gets scene names in the scene stack, ordered from bottom to top.
+//
+// シーンスタックに積まれたシーン名を、底から順に取得します。
+func (ft functor) getSceneStack(L *lua.LState) int {
+	names := ft.game.SceneStackNames()
+	tbl := L.CreateTable(len(names), 0)
+	for _, name := range names {
+		tbl.Append(lua.LString(name))
+	}
+	L.Push(tbl)
+	return 1
+}
+
+// +gendoc "Flow Module"
+// * transitions: table[] = flow.history()
+//
+// it gets recent scene transitions ordered from oldest to newest.
+// Each transition is a table which has the fields, from, to, cause, detail and time.
+// cause is one of "callback", "flow_api" and "scene_next".
+// "callback" means the scene decides next scene by itself,
+// "flow_api" means the scene moves to next scene set by flow API, and
+// "scene_next" means the scene is interrupted by such as flow.gotoNextScene().
+// detail is the name of flow API which sets next scene, or empty string.
+// time is unix time in seconds when the transition occurs.
+//
+// 直近のシーン遷移の履歴を古い順に取得します。
+// 各遷移は from, to, cause, detail, time のフィールドを持つテーブルです。
+// cause は "callback", "flow_api", "scene_next" のいずれかです。
+// "callback" はシーン自身が次のシーンを決めたこと、"flow_api" は flow API で設定された
+// 次のシーンに遷移したこと、"scene_next" は flow.gotoNextScene() などによって
+// シーンが中断されたことを表します。
+// detail は次のシーンを設定した flow API の名前で、なければ空文字列です。
+// time は遷移が起きた時刻のUnix時間(秒)です。
+//
+// Example:
+//
+//	for _, t in ipairs(era.flow.history()) do
+//	  era.printl(t.from .. " -> " .. t.to .. " (" .. t.cause .. ")")
+//	end
+func (ft functor) history(L *lua.LState) int {
+	transitions := ft.game.TransitionHistory()
+	tbl := L.CreateTable(len(transitions), 0)
+	for _, t := range transitions {
+		record := L.CreateTable(0, 5)
+		record.RawSetString("from", lua.LString(t.From))
+		record.RawSetString("to", lua.LString(t.To))
+		record.RawSetString("cause", lua.LString(t.Cause))
+		record.RawSetString("detail", lua.LString(t.Detail))
+		record.RawSetString("time", lua.LNumber(t.Time.Unix()))
+		tbl.Append(record)
+	}
+	L.Push(tbl)
+	return 1
+}
+
+// +gendoc "Flow Module"
+// * dot: string = flow.historyGraph()
+//
+// it returns the scene graph observed in scene transitions as Graphviz dot format.
+// Each edge is labeled by the number of observed transitions.
+//
+// これまでのシーン遷移で観測されたシーングラフを Graphviz の dot 形式で返します。
+// 各辺には観測された遷移の回数がラベルとして付きます。
+func (ft functor) historyGraph(L *lua.LState) int {
+	var sb strings.Builder
+	if err := ft.game.WriteTransitionGraph(&sb); err != nil {
+		L.RaiseError("%v", err)
+	}
+	L.Push(lua.LString(sb.String()))
+	return 1
+}
+
+// +gendoc "Flow Module"
+// * flow.registerScene(scene_name: string, scene_def: table)
+//
+// register the scene defined by script. scene_def must have next function which
+// describes the scene flow and returns next scene name. If next function returns nil,
+// the scene set by flow.setNextScene() is used as next scene.
+// The registered scene takes part in the scene transition like builtin scenes, so
+// [scene_name]_event_start is emitted and callbacks [scene_name]_scene and [scene_name]_event_start
+// are called at start of the scene. Builtin scene names can not be used.
+//
+// スクリプトで定義したシーンを登録します。scene_def には、シーンの処理を記述し、
+// 次のシーン名を返す next 関数が必要です。next 関数が nil を返した場合は、
+// flow.setNextScene() で設定したシーンが次のシーンになります。
+// 登録したシーンは組み込みのシーンと同様にシーン遷移に組み込まれ、シーンの始めに
+// [scene_name]_event_start イベントの発行と、コールバック [scene_name]_scene および
+// [scene_name]_event_start の呼び出しが行われます。組み込みのシーン名は使用できません。
+//
+// Example:
+//
+//	era.flow.registerScene("menu", {
+//	  next = function()
+//	    era.printl("menu")
+//	    return "base"
+//	  end,
+//	})
+func (ip *Interpreter) registerScene(L *lua.LState) int {
+	name := L.CheckString(1)
+	def := L.CheckTable(2)
+	nextFn, ok := def.RawGetString("next").(*lua.LFunction)
+	if !ok {
+		L.ArgError(2, "scene definition requires next function")
+	}
+
+	err := ip.game.RegisterScriptSceneFunc(name, func() (string, error) {
+		if err := ip.callByParam(nextFn, 1); err != nil {
+			return "", ip.checkSpecialError(err)
+		}
+		ret := ip.vm.Get(-1)
+		ip.vm.Pop(1)
+		switch ret := ret.(type) {
+		case lua.LString:
+			return string(ret), nil
+		case *lua.LNilType:
+			return "", nil
+		default:
+			return "", fmt.Errorf("scene %s: next function must return scene name or nil, but got %s", name, ret.Type())
+		}
+	})
+	if err != nil {
+		L.ArgError(1, err.Error())
+	}
+	return 0
+}
+
 // +gendoc "Flow Module"
 // * flow.saveScene()
 //
@@ -1212,26 +1473,15 @@ func (ft functor) doTrains(L *lua.LState) int {
 
 // // Utils
 
-func currentLv(param int64, lvs []int64) int {
-	for lv, step := range lvs {
-		if param < step {
-			return lv
-		}
-	}
-	return 0
-}
-
 func (ft functor) paramLv(L *lua.LState) int {
 	param := L.CheckInt64(1)
-	param_lvs := ft.state.CSV.ParamLvs
-	L.Push(lua.LNumber(currentLv(param, param_lvs)))
+	L.Push(lua.LNumber(ft.state.CSV.ParamLv(param)))
 	return 1
 }
 
 func (ft functor) expLv(L *lua.LState) int {
 	param := L.CheckInt64(1)
-	exp_lvs := ft.state.CSV.ExpLvs
-	L.Push(lua.LNumber(currentLv(param, exp_lvs)))
+	L.Push(lua.LNumber(ft.state.CSV.ExpLv(param)))
 	return 1
 }
 
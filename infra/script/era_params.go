@@ -2,6 +2,7 @@ package script
 
 import (
 	"fmt"
+	"math"
 
 	"github.com/mzki/erago/state"
 
@@ -11,10 +12,15 @@ import (
 // // register System Paramters
 
 // +gendoc "Era Module"
-// * var era.system: table<string, (IntParam|StrParam)>
+// * var era.system: table<string, (IntParam|StrParam|FloatParam|BoolParam)>
+//
+// _DerivedVariables.csv で定義された派生変数も読み取り専用の IntParam として参照できます。
+// 派生変数の値は参照した時点で計算されるため、値を保持し続けても依存する変数の変更は反映されません。
 
 // +gendoc "Era Module"
-// * var era.share: table<string, (IntParam|StrParam)>
+// * var era.share: table<string, (IntParam|StrParam|FloatParam|BoolParam)>
+//
+// era.system と同様に、派生変数も読み取り専用の IntParam として参照できます。
 
 // +gendoc "Era Module"
 // * var era.saveinfo: SaveInfo
@@ -26,40 +32,21 @@ const (
 	saveInfoDataName     = "saveinfo"
 	saveInfoDataMetaName = "SaveInfo"
 
-	intParamMetaName = "IntParam"
-	strParamMetaName = "StrParam"
+	intParamMetaName   = "IntParam"
+	strParamMetaName   = "StrParam"
+	floatParamMetaName = "FloatParam"
+	boolParamMetaName  = "BoolParam"
 )
 
 func registerSystemParams(L *lua.LState, gamestate *state.GameState) {
 	era_module := mustGetEraModule(L)
 
 	len_func := L.NewFunction(lenScalable)
-	next_int_func := L.NewFunction(lnextIntPair)
-	next_str_func := L.NewFunction(lnextStrPair)
 	pairs_func := L.NewFunction(lpairsWithMetaNext)
-	intparam_meta := getOrNewMetatable(L, intParamMetaName, map[string]lua.LValue{
-		"__index":     L.NewFunction(intParamMetaIndex),
-		"__newindex":  L.NewFunction(intParamMetaNewIndex),
-		"__len":       len_func,
-		"__ipairs":    pairs_func,
-		"__pairs":     pairs_func,
-		"__next":      next_int_func,
-		"__metatable": metaProtectObj,
-	})
-	L.SetFuncs(intparam_meta, intParamMethods)
-	L.SetGlobal(intParamMetaName, intparam_meta)
-
-	strparam_meta := getOrNewMetatable(L, strParamMetaName, map[string]lua.LValue{
-		"__index":     L.NewFunction(strParamMetaIndex),
-		"__newindex":  L.NewFunction(strParamMetaNewIndex),
-		"__len":       len_func,
-		"__ipairs":    pairs_func,
-		"__pairs":     pairs_func,
-		"__next":      next_str_func,
-		"__metatable": metaProtectObj,
-	})
-	L.SetFuncs(strparam_meta, strParamMethods)
-	L.SetGlobal(strParamMetaName, strparam_meta)
+	intparam_meta := intParamKind.registerMetatable(L, len_func, pairs_func)
+	strparam_meta := strParamKind.registerMetatable(L, len_func, pairs_func)
+	floatparam_meta := floatParamKind.registerMetatable(L, len_func, pairs_func)
+	boolparam_meta := boolParamKind.registerMetatable(L, len_func, pairs_func)
 
 	// register system and shared params as userdata to module.
 	for _, data := range []struct {
@@ -67,6 +54,9 @@ func registerSystemParams(L *lua.LState, gamestate *state.GameState) {
 		iface   interface {
 			ForEachIntParam(func(key string, param state.IntParam))
 			ForEachStrParam(func(key string, param state.StrParam))
+			ForEachFloatParam(func(key string, param state.FloatParam))
+			ForEachBoolParam(func(key string, param state.BoolParam))
+			GetInt(varname string) (state.IntParam, bool)
 		}
 	}{
 		{systemParamsModuleName, gamestate.SystemData},
@@ -81,7 +71,15 @@ func registerSystemParams(L *lua.LState, gamestate *state.GameState) {
 			ud := newUserDataWithMt(L, param, strparam_meta)
 			mod.RawSetString(key, ud)
 		})
-		L.SetMetatable(mod, getStrictTableMetatable(L))
+		data.iface.ForEachFloatParam(func(key string, param state.FloatParam) {
+			ud := newUserDataWithMt(L, param, floatparam_meta)
+			mod.RawSetString(key, ud)
+		})
+		data.iface.ForEachBoolParam(func(key string, param state.BoolParam) {
+			ud := newUserDataWithMt(L, param, boolparam_meta)
+			mod.RawSetString(key, ud)
+		})
+		L.SetMetatable(mod, newParamsModuleMetatable(L, data.iface.GetInt))
 		era_module.RawSetString(data.modName, mod)
 	}
 
@@ -90,6 +88,22 @@ func registerSystemParams(L *lua.LState, gamestate *state.GameState) {
 	era_module.RawSetString(saveInfoDataName, ud)
 }
 
+// newParamsModuleMetatable returns strict metatable for era.system and era.share,
+// which looks up the derived variables by getInt. They are not contained in
+// the module table since their values are computed on read.
+func newParamsModuleMetatable(L *lua.LState, getInt func(string) (state.IntParam, bool)) *lua.LTable {
+	mt := L.NewTable()
+	mt.RawSetString("__index", L.NewFunction(func(L *lua.LState) int {
+		if param, ok := getInt(L.CheckString(2)); ok {
+			L.Push(intParamKind.newLParam(L, param))
+			return 1
+		}
+		return ltableNotFoundMetaIndex(L)
+	}))
+	mt.RawSetString("__metatable", metaProtectObj)
+	return mt
+}
+
 // +gendoc "SaveInfo"
 // * var SaveInfo.save_comment: string
 // Read/Write
@@ -171,119 +185,419 @@ func checkNameIndexer(L *lua.LState, pos int) state.NameIndexer {
 	return nil
 }
 
-// check pos-th argument is int or string, then return as index,
-func checkParamIndex(L *lua.LState, pos int, indexer state.NameIndexer) int {
-	L.CheckTypes(pos, lua.LTNumber, lua.LTString)
+// indexedValues is values which can be accessed by csv name.
+type indexedValues interface {
+	scalableValues
+	state.NameIndexer
+}
+
+// keyNotFoundMessage returns error message for the key which is not found in csv.
+// It suggests the nearest csv name if exist.
+func keyNotFoundMessage(key string, iv indexedValues) string {
+	msg := key + " is not found in csv"
+	if name, ok := state.NearestName(iv, iv.Len(), key); ok {
+		msg += fmt.Sprintf(", did you mean %q?", name)
+	}
+	return msg
+}
 
-	switch lval := L.Get(pos); lval.Type() {
+// indexOutOfRangeMessage returns error message for the index out of range [0:size).
+func indexOutOfRangeMessage(index int, sv scalableValues) string {
+	return fmt.Sprintf("%s: index %d, must be in 0 ~ %d", indexOutMessage, index, sv.Len()-1)
+}
+
+// convert lua value to index of iv. It returns error message if failed.
+func toParamIndex(lval lua.LValue, iv indexedValues) (int, string) {
+	switch lval.Type() {
 	case lua.LTNumber:
-		return int(lua.LVAsNumber(lval))
+		index := int(lua.LVAsNumber(lval))
+		if !indexIsInRange(index, iv) {
+			return -1, indexOutOfRangeMessage(index, iv)
+		}
+		return index, ""
 	case lua.LTString:
 		key := lua.LVAsString(lval)
-		index := indexer.GetIndex(key)
+		index := iv.GetIndex(key)
+		if index < 0 {
+			return -1, keyNotFoundMessage(key, iv)
+		}
+		return index, ""
+	}
+	return -1, fmt.Sprintf("index must be integer or string, but got %s", lval.Type())
+}
+
+// check pos-th argument is int or string, then return as index in range of iv.
+func checkParamIndex(L *lua.LState, pos int, iv indexedValues) int {
+	L.CheckTypes(pos, lua.LTNumber, lua.LTString)
+	index, errMsg := toParamIndex(L.Get(pos), iv)
+	if len(errMsg) > 0 {
+		L.ArgError(pos, errMsg)
+	}
+	return index
+}
+
+// check pos-th argument is int or csv name, then return as index.
+// Unlike checkParamIndex, the index is not checked its range.
+func checkSliceIndex(L *lua.LState, pos int, iv indexedValues) int {
+	if lv, ok := L.Get(pos).(lua.LString); ok {
+		key := string(lv)
+		index := iv.GetIndex(key)
 		if index < 0 {
-			L.ArgError(pos, key+" is not found in csv")
+			L.ArgError(pos, keyNotFoundMessage(key, iv))
 		}
 		return index
 	}
-	return -1
+	return L.CheckInt(pos)
 }
 
 // return slicing index range, [from:to).
-func checkIndexSliceRange(L *lua.LState, pos int, sv scalableValues) (from, to int) {
-	from = L.CheckInt(pos)
-	to = L.OptInt(pos+1, sv.Len())
+// from and to can be specified by csv name.
+func checkIndexSliceRange(L *lua.LState, pos int, iv indexedValues) (from, to int) {
+	from = checkSliceIndex(L, pos, iv)
+	to = iv.Len()
+	if L.Get(pos+1) != lua.LNil {
+		to = checkSliceIndex(L, pos+1, iv)
+	}
 
 	starting_negative := from < 0
 	inversed_range := from > to
-	ending_overrun := to > sv.Len()
+	ending_overrun := to > iv.Len()
 	if starting_negative || inversed_range || ending_overrun {
-		L.ArgError(pos, fmt.Sprintf("slice range: from(%d) ~ to(%d), must be in 0 ~ data-length(%d)", from, to, sv.Len()))
+		L.ArgError(pos, fmt.Sprintf("slice range: from(%d) ~ to(%d), must be in 0 ~ data-length(%d)", from, to, iv.Len()))
 	}
 	return from, to
 }
 
-// //  intParam
+// return next index after idx which has csv name.
+func nextNamedIndex(iv indexedValues, idx int) (int, string, bool) {
+	for i := idx + 1; i < iv.Len(); i++ {
+		if name := iv.GetName(i); len(name) > 0 {
+			return i, name, true
+		}
+	}
+	return -1, "", false
+}
 
-var intParamMethods = map[string]lua.LGFunction{
-	"new":   intParamNew,
-	"set":   intParamGetSet,
-	"get":   intParamGetSet,
-	"len":   lenScalable,
-	"slice": intParamSlice,
-	"fill":  intParamFill,
-	// TODO: implement "ipairs", "pairs"
+// check pos-th argument is a table for bulk assignment, then return
+// its indexes and values. Keys of the table must be index or csv name, and
+// values must be valueType.
+func checkAssignTable(L *lua.LState, pos int, iv indexedValues, valueType lua.LValueType) ([]int, []lua.LValue) {
+	tbl := L.CheckTable(pos)
+	indexes := make([]int, 0, tbl.Len())
+	values := make([]lua.LValue, 0, tbl.Len())
+	tbl.ForEach(func(k, v lua.LValue) {
+		index, errMsg := toParamIndex(k, iv)
+		if len(errMsg) > 0 {
+			L.ArgError(pos, errMsg)
+		}
+		if v.Type() != valueType {
+			L.ArgError(pos, fmt.Sprintf("value for %s must be %s, but got %s", k, valueType, v.Type()))
+		}
+		indexes = append(indexes, index)
+		values = append(values, v)
+	})
+	return indexes, values
 }
 
-// construct intparam as lua object.
-func newLIntParam(L *lua.LState, ip state.IntParam) *lua.LUserData {
-	return newUserDataWithMt(L, ip, L.GetTypeMetatable(intParamMetaName))
+// // luaParam
+
+const indexOutMessage = "index out of data range"
+
+// multiDimAssignMessage is error message for assigning to multi-dimensional param directly.
+const multiDimAssignMessage = "can not assign to multi-dimensional variable directly, assign to its element such as v[i][j]"
+
+// luaParam is a param exposed to lua, such as IntParam and StrParam.
+// P is the type of param itself and T is the type of its element.
+type luaParam[P any, T any] interface {
+	indexedValues
+	IsMultiDim() bool
+	Dims() []int
+	Sub(i int) P
+	Slice(from, to int) P
+	Get(i int) T
+	GetByStr(key string) (T, bool)
+}
+
+// paramKind defines lua object for the param type P. Metamethods and methods of
+// the lua object are implemented by paramKind, so that all of param types
+// behave same in lua.
+type paramKind[P luaParam[P, T], T any] struct {
+	metaName  string
+	valueType lua.LValueType // lua type of the element.
+
+	newParam   func(size int, indexer state.NameIndexer) P
+	toLValue   func(v T) lua.LValue
+	fromLValue func(lv lua.LValue) T // lv is assured to be valueType.
+	checkValue func(L *lua.LState, pos int) T
+
+	set  func(p P, i int, v T) error
+	fill func(p P, v T) error
+	// canSet returns the error if set(p, i, v) will fail. nil means set never fails.
+	canSet func(p P, i int, v T) error
 }
 
-// check whether pos-th argument is intParams as userdata?
-func checkIntParam(L *lua.LState, pos int) state.IntParam {
+// registerMetatable registers metatable of the param type as global variable.
+func (k *paramKind[P, T]) registerMetatable(L *lua.LState, len_func, pairs_func *lua.LFunction) *lua.LTable {
+	mt := getOrNewMetatable(L, k.metaName, map[string]lua.LValue{
+		"__index":     L.NewFunction(k.metaIndex),
+		"__newindex":  L.NewFunction(k.metaNewIndex),
+		"__len":       len_func,
+		"__ipairs":    pairs_func,
+		"__pairs":     pairs_func,
+		"__next":      L.NewFunction(k.metaNext),
+		"__metatable": metaProtectObj,
+	})
+	L.SetFuncs(mt, map[string]lua.LGFunction{
+		"new":    k.new,
+		"set":    k.getSet,
+		"get":    k.getSet,
+		"len":    lenScalable,
+		"slice":  k.slice,
+		"fill":   k.fillAll,
+		"iter":   k.iter,
+		"assign": k.assign,
+		"dims":   k.dims,
+	})
+	L.SetGlobal(k.metaName, mt)
+	return mt
+}
+
+// construct param as lua object.
+func (k *paramKind[P, T]) newLParam(L *lua.LState, p P) *lua.LUserData {
+	return newUserDataWithMt(L, p, L.GetTypeMetatable(k.metaName))
+}
+
+// check whether pos-th argument is the param as userdata?
+func (k *paramKind[P, T]) check(L *lua.LState, pos int) P {
 	ud := L.CheckUserData(pos)
-	if value, ok := ud.Value.(state.IntParam); ok {
+	if value, ok := ud.Value.(P); ok {
 		return value
 	}
-	L.ArgError(pos, "require IntParam object")
-	return state.IntParam{}
+	L.ArgError(pos, "require "+k.metaName+" object")
+	var zero P
+	return zero
 }
 
-const indexOutMessage = "index out of data range"
+// push index-th value of p. For multi-dimensional p, push its sub-array as the param.
+func (k *paramKind[P, T]) pushElem(L *lua.LState, p P, index int) {
+	if p.IsMultiDim() {
+		L.Push(k.newLParam(L, p.Sub(index)))
+		return
+	}
+	L.Push(k.toLValue(p.Get(index)))
+}
 
-// +gendoc "IntParam"
-// * value: integer = IntParam:__index(key: integer|string)
+// push sizes of each dimension as lua table.
+func pushParamDims(L *lua.LState, dims []int) {
+	tbl := L.CreateTable(len(dims), 0)
+	for _, size := range dims {
+		tbl.Append(lua.LNumber(size))
+	}
+	L.Push(tbl)
+}
 
-// metamethod __index for IntParam.
-func intParamMetaIndex(L *lua.LState) int {
-	data := checkIntParam(L, 1)
+// metamethod __index.
+func (k *paramKind[P, T]) metaIndex(L *lua.LState) int {
+	data := k.check(L, 1)
 	L.CheckTypes(2, lua.LTNumber, lua.LTString)
 
 	switch lvalue := L.Get(2); lvalue.Type() {
 	case lua.LTNumber:
 		index := int(lua.LVAsNumber(lvalue))
 		if ok := indexIsInRange(index, data); !ok {
-			L.ArgError(2, indexOutMessage)
+			L.ArgError(2, indexOutOfRangeMessage(index, data))
 		}
-		L.Push(lua.LNumber(data.Get(index)))
+		k.pushElem(L, data, index)
 		return 1
 	case lua.LTString:
 		key := lua.LVAsString(lvalue)
 		// find methods
-		mt := L.GetTypeMetatable(intParamMetaName).(*lua.LTable)
+		mt := L.GetTypeMetatable(k.metaName).(*lua.LTable)
 		if fn := mt.RawGetString(key); fn.Type() == lua.LTFunction {
 			L.Push(fn)
 			return 1
 		}
 
 		// find data
-		if val, ok := data.GetByStr(key); ok {
-			L.Push(lua.LNumber(val))
+		if data.IsMultiDim() {
+			if index := data.GetIndex(key); indexIsInRange(index, data) {
+				k.pushElem(L, data, index)
+				return 1
+			}
+		} else if val, ok := data.GetByStr(key); ok {
+			L.Push(k.toLValue(val))
 			return 1
 		}
-		L.ArgError(2, key+" is not found in csv")
+		L.ArgError(2, keyNotFoundMessage(key, data))
 	}
 
 	L.Push(lua.LNil)
 	return 1
 }
 
-// +gendoc "IntParam"
-// * IntParam:__newindex(key: integer|string, newValue: integer)
-
-// metamethod __newindex for IntParam.
-func intParamMetaNewIndex(L *lua.LState) int {
-	data := checkIntParam(L, 1)
+// metamethod __newindex.
+func (k *paramKind[P, T]) metaNewIndex(L *lua.LState) int {
+	data := k.check(L, 1)
+	if data.IsMultiDim() {
+		L.ArgError(2, multiDimAssignMessage)
+	}
 	index := checkParamIndex(L, 2, data)
-	if ok := indexIsInRange(index, data); !ok {
-		L.ArgError(2, indexOutMessage)
+	if err := k.set(data, index, k.checkValue(L, 3)); err != nil {
+		L.ArgError(3, err.Error())
+	}
+	return 0
+}
+
+// metamethod __next.
+func (k *paramKind[P, T]) metaNext(L *lua.LState) int {
+	p := k.check(L, 1)
+	idx := L.OptInt(2, -1) + 1
+	if !indexIsInRange(idx, p) {
+		return 0
+	}
+	L.Push(lua.LNumber(idx))
+	k.pushElem(L, p, idx)
+	return 2
+}
+
+func (k *paramKind[P, T]) new(L *lua.LState) int {
+	size := L.CheckInt(1)
+	var indexer state.NameIndexer
+	if L.GetTop() == 2 {
+		indexer = checkNameIndexer(L, 2)
+	} else {
+		indexer = state.NoneNameIndexer{}
+	}
+	L.Push(k.newLParam(L, k.newParam(size, indexer)))
+	return 1
+}
+
+func (k *paramKind[P, T]) getSet(L *lua.LState) int {
+	p := k.check(L, 1)
+	index := checkParamIndex(L, 2, p)
+
+	if L.GetTop() == 3 {
+		// set
+		if p.IsMultiDim() {
+			L.ArgError(2, multiDimAssignMessage)
+		}
+		if err := k.set(p, index, k.checkValue(L, 3)); err != nil {
+			L.ArgError(3, err.Error())
+		}
+		return 0
+	}
+	// get
+	k.pushElem(L, p, index)
+	return 1
+}
+
+func (k *paramKind[P, T]) slice(L *lua.LState) int {
+	p := k.check(L, 1)
+	from, to := checkIndexSliceRange(L, 2, p)
+	L.Push(k.newLParam(L, p.Slice(from, to)))
+	return 1
+}
+
+func (k *paramKind[P, T]) fillAll(L *lua.LState) int {
+	p := k.check(L, 1)
+	if err := k.fill(p, k.checkValue(L, 2)); err != nil {
+		L.ArgError(2, err.Error())
 	}
-	new_value := L.CheckInt64(3)
-	data.Set(index, new_value)
 	return 0
 }
 
+func (k *paramKind[P, T]) iter(L *lua.LState) int {
+	k.check(L, 1)
+	L.Push(L.NewFunction(k.namedNext))
+	L.Push(L.Get(1))
+	L.Push(lua.LNumber(-1))
+	return 3
+}
+
+func (k *paramKind[P, T]) namedNext(L *lua.LState) int {
+	p := k.check(L, 1)
+	idx, name, ok := nextNamedIndex(p, L.CheckInt(2))
+	if !ok {
+		return 0
+	}
+	L.Push(lua.LNumber(idx))
+	L.Push(lua.LString(name))
+	k.pushElem(L, p, idx)
+	return 3
+}
+
+func (k *paramKind[P, T]) assign(L *lua.LState) int {
+	p := k.check(L, 1)
+	if p.IsMultiDim() {
+		L.ArgError(1, multiDimAssignMessage)
+	}
+	indexes, lvalues := checkAssignTable(L, 2, p, k.valueType)
+	values := make([]T, len(lvalues))
+	for i, lv := range lvalues {
+		values[i] = k.fromLValue(lv)
+	}
+	if k.canSet != nil {
+		for i, index := range indexes {
+			if err := k.canSet(p, index, values[i]); err != nil {
+				L.ArgError(2, err.Error())
+			}
+		}
+	}
+	for i, index := range indexes {
+		k.set(p, index, values[i])
+	}
+	return 0
+}
+
+func (k *paramKind[P, T]) dims(L *lua.LState) int {
+	p := k.check(L, 1)
+	pushParamDims(L, p.Dims())
+	return 1
+}
+
+// setParam and fillParam implement paramKind.set and paramKind.fill for state.Param,
+// which never fail.
+func setParam[T any](p state.Param[T], i int, v T) error {
+	p.Set(i, v)
+	return nil
+}
+
+func fillParam[T any](p state.Param[T], v T) error {
+	p.Fill(v)
+	return nil
+}
+
+// //  intParam
+
+var intParamKind = &paramKind[state.IntParam, int64]{
+	metaName:  intParamMetaName,
+	valueType: lua.LTNumber,
+	newParam: func(size int, indexer state.NameIndexer) state.IntParam {
+		return state.NewIntParam(make([]int64, size), indexer)
+	},
+	toLValue:   func(v int64) lua.LValue { return lua.LNumber(v) },
+	fromLValue: func(lv lua.LValue) int64 { return int64(lua.LVAsNumber(lv)) },
+	checkValue: (*lua.LState).CheckInt64,
+	set:        state.IntParam.Set,
+	fill:       state.IntParam.Fill,
+	canSet:     state.IntParam.CheckValue,
+}
+
+// +gendoc "IntParam"
+// * value: integer|IntParam = IntParam:__index(key: integer|string)
+//
+// 多次元変数の場合、値の代わりに次の次元の IntParam を返します。
+// 各次元の index にはその次元の csv の名前も使用できます。
+//
+//	chara.BaseHistory[2]["体力"] = 100
+//	era.printl(chara.BaseHistory[2]["体力"])
+
+// +gendoc "IntParam"
+// * IntParam:__newindex(key: integer|string, newValue: integer)
+//
+// _VariableBounds.csv で範囲が定義されている変数に範囲外の値を代入すると、
+// clamp の場合は範囲内に丸められ、error の場合はエラーとなり値は変更されません。
+
 // +gendoc "IntParam"
 // * new_intparam = IntParam.new(size, [name_indexer])
 //
@@ -304,18 +618,6 @@ func intParamMetaNewIndex(L *lua.LState) int {
 //	value = intparam["道具"]
 //
 // というように、代行してくれます。
-func intParamNew(L *lua.LState) int {
-	size := L.CheckInt(1)
-	var indexer state.NameIndexer
-	if L.GetTop() == 2 {
-		indexer = checkNameIndexer(L, 2)
-	} else {
-		indexer = state.NoneNameIndexer{}
-	}
-	ip := state.NewIntParam(make([]int64, size), indexer)
-	L.Push(newLIntParam(L, ip))
-	return 1
-}
 
 // +gendoc "IntParam"
 // * IntParam:set(key, new_value)
@@ -334,141 +636,83 @@ func intParamNew(L *lua.LState) int {
 //	not_a_value = intparam["len"]
 //
 // その場合（ここでは、"len"というkey）でも、このget/setによって、値の取得/設定が可能です。
-func intParamGetSet(L *lua.LState) int {
-	ip := checkIntParam(L, 1)
-	index := checkParamIndex(L, 2, ip)
-	if ok := indexIsInRange(index, ip); !ok {
-		L.ArgError(2, indexOutMessage)
-	}
-
-	if L.GetTop() == 3 {
-		// set
-		ip.Set(index, L.CheckInt64(3))
-		return 0
-	}
-	// get
-	L.Push(lua.LNumber(ip.Get(index)))
-	return 1
-}
 
 // +gendoc "IntParam"
 // * sliced_intparam = IntParam:slice(from,[to = max_length])
 //
 // fromからtoまでのデータ範囲を切り出します。切り出したデータは再び0から始まり、その長さは(to - from)になります。
 // toは省略可能です。省略したときには、現在のデータの最大の長さがtoとして使用されます。
-func intParamSlice(L *lua.LState) int {
-	ip := checkIntParam(L, 1)
-	from, to := checkIndexSliceRange(L, 2, ip)
-	L.Push(newLIntParam(L, ip.Slice(from, to)))
-	return 1
-}
+// from, toには文字列も指定できます。その場合も、toの位置の要素は含まれません。
 
 // +gendoc "IntParam"
 // * IntParam:fill(new_value)
 //
 // 現在のデータ範囲すべてをnew_valueで初期化します。
-func intParamFill(L *lua.LState) int {
-	ip := checkIntParam(L, 1)
-	val := L.CheckInt64(2)
-	ip.Fill(val)
-	return 0
-}
-
-// // strParan
+// new_value が変数の範囲外の場合は、代入と同様に丸められるかエラーとなります。
 
-var strParamMethods = map[string]lua.LGFunction{
-	"new":   strParamNew,
-	"set":   strParamGetSet,
-	"get":   strParamGetSet,
-	"len":   lenScalable,
-	"slice": strParamSlice,
-	"fill":  strParamFill,
-	// TODO: implement "ipairs", "pairs"
-}
+// +gendoc "IntParam"
+// * iter_func, intparam, start = IntParam:iter()
+//
+// CSVで名前が定義されている要素を、index順に走査するイテレータを返します。
+// イテレータは index, 名前, 値 を返します。名前が定義されていない要素は飛ばされます。
+// 全ての要素を走査する場合は ipairs を使用してください。
+//
+//	for i, name, value in chara.Base:iter() do
+//	  era.printl(name .. ": " .. value)
+//	end
 
-// construct strparam as lua object.
-func newLStrParam(L *lua.LState, sp state.StrParam) *lua.LUserData {
-	return newUserDataWithMt(L, sp, L.GetTypeMetatable(strParamMetaName))
-}
+// +gendoc "IntParam"
+// * IntParam:assign(values: table<integer|string, integer>)
+//
+// values の各要素を、そのキーが示す位置に一括で代入します。
+// キーにはインデックス番号あるいは文字列を指定します。
+// 不正なキーや値が含まれている場合はエラーとなり、いずれの値も代入されません。
+// 変数の範囲外の値が error として扱われる場合も同様です。
+// {10, 20} のような配列は index 1, 2 に代入されることに注意が必要です。
+//
+//	chara.Base:assign({["体力"] = 1000, ["気力"] = 500})
 
-func checkStrParam(L *lua.LState, pos int) state.StrParam {
-	ud := L.CheckUserData(pos)
-	if value, ok := ud.Value.(state.StrParam); ok {
-		return value
-	}
-	L.ArgError(pos, "require StrParam object")
-	return state.StrParam{}
+// +gendoc "IntParam"
+// * dims: table<integer> = IntParam:dims()
+//
+// 各次元の長さを配列で返します。1次元の変数の場合は {IntParam:len()} を返します。
+//
+//	local dims = chara.BaseHistory:dims()
+//	for i = 0, dims[1] - 1 do
+//	  for j = 0, dims[2] - 1 do
+//	    era.print(chara.BaseHistory[i][j])
+//	  end
+//	end
+
+// // strParam
+
+var strParamKind = &paramKind[state.StrParam, string]{
+	metaName:  strParamMetaName,
+	valueType: lua.LTString,
+	newParam: func(size int, indexer state.NameIndexer) state.StrParam {
+		return state.NewStrParam(make([]string, size), indexer)
+	},
+	toLValue:   func(v string) lua.LValue { return lua.LString(v) },
+	fromLValue: lua.LVAsString,
+	checkValue: (*lua.LState).CheckString,
+	set:        setParam[string],
+	fill:       fillParam[string],
 }
 
 // +gendoc "StrParam"
-// * value: string = StrParam:__index(key: integer|string)
-
-// metamethod __index for StrParam.
-func strParamMetaIndex(L *lua.LState) int {
-	data := checkStrParam(L, 1)
-	L.CheckTypes(2, lua.LTNumber, lua.LTString)
-
-	switch lvalue := L.Get(2); lvalue.Type() {
-	case lua.LTNumber:
-		index := int(lua.LVAsNumber(lvalue))
-		if ok := indexIsInRange(index, data); !ok {
-			L.ArgError(2, indexOutMessage)
-		}
-		L.Push(lua.LString(data.Get(index)))
-		return 1
-	case lua.LTString:
-		key := lua.LVAsString(lvalue)
-		// find methods
-		mt := L.GetTypeMetatable(strParamMetaName).(*lua.LTable)
-		if fn := mt.RawGetString(key); fn.Type() == lua.LTFunction {
-			L.Push(fn)
-			return 1
-		}
-
-		// find data
-		if val, ok := data.GetByStr(key); ok {
-			L.Push(lua.LString(val))
-			return 1
-		}
-		L.ArgError(2, key+" is not found in csv")
-	}
-
-	L.Push(lua.LNil)
-	return 1
-}
+// * value: string|StrParam = StrParam:__index(key: integer|string)
+//
+// 多次元変数の場合、値の代わりに次の次元の StrParam を返します。
+// IntParam:__index() の項目も参照。
 
 // +gendoc "StrParam"
 // * StrParam:__newindex(key: integer|string, newValue: string)
 
-// metamethod __newindex for StrParam.
-func strParamMetaNewIndex(L *lua.LState) int {
-	data := checkStrParam(L, 1)
-	index := checkParamIndex(L, 2, data)
-	if ok := indexIsInRange(index, data); !ok {
-		L.ArgError(2, indexOutMessage)
-	}
-	new_value := L.CheckString(3)
-	data.Set(index, new_value)
-	return 0
-}
-
 // +gendoc "StrParam"
 // * new_strparam = StrParam.new(size, [name_indexer])
 //
 // 新しいStrParamを作成します。StrParamはsizeの長さの文字列の配列と同じように振る舞います。
 // 詳細はIntParamに同じ。
-func strParamNew(L *lua.LState) int {
-	size := L.CheckInt(1)
-	var indexer state.NameIndexer
-	if L.GetTop() == 2 {
-		indexer = checkNameIndexer(L, 2)
-	} else {
-		indexer = state.NoneNameIndexer{}
-	}
-	ip := state.NewStrParam(make([]string, size), indexer)
-	L.Push(newLStrParam(L, ip))
-	return 1
-}
 
 // +gendoc "StrParam"
 // * StrParam:set(key, new_value)
@@ -481,42 +725,195 @@ func strParamNew(L *lua.LState) int {
 //
 // key番目の値を取得します。keyにはインデックス番号あるいは文字列を指定します。
 // IntParam:get() の項目も参照。
-func strParamGetSet(L *lua.LState) int {
-	sp := checkStrParam(L, 1)
-	index := checkParamIndex(L, 2, sp)
-	if ok := indexIsInRange(index, sp); !ok {
-		L.ArgError(2, indexOutMessage)
-	}
-
-	if L.GetTop() == 3 {
-		// set
-		sp.Set(index, L.CheckString(3))
-		return 0
-	}
-	// get
-	L.Push(lua.LString(sp.Get(index)))
-	return 1
-}
 
 // +gendoc "StrParam"
 // * sliced_strparam = StrParam:slice(from,[to = max_length])
 //
 // fromからtoまでのデータ範囲を切り出します。切り出したデータは再び0から始まり、その長さは(from - to)になります。
 // toは省略可能です。省略したときには、現在のデータの最大の長さがtoとして使用されます。
-func strParamSlice(L *lua.LState) int {
-	sp := checkStrParam(L, 1)
-	from, to := checkIndexSliceRange(L, 2, sp)
-	L.Push(newLStrParam(L, sp.Slice(from, to)))
-	return 1
-}
+// from, toには文字列も指定できます。
 
 // +gendoc "StrParam"
 // * StrParam:fill(new_value)
 //
 // 現在のデータ範囲すべてをnew_valueで初期化します。
-func strParamFill(L *lua.LState) int {
-	ip := checkStrParam(L, 1)
-	val := L.CheckString(2)
-	ip.Fill(val)
-	return 0
+
+// +gendoc "StrParam"
+// * iter_func, strparam, start = StrParam:iter()
+//
+// CSVで名前が定義されている要素を、index順に走査するイテレータを返します。
+// IntParam:iter() の項目も参照。
+
+// +gendoc "StrParam"
+// * StrParam:assign(values: table<integer|string, string>)
+//
+// values の各要素を、そのキーが示す位置に一括で代入します。
+// IntParam:assign() の項目も参照。
+
+// +gendoc "StrParam"
+// * dims: table<integer> = StrParam:dims()
+//
+// 各次元の長さを配列で返します。IntParam:dims() の項目も参照。
+
+// // floatParam
+
+var floatParamKind = &paramKind[state.FloatParam, float64]{
+	metaName:  floatParamMetaName,
+	valueType: lua.LTNumber,
+	newParam: func(size int, indexer state.NameIndexer) state.FloatParam {
+		return state.NewFloatParam(make([]float64, size), indexer)
+	},
+	toLValue:   func(v float64) lua.LValue { return lua.LNumber(v) },
+	fromLValue: func(lv lua.LValue) float64 { return float64(lua.LVAsNumber(lv)) },
+	checkValue: func(L *lua.LState, pos int) float64 { return float64(L.CheckNumber(pos)) },
+	set: func(p state.FloatParam, i int, v float64) error {
+		if err := checkFinite(v); err != nil {
+			return err
+		}
+		return setParam(p, i, v)
+	},
+	fill: func(p state.FloatParam, v float64) error {
+		if err := checkFinite(v); err != nil {
+			return err
+		}
+		return fillParam(p, v)
+	},
+	canSet: func(_ state.FloatParam, _ int, v float64) error { return checkFinite(v) },
+}
+
+// checkFinite returns error if v is NaN or infinity, which can not be stored into FloatParam.
+func checkFinite(v float64) error {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return fmt.Errorf("value %v is not a finite number", v)
+	}
+	return nil
+}
+
+// +gendoc "FloatParam"
+// * value: number|FloatParam = FloatParam:__index(key: integer|string)
+//
+// 多次元変数の場合、値の代わりに次の次元の FloatParam を返します。
+// IntParam:__index() の項目も参照。
+
+// +gendoc "FloatParam"
+// * FloatParam:__newindex(key: integer|string, newValue: number)
+//
+// NaN や無限大は代入できず、エラーとなります。set(), fill(), assign() も同様です。
+
+// +gendoc "FloatParam"
+// * new_floatparam = FloatParam.new(size, [name_indexer])
+//
+// 新しいFloatParamを作成します。FloatParamはsizeの長さの浮動小数点数の配列と同じように振る舞います。
+// 詳細はIntParamに同じ。
+
+// +gendoc "FloatParam"
+// * FloatParam:set(key, new_value)
+//
+// key番目にnew_valueを設定します。keyにはインデックス番号あるいは文字列を指定します。
+// IntParam:get() の項目も参照。
+
+// +gendoc "FloatParam"
+// * value = FloatParam:get(key)
+//
+// key番目の値を取得します。keyにはインデックス番号あるいは文字列を指定します。
+// IntParam:get() の項目も参照。
+
+// +gendoc "FloatParam"
+// * sliced_floatparam = FloatParam:slice(from,[to = max_length])
+//
+// fromからtoまでのデータ範囲を切り出します。切り出したデータは再び0から始まり、その長さは(to - from)になります。
+// toは省略可能です。省略したときには、現在のデータの最大の長さがtoとして使用されます。
+// from, toには文字列も指定できます。
+
+// +gendoc "FloatParam"
+// * FloatParam:fill(new_value)
+//
+// 現在のデータ範囲すべてをnew_valueで初期化します。
+
+// +gendoc "FloatParam"
+// * iter_func, floatparam, start = FloatParam:iter()
+//
+// CSVで名前が定義されている要素を、index順に走査するイテレータを返します。
+// IntParam:iter() の項目も参照。
+
+// +gendoc "FloatParam"
+// * FloatParam:assign(values: table<integer|string, number>)
+//
+// values の各要素を、そのキーが示す位置に一括で代入します。
+// IntParam:assign() の項目も参照。
+
+// +gendoc "FloatParam"
+// * dims: table<integer> = FloatParam:dims()
+//
+// 各次元の長さを配列で返します。IntParam:dims() の項目も参照。
+
+// // boolParam
+
+var boolParamKind = &paramKind[state.BoolParam, bool]{
+	metaName:  boolParamMetaName,
+	valueType: lua.LTBool,
+	newParam: func(size int, indexer state.NameIndexer) state.BoolParam {
+		return state.NewBoolParam(make([]bool, size), indexer)
+	},
+	toLValue:   func(v bool) lua.LValue { return lua.LBool(v) },
+	fromLValue: lua.LVAsBool,
+	checkValue: (*lua.LState).CheckBool,
+	set:        setParam[bool],
+	fill:       fillParam[bool],
 }
+
+// +gendoc "BoolParam"
+// * value: boolean|BoolParam = BoolParam:__index(key: integer|string)
+//
+// 多次元変数の場合、値の代わりに次の次元の BoolParam を返します。
+// IntParam:__index() の項目も参照。
+
+// +gendoc "BoolParam"
+// * BoolParam:__newindex(key: integer|string, newValue: boolean)
+
+// +gendoc "BoolParam"
+// * new_boolparam = BoolParam.new(size, [name_indexer])
+//
+// 新しいBoolParamを作成します。BoolParamはsizeの長さの真偽値の配列と同じように振る舞います。
+// 詳細はIntParamに同じ。
+
+// +gendoc "BoolParam"
+// * BoolParam:set(key, new_value)
+//
+// key番目にnew_valueを設定します。keyにはインデックス番号あるいは文字列を指定します。
+// IntParam:get() の項目も参照。
+
+// +gendoc "BoolParam"
+// * value = BoolParam:get(key)
+//
+// key番目の値を取得します。keyにはインデックス番号あるいは文字列を指定します。
+// IntParam:get() の項目も参照。
+
+// +gendoc "BoolParam"
+// * sliced_boolparam = BoolParam:slice(from,[to = max_length])
+//
+// fromからtoまでのデータ範囲を切り出します。切り出したデータは再び0から始まり、その長さは(to - from)になります。
+// toは省略可能です。省略したときには、現在のデータの最大の長さがtoとして使用されます。
+// from, toには文字列も指定できます。
+
+// +gendoc "BoolParam"
+// * BoolParam:fill(new_value)
+//
+// 現在のデータ範囲すべてをnew_valueで初期化します。
+
+// +gendoc "BoolParam"
+// * iter_func, boolparam, start = BoolParam:iter()
+//
+// CSVで名前が定義されている要素を、index順に走査するイテレータを返します。
+// IntParam:iter() の項目も参照。
+
+// +gendoc "BoolParam"
+// * BoolParam:assign(values: table<integer|string, boolean>)
+//
+// values の各要素を、そのキーが示す位置に一括で代入します。
+// IntParam:assign() の項目も参照。
+
+// +gendoc "BoolParam"
+// * dims: table<integer> = BoolParam:dims()
+//
+// 各次元の長さを配列で返します。IntParam:dims() の項目も参照。
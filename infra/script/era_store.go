@@ -0,0 +1,474 @@
+package script
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/ugorji/go/codec"
+
+	"github.com/mzki/erago/filesystem"
+	lua "github.com/yuin/gopher-lua"
+)
+
+const (
+	eraStoreModName = "store"
+
+	// StoreFileName is a file name of era.store data under Config.StoreDir.
+	StoreFileName = "store.dat"
+
+	// storeFormatVersion is a version of the file format of era.store.
+	// It is independent from the data version managed by user script.
+	storeFormatVersion = 1
+
+	// maximum depth of nested table stored into era.store.
+	storeMaxDepth = 32
+)
+
+// storeKind is a type of the value stored in era.store.
+type storeKind uint8
+
+const (
+	storeKindNumber storeKind = iota + 1
+	storeKindString
+	storeKindBool
+	storeKindTable
+)
+
+// storeValue is a serializable form of Lua value.
+// Table is stored as a list of key-value entries to keep key types.
+type storeValue struct {
+	Kind  storeKind    `codec:"k"`
+	Num   float64      `codec:"n,omitempty"`
+	Str   string       `codec:"s,omitempty"`
+	Bool  bool         `codec:"b,omitempty"`
+	Table []storeEntry `codec:"t,omitempty"`
+}
+
+type storeEntry struct {
+	Key   storeValue `codec:"k"`
+	Value storeValue `codec:"v"`
+}
+
+// storeFile is the content of the era.store file.
+type storeFile struct {
+	Format  int                   `codec:"format"`
+	Version int64                 `codec:"version"`
+	Data    map[string]storeValue `codec:"data"`
+}
+
+var storeCodecHandler = &codec.MsgpackHandle{}
+
+// kvStore is a persistent key-value storage for scripts, which is
+// independent from save slots.
+type kvStore struct {
+	path      string // empty means no persistence.
+	sizeLimit int
+
+	loaded  bool
+	version int64
+	data    map[string]storeValue
+	sizes   map[string]int
+	used    int
+}
+
+func newKVStore(config Config) *kvStore {
+	var path string
+	if len(config.StoreDir) > 0 {
+		path = filepath.Join(config.StoreDir, StoreFileName)
+	}
+	return &kvStore{
+		path:      path,
+		sizeLimit: config.StoreSizeLimit,
+		data:      make(map[string]storeValue),
+		sizes:     make(map[string]int),
+	}
+}
+
+// encodedSize returns byte size of the value when it is serialized.
+func encodedSize(v interface{}) (int, error) {
+	var buf bytes.Buffer
+	if err := codec.NewEncoder(&buf, storeCodecHandler).Encode(v); err != nil {
+		return 0, err
+	}
+	return buf.Len(), nil
+}
+
+// ensureLoaded loads data from the file at first access.
+func (s *kvStore) ensureLoaded() error {
+	if s.loaded {
+		return nil
+	}
+	return s.load()
+}
+
+// load discards current data and loads data from the file.
+// Not existing file is treated as empty data.
+// Current data is kept as is when loading fails.
+func (s *kvStore) load() error {
+	loaded := &kvStore{
+		path:      s.path,
+		sizeLimit: s.sizeLimit,
+		loaded:    true,
+		data:      make(map[string]storeValue),
+		sizes:     make(map[string]int),
+	}
+	if len(s.path) > 0 && filesystem.Exist(s.path) {
+		if err := loaded.decodeFile(); err != nil {
+			return err
+		}
+	}
+	*s = *loaded
+	return nil
+}
+
+// decodeFile reads the store file into s which must be empty.
+func (s *kvStore) decodeFile() error {
+	fp, err := filesystem.Load(s.path)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+
+	var content storeFile
+	if err := codec.NewDecoder(fp, storeCodecHandler).Decode(&content); err != nil {
+		return fmt.Errorf("store: failed to decode %s: %w", s.path, err)
+	}
+	if content.Format != storeFormatVersion {
+		return fmt.Errorf("store: unsupported format version %d in %s", content.Format, s.path)
+	}
+	s.version = content.Version
+	for key, v := range content.Data {
+		if err := s.put(key, v); err != nil {
+			return fmt.Errorf("store: %s: %w", s.path, err)
+		}
+	}
+	return nil
+}
+
+// save writes current data into the file.
+// The data is written into a temporary file first, then it replaces
+// the store file so that failure in writing does not break the existing file.
+// If the filesystem can not rename files, the data is written into the store file directly.
+func (s *kvStore) save() error {
+	if len(s.path) == 0 {
+		return errors.New("store: StoreDir is not configured")
+	}
+	var buf bytes.Buffer
+	if err := codec.NewEncoder(&buf, storeCodecHandler).Encode(&storeFile{
+		Format:  storeFormatVersion,
+		Version: s.version,
+		Data:    s.data,
+	}); err != nil {
+		return err
+	}
+
+	if !filesystem.CanRename() {
+		return writeFile(s.path, buf.Bytes())
+	}
+	tmpPath := s.path + ".tmp"
+	if err := writeFile(tmpPath, buf.Bytes()); err != nil {
+		filesystem.Remove(tmpPath)
+		return err
+	}
+	if err := filesystem.Rename(tmpPath, s.path); err != nil {
+		filesystem.Remove(tmpPath)
+		return fmt.Errorf("store: failed to replace %s: %w", s.path, err)
+	}
+	return nil
+}
+
+func writeFile(path string, data []byte) error {
+	fp, err := filesystem.Store(path)
+	if err != nil {
+		return err
+	}
+	if _, err := fp.Write(data); err != nil {
+		fp.Close()
+		return err
+	}
+	return fp.Close()
+}
+
+// put stores the value with the key. It returns error if the store exceeds its size limit.
+func (s *kvStore) put(key string, v storeValue) error {
+	size, err := encodedSize(&v)
+	if err != nil {
+		return err
+	}
+	size += len(key)
+	if used := s.used - s.sizes[key] + size; s.sizeLimit > 0 && used > s.sizeLimit {
+		return fmt.Errorf("size limit exceeded: %d bytes used, but limit is %d bytes", used, s.sizeLimit)
+	}
+	s.used += size - s.sizes[key]
+	s.sizes[key] = size
+	s.data[key] = v
+	return nil
+}
+
+func (s *kvStore) remove(key string) {
+	s.used -= s.sizes[key]
+	delete(s.sizes, key)
+	delete(s.data, key)
+}
+
+func (s *kvStore) keys() []string {
+	keys := make([]string, 0, len(s.data))
+	for k := range s.data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// toStoreValue converts Lua value into storeValue.
+// Only number, string, boolean and table containing these are allowed.
+func toStoreValue(lv lua.LValue, depth int) (storeValue, error) {
+	switch v := lv.(type) {
+	case lua.LNumber:
+		return storeValue{Kind: storeKindNumber, Num: float64(v)}, nil
+	case lua.LString:
+		return storeValue{Kind: storeKindString, Str: string(v)}, nil
+	case lua.LBool:
+		return storeValue{Kind: storeKindBool, Bool: bool(v)}, nil
+	case *lua.LTable:
+		if depth >= storeMaxDepth {
+			return storeValue{}, fmt.Errorf("table nesting is too deep, must be less than %d", storeMaxDepth)
+		}
+		entries := make([]storeEntry, 0, v.Len())
+		var err error
+		v.ForEach(func(lk, lv lua.LValue) {
+			if err != nil {
+				return
+			}
+			if lk.Type() != lua.LTNumber && lk.Type() != lua.LTString {
+				err = fmt.Errorf("table key must be number or string, but got %s", lk.Type())
+				return
+			}
+			var k, val storeValue
+			if k, err = toStoreValue(lk, depth+1); err != nil {
+				return
+			}
+			if val, err = toStoreValue(lv, depth+1); err != nil {
+				return
+			}
+			entries = append(entries, storeEntry{Key: k, Value: val})
+		})
+		if err != nil {
+			return storeValue{}, err
+		}
+		return storeValue{Kind: storeKindTable, Table: entries}, nil
+	default:
+		return storeValue{}, fmt.Errorf("%s can not be stored", lv.Type())
+	}
+}
+
+// toLValue converts storeValue into Lua value. Table is newly created.
+func (v storeValue) toLValue(L *lua.LState) lua.LValue {
+	switch v.Kind {
+	case storeKindNumber:
+		return lua.LNumber(v.Num)
+	case storeKindString:
+		return lua.LString(v.Str)
+	case storeKindBool:
+		return lua.LBool(v.Bool)
+	case storeKindTable:
+		tbl := L.CreateTable(0, len(v.Table))
+		for _, e := range v.Table {
+			tbl.RawSet(e.Key.toLValue(L), e.Value.toLValue(L))
+		}
+		return tbl
+	default:
+		return lua.LNil
+	}
+}
+
+func (ip *Interpreter) registerStoreModule(L *lua.LState) {
+	storeMod := L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"get":     ip.storeGet,
+		"set":     ip.storeSet,
+		"keys":    ip.storeKeys,
+		"clear":   ip.storeClear,
+		"save":    ip.storeSave,
+		"load":    ip.storeLoad,
+		"version": ip.storeVersion,
+		"size":    ip.storeSize,
+	})
+	L.SetMetatable(storeMod, getStrictTableMetatable(L))
+	ip.eraModule.RawSetString(eraStoreModName, storeMod)
+}
+
+// checkStore returns era.store data loaded from the file at first access.
+func (ip *Interpreter) checkStore(L *lua.LState) *kvStore {
+	if err := ip.store.ensureLoaded(); err != nil {
+		L.RaiseError("%v", err)
+	}
+	return ip.store
+}
+
+// +gendoc "Era Module"
+// * var era.store: store
+
+// +gendoc "Store Module"
+// * value: any = store.get(key: string, [default: any])
+//
+// get the value stored with the key. If the key is not found, default is returned.
+// Returned table is a copy, so modifying it does not affect the stored value until store.set is called.
+// store is a persistent key-value storage which is independent from save slots,
+// such as achievements, gallery unlocks and settings.
+// The data is loaded from the store file at first access.
+//
+// key に対応する値を取得します。key が見つからない場合は default を返します。
+// 返されるテーブルはコピーであり、store.set を呼ぶまで変更は保存される値に影響しません。
+// store はセーブスロットとは独立した永続的なキーバリューストアです。
+// 実績やギャラリーの解放状態、設定などの保存に使用します。
+// データは最初にアクセスした際にファイルから読み込まれます。
+//
+// Example:
+//
+//	local unlocked = era.store.get("gallery", {})
+//	unlocked[3] = true
+//	era.store.set("gallery", unlocked)
+//	era.store.save()
+func (ip *Interpreter) storeGet(L *lua.LState) int {
+	key := L.CheckString(1)
+	s := ip.checkStore(L)
+	if v, ok := s.data[key]; ok {
+		L.Push(v.toLValue(L))
+	} else {
+		L.Push(L.Get(2))
+	}
+	return 1
+}
+
+// +gendoc "Store Module"
+// * store.set(key: string, value: (number|string|boolean|table|nil))
+//
+// set the value with the key. Table can contain numbers, strings, booleans and nested tables,
+// and its keys must be numbers or strings. The value is copied when it is set.
+// nil removes the key. It raises error if total size of the stored data exceeds the size limit.
+// The change is not persisted until store.save is called.
+//
+// key に value を設定します。テーブルには数値、文字列、真偽値、およびそれらを含むテーブルを格納でき、
+// キーは数値か文字列である必要があります。value は設定時にコピーされます。
+// nil を設定すると key を削除します。保存されるデータの合計サイズが上限を超える場合はエラーとなります。
+// 変更は store.save を呼ぶまでファイルに保存されません。
+func (ip *Interpreter) storeSet(L *lua.LState) int {
+	key := L.CheckString(1)
+	lv := L.CheckAny(2)
+	s := ip.checkStore(L)
+	if lv == lua.LNil {
+		s.remove(key)
+		return 0
+	}
+	v, err := toStoreValue(lv, 0)
+	if err != nil {
+		L.ArgError(2, err.Error())
+	}
+	if err := s.put(key, v); err != nil {
+		L.ArgError(2, err.Error())
+	}
+	return 0
+}
+
+// +gendoc "Store Module"
+// * keys: string[] = store.keys()
+//
+// return stored keys in sorted order.
+//
+// 保存されているキーをソートして返します。
+func (ip *Interpreter) storeKeys(L *lua.LState) int {
+	s := ip.checkStore(L)
+	keys := s.keys()
+	tbl := L.CreateTable(len(keys), 0)
+	for _, k := range keys {
+		tbl.Append(lua.LString(k))
+	}
+	L.Push(tbl)
+	return 1
+}
+
+// +gendoc "Store Module"
+// * store.clear()
+//
+// remove all of stored keys. The data version is not changed.
+//
+// 保存されている全てのキーを削除します。データバージョンは変更されません。
+func (ip *Interpreter) storeClear(L *lua.LState) int {
+	s := ip.checkStore(L)
+	for _, k := range s.keys() {
+		s.remove(k)
+	}
+	return 0
+}
+
+// +gendoc "Store Module"
+// * store.save()
+//
+// write the stored data into the store file.
+//
+// 保存されているデータをファイルに書き込みます。
+func (ip *Interpreter) storeSave(L *lua.LState) int {
+	s := ip.checkStore(L)
+	if err := s.save(); err != nil {
+		L.RaiseError("%v", err)
+	}
+	return 0
+}
+
+// +gendoc "Store Module"
+// * store.load()
+//
+// discard unsaved changes and reload the data from the store file.
+//
+// 保存されていない変更を破棄し、ファイルからデータを再読み込みします。
+func (ip *Interpreter) storeLoad(L *lua.LState) int {
+	if err := ip.store.load(); err != nil {
+		L.RaiseError("%v", err)
+	}
+	return 0
+}
+
+// +gendoc "Store Module"
+// * version: integer = store.version([new_version: integer])
+//
+// get the data version of the store. If new_version is given, set it and return old one.
+// The data version is independent from save slots and game version,
+// so that user script can migrate the stored data when its layout is changed.
+// The default version is 0.
+//
+// store のデータバージョンを取得します。new_version が与えられた場合は設定し、古いバージョンを返します。
+// データバージョンはセーブスロットやゲームのバージョンとは独立しており、
+// 保存するデータの形式を変更した際にユーザースクリプトで移行処理を行うために使用します。
+// 既定値は 0 です。
+//
+// Example:
+//
+//	if era.store.version() < 2 then
+//	  era.store.set("settings", migrate(era.store.get("settings")))
+//	  era.store.version(2)
+//	end
+func (ip *Interpreter) storeVersion(L *lua.LState) int {
+	s := ip.checkStore(L)
+	old := s.version
+	if L.Get(1) != lua.LNil {
+		s.version = L.CheckInt64(1)
+	}
+	L.Push(lua.LNumber(old))
+	return 1
+}
+
+// +gendoc "Store Module"
+// * used: integer, limit: integer = store.size()
+//
+// return used bytes and limit bytes of the store. limit is 0 if not limited.
+//
+// store の使用バイト数と上限バイト数を返します。上限がない場合 limit は 0 です。
+func (ip *Interpreter) storeSize(L *lua.LState) int {
+	s := ip.checkStore(L)
+	L.Push(lua.LNumber(s.used))
+	L.Push(lua.LNumber(s.sizeLimit))
+	return 2
+}
@@ -0,0 +1,217 @@
+package script
+
+import (
+	"github.com/mzki/erago/state"
+	lua "github.com/yuin/gopher-lua"
+)
+
+const eraWatchModName = "watch"
+
+func (ip *Interpreter) registerWatchModule(L *lua.LState) {
+	watchMod := L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"var":      ip.watchVar,
+		"chara":    ip.watchChara,
+		"off":      ip.watchOff,
+		"clear":    ip.watchClear,
+		"dispatch": ip.watchDispatch,
+	})
+	L.SetMetatable(watchMod, getStrictTableMetatable(L))
+	ip.eraModule.RawSetString(eraWatchModName, watchMod)
+}
+
+// +gendoc "Era Module"
+// * var era.watch: watch
+
+// +gendoc "Watch Module"
+// * watch_id: integer = watch.var(var_name: string, handler: function(change: table))
+//
+// watch changes of the system or share variable var_name, and return id of the watch.
+// handler is called with the change for each changed index at the next safe point,
+// that is the scene transition, the end of each train command or watch.dispatch().
+// change is a table which has the fields, var, index, key, old, new and chara.
+// key is the name of index defined by csv, or empty string if not defined.
+// For multi-dimensional variable, index is the index of the flatten values
+// in row-major order and key is always empty string.
+// chara is nil for the system or share variable.
+// Values are compared at the safe point, so a value changed and restored
+// before that is not notified. Loading the game is not notified either.
+//
+// システム変数、または共有変数 var_name の変更を監視し、監視のIDを返します。
+// handler は次の安全な時点、つまりシーン遷移時、調教コマンドの実行ごと、
+// または watch.dispatch() の呼び出し時に、変更された添字ごとに呼び出されます。
+// change は var, index, key, old, new, chara のフィールドを持つテーブルです。
+// key は csv で定義された添字の名前で、定義されていない場合は空文字列です。
+// 多次元変数の場合、index は行優先で1次元に並べた値の添字で、key は常に空文字列です。
+// システム変数、共有変数の場合、chara は nil です。
+// 値は安全な時点で比較されるため、それまでに変更されて元に戻された値は通知されません。
+// ゲームのロードによる変更も通知されません。
+//
+// Example:
+//
+//	era.watch.var("Number", function(change)
+//	  era.printl(change.key .. ": " .. change.old .. " -> " .. change.new)
+//	end)
+func (ip *Interpreter) watchVar(L *lua.LState) int {
+	varname := L.CheckString(1)
+	fn := L.CheckFunction(2)
+	id, err := ip.state.Watch(varname)
+	if err != nil {
+		L.ArgError(1, err.Error())
+	}
+	ip.watchHandlers[id] = fn
+	L.Push(lua.LNumber(id))
+	return 1
+}
+
+// +gendoc "Watch Module"
+// * watch_id: integer = watch.chara(chara: Chara|nil, var_name: string, handler: function(change: table))
+//
+// watch changes of the character variable var_name, and return id of the watch.
+// If chara is nil, all of characters are watched, including characters added later.
+// The field chara of change is the changed character.
+// See watch.var() for details of the handler.
+//
+// キャラクター変数 var_name の変更を監視し、監視のIDを返します。
+// chara が nil の場合、後から追加されたキャラクターを含め、全てのキャラクターを監視します。
+// change のフィールド chara は変更されたキャラクターです。
+// handler の詳細は watch.var() を参照してください。
+//
+// Example:
+//
+//	era.watch.chara(nil, "Abl", function(change)
+//	  if change.key == "技巧" and change.old < 5 and change.new >= 5 then
+//	    change.chara.Talent["熟練"] = 1
+//	  end
+//	end)
+func (ip *Interpreter) watchChara(L *lua.LState) int {
+	var uid uint64 = 0
+	if L.Get(1) != lua.LNil {
+		uid = checkCharacter(L, 1).UID
+	}
+	varname := L.CheckString(2)
+	fn := L.CheckFunction(3)
+	id, err := ip.state.WatchChara(uid, varname)
+	if err != nil {
+		L.ArgError(2, err.Error())
+	}
+	ip.watchHandlers[id] = fn
+	L.Push(lua.LNumber(id))
+	return 1
+}
+
+// +gendoc "Watch Module"
+// * removed: boolean = watch.off(watch_id: integer)
+//
+// stop the watch specified by watch_id. It returns true if the watch is removed, otherwise false.
+//
+// watch_id で指定した監視を解除します。解除された場合は true を、そうでなければ false を返します。
+func (ip *Interpreter) watchOff(L *lua.LState) int {
+	id := L.CheckInt(1)
+	delete(ip.watchHandlers, id)
+	L.Push(lua.LBool(ip.state.Unwatch(id)))
+	return 1
+}
+
+// +gendoc "Watch Module"
+// * watch.clear()
+//
+// stop all of watches.
+//
+// 全ての監視を解除します。
+func (ip *Interpreter) watchClear(L *lua.LState) int {
+	ip.clearWatches()
+	return 0
+}
+
+func (ip *Interpreter) clearWatches() {
+	// clear in place since the handlers may be referred while dispatching.
+	for id := range ip.watchHandlers {
+		delete(ip.watchHandlers, id)
+	}
+	ip.state.ClearWatches()
+}
+
+// +gendoc "Watch Module"
+// * n_called: integer = watch.dispatch()
+//
+// notify changes until now to the handlers immediately, and return number of called handlers.
+// Error in a handler stops calling rest of handlers.
+//
+// 現時点までの変更を直ちにハンドラに通知し、呼び出されたハンドラの数を返します。
+// ハンドラ内でエラーが起きた場合、残りのハンドラは呼び出されません。
+func (ip *Interpreter) watchDispatch(L *lua.LState) int {
+	n, _ := ip.dispatchChanges(func(fn *lua.LFunction, change lua.LValue) error {
+		L.Push(fn)
+		L.Push(change)
+		L.Call(1, 0)
+		return nil
+	})
+	L.Push(lua.LNumber(n))
+	return 1
+}
+
+// EraDispatchChanges calls the watch handlers for changes of the watched
+// variables. It returns error of the first failed handler.
+// It implements scene.Scripter interface.
+func (ip Interpreter) EraDispatchChanges() error {
+	_, err := ip.dispatchChanges(func(fn *lua.LFunction, change lua.LValue) error {
+		err := ip.callByParam(fn, 0, change)
+		return ip.checkSpecialError(err)
+	})
+	return err
+}
+
+// dispatchChanges polls changes and calls the handler of each change by call.
+// It returns number of called handlers.
+func (ip Interpreter) dispatchChanges(call func(*lua.LFunction, lua.LValue) error) (int, error) {
+	if len(ip.watchHandlers) == 0 {
+		return 0, nil
+	}
+	n := 0
+	for _, change := range ip.state.PollChanges() {
+		// the handler may be removed by the other handler.
+		fn, ok := ip.watchHandlers[change.WatchID]
+		if !ok {
+			continue
+		}
+		if err := call(fn, ip.newChangeTable(change)); err != nil {
+			return n, err
+		}
+		n += 1
+	}
+	return n, nil
+}
+
+func (ip Interpreter) newChangeTable(change state.VarChange) *lua.LTable {
+	L := ip.vm
+	tbl := L.CreateTable(0, 6)
+	tbl.RawSetString("var", lua.LString(change.VarName))
+	tbl.RawSetString("index", lua.LNumber(change.Index))
+	key := ""
+	// index of multi-dimensional variable is one of the flatten values, which has no csv name.
+	if _, isMultiDim := ip.state.CSV.Shape(change.VarName); !isMultiDim {
+		if c, err := ip.state.CSV.Const(change.VarName); err == nil {
+			key = c.GetName(change.Index)
+		}
+	}
+	tbl.RawSetString("key", lua.LString(key))
+	for field, v := range map[string]interface{}{"old": change.Old, "new": change.New} {
+		switch v := v.(type) {
+		case int64:
+			tbl.RawSetString(field, lua.LNumber(v))
+		case string:
+			tbl.RawSetString(field, lua.LString(v))
+		case float64:
+			tbl.RawSetString(field, lua.LNumber(v))
+		case bool:
+			tbl.RawSetString(field, lua.LBool(v))
+		}
+	}
+	if change.CharaUID != 0 {
+		charas := ip.state.SystemData.Chara
+		if chara := charas.Get(charas.FindIndexByUID(change.CharaUID)); chara != nil {
+			tbl.RawSetString("chara", newLCharacter(L, charas, chara))
+		}
+	}
+	return tbl
+}
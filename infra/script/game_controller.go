@@ -1,6 +1,8 @@
 package script
 
 import (
+	"io"
+
 	"github.com/mzki/erago/scene"
 )
 
@@ -24,13 +26,32 @@ type GameController interface {
 	// get next scene name.
 	NextSceneName() string
 
+	// push current scene into the scene stack and set next scene specified by its name.
+	CallSceneByName(name string) error
+
+	// pop the scene from the scene stack and set it as next scene.
+	ReturnScene() error
+
+	// get scene names in the scene stack, ordered from bottom to top.
+	SceneStackNames() []string
+
 	// get current scene name.
 	CurrentSceneName() string
 
+	// get recent scene transitions ordered from oldest to newest.
+	TransitionHistory() []scene.Transition
+
+	// write the scene graph observed in scene transitions as Graphviz dot format.
+	WriteTransitionGraph(w io.Writer) error
+
 	// register new scene flow using its name and the function desclibeing its flow.
 	// next_func must return next scene name to move to next flow.
 	RegisterSceneFunc(name string, next_func func() (string, error))
 
+	// register new scene flow defined by script. The scene has start handling same as builtin scenes.
+	// next_func returns next scene name, or empty name to use next scene set by script.
+	RegisterScriptSceneFunc(name string, next_func func() (string, error)) error
+
 	// remove registered scene specified name.
 	UnRegisterScene(name string)
 }
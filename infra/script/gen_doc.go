@@ -82,11 +82,18 @@ var sortingOrder = []string{
 	"Era Module",
 	"Flow Module",
 	"Layout Module",
+	"Events Module",
+	"Store Module",
+	"Calendar Module",
+	"Watch Module",
 	"Lua Character",
 	"Characters",
 	"Reference Characters",
+	"Character Collections",
 	"IntParam",
 	"StrParam",
+	"FloatParam",
+	"BoolParam",
 	"CSV Names",
 	"CSV Index",
 	"CSV Fields",
@@ -795,7 +802,9 @@ const luaLSConfigJSONContent = `
         "Lua.diagnostics.globals" : [
             "era",
 			"IntParam",
-			"StrParam"
+			"StrParam",
+			"FloatParam",
+			"BoolParam"
         ]
     }
 }
@@ -30,6 +30,9 @@ type Interpreter struct {
 	game  GameController
 
 	customLoaders *customLoaders
+	events        *eventBus
+	store         *kvStore
+	watchHandlers map[int]*lua.LFunction
 	taskQueue     *ipTaskQueue
 	watchDogTimer *watchDogTimer
 
@@ -51,6 +54,9 @@ func NewInterpreter(s *state.GameState, g GameController, config Config) *Interp
 		state:         s,
 		game:          g,
 		customLoaders: newCustomLoaders(config.ReloadFileChange),
+		events:        newEventBus(),
+		store:         newKVStore(config),
+		watchHandlers: make(map[int]*lua.LFunction, 4),
 		taskQueue:     newIpTaskQueue(),
 		watchDogTimer: newWatchDogTimer(
 			time.Duration(config.InfiniteLoopTimeoutSecond) * time.Second),
@@ -100,6 +106,10 @@ func (ip *Interpreter) init() {
 	}
 
 	ip.eraModule = ip.registerEraModule(L, ip.state, ip.game)
+	ip.registerEventsModule(L)
+	ip.registerStoreModule(L)
+	ip.registerCalendarModule(L)
+	ip.registerWatchModule(L)
 	registerSystemParams(L, ip.state)
 	registerCsvParams(L, ip.state.CSV)
 	registerCharaParams(L, ip.state)
@@ -157,6 +167,7 @@ func (ip *Interpreter) Quit() {
 	ip.customLoaders.Unregister(ip.vm)
 	ip.vm.Close()
 	ip.watchDogTimer.Quit()
+	ip.clearWatches()
 	ip.game = nil
 	ip.state = nil
 }
@@ -245,9 +256,22 @@ type LoadPatternNotFoundError = os.PathError
 
 // load all files matched to config pattern.
 // it is used for loading user scirpts under specified directory.
+// Plugins under config.PluginDir are loaded before user scripts, and
+// their exported callbacks are installed after user scripts are loaded.
 // If any files not found to be loaded, it returns LoadPattenNotFoundError.
 // And other cases in failure, It returns arbitrary error type.
 func (ip Interpreter) LoadSystem() error {
+	plugins, err := ip.loadPlugins()
+	if err != nil {
+		return fmt.Errorf("failed to load plugins: %w", err)
+	}
+	if err := ip.loadUserScripts(); err != nil {
+		return err
+	}
+	return ip.installPluginCallbacks(plugins)
+}
+
+func (ip Interpreter) loadUserScripts() error {
 	path := ip.config.loadPattern()
 	if err := validateScriptPath(path, ip.config.LoadDir); err != nil {
 		return fmt.Errorf("got invalid script LoadDir and LoadPattern: %w", err)
@@ -308,6 +332,15 @@ func (ip Interpreter) HasEraValue(vname string) bool {
 	return lua.LVAsBool(ip.getEraValue(vname))
 }
 
+// EraReloadParams re-registers the game state variables, which may be
+// changed its structure by loading outside of the script.
+// It implements scene.Scripter interface.
+func (ip Interpreter) EraReloadParams() error {
+	registerSystemParams(ip.vm, ip.state)
+	registerCharaParams(ip.vm, ip.state)
+	return nil
+}
+
 // call funtion vname in era module.
 func (ip Interpreter) EraCall(vname string) error {
 	fn := ip.getEraValue(vname)
@@ -366,3 +399,25 @@ func (ip Interpreter) EraCallBoolArgInt(func_name string, arg int64) (bool, erro
 	ip.vm.Pop(1)
 	return lua.LVAsBool(ret), nil
 }
+
+// call funtion vname in era module with arguments int64,
+// and return an integer value.
+// It returns error if the function does not return a number.
+func (ip Interpreter) EraCallIntArgInts(func_name string, args ...int64) (int64, error) {
+	fn := ip.getEraValue(func_name)
+	largs := make([]lua.LValue, 0, len(args))
+	for _, arg := range args {
+		largs = append(largs, lua.LNumber(arg))
+	}
+	err := ip.callByParam(fn, 1, largs...)
+	if err = ip.checkSpecialError(err); err != nil {
+		return 0, err
+	}
+	ret := ip.vm.Get(-1)
+	ip.vm.Pop(1)
+	n, ok := ret.(lua.LNumber)
+	if !ok {
+		return 0, fmt.Errorf("era.%s must return a number, but got %s", func_name, ret.Type())
+	}
+	return int64(n), nil
+}
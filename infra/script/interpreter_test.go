@@ -4,13 +4,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/mzki/erago/filesystem"
 	"github.com/mzki/erago/scene"
+	"github.com/mzki/erago/state"
+	"github.com/mzki/erago/state/csv"
 	"github.com/mzki/erago/stub"
 	lua "github.com/yuin/gopher-lua"
 )
@@ -45,12 +49,29 @@ func newInterpreter() *Interpreter {
 	return newInterpreterWithConf(newConfig())
 }
 
+// gameController implements GameController by the stub with empty
+// scene transition history.
+type gameController struct {
+	*stub.ScriptGameController
+}
+
+func newGameController() gameController {
+	return gameController{stub.NewScriptGameController()}
+}
+
+func (gameController) TransitionHistory() []scene.Transition { return []scene.Transition{} }
+
+func (gameController) WriteTransitionGraph(w io.Writer) error {
+	_, err := io.WriteString(w, "digraph scenes {\n}\n")
+	return err
+}
+
 func newInterpreterWithConf(conf Config) *Interpreter {
 	state, err := stub.GetGameState()
 	if err != nil {
 		panic(err)
 	}
-	return NewInterpreter(state, stub.NewScriptGameController(), conf)
+	return NewInterpreter(state, newGameController(), conf)
 }
 
 func newOlderDataInterpreter() *Interpreter {
@@ -58,7 +79,7 @@ func newOlderDataInterpreter() *Interpreter {
 	if err != nil {
 		panic(err)
 	}
-	return NewInterpreter(state, stub.NewScriptGameController(), newConfig())
+	return NewInterpreter(state, newGameController(), newConfig())
 }
 
 func newInterpreterAndInputQueuer() (*Interpreter, InputQueuer) {
@@ -66,8 +87,8 @@ func newInterpreterAndInputQueuer() (*Interpreter, InputQueuer) {
 	if err != nil {
 		panic(err)
 	}
-	ctrlr := stub.NewScriptGameController()
-	inputQ := stub.GetInputQueue(ctrlr)
+	ctrlr := newGameController()
+	inputQ := stub.GetInputQueue(ctrlr.ScriptGameController)
 	return NewInterpreter(state, ctrlr, newConfig()), inputQ
 }
 
@@ -92,6 +113,8 @@ func TestInterpreterDoFiles(t *testing.T) {
 		"lua_function.lua",
 		"pairs.lua",
 		"era_types.lua",
+		"era_events.lua",
+		"era_chara_params.lua",
 	} {
 		if err := ip.DoFile(filepath.Join(scriptDir, file)); err != nil {
 			t.Error(err)
@@ -361,6 +384,43 @@ func TestInterpreterEraCall(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
+
+	n, err := ip.EraCallIntArgInts("testing_int", 6, 7)
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 42 {
+		t.Errorf("invalid return value, expect: 42, got: %v", n)
+	}
+	if _, err := ip.EraCallIntArgInts("testing_int_invalid"); err == nil {
+		t.Error("non number return value should be error")
+	}
+}
+
+func TestInterpreterEraEmit(t *testing.T) {
+	ip := newInterpreter()
+	defer ip.Quit()
+
+	// no handler is OK.
+	if err := ip.EraEmit("turnend_event_start"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ip.DoString(`
+		era.events.on("shop_event_buy_item", function(item) emitted_item = item end)
+		era.events.on("goto_event", function() era.flow.gotoNextScene("title") end)
+	`); err != nil {
+		t.Fatal(err)
+	}
+	if err := ip.EraEmit("shop_event_buy_item", 3); err != nil {
+		t.Fatal(err)
+	}
+	if got := ip.vm.GetGlobal("emitted_item"); got != lua.LNumber(3) {
+		t.Errorf("handler should receive emitted argument, expect: 3, got: %v", got)
+	}
+	if err := ip.EraEmit("goto_event"); !errors.Is(err, scene.ErrorSceneNext) {
+		t.Errorf("special error in handler should be propagated, got: %v", err)
+	}
 }
 
 func TestInterpreterSpecialErrors(t *testing.T) {
@@ -559,7 +619,7 @@ func newInterpreterWithInputError() *Interpreter {
 	if err != nil {
 		panic(err)
 	}
-	ctrlr := stub.NewScriptGameController()
+	ctrlr := newGameController()
 	return NewInterpreter(state, &gameControllerWithInputError{ctrlr}, newConfig())
 }
 
@@ -656,3 +716,654 @@ func BenchmarkScriptWithContext(b *testing.B) {
 		}
 	}
 }
+
+func TestInterpreterStore(t *testing.T) {
+	conf := newConfig()
+	conf.StoreDir = t.TempDir()
+	conf.StoreSizeLimit = 256
+
+	ip := newInterpreterWithConf(conf)
+	defer ip.Quit()
+	if err := ip.DoString(`
+		era.store.set("achievements", {first_clear = true, count = 3, list = {"a", "b", [10] = {x = 1}}})
+		era.store.set("volume", 0.5)
+		assert(era.store.version(2) == 0)
+		era.store.save()
+	`); err != nil {
+		t.Fatal(err)
+	}
+	if err := ip.DoString(`era.store.set("large", string.rep("x", 512))`); err == nil {
+		t.Error("store should fail when exceeding size limit")
+	}
+
+	// persisted data is loaded by another interpreter.
+	ip2 := newInterpreterWithConf(conf)
+	defer ip2.Quit()
+	if err := ip2.DoString(`
+		local s = era.store
+		assert(s.version() == 2)
+		local keys = s.keys()
+		assert(#keys == 2 and keys[1] == "achievements" and keys[2] == "volume")
+		local a = s.get("achievements")
+		assert(a.first_clear == true and a.count == 3)
+		assert(a.list[1] == "a" and a.list[2] == "b" and a.list[10].x == 1)
+		assert(s.get("volume") == 0.5)
+		assert(s.get("not_found", 10) == 10)
+
+		-- returned value is a copy.
+		a.count = 100
+		assert(s.get("achievements").count == 3)
+
+		-- unsaved changes are discarded by load.
+		s.set("volume", nil)
+		assert(s.get("volume") == nil)
+		s.load()
+		assert(s.get("volume") == 0.5)
+
+		assert(not pcall(s.set, "func", function() end))
+		assert(not pcall(s.set, "bad_key", {[true] = 1}))
+		local used, limit = s.size()
+		assert(used > 0 and limit == 256)
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	// broken file does not discard current data.
+	storePath := filepath.Join(conf.StoreDir, StoreFileName)
+	if _, err := os.Stat(storePath + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file should be removed after save, got %v", err)
+	}
+	if err := os.WriteFile(storePath, []byte("broken"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ip2.DoString(`
+		local s = era.store
+		assert(not pcall(s.load))
+		assert(s.version() == 2)
+		assert(s.get("volume") == 0.5)
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	// temporary file is removed even if replacing the store file fails.
+	if err := os.Remove(storePath); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(storePath, "not_empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ip2.DoString(`local ok, err = pcall(era.store.save)
+		assert(not ok and string.find(err, "failed to replace"), err)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(storePath + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file should be removed after failure, got %v", err)
+	}
+}
+
+// sceneRegisterRecorder records scenes registered by script.
+type sceneRegisterRecorder struct {
+	GameController
+	scenes map[string]func() (string, error)
+}
+
+func (r *sceneRegisterRecorder) RegisterScriptSceneFunc(name string, next_func func() (string, error)) error {
+	r.scenes[name] = next_func
+	return nil
+}
+
+func TestInterpreterRegisterScene(t *testing.T) {
+	state, err := stub.GetGameState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := &sceneRegisterRecorder{
+		GameController: newGameController(),
+		scenes:         make(map[string]func() (string, error)),
+	}
+	ip := NewInterpreter(state, recorder, newConfig())
+	defer ip.Quit()
+
+	if err := ip.DoString(`
+		era.flow.registerScene("menu", { next = function() return "base" end })
+		era.flow.registerScene("menu_nil", { next = function() return nil end })
+		era.flow.registerScene("menu_goto", { next = function() era.flow.gotoNextScene("title") end })
+		era.flow.registerScene("menu_invalid", { next = function() return 1 end })
+		assert(not pcall(era.flow.registerScene, "no_next", {}))
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, testcase := range []struct {
+		name   string
+		next   string
+		hasErr bool
+	}{
+		{"menu", "base", false},
+		{"menu_nil", "", false},
+		{"menu_goto", "", true},
+		{"menu_invalid", "", true},
+	} {
+		next_func, ok := recorder.scenes[testcase.name]
+		if !ok {
+			t.Errorf("scene %s is not registered", testcase.name)
+			continue
+		}
+		next, err := next_func()
+		if testcase.hasErr != (err != nil) {
+			t.Errorf("%s: unexpected error state, expect error: %v, got: %v", testcase.name, testcase.hasErr, err)
+		}
+		if next != testcase.next {
+			t.Errorf("%s: invalid next scene, expect: %v, got: %v", testcase.name, testcase.next, next)
+		}
+	}
+	if _, err := recorder.scenes["menu_goto"](); !errors.Is(err, scene.ErrorSceneNext) {
+		t.Errorf("gotoNextScene in next function should be ErrorSceneNext, got: %v", err)
+	}
+}
+
+// transitionHistoryController has fixed scene transition history.
+type transitionHistoryController struct {
+	GameController
+	transitions []scene.Transition
+}
+
+func (c *transitionHistoryController) TransitionHistory() []scene.Transition {
+	return c.transitions
+}
+
+func (c *transitionHistoryController) WriteTransitionGraph(w io.Writer) error {
+	_, err := io.WriteString(w, "digraph scenes {\n}\n")
+	return err
+}
+
+func TestInterpreterFlowHistory(t *testing.T) {
+	state, err := stub.GetGameState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	controller := &transitionHistoryController{
+		GameController: newGameController(),
+		transitions: []scene.Transition{
+			{From: "title", To: "newgame", Cause: scene.TransitionCauseCallback, Time: time.Unix(100, 0)},
+			{From: "newgame", To: "menu", Cause: scene.TransitionCauseFlowAPI, Detail: "setNextScene", Time: time.Unix(200, 0)},
+		},
+	}
+	ip := NewInterpreter(state, controller, newConfig())
+	defer ip.Quit()
+
+	if err := ip.DoString(`
+		local h = era.flow.history()
+		assert(#h == 2)
+		assert(h[1].from == "title" and h[1].to == "newgame" and h[1].cause == "callback" and h[1].detail == "")
+		assert(h[2].cause == "flow_api" and h[2].detail == "setNextScene" and h[2].time == 200)
+		assert(string.find(era.flow.historyGraph(), "digraph", 1, true))
+	`); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInterpreterCalendar(t *testing.T) {
+	state, err := stub.GetGameState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cal := state.Calendar()
+	defer cal.SetTime(cal.Time())
+
+	ip := NewInterpreter(state, newGameController(), newConfig())
+	defer ip.Quit()
+
+	if err := ip.DoString(`
+		era.calendar.setTime(3)
+		local ids = era.calendar.advance()
+		assert(#ids == 1 and era.calendar.eventName(ids[1]) == "朝礼")
+		local date = era.calendar.now()
+		assert(date.time == 4 and date.day == 1 and date.slot == 0 and date.slot_name == "朝")
+		assert(date.weekday_name == "火" and date.season_name == "春" and date.day_of_season == 1)
+		assert(#era.calendar.timeSlots() == 4)
+		assert(not pcall(era.calendar.eventName, 100))
+	`); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInterpreterRewind(t *testing.T) {
+	state, err := stub.GetGameState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer state.ClearSnapshots()
+	money, _ := state.SystemData.GetInt(csv.BuiltinMoneyName)
+	defer money.Set(0, money.Get(0))
+
+	ip := NewInterpreter(state, newGameController(), newConfig())
+	defer ip.Quit()
+
+	if err := ip.DoString(`
+		assert(era.rewind() == nil)
+		era.system.Money[0] = 100
+		era.snapshot("before")
+		era.system.Money[0] = 0
+		era.snapshot()
+		local labels = era.snapshotLabels()
+		assert(#labels == 2 and labels[1] == "before" and labels[2] == "script")
+		assert(era.rewind(2) == "before")
+		assert(era.system.Money[0] == 100)
+		assert(#era.snapshotLabels() == 1)
+	`); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInterpreterWatch(t *testing.T) {
+	ip := newInterpreter()
+	defer ip.Quit()
+
+	number, _ := ip.state.SystemData.GetInt("Number")
+	defer number.Set(0, number.Get(0))
+	chara, err := ip.state.SystemData.Chara.AddID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ip.state.SystemData.Chara.Remove(ip.state.SystemData.Chara.FindIndexByUID(chara.UID))
+
+	// no handler is OK.
+	if err := ip.EraDispatchChanges(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ip.DoString(`
+		changes = {}
+		era.watch.var("Number", function(change)
+			table.insert(changes, change.var .. ":" .. change.old .. "->" .. change.new)
+		end)
+		local charaWatch = era.watch.chara(era.chara[#era.chara-1], "Base", function(change)
+			assert(change.chara.uid == era.chara[#era.chara-1].uid)
+			table.insert(changes, change.key .. ":" .. change.old .. "->" .. change.new)
+		end)
+		era.system.Number[0] = 10
+		era.chara[#era.chara-1].Base[0] = 1
+		assert(era.watch.dispatch() == 2)
+		assert(changes[1] == "Number:0->10", changes[1])
+		assert(changes[2] == era.csv.Base[0] .. ":2000->1", changes[2])
+
+		assert(era.watch.off(charaWatch))
+		assert(not era.watch.off(charaWatch))
+		era.chara[#era.chara-1].Base[0] = 2
+		era.system.Number[0] = 20
+	`); err != nil {
+		t.Fatal(err)
+	}
+	if err := ip.EraDispatchChanges(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ip.DoString(`
+		assert(#changes == 3)
+		assert(changes[3] == "Number:10->20", changes[3])
+		era.watch.clear()
+		era.system.Number[0] = 30
+		assert(era.watch.dispatch() == 0)
+	`); err != nil {
+		t.Fatal(err)
+	}
+	if err := ip.DoString(`era.watch.var("Base", function() end)`); err == nil {
+		t.Error("watching character variable by watch.var should be error")
+	}
+}
+
+func TestInterpreterCharaHandle(t *testing.T) {
+	ip := newInterpreter()
+	defer ip.Quit()
+
+	sysdata := ip.state.SystemData
+	nCharas := sysdata.Chara.Len()
+	savedUIDs := append([]uint64(nil), sysdata.Target.UIDs...)
+	defer func() {
+		copy(sysdata.Target.UIDs, savedUIDs)
+		for sysdata.Chara.Len() > nCharas {
+			sysdata.Chara.Remove(sysdata.Chara.Len() - 1)
+		}
+	}()
+	sysdata.Target.Clear()
+
+	if err := ip.DoString(`
+		assert(era.target[0] == nil)
+		local first = era.chara:add(1)
+		local second = era.chara:add(1)
+		second.name = "second"
+		era.target[0] = second
+
+		-- remove other character, but second is still valid.
+		era.chara:remove(#era.chara-2)
+		assert(second.name == "second")
+		assert(era.chara[#era.chara-1].uid == second.uid)
+		assert(era.target[0].uid == second.uid)
+
+		era.chara:remove(#era.chara-1)
+		local ok, msg = pcall(function() return second.name end)
+		assert(not ok and string.find(msg, "removed"), msg)
+		ok, msg = pcall(function() return era.target[0] end)
+		assert(not ok and string.find(msg, "removed"), msg)
+		for _, c in pairs(era.target) do
+			assert(c.uid ~= second.uid)
+		end
+	`); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInterpreterCharaQuery(t *testing.T) {
+	shared, err := stub.GetGameState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// use new state to start with empty character list.
+	gamestate := state.NewGameState(shared.CSV, nil)
+	ip := NewInterpreter(gamestate, newGameController(), newConfig())
+	defer ip.Quit()
+
+	if err := ip.DoString(`
+		for i, hp in ipairs {100, 300, 200, 300} do
+			local c = era.chara:add(1)
+			c.Base["体力"] = hp
+			c.name = "c" .. i
+		end
+
+		local charas = era.chara:query{where = "Base.体力 >= 200", order_by = {"-Base.体力", "name"}, limit = 2}
+		assert(#charas == 2)
+		assert(charas[1].name == "c2" and charas[2].name == "c4")
+		assert(#era.chara:query{} == 4)
+
+		assert(era.chara:count("Base.体力 > 100", 'name != "c2"') == 2)
+
+		local groups = era.chara:group("Base.体力")
+		assert(#groups == 3)
+		assert(groups[1].value == 100 and #groups[1].charas == 1)
+		assert(groups[3].value == 300 and #groups[3].charas == 2)
+
+		local first = era.chara[0]
+		era.chara:sort("-Base.体力")
+		assert(era.chara[0].name == "c2")
+		assert(era.chara[3].name == "c1")
+		assert(first.name == "c1")
+
+		assert(not pcall(function() era.chara:query{where = "Unknown >= 1"} end))
+		assert(not pcall(function() era.chara:sort() end))
+	`); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInterpreterCharaCollection(t *testing.T) {
+	shared, err := stub.GetGameState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// use new state to start with empty character list.
+	gamestate := state.NewGameState(shared.CSV, nil)
+	ip := NewInterpreter(gamestate, newGameController(), newConfig())
+	defer ip.Quit()
+
+	if err := ip.DoString(`
+		local party = era.collections.Party
+		for i, hp in ipairs {100, 300, 200, 300, 400} do
+			local c = era.chara:add(1)
+			c.Base["体力"] = hp
+			c.name = "c" .. i
+		end
+		party:add(era.chara[0], era.chara[1], era.chara[2])
+		party:add(era.chara[0]) -- already added
+		assert(#party == 3)
+		assert(party[1].name == "c2")
+		assert(party:has(era.chara[2]) and not party:has(era.chara[3]))
+		assert(party:indexof(era.chara[2]) == 2)
+
+		party:add(era.chara[3])
+		assert(not pcall(function() party:add(era.chara[4]) end)) -- full
+
+		local n = 0
+		for i, c in pairs(party) do
+			assert(c.name == "c" .. (i+1))
+			n = n + 1
+		end
+		assert(n == 4)
+
+		assert(party:count("Base.体力 >= 300") == 2)
+		assert(#party:query{where = "Base.体力 >= 200", limit = 1} == 1)
+		assert(#party:group("Base.体力") == 3)
+		party:sort("-Base.体力", "name")
+		assert(party[0].name == "c2" and party[3].name == "c1")
+		assert(era.chara[0].name == "c1")
+
+		assert(party:remove(era.chara[1]))
+		assert(party:remove(0))
+		assert(not party:remove(10))
+		assert(#party == 2)
+		assert(#era.chara == 5)
+
+		-- removed character from era.chara is removed from the collection too.
+		era.chara:remove(2)
+		assert(#party == 1 and party[0].name == "c1")
+
+		-- a character can belong to multiple collections.
+		era.collections.Prison:add(era.chara[0])
+		assert(party:has(era.chara[0]) and era.collections.Prison:has(era.chara[0]))
+		party:clear()
+		assert(#party == 0 and #era.collections.Prison == 1)
+
+		assert(not pcall(function() return era.collections.Unknown end))
+	`); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInterpreterDeclaredCharaReferences(t *testing.T) {
+	shared, err := stub.GetGameState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gamestate := state.NewGameState(shared.CSV, nil)
+	ip := NewInterpreter(gamestate, newGameController(), newConfig())
+	defer ip.Quit()
+
+	if err := ip.DoString(`
+		-- declared in VariableSpec.csv as "CharaRef, , Rival, , 3"
+		assert(#era.rival == 3)
+		assert(#era.target == #era.master)
+		local c = era.chara:add(1)
+		era.rival[2] = c
+		assert(era.rival[2].uid == c.uid)
+		-- reference not set refers the first character.
+		assert(era.rival[0].uid == era.chara[0].uid)
+		assert(not pcall(function() return era.rival[3] end))
+	`); err != nil {
+		t.Fatal(err)
+	}
+	rival, _ := gamestate.SystemData.Reference("Rival")
+	if got := rival.GetChara(2); got == nil || got != gamestate.SystemData.Chara.Get(0) {
+		t.Errorf("declared reference is not set by script, got: %v", rival.UIDs)
+	}
+}
+
+func TestInterpreterMultiDimParams(t *testing.T) {
+	shared, err := stub.GetGameState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gamestate := state.NewGameState(shared.CSV, nil)
+	ip := NewInterpreter(gamestate, newGameController(), newConfig())
+	defer ip.Quit()
+
+	if err := ip.DoString(`
+		-- declared in VariableSpec.csv as "Chara, Int, BaseHistory, :Base.csv, 3, "
+		local c = era.chara:add(1)
+		local history = c.BaseHistory
+		assert(#history == 3)
+		local dims = history:dims()
+		assert(dims[1] == 3 and dims[2] == #c.Base)
+		assert(history[2]["気力"] == 5)
+		assert(history[2][1] == 5)
+		history[1]["体力"] = 100
+		assert(history:get(1):get("体力") == 100)
+		assert(not pcall(function() history[0] = 1 end))
+		assert(not pcall(function() return history[3] end))
+		assert(not pcall(function() history:assign({[0] = 1}) end))
+
+		local n = 0
+		for i, sub in ipairs(history) do
+			assert(#sub == dims[2])
+			n = n + 1
+		end
+		assert(n == 3)
+
+		-- declared in VariableSpec.csv as "System, Str, Memo, Number.csv:Base.csv, , "
+		era.system.Memo[0]["体力"] = "memo"
+		assert(era.system.Memo[0][0] == "memo")
+	`); err != nil {
+		t.Fatal(err)
+	}
+	c := gamestate.SystemData.Chara.Get(0)
+	history, _ := c.GetInt("BaseHistory")
+	if v := history.Sub(1).Get(0); v != 100 {
+		t.Errorf("multi-dimensional value is not set by script, got: %v", v)
+	}
+}
+
+func TestInterpreterFloatBoolParams(t *testing.T) {
+	shared, err := stub.GetGameState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gamestate := state.NewGameState(shared.CSV, nil)
+	ip := NewInterpreter(gamestate, newGameController(), newConfig())
+	defer ip.Quit()
+
+	if err := ip.DoString(`
+		-- declared in VariableSpec.csv as "Chara, Float, BaseRate, Base.csv, "
+		local c = era.chara:add(1)
+		assert(c.BaseRate["気力"] == 0.5)
+		c.BaseRate["体力"] = 0.25
+		assert(c.BaseRate[0] == 0.25)
+		assert(not pcall(function() c.BaseRate[0] = "a" end))
+		c.BaseRate:assign({["体力"] = 1.5})
+		assert(c.BaseRate:get("体力") == 1.5)
+
+		-- declared in VariableSpec.csv as "Chara, Bool, Known, , 2"
+		assert(#c.Known == 2)
+		assert(c.Known[0] == false and c.Known[1] == true)
+		c.Known[0] = true
+		assert(not pcall(function() c.Known[0] = 1 end))
+		local n = 0
+		for i, v in ipairs(c.Known) do
+			assert(v == true)
+			n = n + 1
+		end
+		assert(n == 2)
+
+		era.system.Difficulty["数値１"] = 1.75
+		era.share.Cleared:fill(true)
+		assert(era.share.Cleared[0] == true)
+
+		local bp = BoolParam.new(3)
+		bp[2] = true
+		assert(bp[1] == false and bp[2] == true)
+		local fp = FloatParam.new(2)
+		assert(fp[0] == 0)
+
+		-- non-finite values are rejected.
+		assert(not pcall(function() fp[0] = 0/0 end))
+		assert(not pcall(function() fp:set(0, 1/0) end))
+		assert(not pcall(function() fp:fill(-1/0) end))
+		assert(not pcall(function() fp:assign({[0] = 1, [1] = 0/0}) end))
+		assert(fp[0] == 0 and fp[1] == 0)
+	`); err != nil {
+		t.Fatal(err)
+	}
+	c := gamestate.SystemData.Chara.Get(0)
+	if known, _ := c.GetBool("Known"); !known.Get(0) {
+		t.Errorf("bool value is not set by script, got: %v", known.Values)
+	}
+	if difficulty, _ := gamestate.SystemData.GetFloat("Difficulty"); difficulty.Get(0) != 1.75 {
+		t.Errorf("float value is not set by script, got: %v", difficulty.Values)
+	}
+}
+
+func TestInterpreterIntParamBounds(t *testing.T) {
+	shared, err := stub.GetGameState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gamestate := state.NewGameState(shared.CSV, nil)
+	ip := NewInterpreter(gamestate, newGameController(), newConfig())
+	defer ip.Quit()
+
+	// Base and Stain are bounded by _VariableBounds.csv.
+	if err := ip.DoString(`
+		local c = era.chara:add(1)
+		c.Base[0] = -100
+		assert(c.Base[0] == 0)
+		c.Base:fill(-1)
+		assert(c.Base[1] == 0)
+
+		assert(c.Stain[0] == 10)
+		assert(not pcall(function() c.Stain[0] = 101 end))
+		assert(not pcall(function() c.Stain:set(0, -1) end))
+		assert(not pcall(function() c.Stain:fill(1000) end))
+		assert(not pcall(function() c.Stain:assign({[0] = 20, [1] = 200}) end))
+		assert(c.Stain[0] == 10, "no value should be assigned")
+		c.Stain[0] = 100
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	err = ip.DoString(`
+		local c = era.chara[0]
+		c.Stain[1] = 101 -- line 3
+	`)
+	if err == nil {
+		t.Fatal("out of range should be error")
+	}
+	for _, expect := range []string{"Stain[1", "out of range", "<string>:3"} {
+		if !strings.Contains(err.Error(), expect) {
+			t.Errorf("error message should contain %q, got: %v", expect, err)
+		}
+	}
+}
+
+func TestInterpreterDerivedVariables(t *testing.T) {
+	shared, err := stub.GetGameState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gamestate := state.NewGameState(shared.CSV, nil)
+	ip := NewInterpreter(gamestate, newGameController(), newConfig())
+	defer ip.Quit()
+
+	// ParamLv and NumberDouble are declared in _DerivedVariables.csv.
+	if err := ip.DoString(`
+		local c = era.chara:add(1)
+		c.Param[1] = 600
+		assert(c.ParamLv[1] == era.paramlv(600))
+		assert(#c.ParamLv == #c.Param)
+		c.Param[1] = 0
+		assert(c.ParamLv[1] == era.paramlv(0))
+		assert(not pcall(function() c.ParamLv[0] = 1 end))
+		assert(not pcall(function() c.ParamLv:fill(1) end))
+
+		era.system.Number[0] = 21
+		assert(era.system.NumberDouble[0] == 42)
+		assert(not pcall(function() return era.system.Unknown end))
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	err = ip.DoString(`
+		era.chara[0].ParamLv[0] = 1 -- line 2
+	`)
+	for _, expect := range []string{"ParamLv", "read-only", "<string>:2"} {
+		if err == nil || !strings.Contains(err.Error(), expect) {
+			t.Errorf("error message should contain %q, got: %v", expect, err)
+		}
+	}
+}
@@ -0,0 +1,650 @@
+package script
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/mzki/erago/filesystem"
+	"github.com/mzki/erago/scene"
+	"github.com/mzki/erago/state/csv"
+	"github.com/mzki/erago/util/strutil"
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/ast"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+// LintIssue is a problem in the script found by static analysis.
+type LintIssue struct {
+	File    string
+	Line    int
+	Rule    string
+	Message string
+}
+
+func (issue LintIssue) String() string {
+	return fmt.Sprintf("%s:%d: %s [%s]", issue.File, issue.Line, issue.Message, issue.Rule)
+}
+
+// Rule names for LintIssue.
+const (
+	LintRuleSyntax          = "syntax"
+	LintRuleGlobalAssign    = "global-assign"
+	LintRuleUnknownEra      = "unknown-era"
+	LintRuleCallbackName    = "callback-name"
+	LintRuleUnknownVariable = "unknown-variable"
+	LintRuleUnreachable     = "unreachable"
+)
+
+// Lint parses given script files and reports problems without running these.
+// It detects:
+//
+//   - assignment to undeclared global variable.
+//   - access to undefined member of era module, such as era.prnt().
+//   - misspelled scene callback, such as era.titel_scene.
+//   - unknown variable name of era.system, era.share, era.csv and characters.
+//   - unreachable code after return, break, error() and scene transition.
+//
+// Members of era module defined in any of the files are treated as defined.
+// The returned issues are sorted by file and line.
+func (ip *Interpreter) Lint(files []string) ([]LintIssue, error) {
+	type parsed struct {
+		file  string
+		chunk []ast.Stmt
+	}
+
+	lt := newLinter(ip)
+	chunks := make([]parsed, 0, len(files))
+	for _, file := range files {
+		chunk, err := parseFileFromFS(file)
+		var perr *parse.Error
+		if errors.As(err, &perr) {
+			lt.issues = append(lt.issues, LintIssue{
+				File:    file,
+				Line:    perr.Pos.Line,
+				Rule:    LintRuleSyntax,
+				Message: strings.TrimSpace(perr.Message),
+			})
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, parsed{file, chunk})
+	}
+
+	// first, collect era members defined by scripts.
+	for _, p := range chunks {
+		lt.collectEraDefinitions(p.chunk)
+	}
+	for _, p := range chunks {
+		lt.lintChunk(p.file, p.chunk)
+	}
+
+	sort.SliceStable(lt.issues, func(i, j int) bool {
+		a, b := lt.issues[i], lt.issues[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	return lt.issues, nil
+}
+
+func parseFileFromFS(file string) ([]ast.Stmt, error) {
+	fp, err := filesystem.Load(file)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	return parseChunk(fp, file)
+}
+
+func parseChunk(r io.Reader, name string) (chunk []ast.Stmt, err error) {
+	// parser may panic for some broken input.
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = &parse.Error{Pos: ast.Position{Source: name}, Message: fmt.Sprint(rec)}
+		}
+	}()
+	return parse.Parse(r, name)
+}
+
+// names of modules whose members are variables rather than functions.
+var lintVariableModules = map[string]bool{
+	systemParamsModuleName: true,
+	sharedParamsModuleName: true,
+	csvModuleName:          true,
+	csvIndexModuleName:     true,
+	csvFieldsModuleName:    true,
+}
+
+// names of modules whose members are unknown until running scripts.
+var lintOpenModules = map[string]bool{
+	pluginsModuleName: true,
+}
+
+// call of these functions never returns.
+var lintNoReturnFuncs = map[string]bool{
+	"error": true,
+	EraModuleName + "." + eraFlowModName + ".quit":          true,
+	EraModuleName + "." + eraFlowModName + ".longReturn":    true,
+	EraModuleName + "." + eraFlowModName + ".gotoNextScene": true,
+	EraModuleName + "." + eraFlowModName + ".callScene":     true,
+	EraModuleName + "." + eraFlowModName + ".returnScene":   true,
+}
+
+type linter struct {
+	globals     map[string]bool
+	eraMembers  map[string]map[string]bool // member name -> its keys if table, or nil.
+	eraDefined  map[string]bool            // era members defined by scripts.
+	callbacks   map[string]bool
+	sceneNames  map[string]bool
+	charaFields map[string]bool
+	// names of era members which hold characters by indexing.
+	charaContainers map[string]bool
+
+	file   string
+	scopes []map[string]bool
+	issues []LintIssue
+}
+
+func newLinter(ip *Interpreter) *linter {
+	lt := &linter{
+		globals:     make(map[string]bool),
+		eraMembers:  make(map[string]map[string]bool),
+		eraDefined:  make(map[string]bool),
+		callbacks:   make(map[string]bool),
+		sceneNames:  make(map[string]bool),
+		charaFields: make(map[string]bool),
+		charaContainers: map[string]bool{
+			luaCharaListName: true,
+		},
+	}
+
+	ip.vm.G.Global.ForEach(func(k, _ lua.LValue) {
+		lt.globals[lua.LVAsString(k)] = true
+	})
+	ip.eraModule.ForEach(func(k, v lua.LValue) {
+		var keys map[string]bool
+		if tbl, ok := v.(*lua.LTable); ok {
+			keys = make(map[string]bool)
+			tbl.ForEach(func(kk, _ lua.LValue) {
+				keys[lua.LVAsString(kk)] = true
+			})
+		}
+		lt.eraMembers[lua.LVAsString(k)] = keys
+		// references are declared in csv.
+		if ud, ok := v.(*lua.LUserData); ok && isCharaReferences(ud) {
+			lt.charaContainers[lua.LVAsString(k)] = true
+		}
+	})
+
+	for _, name := range scene.CallbackNames() {
+		lt.callbacks[name] = true
+		lt.sceneNames[strings.SplitN(name, scene.ScrSep, 2)[0]] = true
+	}
+
+	for _, field := range []string{
+		characterFieldIDName,
+		characterFieldUIDName,
+		characterFieldIsAssiName,
+		characterFieldNameName,
+		characterFieldMasterNameName,
+		characterFieldNickNameName,
+		characterFieldCallNameName,
+	} {
+		lt.charaFields[field] = true
+	}
+	if ip.state != nil && ip.state.CSV != nil {
+		for _, specs := range [][]csv.VariableSpec{
+			ip.state.CSV.IntVariableSpecs(csv.ScopeChara),
+			ip.state.CSV.StrVariableSpecs(csv.ScopeChara),
+			ip.state.CSV.FloatVariableSpecs(csv.ScopeChara),
+			ip.state.CSV.BoolVariableSpecs(csv.ScopeChara),
+		} {
+			for _, spec := range specs {
+				lt.charaFields[spec.VarName] = true
+			}
+		}
+		for vname := range ip.state.CSV.DerivedVariables(csv.ScopeChara) {
+			lt.charaFields[vname] = true
+		}
+	}
+	return lt
+}
+
+func (lt *linter) report(line int, rule string, format string, args ...interface{}) {
+	lt.issues = append(lt.issues, LintIssue{
+		File:    lt.file,
+		Line:    line,
+		Rule:    rule,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func suggestion(name string, candidates map[string]bool) string {
+	list := make([]string, 0, len(candidates))
+	for c := range candidates {
+		list = append(list, c)
+	}
+	sort.Strings(list) // to suggest stable result.
+	if nearest, ok := strutil.Nearest(name, list, strutil.SuggestDist(name)); ok {
+		return fmt.Sprintf(", did you mean %q?", nearest)
+	}
+	return ""
+}
+
+// ---- pass 1: collecting era definitions ----
+
+func (lt *linter) collectEraDefinitions(stmts []ast.Stmt) {
+	for _, stmt := range stmts {
+		switch s := stmt.(type) {
+		case *ast.AssignStmt:
+			for _, lhs := range s.Lhs {
+				if name, ok := eraMemberName(lhs); ok {
+					lt.eraDefined[name] = true
+				}
+			}
+			lt.collectEraDefinitionsExprs(s.Rhs)
+		case *ast.LocalAssignStmt:
+			lt.collectEraDefinitionsExprs(s.Exprs)
+		case *ast.FuncDefStmt:
+			if s.Name.Func != nil {
+				if name, ok := eraMemberName(s.Name.Func); ok {
+					lt.eraDefined[name] = true
+				}
+			}
+			lt.collectEraDefinitions(s.Func.Stmts)
+		case *ast.FuncCallStmt:
+			lt.collectEraDefinitionsExprs([]ast.Expr{s.Expr})
+		case *ast.DoBlockStmt:
+			lt.collectEraDefinitions(s.Stmts)
+		case *ast.WhileStmt:
+			lt.collectEraDefinitions(s.Stmts)
+		case *ast.RepeatStmt:
+			lt.collectEraDefinitions(s.Stmts)
+		case *ast.IfStmt:
+			lt.collectEraDefinitions(s.Then)
+			lt.collectEraDefinitions(s.Else)
+		case *ast.NumberForStmt:
+			lt.collectEraDefinitions(s.Stmts)
+		case *ast.GenericForStmt:
+			lt.collectEraDefinitions(s.Stmts)
+		}
+	}
+}
+
+// search function bodies in the expressions.
+func (lt *linter) collectEraDefinitionsExprs(exprs []ast.Expr) {
+	for _, expr := range exprs {
+		switch e := expr.(type) {
+		case *ast.FunctionExpr:
+			lt.collectEraDefinitions(e.Stmts)
+		case *ast.FuncCallExpr:
+			lt.collectEraDefinitionsExprs(e.Args)
+		case *ast.TableExpr:
+			for _, f := range e.Fields {
+				lt.collectEraDefinitionsExprs([]ast.Expr{f.Value})
+			}
+		}
+	}
+}
+
+// return X for expression era.X
+func eraMemberName(expr ast.Expr) (string, bool) {
+	attr, ok := expr.(*ast.AttrGetExpr)
+	if !ok {
+		return "", false
+	}
+	obj, ok := attr.Object.(*ast.IdentExpr)
+	if !ok || obj.Value != EraModuleName {
+		return "", false
+	}
+	key, ok := attr.Key.(*ast.StringExpr)
+	if !ok {
+		return "", false
+	}
+	return key.Value, true
+}
+
+// ---- pass 2: linting ----
+
+func (lt *linter) lintChunk(file string, chunk []ast.Stmt) {
+	lt.file = file
+	lt.scopes = lt.scopes[:0]
+	lt.lintBlock(chunk)
+}
+
+func (lt *linter) pushScope()          { lt.scopes = append(lt.scopes, make(map[string]bool)) }
+func (lt *linter) popScope()           { lt.scopes = lt.scopes[:len(lt.scopes)-1] }
+func (lt *linter) declare(name string) { lt.scopes[len(lt.scopes)-1][name] = true }
+
+func (lt *linter) isLocal(name string) bool {
+	for i := len(lt.scopes) - 1; i >= 0; i-- {
+		if lt.scopes[i][name] {
+			return true
+		}
+	}
+	return false
+}
+
+// whether the identifier refers era module, not shadowed by local variable.
+func (lt *linter) isEraIdent(expr ast.Expr) bool {
+	ident, ok := expr.(*ast.IdentExpr)
+	return ok && ident.Value == EraModuleName && !lt.isLocal(EraModuleName)
+}
+
+func (lt *linter) lintBlock(stmts []ast.Stmt) {
+	lt.pushScope()
+	defer lt.popScope()
+	lt.lintStmts(stmts)
+}
+
+func (lt *linter) lintStmts(stmts []ast.Stmt) {
+	for i, stmt := range stmts {
+		lt.lintStmt(stmt)
+		if lt.terminates(stmt) && i+1 < len(stmts) {
+			lt.report(stmts[i+1].Line(), LintRuleUnreachable, "unreachable code")
+			// lint rest statements anyway.
+			for _, rest := range stmts[i+1:] {
+				lt.lintStmt(rest)
+			}
+			return
+		}
+	}
+}
+
+func (lt *linter) lintStmt(stmt ast.Stmt) {
+	switch s := stmt.(type) {
+	case *ast.AssignStmt:
+		lt.lintExprs(s.Rhs)
+		for _, lhs := range s.Lhs {
+			lt.lintAssignTarget(lhs, s.Line())
+		}
+	case *ast.LocalAssignStmt:
+		if len(s.Names) == 1 && len(s.Exprs) == 1 {
+			if _, ok := s.Exprs[0].(*ast.FunctionExpr); ok {
+				// local function f() can refer itself.
+				lt.declare(s.Names[0])
+			}
+		}
+		lt.lintExprs(s.Exprs)
+		for _, name := range s.Names {
+			lt.declare(name)
+		}
+	case *ast.FuncCallStmt:
+		lt.lintExpr(s.Expr)
+	case *ast.DoBlockStmt:
+		lt.lintBlock(s.Stmts)
+	case *ast.WhileStmt:
+		lt.lintExpr(s.Condition)
+		if _, ok := s.Condition.(*ast.FalseExpr); ok && len(s.Stmts) > 0 {
+			lt.report(s.Stmts[0].Line(), LintRuleUnreachable, "unreachable code in while false")
+		}
+		lt.lintBlock(s.Stmts)
+	case *ast.RepeatStmt:
+		lt.pushScope()
+		lt.lintStmts(s.Stmts)
+		lt.lintExpr(s.Condition)
+		lt.popScope()
+	case *ast.IfStmt:
+		lt.lintExpr(s.Condition)
+		switch s.Condition.(type) {
+		case *ast.FalseExpr, *ast.NilExpr:
+			if len(s.Then) > 0 {
+				lt.report(s.Then[0].Line(), LintRuleUnreachable, "unreachable code in if false")
+			}
+		}
+		lt.lintBlock(s.Then)
+		lt.lintBlock(s.Else)
+	case *ast.NumberForStmt:
+		lt.lintExpr(s.Init)
+		lt.lintExpr(s.Limit)
+		if s.Step != nil {
+			lt.lintExpr(s.Step)
+		}
+		lt.pushScope()
+		lt.declare(s.Name)
+		lt.lintBlock(s.Stmts)
+		lt.popScope()
+	case *ast.GenericForStmt:
+		lt.lintExprs(s.Exprs)
+		lt.pushScope()
+		for _, name := range s.Names {
+			lt.declare(name)
+		}
+		lt.lintBlock(s.Stmts)
+		lt.popScope()
+	case *ast.FuncDefStmt:
+		if s.Name.Func != nil {
+			lt.lintAssignTarget(s.Name.Func, s.Line())
+		} else if s.Name.Receiver != nil {
+			lt.lintExpr(s.Name.Receiver)
+		}
+		lt.lintFunction(s.Func, s.Name.Receiver != nil)
+	case *ast.ReturnStmt:
+		lt.lintExprs(s.Exprs)
+	}
+}
+
+// check assignment target.
+func (lt *linter) lintAssignTarget(lhs ast.Expr, line int) {
+	switch e := lhs.(type) {
+	case *ast.IdentExpr:
+		if !lt.isLocal(e.Value) && !lt.globals[e.Value] {
+			lt.report(line, LintRuleGlobalAssign, "assignment to undeclared global variable %q, use local instead", e.Value)
+		}
+	case *ast.AttrGetExpr:
+		if lt.isEraIdent(e.Object) {
+			if name, ok := e.Key.(*ast.StringExpr); ok {
+				lt.lintCallbackName(name.Value, line)
+				return
+			}
+		}
+		lt.lintExpr(e)
+	default:
+		lt.lintExpr(e)
+	}
+}
+
+// check era.name is a valid callback name, if it looks like a callback.
+func (lt *linter) lintCallbackName(name string, line int) {
+	if _, builtin := lt.eraMembers[name]; builtin || lt.callbacks[name] {
+		return
+	}
+	parts := strings.Split(name, scene.ScrSep)
+	looksCallback := lt.sceneNames[parts[0]] && len(parts) >= 2
+	if len(parts) >= 2 {
+		switch parts[1] {
+		case scene.ScrEventPrefix, scene.ScrScenePrefix, scene.ScrReplacePrefix, scene.ScrUserPrefix:
+			looksCallback = true
+		}
+	}
+	if sug := suggestion(name, lt.callbacks); looksCallback || len(sug) > 0 {
+		lt.report(line, LintRuleCallbackName, "era.%s is not a scene callback%s", name, sug)
+	}
+}
+
+func (lt *linter) lintFunction(fn *ast.FunctionExpr, isMethod bool) {
+	lt.pushScope()
+	defer lt.popScope()
+	if isMethod {
+		lt.declare("self")
+	}
+	if fn.ParList != nil {
+		for _, name := range fn.ParList.Names {
+			lt.declare(name)
+		}
+	}
+	lt.lintStmts(fn.Stmts)
+}
+
+func (lt *linter) lintExprs(exprs []ast.Expr) {
+	for _, expr := range exprs {
+		lt.lintExpr(expr)
+	}
+}
+
+func (lt *linter) lintExpr(expr ast.Expr) {
+	switch e := expr.(type) {
+	case *ast.AttrGetExpr:
+		lt.lintAttrGet(e)
+	case *ast.TableExpr:
+		for _, f := range e.Fields {
+			if f.Key != nil {
+				lt.lintExpr(f.Key)
+			}
+			lt.lintExpr(f.Value)
+		}
+	case *ast.FuncCallExpr:
+		if e.Func != nil {
+			lt.lintExpr(e.Func)
+		}
+		if e.Receiver != nil {
+			lt.lintExpr(e.Receiver)
+		}
+		lt.lintExprs(e.Args)
+	case *ast.LogicalOpExpr:
+		lt.lintExpr(e.Lhs)
+		lt.lintExpr(e.Rhs)
+	case *ast.RelationalOpExpr:
+		lt.lintExpr(e.Lhs)
+		lt.lintExpr(e.Rhs)
+	case *ast.StringConcatOpExpr:
+		lt.lintExpr(e.Lhs)
+		lt.lintExpr(e.Rhs)
+	case *ast.ArithmeticOpExpr:
+		lt.lintExpr(e.Lhs)
+		lt.lintExpr(e.Rhs)
+	case *ast.UnaryMinusOpExpr:
+		lt.lintExpr(e.Expr)
+	case *ast.UnaryNotOpExpr:
+		lt.lintExpr(e.Expr)
+	case *ast.UnaryLenOpExpr:
+		lt.lintExpr(e.Expr)
+	case *ast.FunctionExpr:
+		lt.lintFunction(e, false)
+	}
+}
+
+func (lt *linter) lintAttrGet(e *ast.AttrGetExpr) {
+	lt.lintExpr(e.Object)
+	lt.lintExpr(e.Key)
+
+	key, ok := e.Key.(*ast.StringExpr)
+	if !ok {
+		return
+	}
+
+	// era.X
+	if lt.isEraIdent(e.Object) {
+		if _, ok := lt.eraMembers[key.Value]; ok || lt.eraDefined[key.Value] {
+			return
+		}
+		candidates := make(map[string]bool, len(lt.eraMembers)+len(lt.eraDefined))
+		for k := range lt.eraMembers {
+			candidates[k] = true
+		}
+		for k := range lt.eraDefined {
+			candidates[k] = true
+		}
+		lt.report(e.Line(), LintRuleUnknownEra, "era.%s is not defined%s", key.Value, suggestion(key.Value, candidates))
+		return
+	}
+
+	parent, ok := e.Object.(*ast.AttrGetExpr)
+	if !ok || !lt.isEraIdent(parent.Object) {
+		// era.container[i].X
+		if idx, ok := e.Object.(*ast.AttrGetExpr); ok {
+			if container, ok := idx.Object.(*ast.AttrGetExpr); ok && lt.isEraIdent(container.Object) {
+				if _, isStr := idx.Key.(*ast.StringExpr); !isStr {
+					lt.lintCharaField(container, key.Value, e.Line())
+				}
+			}
+		}
+		return
+	}
+
+	// era.M.X
+	modName, ok := parent.Key.(*ast.StringExpr)
+	if !ok || lintOpenModules[modName.Value] {
+		return
+	}
+	keys := lt.eraMembers[modName.Value]
+	if keys == nil || keys[key.Value] {
+		// not a table or found.
+		return
+	}
+	if lintVariableModules[modName.Value] {
+		lt.report(e.Line(), LintRuleUnknownVariable, "variable %q is not defined in era.%s%s",
+			key.Value, modName.Value, suggestion(key.Value, keys))
+	} else {
+		lt.report(e.Line(), LintRuleUnknownEra, "era.%s.%s is not defined%s",
+			modName.Value, key.Value, suggestion(key.Value, keys))
+	}
+}
+
+// check X of era.container[i].X
+func (lt *linter) lintCharaField(container *ast.AttrGetExpr, field string, line int) {
+	name, ok := container.Key.(*ast.StringExpr)
+	if !ok || !lt.charaContainers[name.Value] {
+		return
+	}
+	if lt.charaFields[field] {
+		return
+	}
+	lt.report(line, LintRuleUnknownVariable, "character variable %q is not defined%s",
+		field, suggestion(field, lt.charaFields))
+}
+
+// whether the statement never continues to the next statement.
+// goto is not treated as terminating since the label may follow it,
+// as like `goto continue` idiom.
+func (lt *linter) terminates(stmt ast.Stmt) bool {
+	switch s := stmt.(type) {
+	case *ast.ReturnStmt, *ast.BreakStmt:
+		return true
+	case *ast.FuncCallStmt:
+		call, ok := s.Expr.(*ast.FuncCallExpr)
+		if !ok || call.Func == nil {
+			return false
+		}
+		return lintNoReturnFuncs[lt.exprPath(call.Func)]
+	case *ast.DoBlockStmt:
+		return lt.terminatesBlock(s.Stmts)
+	case *ast.IfStmt:
+		return len(s.Else) > 0 && lt.terminatesBlock(s.Then) && lt.terminatesBlock(s.Else)
+	}
+	return false
+}
+
+func (lt *linter) terminatesBlock(stmts []ast.Stmt) bool {
+	for _, stmt := range stmts {
+		if lt.terminates(stmt) {
+			return true
+		}
+	}
+	return false
+}
+
+// return dotted path such as "era.flow.quit" for the expression, or empty string.
+func (lt *linter) exprPath(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.IdentExpr:
+		if lt.isLocal(e.Value) {
+			return ""
+		}
+		return e.Value
+	case *ast.AttrGetExpr:
+		key, ok := e.Key.(*ast.StringExpr)
+		if !ok {
+			return ""
+		}
+		if parent := lt.exprPath(e.Object); len(parent) > 0 {
+			return parent + "." + key.Value
+		}
+	}
+	return ""
+}
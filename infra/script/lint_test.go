@@ -0,0 +1,56 @@
+package script
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestInterpreterLint(t *testing.T) {
+	ip := newInterpreter()
+	defer ip.Quit()
+
+	lintDir := filepath.Join(scriptDir, "lint")
+	issues, err := ip.Lint([]string{
+		filepath.Join(lintDir, "ok.lua"),
+		filepath.Join(lintDir, "bad.lua"),
+		filepath.Join(lintDir, "syntax_error.lua"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type issueKey struct {
+		File string
+		Line int
+		Rule string
+	}
+	badFile := filepath.Join(lintDir, "bad.lua")
+	expects := []issueKey{
+		{badFile, 2, LintRuleGlobalAssign},
+		{badFile, 4, LintRuleCallbackName},
+		{badFile, 5, LintRuleUnknownEra},
+		{badFile, 8, LintRuleCallbackName},
+		{badFile, 9, LintRuleUnknownEra},
+		{badFile, 13, LintRuleUnknownVariable},
+		{badFile, 14, LintRuleUnknownVariable},
+		{badFile, 16, LintRuleUnreachable},
+		{badFile, 25, LintRuleUnreachable},
+		{filepath.Join(lintDir, "syntax_error.lua"), 0, LintRuleSyntax},
+	}
+	for _, issue := range issues {
+		t.Log(issue)
+	}
+	if len(issues) != len(expects) {
+		t.Fatalf("different number of issues, expect: %d, got: %d", len(expects), len(issues))
+	}
+	for i, expect := range expects {
+		issue := issues[i]
+		got := issueKey{issue.File, issue.Line, issue.Rule}
+		if expect.Rule == LintRuleSyntax {
+			got.Line = 0 // line for EOF is not stable.
+		}
+		if got != expect {
+			t.Errorf("issue[%d]: expect: %v, got: %v", i, expect, issue)
+		}
+	}
+}
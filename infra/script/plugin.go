@@ -0,0 +1,370 @@
+package script
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mzki/erago/filesystem"
+	"github.com/mzki/erago/infra/serialize/toml"
+	"github.com/mzki/erago/util/errutil"
+	"github.com/mzki/erago/util/log"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// Plugin directory convention:
+//
+//	<LoadDir>/<PluginDir>/<plugin-name>/plugin.toml  -- manifest
+//	<LoadDir>/<PluginDir>/<plugin-name>/init.lua     -- entry module
+//
+// Entry module must return a table which contains the functions
+// listed in the manifest's callbacks. These are accessible from
+// era.plugins.<plugin-name>.<callback> and are also installed into
+// era.<callback> when user script does not define it.
+const (
+	PluginManifestFile = "plugin.toml"
+	PluginEntry        = "init"
+
+	pluginsModuleName = "plugins"
+)
+
+// +gendoc.set_section "Era Module"
+
+// +gendoc
+// * var era.plugins: table
+// plugins holds tables returned by the entry module of the loaded plugins, keyed by plugin name.
+// Plugins are loaded in dependency order before init.lua, so that dependencies are
+// accessible from dependent plugins and user scripts.
+// Exported callbacks declared in plugin.toml are installed into era module
+// only when user script does not define it.
+//
+// plugins は読み込まれたプラグインのエントリーモジュールが返すテーブルを、プラグイン名をキーとして保持します。
+// プラグインは依存関係の順に init.lua より先に読み込まれるため、依存先のプラグインやユーザースクリプトから参照できます。
+// plugin.toml で宣言されたコールバックは、ユーザースクリプトが同名のコールバックを定義していない場合のみ era モジュールに登録されます。
+//
+// Example:
+//  era.title_scene = function()
+//    era.plugins.shop_ext.title_scene() -- call plugin's callback explicitly.
+//  end
+
+// PluginManifest is a description of a plugin, loaded from plugin.toml.
+//
+// Example:
+//
+//	name = "shop_ext"
+//	version = "1.2.0"
+//	callbacks = ["shop_event_buy_item"]
+//	[dependencies]
+//	base_lib = ">=1.0, <2.0"
+type PluginManifest struct {
+	Name         string            `toml:"name"`
+	Version      string            `toml:"version"`
+	Entry        string            `toml:"entry"`
+	Dependencies map[string]string `toml:"dependencies"`
+	Callbacks    []string          `toml:"callbacks"`
+
+	dir string // plugin directory relative to LoadDir.
+}
+
+func (m PluginManifest) entryModule() string {
+	entry := m.Entry
+	if len(entry) == 0 {
+		entry = PluginEntry
+	}
+	entry = strings.TrimSuffix(entry, ".lua")
+	modpath := filepath.Join(m.dir, entry)
+	return strings.ReplaceAll(modpath, string(filepath.Separator), ".")
+}
+
+// pluginVersion is a dot separated version number, such as 1.2.3.
+// Omitted parts are treated as 0.
+type pluginVersion [3]int
+
+func parsePluginVersion(s string) (pluginVersion, error) {
+	var v pluginVersion
+	s = strings.TrimSpace(s)
+	if len(s) == 0 {
+		return v, fmt.Errorf("empty version")
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) > len(v) {
+		return v, fmt.Errorf("too many version parts in %q", s)
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid version %q", s)
+		}
+		v[i] = n
+	}
+	return v, nil
+}
+
+func (v pluginVersion) compare(other pluginVersion) int {
+	for i := range v {
+		if v[i] != other[i] {
+			if v[i] < other[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func (v pluginVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", v[0], v[1], v[2])
+}
+
+// satisfyVersion checks whether version v satisfies the constraint.
+// The constraint is comma separated conditions, each of which is
+// "<op><version>" where op is one of >=, <=, >, <, = or empty (exact match).
+// Empty constraint accepts any version.
+func satisfyVersion(v pluginVersion, constraint string) (bool, error) {
+	for _, cond := range strings.Split(constraint, ",") {
+		cond = strings.TrimSpace(cond)
+		if len(cond) == 0 || cond == "*" {
+			continue
+		}
+		var op string
+		for _, candidate := range []string{">=", "<=", "==", ">", "<", "="} {
+			if strings.HasPrefix(cond, candidate) {
+				op = candidate
+				break
+			}
+		}
+		want, err := parsePluginVersion(strings.TrimPrefix(cond, op))
+		if err != nil {
+			return false, err
+		}
+		cmp := v.compare(want)
+		var ok bool
+		switch op {
+		case ">=":
+			ok = cmp >= 0
+		case "<=":
+			ok = cmp <= 0
+		case ">":
+			ok = cmp > 0
+		case "<":
+			ok = cmp < 0
+		default:
+			ok = cmp == 0
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// resolvePluginOrder validates manifests and returns these sorted by dependency order,
+// so that dependencies are loaded before dependents.
+// All of the missing dependencies, version conflicts and cyclic dependencies
+// are reported at once.
+func resolvePluginOrder(manifests []*PluginManifest) ([]*PluginManifest, error) {
+	merr := errutil.NewMultiError()
+	byName := make(map[string]*PluginManifest, len(manifests))
+	versions := make(map[string]pluginVersion, len(manifests))
+	for _, m := range manifests {
+		if len(m.Name) == 0 {
+			merr.Add(fmt.Errorf("plugin %s: empty name", m.dir))
+			continue
+		}
+		if other, ok := byName[m.Name]; ok {
+			merr.Add(fmt.Errorf("plugin %s: duplicate name in %s and %s", m.Name, other.dir, m.dir))
+			continue
+		}
+		v, err := parsePluginVersion(m.Version)
+		if err != nil {
+			merr.Add(fmt.Errorf("plugin %s: %w", m.Name, err))
+			continue
+		}
+		byName[m.Name] = m
+		versions[m.Name] = v
+	}
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names) // to load in stable order.
+
+	for _, name := range names {
+		m := byName[name]
+		for dep, constraint := range m.Dependencies {
+			depV, ok := versions[dep]
+			if !ok {
+				merr.Add(fmt.Errorf("plugin %s: dependency %s is not found", name, dep))
+				continue
+			}
+			ok, err := satisfyVersion(depV, constraint)
+			if err != nil {
+				merr.Add(fmt.Errorf("plugin %s: invalid version constraint for %s: %w", name, dep, err))
+			} else if !ok {
+				merr.Add(fmt.Errorf("plugin %s: version conflict, requires %s %s but %s is found", name, dep, constraint, depV))
+			}
+		}
+	}
+	if err := merr.Err(); err != nil {
+		return nil, err
+	}
+
+	// topological sort by depth first search.
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	marks := make(map[string]int, len(names))
+	ordered := make([]*PluginManifest, 0, len(names))
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch marks[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("plugin %s: cyclic dependency %s", name, strings.Join(append(path, name), " -> "))
+		}
+		marks[name] = visiting
+		m := byName[name]
+		deps := make([]string, 0, len(m.Dependencies))
+		for dep := range m.Dependencies {
+			deps = append(deps, dep)
+		}
+		sort.Strings(deps)
+		for _, dep := range deps {
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		marks[name] = visited
+		ordered = append(ordered, m)
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// findPlugins searches plugin manifests under config.PluginDir.
+// It returns empty list if plugin directory is not configured or not exist.
+func (ip Interpreter) findPlugins() ([]*PluginManifest, error) {
+	if len(ip.config.PluginDir) == 0 {
+		return nil, nil
+	}
+	pattern := filepath.Join(ip.config.LoadDir, ip.config.PluginDir, "*", PluginManifestFile)
+	if err := validateScriptPath(pattern, ip.config.LoadDir); err != nil {
+		return nil, fmt.Errorf("got invalid script PluginDir: %w", err)
+	}
+	files, err := filesystem.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	trimFiles, err := trimBaseDirPath(ip.config.LoadDir, files)
+	if err != nil {
+		return nil, fmt.Errorf("failed to triming base dir %s: %w", ip.config.LoadDir, err)
+	}
+
+	manifests := make([]*PluginManifest, 0, len(files))
+	for i, file := range files {
+		m := &PluginManifest{}
+		if err := toml.DecodeFile(file, m); err != nil {
+			return nil, fmt.Errorf("plugin manifest %s: %w", file, err)
+		}
+		m.dir = filepath.Dir(trimFiles[i])
+		manifests = append(manifests, m)
+	}
+	return manifests, nil
+}
+
+// loadPlugins loads plugins in dependency order and registers these into era.plugins.
+// It returns loaded plugins.
+func (ip Interpreter) loadPlugins() ([]*PluginManifest, error) {
+	manifests, err := ip.findPlugins()
+	if err != nil {
+		return nil, err
+	}
+	ordered, err := resolvePluginOrder(manifests)
+	if err != nil {
+		return nil, err
+	}
+
+	L := ip.vm
+	pluginsMod := ip.getPluginsModule()
+	for _, m := range ordered {
+		log.Debugf("ScriptLoader: Load plugin %s (%s)", m.Name, m.Version)
+		if err := ip.callByParam(L.GetGlobal("require"), 1, lua.LString(m.entryModule())); err != nil {
+			if err = ip.checkSpecialError(err); err != nil {
+				return nil, fmt.Errorf("plugin %s: %w", m.Name, err)
+			}
+			return nil, fmt.Errorf("plugin %s: interrupted while loading", m.Name)
+		}
+		ret := L.Get(-1)
+		L.Pop(1)
+
+		exports, ok := ret.(*lua.LTable)
+		if !ok {
+			if len(m.Callbacks) > 0 {
+				return nil, fmt.Errorf("plugin %s: entry module must return a table to export callbacks", m.Name)
+			}
+			exports = L.NewTable()
+		}
+		for _, cb := range m.Callbacks {
+			if _, ok := exports.RawGetString(cb).(*lua.LFunction); !ok {
+				return nil, fmt.Errorf("plugin %s: exported callback %s is not a function", m.Name, cb)
+			}
+		}
+		pluginsMod.RawSetString(m.Name, exports)
+	}
+	return ordered, nil
+}
+
+// installPluginCallbacks installs exported callbacks of the plugins into era module
+// if user script does not define these.
+// It is error that several plugins export same callback which is not defined by user script.
+func (ip Interpreter) installPluginCallbacks(plugins []*PluginManifest) error {
+	pluginsMod := ip.getPluginsModule()
+	exporters := make(map[string][]string)
+	order := make([]string, 0)
+	for _, m := range plugins {
+		for _, cb := range m.Callbacks {
+			if _, ok := exporters[cb]; !ok {
+				order = append(order, cb)
+			}
+			exporters[cb] = append(exporters[cb], m.Name)
+		}
+	}
+
+	merr := errutil.NewMultiError()
+	for _, cb := range order {
+		if ip.eraModule.RawGetString(cb) != lua.LNil {
+			// user script takes precedence. plugin callbacks are still accessible by era.plugins.
+			continue
+		}
+		names := exporters[cb]
+		if len(names) > 1 {
+			merr.Add(fmt.Errorf("callback %s is exported by several plugins: %s. Define era.%s to resolve it",
+				cb, strings.Join(names, ", "), cb))
+			continue
+		}
+		exports := pluginsMod.RawGetString(names[0]).(*lua.LTable)
+		ip.eraModule.RawSetString(cb, exports.RawGetString(cb))
+	}
+	return merr.Err()
+}
+
+func (ip Interpreter) getPluginsModule() *lua.LTable {
+	if mod, ok := ip.eraModule.RawGetString(pluginsModuleName).(*lua.LTable); ok {
+		return mod
+	}
+	mod := ip.vm.NewTable()
+	ip.vm.SetMetatable(mod, getStrictTableMetatable(ip.vm))
+	ip.eraModule.RawSetString(pluginsModuleName, mod)
+	return mod
+}
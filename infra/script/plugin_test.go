@@ -0,0 +1,137 @@
+package script
+
+import (
+	"strings"
+	"testing"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+func newPluginTestConfig(loadPattern string) Config {
+	conf := newConfig()
+	conf.LoadPattern = loadPattern
+	conf.PluginDir = PluginDir
+	return conf
+}
+
+func TestInterpreterLoadSystemWithPlugins(t *testing.T) {
+	ip := newInterpreterWithConf(newPluginTestConfig("plugin_init.lua"))
+	defer ip.Quit()
+
+	if err := ip.LoadSystem(); err != nil {
+		t.Fatalf("Failed to LoadSystem: %v", err)
+	}
+
+	for _, name := range []string{"base_lib", "shop_ext"} {
+		if _, ok := ip.getPluginsModule().RawGetString(name).(*lua.LTable); !ok {
+			t.Errorf("plugin %s is not registered into era.plugins", name)
+		}
+	}
+	// exported by only shop_ext
+	if !ip.HasEraValue("shop_event_buy_item") {
+		t.Error("exported callback shop_event_buy_item is not installed")
+	}
+	if err := ip.EraCall("title_scene"); err != nil {
+		t.Errorf("Failed to call title_scene: %v", err)
+	}
+}
+
+func TestInterpreterLoadSystemPluginCallbackConflict(t *testing.T) {
+	ip := newInterpreterWithConf(newPluginTestConfig("eracall.lua"))
+	defer ip.Quit()
+
+	// title_scene is exported by base_lib and shop_ext, and user script does not define it.
+	err := ip.LoadSystem()
+	if err == nil {
+		t.Fatal("LoadSystem should fail due to callback conflict, but not error")
+	}
+	if !strings.Contains(err.Error(), "title_scene") {
+		t.Errorf("error should report conflicted callback, got: %v", err)
+	}
+}
+
+func TestResolvePluginOrder(t *testing.T) {
+	manifests := []*PluginManifest{
+		{Name: "c", Version: "1.0", Dependencies: map[string]string{"b": ">=2"}},
+		{Name: "a", Version: "0.3.1"},
+		{Name: "b", Version: "2.1", Dependencies: map[string]string{"a": "0.3.1"}},
+		{Name: "d", Version: "1"},
+	}
+	ordered, err := resolvePluginOrder(manifests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, 0, len(ordered))
+	for _, m := range ordered {
+		got = append(got, m.Name)
+	}
+	if expect := "a b c d"; strings.Join(got, " ") != expect {
+		t.Errorf("invalid load order, expect: %v, got: %v", expect, got)
+	}
+}
+
+func TestResolvePluginOrderErrors(t *testing.T) {
+	for _, testcase := range []struct {
+		Name      string
+		Manifests []*PluginManifest
+		ErrorMsg  string
+	}{
+		{"missing", []*PluginManifest{
+			{Name: "a", Version: "1", Dependencies: map[string]string{"b": ""}},
+		}, "dependency b is not found"},
+		{"version conflict", []*PluginManifest{
+			{Name: "a", Version: "1", Dependencies: map[string]string{"b": ">=1.2, <2"}},
+			{Name: "b", Version: "1.1.9"},
+		}, "version conflict"},
+		{"invalid version", []*PluginManifest{
+			{Name: "a", Version: "v1"},
+		}, "invalid version"},
+		{"duplicate", []*PluginManifest{
+			{Name: "a", Version: "1"},
+			{Name: "a", Version: "2"},
+		}, "duplicate name"},
+		{"cyclic", []*PluginManifest{
+			{Name: "a", Version: "1", Dependencies: map[string]string{"b": ""}},
+			{Name: "b", Version: "1", Dependencies: map[string]string{"a": ""}},
+		}, "cyclic dependency a -> b -> a"},
+	} {
+		_, err := resolvePluginOrder(testcase.Manifests)
+		if err == nil {
+			t.Errorf("%s: should be error but not", testcase.Name)
+			continue
+		}
+		if !strings.Contains(err.Error(), testcase.ErrorMsg) {
+			t.Errorf("%s: error should contain %q, got: %v", testcase.Name, testcase.ErrorMsg, err)
+		}
+	}
+}
+
+func TestSatisfyVersion(t *testing.T) {
+	v, err := parsePluginVersion("1.2.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, testcase := range []struct {
+		Constraint string
+		Expect     bool
+	}{
+		{"", true},
+		{"*", true},
+		{"1.2.3", true},
+		{"=1.2", false},
+		{">=1.2", true},
+		{">1.2.3", false},
+		{"<2", true},
+		{"<=1.2.2", false},
+		{">=1.0, <1.2.3", false},
+	} {
+		got, err := satisfyVersion(v, testcase.Constraint)
+		if err != nil {
+			t.Errorf("%q: %v", testcase.Constraint, err)
+			continue
+		}
+		if got != testcase.Expect {
+			t.Errorf("%q: expect %v, got %v", testcase.Constraint, testcase.Expect, got)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+-- character parameters as csv name indexed views
+local chara = era.chara:add(1)
+local base = chara.Base
+
+-- iteration in csv order with index, name and value
+base:fill(0)
+base["体力"] = 1000
+base["気力"] = 500
+local count = 0
+local last_index = -1
+for i, name, value in base:iter() do
+	assert(i > last_index)
+	assert(name == era.csv.Base[i])
+	assert(value == base[i])
+	last_index = i
+	count = count + 1
+end
+assert(count > 0)
+local i, name, value = base:iter()(base, -1)
+assert(i == 0 and name == "体力" and value == 1000)
+
+-- slicing by csv names
+local sliced = base:slice("気力")
+assert(#sliced == #base - 1)
+assert(sliced["気力"] == 500)
+assert(#base:slice(0, "気力") == 1)
+
+-- bulk assignment
+base:assign({["体力"] = 10, [1] = 20})
+assert(base["体力"] == 10)
+assert(base["気力"] == 20)
+
+local ok, msg = pcall(base.assign, base, {["体力"] = 30, ["体カ"] = 40})
+assert(not ok)
+assert(string.find(msg, "did you mean \"体力\""), msg)
+assert(base["体力"] == 10, "assignment must not be partially applied")
+
+ok, msg = pcall(base.assign, base, {["体力"] = "string"})
+assert(not ok)
+
+chara.CStr:assign({[1] = "hello"})
+assert(chara.CStr[1] == "hello")
+
+-- diagnostics
+ok, msg = pcall(function() return base["体カ"] end)
+assert(not ok)
+assert(string.find(msg, "did you mean \"体力\""), msg)
+
+ok, msg = pcall(function() base["気カ"] = 1 end)
+assert(not ok)
+assert(string.find(msg, "did you mean \"気力\""), msg)
+
+ok, msg = pcall(function() return base[#base] end)
+assert(not ok)
+assert(string.find(msg, "index " .. #base), msg)
+
+ok, msg = pcall(function() return chara.Bace end)
+assert(not ok)
+assert(string.find(msg, "did you mean \"Base\""), msg)
+
+ok, msg = pcall(function() return era.csvindex.Base["体カ"] end)
+assert(not ok)
+assert(string.find(msg, "did you mean \"体力\""), msg)
+
+era.chara:remove(#era.chara-1)
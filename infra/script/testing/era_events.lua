@@ -0,0 +1,33 @@
+-- era.events
+
+local events = era.events
+events.clear()
+
+local order = {}
+local id_low = events.on("test_event", function(v) order[#order+1] = "low" .. v end, -1)
+local id_default = events.on("test_event", function(v) order[#order+1] = "default" .. v end)
+local id_high = events.on("test_event", function(v) order[#order+1] = "high" .. v end, 10)
+local id_default2 = events.on("test_event", function(v) order[#order+1] = "default2" .. v end, 0)
+
+assert(events.emit("test_event", 1) == 4)
+assert(table.concat(order, ",") == "high1,default1,default21,low1")
+
+assert(events.off("test_event", id_default))
+assert(not events.off("test_event", id_default))
+assert(not events.off("no_such_event", id_high))
+
+order = {}
+assert(events.emit("test_event", 2) == 3)
+assert(table.concat(order, ",") == "high2,default22,low2")
+
+-- emitting unknown event is OK.
+assert(events.emit("no_such_event") == 0)
+
+-- error in handler propagates to emitter.
+events.on("error_event", function() error("handler error") end)
+assert(not pcall(events.emit, "error_event"))
+
+events.clear("test_event")
+assert(events.emit("test_event", 3) == 0)
+events.clear()
+assert(events.emit("error_event") == 0)
@@ -5,3 +5,11 @@ end
 function era.testing_bool() 
 	return true
 end
+
+function era.testing_int(a, b)
+	return a * b
+end
+
+function era.testing_int_invalid()
+	return "not a number"
+end
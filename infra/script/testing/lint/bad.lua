@@ -0,0 +1,26 @@
+-- script containing lint issues.
+counter = 0
+
+function era.titel_scene()
+  era.prinl "title"
+end
+
+function era.shop_event_steal_item(n) -- no such callback in the shop scene.
+  era.flow.gotoNext("base")
+end
+
+era.base_event_start = function()
+  era.printl(era.master[0].Bse[0])
+  era.system.Numbr[0] = 1
+  era.flow.gotoNextScene("title")
+  era.printl "never reached"
+end
+
+function era.my_func()
+  if true then
+    return 1
+  else
+    error("no")
+  end
+  print("unreachable")
+end
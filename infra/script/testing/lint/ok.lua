@@ -0,0 +1,42 @@
+-- valid script. no lint issue is expected.
+local M = {}
+
+local function helper(n)
+  return n + 1
+end
+
+function era.title_scene()
+  era.printl "title"
+  era.flow.setNextScene("base")
+end
+
+era.base_event_start = function()
+  local chara = era.master[0]
+  era.printl(chara.name)
+  era.system.Number[0] = helper(era.system.Number[0])
+  era.my_helper()
+end
+
+function era.my_helper()
+  for i, v in ipairs({1, 2}) do
+    M[i] = v
+  end
+  if M[1] then
+    return true
+  else
+    return false
+  end
+end
+
+function era.my_continue()
+  for i = 1, 3 do
+    if M[i] then
+      goto continue
+    else
+      goto continue
+    end
+    ::continue::
+  end
+end
+
+return M
@@ -0,0 +1,2 @@
+function era.title_scene()
+  era.printl "unclosed"
@@ -0,0 +1,4 @@
+-- title_scene is exported by several plugins. user script resolves it.
+era.title_scene = function()
+  era.plugins.shop_ext.title_scene()
+end
@@ -0,0 +1,9 @@
+local M = {}
+
+M.greeting = "hello from base_lib"
+
+function M.title_scene()
+  era.printl(M.greeting)
+end
+
+return M
@@ -0,0 +1,15 @@
+-- dependency must be loaded before this plugin.
+local base = era.plugins.base_lib
+assert(base.greeting == "hello from base_lib")
+
+local M = {}
+
+function M.shop_event_buy_item(item)
+  return true
+end
+
+function M.title_scene()
+  base.title_scene()
+end
+
+return M
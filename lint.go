@@ -0,0 +1,63 @@
+package erago
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/mzki/erago/filesystem"
+	"github.com/mzki/erago/infra/script"
+	"github.com/mzki/erago/stub"
+)
+
+// Lint parses script files with Config and returns
+// problems found by static analysis. The scripts are not executed.
+// If script_files is empty, all of lua files under the script
+// directory are analyzed.
+func Lint(conf Config, script_files []string) ([]script.LintIssue, error) {
+	game := NewGame()
+	if err := game.Init(stub.NewGameUIStub(), conf); err != nil {
+		return nil, fmt.Errorf("Game.Init() Fail: %v", err)
+	}
+	// game flow and input are not run, so that only the interpreter is needed to quit.
+	defer game.ipr.Quit()
+
+	// enable testing features so that test scripts are also analyzed correctly.
+	game.ipr.OpenTestingLibs(newRequestObserver(game))
+
+	if len(script_files) == 0 {
+		files, err := findScriptFiles(game.config.ScriptConfig.LoadDir)
+		if err != nil {
+			return nil, err
+		}
+		script_files = files
+	}
+	return game.ipr.Lint(script_files)
+}
+
+// find all lua files under dir recursively.
+func findScriptFiles(dir string) ([]string, error) {
+	resDir, err := filesystem.ResolvePath(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, 16)
+	err = filepath.WalkDir(resDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.HasSuffix(path, ".lua") {
+			rel, err := filepath.Rel(resDir, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, filepath.Join(dir, rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find script files under %s: %w", dir, err)
+	}
+	return files, nil
+}
@@ -0,0 +1,52 @@
+package erago
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/mzki/erago/infra/script"
+)
+
+func TestLint(t *testing.T) {
+	t.Parallel()
+
+	conf := NewConfig("./stub/")
+	issues, err := Lint(conf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type issueKey struct {
+		File string
+		Line int
+		Rule string
+	}
+	replaceFile := filepath.Join("stub", "ELA", "__builtin_replace.lua")
+	testFile := filepath.Join("stub", "ELA", "game_test.lua")
+	expects := []issueKey{
+		{replaceFile, 1, script.LintRuleGlobalAssign},
+		{testFile, 7, script.LintRuleGlobalAssign},
+		{testFile, 8, script.LintRuleGlobalAssign},
+		{testFile, 17, script.LintRuleGlobalAssign},
+		{testFile, 30, script.LintRuleGlobalAssign},
+		{testFile, 31, script.LintRuleGlobalAssign},
+		{testFile, 39, script.LintRuleGlobalAssign},
+		{testFile, 41, script.LintRuleGlobalAssign},
+	}
+	if len(issues) != len(expects) {
+		for _, issue := range issues {
+			t.Log(issue)
+		}
+		t.Fatalf("different number of issues, expect: %d, got: %d", len(expects), len(issues))
+	}
+	for i, expect := range expects {
+		issue := issues[i]
+		if got := (issueKey{filepath.Clean(issue.File), issue.Line, issue.Rule}); got != expect {
+			t.Errorf("issue[%d]: expect: %v, got: %v", i, expect, issue)
+		}
+	}
+
+	if _, err := Lint(conf, []string{"nothing.lua"}); err == nil {
+		t.Fatal("no existing file does not occurs error, why?")
+	}
+}
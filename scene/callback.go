@@ -9,24 +9,67 @@ type Scripter interface {
 	// oprations for era module.
 	EraCall(string) error
 	EraCallBoolArgInt(string, int64) (bool, error)
+	EraCallIntArgInts(string, ...int64) (int64, error)
 	HasEraValue(string) bool
+
+	// EraEmit notifies the event to all of the script handlers
+	// subscribing the event name. It is OK that no handler exists.
+	EraEmit(string, ...int64) error
+
+	// EraDispatchChanges notifies changes of the variables to the script
+	// handlers watching them. It is called at the safe points, such as
+	// the scene transition. It is OK that no handler exists.
+	EraDispatchChanges() error
+
+	// EraReloadParams makes the script use the variables of the game state
+	// again. It is called after the game state is loaded outside of the script,
+	// since the variables held by the script are no longer valid.
+	EraReloadParams() error
 }
 
+// loggedScripter logs script calls, and counts the calls in progress
+// so that the control requests are not processed while script is running.
 type loggedScripter struct {
 	Scripter
+	calls *int
+}
+
+// enter counts up the calls in progress, and returns function to count down it.
+func (ls *loggedScripter) enter() func() {
+	*ls.calls++
+	return func() { *ls.calls-- }
 }
 
 // oprations for era module.
 func (ls *loggedScripter) EraCall(fn_name string) error {
 	log.Debugf("ScriptCall: %s", fn_name)
+	defer ls.enter()()
 	return ls.Scripter.EraCall(fn_name)
 }
 
 func (ls *loggedScripter) EraCallBoolArgInt(fn_name string, v int64) (bool, error) {
 	log.Debugf("ScriptCall: %s", fn_name)
+	defer ls.enter()()
 	return ls.Scripter.EraCallBoolArgInt(fn_name, v)
 }
 
+func (ls *loggedScripter) EraCallIntArgInts(fn_name string, args ...int64) (int64, error) {
+	log.Debugf("ScriptCall: %s", fn_name)
+	defer ls.enter()()
+	return ls.Scripter.EraCallIntArgInts(fn_name, args...)
+}
+
+func (ls *loggedScripter) EraEmit(ev_name string, args ...int64) error {
+	log.Debugf("ScriptEmit: %s", ev_name)
+	defer ls.enter()()
+	return ls.Scripter.EraEmit(ev_name, args...)
+}
+
+func (ls *loggedScripter) EraDispatchChanges() error {
+	defer ls.enter()()
+	return ls.Scripter.EraDispatchChanges()
+}
+
 //go:generate go run gen_callback_doc.go --outputdir ./gendoc
 const (
 	// separator for the script function name
@@ -51,31 +94,77 @@ const (
 	ScrUserPrefix = "user"
 )
 
+// CallbackNames returns the names of script callback functions which are
+// called by the builtin scenes. The list is generated by gen_callback_doc.go.
+func CallbackNames() []string {
+	ret := make([]string, len(callbackNames))
+	copy(ret, callbackNames)
+	return ret
+}
+
 type callBacker struct {
 	Scripter
 	io IOController
 }
 
-// Call script function if exists and return error of
-// calling result. If the function is not found
+// Emit script event with the callback name. The event is emitted
+// at the point where optional script function, [scene]_event_XXX,
+// is called, so that several handlers can hook it through era.events.
+func (cb callBacker) emit(ev_name string, args ...int64) error {
+	return cb.Scripter.EraEmit(ev_name, args...)
+}
+
+// Notify changes of the watched variables to the script handlers.
+// The builtin scenes call it at the safe points, where the handlers
+// can modify the game state without breaking the flow of the scene.
+func (cb callBacker) dispatchChanges() error {
+	return cb.Scripter.EraDispatchChanges()
+}
+
+// Notify the script that the game state is loaded by the builtin scenes.
+func (cb callBacker) reloadParams() error {
+	return cb.Scripter.EraReloadParams()
+}
+
+// Emit event and then call script function if exists and
+// return error of calling result. If the function is not found
 // do nothing and return nil.
 func (cb callBacker) maybeCall(fn_name string) error {
+	if err := cb.emit(fn_name); err != nil {
+		return err
+	}
+	return cb.callIfExists(fn_name)
+}
+
+// Call script function if exists without emitting event.
+func (cb callBacker) callIfExists(fn_name string) error {
 	if cb.Scripter.HasEraValue(fn_name) {
 		return cb.Scripter.EraCall(fn_name)
 	}
 	return nil
 }
 
-// Call script function with arg int64 if exists and
-// return bool and error of calling result.
+// Emit event with arg int64, and then call script function with arg int64
+// if exists and return bool and error of calling result.
 // return false, nil if function is not found.
 func (cb callBacker) maybeCallBoolArgInt(fn_name string, arg int64) (bool, error) {
+	if err := cb.emit(fn_name, arg); err != nil {
+		return false, err
+	}
 	if cb.Scripter.HasEraValue(fn_name) {
 		return cb.Scripter.EraCallBoolArgInt(fn_name, arg)
 	}
 	return false, nil
 }
 
+// Emit event with arg int64, and then call script function with arg int64
+// if exists and return error of calling result. The return value of the
+// function is not used. If the function is not found do nothing and return nil.
+func (cb callBacker) maybeCallArgInt(fn_name string, arg int64) error {
+	_, err := cb.maybeCallBoolArgInt(fn_name, arg)
+	return err
+}
+
 // Call script function and return error of
 // calling result. If function is not found,
 // return not found error.
@@ -105,6 +194,17 @@ func (cb callBacker) checkCallBoolArgInt(fn_name string, arg int64) (struct{ Cal
 	return struct{ Called, Return bool }{}, nil
 }
 
+// call script function with int args if it exists.
+// it returns script function is called?(bool) and
+// calling result.
+func (cb callBacker) checkCallIntArgInts(fn_name string, args ...int64) (bool, int64, error) {
+	if cb.Scripter.HasEraValue(fn_name) {
+		ret, err := cb.Scripter.EraCallIntArgInts(fn_name, args...)
+		return true, ret, err
+	}
+	return false, 0, nil
+}
+
 // call script function if it exists, and return
 // call result. If not exists, print caution to screen.
 func (cb callBacker) cautionCall(fn_name string) error {
@@ -0,0 +1,55 @@
+// Code generated by gen_callback_doc.go; DO NOT EDIT.
+
+package scene
+
+// callbackNames is a list of the script callback names documented in this package,
+// including default callbacks [scene]_scene and [scene]_event_start for each scene.
+var callbackNames = []string{
+	"ablup_scene",
+	"ablup_event_start",
+	"ablup_user_show_juel",
+	"ablup_user_show_menu",
+	"ablup_user_menu_selected",
+	"autosave_scene",
+	"autosave_event_start",
+	"autosave_replace",
+	"base_scene",
+	"base_event_start",
+	"base_user_show_menu",
+	"base_user_menu_selected",
+	"loadend_scene",
+	"loadend_event_start",
+	"main_scene",
+	"main_event_start",
+	"main_user_loop",
+	"newgame_scene",
+	"newgame_event_start",
+	"newgame_event_init",
+	"savegame_scene",
+	"savegame_event_start",
+	"savegame_event_before_save",
+	"shop_scene",
+	"shop_event_start",
+	"shop_replace_show_menu",
+	"shop_event_menu_selected",
+	"shop_event_buy_item",
+	"shop_event_sell_item",
+	"shop_replace_price",
+	"title_scene",
+	"title_event_start",
+	"title_replace_loadgame",
+	"train_scene",
+	"train_event_start",
+	"train_user_show_status",
+	"train_replace_cmd_able",
+	"train_replace_show_other_cmd",
+	"train_user_cmd",
+	"train_user_other_cmd",
+	"train_user_check_source",
+	"train_event_cmd_end",
+	"trainend_scene",
+	"trainend_event_start",
+	"turnend_scene",
+	"turnend_event_start",
+	"turnend_event_calendar",
+}
@@ -11,6 +11,150 @@ import (
 type Config struct {
 	// can be auto-saved in the specific scene transition
 	CanAutoSave bool
+
+	// AutoSaveSlots is the number of rotating autosave slots, at most MaxAutoSaveSlots.
+	// The slots are numbered downward from 99, such as 99, 98, 97, and separated from manual save slots.
+	// 0 means a single slot, 99.
+	AutoSaveSlots int
+
+	// AutoSavePolicy selects when autosave occurs. See AutoSavePolicyXXX.
+	// Empty means AutoSavePolicyBase.
+	AutoSavePolicy string
+
+	// AutoSaveEveryTurns is the number of turns between autosaves, used only for AutoSavePolicyTurn.
+	// 0 means every turn.
+	AutoSaveEveryTurns int
+
+	// AutoSaveScenes is comma separated scene names, such as "base,shop".
+	// Starting of these scenes triggers autosave, used only for AutoSavePolicyScene.
+	AutoSaveScenes string
+
+	// AutoSaveIntervalSec is the minimum seconds between autosaves,
+	// used only for AutoSavePolicyInterval.
+	AutoSaveIntervalSec int
+
+	// SceneProfile selects the builtin scene graph. See SceneProfileXXX.
+	// Empty means SceneProfileEramaker.
+	SceneProfile string
+
+	// EntryScene is the scene name started after booting, used only for SceneProfileScript.
+	// It must not be empty for SceneProfileScript, since the profile has no builtin title.
+	EntryScene string
+
+	// ShopBuyQuantity enables to input quantity of buying or selling item in the shop scene.
+	ShopBuyQuantity bool
+
+	// ShopSellRate is the percentage of the item price when selling the item back in the shop scene.
+	// 0 disables selling.
+	ShopSellRate int
+
+	// ShopLimitStock treats ItemStock as the number of stocks in the shop scene.
+	// Buying item decreases ItemStock and selling item increases it.
+	// Otherwise, positive ItemStock means the item is always available.
+	ShopLimitStock bool
+
+	// RewindSnapshot enables to take a snapshot of the game state at every scene transition,
+	// so that the game state can be rewound to the start of the recent scenes.
+	// It is disabled by default since every transition costs encoding whole of
+	// the system data into memory, as same as saving the game.
+	// The snapshots are removed by loading the game, starting new game or returning to title.
+	RewindSnapshot bool
+
+	// TrainQueueLimit is the maximum number of train commands entered by one input in the train scene,
+	// such as "3 3 5", "3x2" or "repeat 3x". 0 or 1 disables multiple commands.
+	TrainQueueLimit int
+}
+
+const (
+	// SceneProfileEramaker is the eramaker-style scene graph,
+	// title -> newgame -> base -> train -> ablup -> turnend -> autosave, plus shop.
+	SceneProfileEramaker = "eramaker"
+	// SceneProfileMinimal is the minimal scene graph, title -> newgame -> main loop.
+	SceneProfileMinimal = "minimal"
+	// SceneProfileScript is the fully script-defined scene graph.
+	// Only loadend is builtin, and the other scenes must be registered by script.
+	SceneProfileScript = "script"
+)
+
+const (
+	// AutoSavePolicyBase autosaves in the autosave scene unless the previous scene is loadend.
+	AutoSavePolicyBase = "base"
+	// AutoSavePolicyTurn autosaves in the autosave scene after turnend,
+	// every AutoSaveEveryTurns turns.
+	AutoSavePolicyTurn = "turn"
+	// AutoSavePolicyScene autosaves when one of AutoSaveScenes starts,
+	// unless the previous scene is loadend.
+	AutoSavePolicyScene = "scene"
+	// AutoSavePolicyInterval autosaves in the autosave scene if
+	// AutoSaveIntervalSec seconds have passed since the last autosave.
+	AutoSavePolicyInterval = "interval"
+)
+
+// MaxAutoSaveSlots is the maximum number of autosave slots.
+const MaxAutoSaveSlots = 10
+
+// Validate checks Config has valid values.
+func (c Config) Validate() error {
+	switch c.SceneProfile {
+	case "", SceneProfileEramaker, SceneProfileMinimal, SceneProfileScript:
+	default:
+		return fmt.Errorf("unknown scene profile %q, must be one of %q, %q or %q",
+			c.SceneProfile, SceneProfileEramaker, SceneProfileMinimal, SceneProfileScript)
+	}
+	if len(c.EntryScene) > 0 && c.SceneProfile != SceneProfileScript {
+		return fmt.Errorf("EntryScene is only available for scene profile %q", SceneProfileScript)
+	}
+	if len(c.EntryScene) == 0 && c.SceneProfile == SceneProfileScript {
+		// no builtin title exists for the profile.
+		return fmt.Errorf("EntryScene must not be empty for scene profile %q", SceneProfileScript)
+	}
+	switch c.AutoSavePolicy {
+	case "", AutoSavePolicyBase, AutoSavePolicyTurn:
+	case AutoSavePolicyScene:
+		if len(c.autoSaveSceneNames()) == 0 {
+			return fmt.Errorf("AutoSaveScenes must not be empty for autosave policy %q", c.AutoSavePolicy)
+		}
+	case AutoSavePolicyInterval:
+		if c.AutoSaveIntervalSec <= 0 {
+			return fmt.Errorf("AutoSaveIntervalSec must be > 0 for autosave policy %q, but %d", c.AutoSavePolicy, c.AutoSaveIntervalSec)
+		}
+	default:
+		return fmt.Errorf("unknown autosave policy %q, must be one of %q, %q, %q or %q",
+			c.AutoSavePolicy, AutoSavePolicyBase, AutoSavePolicyTurn, AutoSavePolicyScene, AutoSavePolicyInterval)
+	}
+	if c.AutoSaveSlots < 0 || c.AutoSaveSlots > MaxAutoSaveSlots {
+		return fmt.Errorf("AutoSaveSlots must be in [0, %d], but %d", MaxAutoSaveSlots, c.AutoSaveSlots)
+	}
+	if c.AutoSaveEveryTurns < 0 {
+		return fmt.Errorf("AutoSaveEveryTurns must be >= 0, but %d", c.AutoSaveEveryTurns)
+	}
+	if c.TrainQueueLimit < 0 {
+		return fmt.Errorf("TrainQueueLimit must be >= 0, but %d", c.TrainQueueLimit)
+	}
+	if c.ShopSellRate < 0 {
+		return fmt.Errorf("ShopSellRate must be >= 0, but %d", c.ShopSellRate)
+	}
+	return nil
+}
+
+// autoSaveSceneNames returns AutoSaveScenes as a list of scene names.
+func (c Config) autoSaveSceneNames() []string {
+	names := make([]string, 0, 4)
+	for _, name := range strings.Split(c.AutoSaveScenes, ",") {
+		if name = strings.TrimSpace(name); len(name) > 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// entrySceneName returns the scene name started after booting.
+// EntryScene is used only for the scene profile script, which is validated by Validate.
+func (c Config) entrySceneName() string {
+	if c.SceneProfile == SceneProfileScript {
+		return c.EntryScene
+	}
+	return SceneNameTitle
 }
 
 // ConfigReplaceText holds strings for replace specific text in the bultin scenes.
@@ -25,21 +169,36 @@ type ConfigReplaceText struct {
 	QuitGame string
 
 	// for shop
-	ReturnMenu  string
-	MoneyFormat string
+	ReturnMenu    string
+	MoneyFormat   string
+	SellItem      string
+	InputQuantity string
 
 	// for save/load
 	SelectSaveData   string
 	SelectLoadData   string
 	ConfirmOverwrite string
+
+	// for quick save/load
+	QuickSaved  string
+	QuickLoaded string
+	NoQuickSave string
+
+	// for rewind
+	Rewound    string
+	NoSnapshot string
+
+	// for quick save/load and rewind requested while script is running.
+	ControlDeferred string
 }
 
 const (
 	// Max length for replace text length used for plain text.
-	// Affects to LoadingMessage.
+	// Affects to LoadingMessage, InputQuantity, SelectSaveData, SelectLoadData, ConfirmOverwrite,
+	// QuickSaved, QuickLoaded, NoQuickSave, Rewound, NoSnapshot and ControlDeferred.
 	MaxReplacePlainTextLen = 32
 	// Max length for replace text length used for command. -5 means the length of command prefix "[NN] ".
-	// Affects to NewGame, LoadGame, QuitGame and ReturnMenu.
+	// Affects to NewGame, LoadGame, QuitGame, ReturnMenu and SellItem.
 	MaxReplaceCmdTextLen = DefaultPrintCWidth - 5
 )
 
@@ -47,9 +206,16 @@ func (c *ConfigReplaceText) Validate() error {
 	// Palin text
 	for _, text := range []string{
 		c.LoadingMessage,
+		c.InputQuantity,
 		c.SelectSaveData,
 		c.SelectLoadData,
 		c.ConfirmOverwrite,
+		c.QuickSaved,
+		c.QuickLoaded,
+		c.NoQuickSave,
+		c.Rewound,
+		c.NoSnapshot,
+		c.ControlDeferred,
 	} {
 		if width.StringWidth(text) > MaxReplacePlainTextLen {
 			return fmt.Errorf("text length should be < %d for %q", MaxReplacePlainTextLen, text)
@@ -61,6 +227,7 @@ func (c *ConfigReplaceText) Validate() error {
 		c.LoadGame,
 		c.QuitGame,
 		c.ReturnMenu,
+		c.SellItem,
 	} {
 		if width.StringWidth(text) > MaxReplaceCmdTextLen {
 			return fmt.Errorf("text length should be < %d for %q", MaxReplaceCmdTextLen, text)
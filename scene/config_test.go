@@ -23,6 +23,13 @@ func TestConfigReplaceTextPlainTextValidate(t *testing.T) {
 		SelectSaveData:   strings.Repeat("f", MaxReplacePlainTextLen),
 		SelectLoadData:   strings.Repeat("g", MaxReplacePlainTextLen),
 		ConfirmOverwrite: strings.Repeat("h", MaxReplacePlainTextLen),
+		QuickSaved:       strings.Repeat("i", MaxReplacePlainTextLen),
+		QuickLoaded:      strings.Repeat("j", MaxReplacePlainTextLen),
+		NoQuickSave:      strings.Repeat("k", MaxReplacePlainTextLen),
+		Rewound:          strings.Repeat("l", MaxReplacePlainTextLen),
+		NoSnapshot:       strings.Repeat("m", MaxReplacePlainTextLen),
+		InputQuantity:    strings.Repeat("n", MaxReplacePlainTextLen),
+		ControlDeferred:  strings.Repeat("o", MaxReplacePlainTextLen),
 	}
 
 	if err := replace.Validate(); err != nil {
@@ -57,6 +64,18 @@ func TestConfigReplaceTextPlainTextValidate(t *testing.T) {
 	if err := replace.Validate(); err == nil {
 		t.Errorf("the text length %d should be accepted", MaxReplacePlainTextLen)
 	}
+	replace = ConfigReplaceText{
+		InputQuantity: invalidLenText,
+	}
+	if err := replace.Validate(); err == nil {
+		t.Errorf("the text length %d should be accepted", MaxReplacePlainTextLen)
+	}
+	replace = ConfigReplaceText{
+		QuickLoaded: invalidLenText,
+	}
+	if err := replace.Validate(); err == nil {
+		t.Errorf("the text length %d should be accepted", MaxReplacePlainTextLen)
+	}
 
 	// cmd text
 	invalidLenCmdText := strings.Repeat("a", MaxReplaceCmdTextLen+1)
@@ -140,3 +159,34 @@ func TestConfigReplaceTextMoneyFormatValidate(t *testing.T) {
 		}
 	}
 }
+
+func TestConfigValidateSceneProfile(t *testing.T) {
+	for _, testcase := range []struct {
+		Config Config
+		Valid  bool
+	}{
+		{Config{}, true},
+		{Config{SceneProfile: SceneProfileEramaker}, true},
+		{Config{SceneProfile: SceneProfileMinimal}, true},
+		{Config{SceneProfile: SceneProfileScript, EntryScene: "opening"}, true},
+		{Config{SceneProfile: SceneProfileScript}, false},
+		{Config{SceneProfile: "unknown"}, false},
+		{Config{SceneProfile: SceneProfileMinimal, EntryScene: "opening"}, false},
+		{Config{ShopSellRate: 50}, true},
+		{Config{ShopSellRate: -1}, false},
+		{Config{TrainQueueLimit: 10}, true},
+		{Config{TrainQueueLimit: -1}, false},
+		{Config{AutoSaveSlots: MaxAutoSaveSlots, AutoSavePolicy: AutoSavePolicyTurn, AutoSaveEveryTurns: 3}, true},
+		{Config{AutoSaveSlots: MaxAutoSaveSlots + 1}, false},
+		{Config{AutoSavePolicy: "unknown"}, false},
+		{Config{AutoSavePolicy: AutoSavePolicyScene}, false},
+		{Config{AutoSavePolicy: AutoSavePolicyScene, AutoSaveScenes: "base, shop"}, true},
+		{Config{AutoSavePolicy: AutoSavePolicyInterval}, false},
+		{Config{AutoSavePolicy: AutoSavePolicyInterval, AutoSaveIntervalSec: 60}, true},
+	} {
+		err := testcase.Config.Validate()
+		if got := err == nil; got != testcase.Valid {
+			t.Errorf("%+v: expect valid %v, got error: %v", testcase.Config, testcase.Valid, err)
+		}
+	}
+}
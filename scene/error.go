@@ -23,3 +23,9 @@ var ErrorRunNextSceneNotFound = errors.New("Next Scene is not found")
 
 // ErrorSceneNameNotRegistered indicates the snene name not registered.
 var ErrorSceneNameNotRegistered = errors.New("Scene Name is not registered")
+
+// ErrorSceneStackOverflow indicates the scene stack exceeds MaxSceneStackDepth.
+var ErrorSceneStackOverflow = errors.New("scene stack overflow")
+
+// ErrorSceneStackEmpty indicates returning scene but the scene stack is empty.
+var ErrorSceneStackEmpty = errors.New("scene stack is empty")
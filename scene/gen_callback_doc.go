@@ -209,14 +209,23 @@ func ParseAST(dir string, outputDir string) error {
 		return err
 	}
 
-	callbacks := parseCallBacksFromAST(pkgs["scene"]) // NOTE: use package name directly
+	callbacks, err := parseCallBacksFromAST(fset, pkgs["scene"]) // NOTE: use package name directly
+	if err != nil {
+		return err
+	}
 
 	// create sorted key list to fix output order.
 	keys := make([]string, 0, len(callbacks))
 	for k, _ := range callbacks {
 		keys = append(keys, k)
 	}
-	sort.Strings(keys)
+	sort.Slice(keys, func(i, j int) bool {
+		// template scenes are placed at the end.
+		if ti, tj := isTemplateScene(keys[i]), isTemplateScene(keys[j]); ti != tj {
+			return tj
+		}
+		return keys[i] < keys[j]
+	})
 
 	err = checkNameConvention(callbacks, keys)
 	if err != nil {
@@ -227,14 +236,39 @@ func ParseAST(dir string, outputDir string) error {
 	if err != nil {
 		return err
 	}
+	// Go source is written into package directory, not outputDir.
+	err = writeAsGoList(filepath.Join(dir, CallbackNamesFile), callbacks, keys)
+	if err != nil {
+		return err
+	}
 	err = writeAsLuaLSAddon(outputDir, callbacks, keys)
 	return err
 }
 
 const SceneTag = "// +scene:"
 
-func parseCallBacksFromAST(pkg *ast.Package) sceneDeclMap {
+// isTemplateScene returns whether the scene name is a placeholder such as [custom],
+// which is used to document the scenes defined by user script.
+// Callbacks of template scenes are documented but not listed as the concrete names.
+func isTemplateScene(name string) bool {
+	return strings.HasPrefix(name, "[") && strings.HasSuffix(name, "]")
+}
+
+func parseCallBacksFromAST(fset *token.FileSet, pkg *ast.Package) (sceneDeclMap, error) {
 	callback_map := make(sceneDeclMap)
+	multiErr := errutil.NewMultiError()
+	// addScene returns nil if sceneName is empty, reporting the declaration line of tag.
+	addScene := func(sceneName string, tag *ast.Comment, doc []*ast.Comment) *sceneDecl {
+		if len(sceneName) == 0 {
+			multiErr.Add(fmt.Errorf("%s: scene name is empty in %q", fset.Position(tag.Pos()), tag.Text))
+			return nil
+		}
+		if _, has := callback_map[sceneName]; !has {
+			callback_map[sceneName] = newSceneDecl(sceneName)
+		}
+		callback_map[sceneName].Doc = parseDoc(doc)
+		return callback_map[sceneName]
+	}
 
 	for _, f := range pkg.Files {
 		ast.Inspect(f, func(n ast.Node) bool {
@@ -254,22 +288,32 @@ func parseCallBacksFromAST(pkg *ast.Package) sceneDeclMap {
 					break
 				}
 
-				sceneName := strings.TrimPrefix(tag, SceneTag)
-				sceneName = strings.TrimSpace(sceneName)
-				if len(sceneName) == 0 {
-					// TODO: notify error with declaration line.
-				}
-				if _, has := callback_map[sceneName]; !has {
-					callback_map[sceneName] = newSceneDecl(sceneName)
+				sceneName := strings.TrimSpace(strings.TrimPrefix(tag, SceneTag))
+				sd := addScene(sceneName, doc.List[0], doc.List[1:])
+				if sd == nil {
+					break
 				}
+				sd.callbacks = addCallBacksFromSpecs(sd.callbacks, decl.Specs)
 
-				callback_map[sceneName].Doc = parseDoc(doc.List[1:])
-				callback_map[sceneName].callbacks = addCallBacksFromSpecs(callback_map[sceneName].callbacks, decl.Specs)
+			case *ast.FuncDecl:
+				// scene which has no callback constants, such as the scene defined by script,
+				// is documented in the doc of the function which creates it.
+				// The scene tag follows the function's own doc.
+				if decl.Doc == nil {
+					break
+				}
+				for i, com := range decl.Doc.List {
+					if strings.HasPrefix(com.Text, SceneTag) {
+						sceneName := strings.TrimSpace(strings.TrimPrefix(com.Text, SceneTag))
+						addScene(sceneName, com, decl.Doc.List[i+1:])
+						break
+					}
+				}
 			}
 			return true
 		})
 	}
-	return callback_map
+	return callback_map, multiErr.Err()
 }
 
 func parseDoc(comments []*ast.Comment) []string {
@@ -435,7 +479,9 @@ callback関数は、命名規則 [scene-name]_[callback-type]_[funcion-name] に
 		sceneImageName := scene + "_flow"
 
 		fmt.Fprintln(fp, "# "+scene+"\n")
-		fmt.Fprintln(fp, "!["+sceneImageName+"](images/"+sceneImageName+".png)\n")
+		if !isTemplateScene(scene) {
+			fmt.Fprintln(fp, "!["+sceneImageName+"](images/"+sceneImageName+".png)\n")
+		}
 		for _, line := range sceneDecl.Doc {
 			fmt.Fprintln(fp, indent+line)
 		}
@@ -456,6 +502,44 @@ callback関数は、命名規則 [scene-name]_[callback-type]_[funcion-name] に
 	return nil
 }
 
+// --- Go source for callback name list -------------------------------------------------------
+
+const CallbackNamesFile = "callback_names.go"
+
+var goListTmpl = template.Must(template.New("GoList").Parse(`// Code generated by gen_callback_doc.go; DO NOT EDIT.
+
+package scene
+
+// callbackNames is a list of the script callback names documented in this package,
+// including default callbacks [scene]_scene and [scene]_event_start for each scene.
+var callbackNames = []string{
+{{- range $i, $name := .}}
+	"{{$name}}",
+{{- end}}
+}
+`))
+
+func writeAsGoList(file string, callbacks_list sceneDeclMap, keys []string) error {
+	names := make([]string, 0, 4*len(keys))
+	for _, scene := range keys {
+		sceneDecl, ok := callbacks_list[scene]
+		if !ok || isTemplateScene(scene) {
+			continue
+		}
+		functions := append(makeDefaultCallback(scene), sceneDecl.callbacks...)
+		for _, f := range functions {
+			names = append(names, f.Name)
+		}
+	}
+
+	fp, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+	return goListTmpl.Execute(fp, names)
+}
+
 func makeDefaultCallback(scene_name string) callbacks {
 	scene_decl := funcDecl{
 		Template: "{{.Name}}()",
@@ -602,7 +686,7 @@ func writeAsLuaLSAddon(outputDir string, callbacks_list sceneDeclMap, sceneOrder
 
 	var sceceDeclList []*sceneDecl
 	for _, s := range sceneOrder {
-		if sd, ok := callbacks_list[s]; ok {
+		if sd, ok := callbacks_list[s]; ok && !isTemplateScene(s) {
 			sceceDeclList = append(sceceDeclList, sd)
 		}
 	}
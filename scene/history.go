@@ -0,0 +1,121 @@
+package scene
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TransitionHistorySize is the maximum number of transitions
+// kept in the transition history.
+const TransitionHistorySize = 128
+
+// TransitionCause indicates why the scene transition occurs.
+type TransitionCause string
+
+const (
+	// The scene returns its next scene by itself, which is decided
+	// by builtin flow or script callbacks in the scene.
+	TransitionCauseCallback TransitionCause = "callback"
+	// The scene returns next scene which is set by flow API,
+	// such as era.flow.setNextScene.
+	TransitionCauseFlowAPI TransitionCause = "flow_api"
+	// The scene is interrupted by ErrorSceneNext, such as era.flow.gotoNextScene.
+	TransitionCauseSceneNext TransitionCause = "scene_next"
+)
+
+// Transition is a record of the scene transition.
+type Transition struct {
+	From  string
+	To    string
+	Cause TransitionCause
+	// Detail is the name of flow API which sets next scene.
+	// It is empty if next scene is not set by flow API.
+	Detail string
+	Time   time.Time
+}
+
+func (t Transition) String() string {
+	cause := string(t.Cause)
+	if len(t.Detail) > 0 {
+		cause += ":" + t.Detail
+	}
+	return fmt.Sprintf("%s %s -> %s (%s)", t.Time.Format("15:04:05.000"), t.From, t.To, cause)
+}
+
+type transitionEdge struct {
+	from, to string
+}
+
+// transitionJournal records scene transitions in bounded ring buffer.
+// It also counts all of observed edges of the scene graph.
+type transitionJournal struct {
+	records []Transition
+	head    int // next write position when records is full.
+	edges   map[transitionEdge]int
+
+	now func() time.Time
+}
+
+func newTransitionJournal() *transitionJournal {
+	return &transitionJournal{
+		records: make([]Transition, 0, TransitionHistorySize),
+		edges:   make(map[transitionEdge]int),
+		now:     time.Now,
+	}
+}
+
+func (j *transitionJournal) record(from, to string, cause TransitionCause, detail string) {
+	t := Transition{From: from, To: to, Cause: cause, Detail: detail, Time: j.now()}
+	if len(j.records) < TransitionHistorySize {
+		j.records = append(j.records, t)
+	} else {
+		j.records[j.head] = t
+		j.head = (j.head + 1) % TransitionHistorySize
+	}
+	j.edges[transitionEdge{from, to}] += 1
+}
+
+// history returns copy of the records ordered from oldest to newest.
+func (j *transitionJournal) history() []Transition {
+	ret := make([]Transition, 0, len(j.records))
+	ret = append(ret, j.records[j.head:]...)
+	ret = append(ret, j.records[:j.head]...)
+	return ret
+}
+
+// dump returns the records as human readable text, one transition per line.
+func (j *transitionJournal) dump() string {
+	var sb strings.Builder
+	sb.WriteString("scene transition history (oldest first):\n")
+	for _, t := range j.history() {
+		sb.WriteString("  " + t.String() + "\n")
+	}
+	return sb.String()
+}
+
+// writeGraph writes observed scene graph as Graphviz dot format.
+// Each edge is labeled by the number of observed transitions.
+func (j *transitionJournal) writeGraph(w io.Writer) error {
+	edges := make([]transitionEdge, 0, len(j.edges))
+	for e := range j.edges {
+		edges = append(edges, e)
+	}
+	sort.Slice(edges, func(i, k int) bool { // to output stable result.
+		if edges[i].from != edges[k].from {
+			return edges[i].from < edges[k].from
+		}
+		return edges[i].to < edges[k].to
+	})
+
+	var sb strings.Builder
+	sb.WriteString("digraph scenes {\n")
+	for _, e := range edges {
+		fmt.Fprintf(&sb, "  %q -> %q [label=\"%d\"];\n", e.from, e.to, j.edges[e])
+	}
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
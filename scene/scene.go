@@ -25,13 +25,18 @@ func newSceneCommon(name string, sf *sceneFields) sceneCommon {
 }
 
 // it is called at start of Next() in every scene.
+// start event is always emitted even if the scene is replaced by script.
 func (common sceneCommon) atStart() (Scene, error) {
+	if err := common.Script().emit(common.atStartEvent); err != nil {
+		return nil, err
+	}
+
 	called, err := common.Script().checkCall(common.atStartScene)
 	if called {
 		return common.Scenes().Next(), err
 	}
 
-	err = common.Script().maybeCall(common.atStartEvent)
+	err = common.Script().callIfExists(common.atStartEvent)
 	return nil, err
 }
 
@@ -76,3 +81,41 @@ func (ex externalScene) Next() (Scene, error) {
 	}
 	return ex.scenes.GetScene(next_name)
 }
+
+// scriptScene is a scene defined by script.
+// Unlike externalScene, it has start handling same as builtin scenes.
+type scriptScene struct {
+	sceneCommon
+	sceneName string
+	nextFunc  NextFunc
+}
+
+func newScriptScene(name string, next_func NextFunc, sf *sceneFields) Scene {
+	return &scriptScene{
+		sceneCommon: newSceneCommon(name, sf),
+		sceneName:   name,
+		nextFunc:    next_func,
+	}
+}
+
+func (sc scriptScene) Name() string {
+	return sc.sceneName
+}
+
+func (sc scriptScene) Next() (Scene, error) {
+	if next, err := sc.atStart(); next != nil || err != nil {
+		return next, err
+	}
+
+	sc.scriptCalls++
+	next_name, err := sc.nextFunc()
+	sc.scriptCalls--
+	if err != nil {
+		return nil, err
+	}
+	if len(next_name) == 0 {
+		// next scene may be set by script.
+		return sc.Scenes().Next(), nil
+	}
+	return sc.Scenes().GetScene(next_name)
+}
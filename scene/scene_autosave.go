@@ -1,5 +1,7 @@
 package scene
 
+import "time"
+
 // AUTOSAVE SCENE
 type autosaveScene struct {
 	sceneCommon
@@ -14,10 +16,11 @@ func (*autosaveScene) Name() string { return SceneNameAutosave }
 // +scene: autosave
 // 自動保存シーンです。
 // 現在のゲームの状態を自動保存します。
+// 自動保存を行う条件は、設定の AutoSavePolicy によって選択できます。
 const (
 	// +callback: {{.Name}}()
-	// loadendシーン以外のシーンからbaseシーンへ遷移したとき、
-	// 現在のゲームの状態を自動で保存します。
+	// 自動保存の条件を満たしたとき、例えば loadendシーン以外のシーンから
+	// baseシーンへ遷移したとき、現在のゲームの状態を自動で保存します。
 	// この関数によって、その保存処理を置き換えることができます。
 	// もし、自動保存処理を行いたくない場合、この関数を定義し、
 	// その中で何も処理を行わないことで実現できます。
@@ -29,22 +32,10 @@ func (sc *autosaveScene) Next() (Scene, error) {
 		return next, err
 	}
 
-	// from not load game scene, auto saving if allowed.
-	prev := sc.Scenes().Prev()
-	prev_is_not_load_end := prev != nil && prev.Name() != SceneNameLoadEnd
-
-	if prev_is_not_load_end && sc.Config().CanAutoSave {
-		called, err := sc.Script().checkCall(ScrAutoSaveReplace)
-		if err != nil {
+	if sc.autosaver.shouldSaveInScene(sc.Config(), sc.Scenes().Prev()) {
+		if err := autoSaveProcess(sc.sceneFields); err != nil {
 			return nil, err
 		}
-
-		if !called {
-			// do builtin-autosave flow
-			if err := saveGameSceneProcess(autoSaveNumber, sc.sceneFields); err != nil {
-				return nil, err
-			}
-		}
 	}
 
 	if scenes := sc.Scenes(); scenes.HasNext() {
@@ -53,3 +44,107 @@ func (sc *autosaveScene) Next() (Scene, error) {
 		return scenes.GetScene(SceneNameBase)
 	}
 }
+
+// autoSaver holds states of the autosave across scenes.
+type autoSaver struct {
+	// position of the next slot in the rotation. negative means not decided yet.
+	rotation int
+	// number of turns since the last autosave.
+	turns    int
+	lastSave time.Time
+
+	now func() time.Time
+}
+
+func newAutoSaver() *autoSaver {
+	return &autoSaver{rotation: -1, now: time.Now}
+}
+
+// shouldSaveInScene returns whether the autosave scene saves the game,
+// after the scene prev.
+func (as *autoSaver) shouldSaveInScene(conf Config, prev Scene) bool {
+	if prev == nil || prev.Name() == SceneNameLoadEnd {
+		return false
+	}
+	switch conf.AutoSavePolicy {
+	case "", AutoSavePolicyBase:
+		return true
+	case AutoSavePolicyTurn:
+		if prev.Name() != SceneNameTurnEnd {
+			return false
+		}
+		as.turns += 1
+		return as.turns >= conf.AutoSaveEveryTurns
+	case AutoSavePolicyInterval:
+		return as.now().Sub(as.lastSave) >= time.Duration(conf.AutoSaveIntervalSec)*time.Second
+	default:
+		return false
+	}
+}
+
+// shouldSaveOnTransition returns whether the game is saved at
+// the scene transition from the scene to the scene.
+func (as *autoSaver) shouldSaveOnTransition(conf Config, from, to string) bool {
+	if conf.AutoSavePolicy != AutoSavePolicyScene || from == SceneNameLoadEnd {
+		return false
+	}
+	for _, name := range conf.autoSaveSceneNames() {
+		if name == to {
+			return true
+		}
+	}
+	return false
+}
+
+// nextSlot returns the save number for the next autosave and advances the rotation.
+// The rotation starts with the first empty slot, or the first slot if all slots are used.
+// Because the save data has no timestamp, the rotation restarts in this way every boot.
+func (as *autoSaver) nextSlot(sf *sceneFields) int {
+	slots := autoSaveSlots(sf.Config())
+	if as.rotation < 0 || as.rotation >= len(slots) {
+		as.rotation = 0
+		for i, no := range slots {
+			if !sf.State().FileExists(no) {
+				as.rotation = i
+				break
+			}
+		}
+	}
+	no := slots[as.rotation]
+	as.rotation = (as.rotation + 1) % len(slots)
+	return no
+}
+
+// autoSaveProcess saves the game to the next autosave slot if autosave is allowed.
+// The builtin save is replaced by the script callback if it exists.
+func autoSaveProcess(sf *sceneFields) error {
+	if !sf.Config().CanAutoSave {
+		return nil
+	}
+	called, err := sf.Script().checkCall(ScrAutoSaveReplace)
+	if err != nil {
+		return err
+	}
+	if !called {
+		// do builtin-autosave flow
+		if err := saveGameSceneProcess(sf.autosaver.nextSlot(sf), sf); err != nil {
+			return err
+		}
+	}
+	sf.autosaver.turns = 0
+	sf.autosaver.lastSave = sf.autosaver.now()
+	return nil
+}
+
+// autoSaveSlots returns save numbers of the autosave slots in the rotation order.
+func autoSaveSlots(conf Config) []int {
+	n := conf.AutoSaveSlots
+	if n <= 0 {
+		n = 1
+	}
+	slots := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		slots = append(slots, autoSaveNumber-i)
+	}
+	return slots
+}
@@ -2,6 +2,7 @@ package scene
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/mzki/erago/state"
 )
@@ -15,6 +16,8 @@ type sceneFields struct {
 	scenes      *sceneHolder
 	conf        Config
 	replaceText ConfigReplaceText
+	autosaver   *autoSaver
+	scriptCalls int // the number of script calls in progress.
 }
 
 // Get Field Methods.
@@ -26,13 +29,27 @@ func (sf sceneFields) Config() Config                 { return sf.conf }
 func (sf sceneFields) ReplaceText() ConfigReplaceText { return sf.replaceText }
 
 // sceneHolder holds secne instances and next and prev scene.
+// It also holds the scene stack to return the caller scene.
 type sceneHolder struct {
 	prev Scene
 	next Scene
 
+	stack []Scene
+
 	scenes map[string]Scene
+
+	// default next scene names depending on the scene profile.
+	// empty means the next scene must be set by script.
+	afterNewGame string
+	afterLoadEnd string
+
+	// name of flow API which sets next scene. empty if not set by flow API.
+	nextBy string
 }
 
+// MaxSceneStackDepth is the maximum depth of the scene stack.
+const MaxSceneStackDepth = 32
+
 const (
 	// use for get or set scene name.
 	SceneNameTitle    = "title"
@@ -45,6 +62,7 @@ const (
 	SceneNameTrainEnd = "trainend"
 	SceneNameTurnEnd  = "turnend"
 	SceneNameLoadEnd  = "loadend"
+	SceneNameMain     = "main"
 )
 
 func newSceneHolder(sf *sceneFields) *sceneHolder {
@@ -53,17 +71,36 @@ func newSceneHolder(sf *sceneFields) *sceneHolder {
 		next: nil,
 	}
 
-	shr.scenes = map[string]Scene{
-		SceneNameTitle:    newTitleScene(sf),
-		SceneNameNewGame:  newNewGameScene(sf),
-		SceneNameAutosave: newAutosaveScene(sf),
-		SceneNameBase:     newBaseScene(sf),
-		SceneNameShop:     newShopScene(sf),
-		SceneNameTrain:    newTrainScene(sf),
-		SceneNameAblUp:    newAblUpScene(sf),
-		SceneNameTrainEnd: newTrainEndScene(sf),
-		SceneNameTurnEnd:  newTurnEndScene(sf),
-		SceneNameLoadEnd:  newLoadEndScene(sf),
+	switch sf.conf.SceneProfile {
+	case SceneProfileMinimal:
+		shr.scenes = map[string]Scene{
+			SceneNameTitle:   newTitleScene(sf),
+			SceneNameNewGame: newNewGameScene(sf),
+			SceneNameMain:    newMainScene(sf),
+			SceneNameLoadEnd: newLoadEndScene(sf),
+		}
+		shr.afterNewGame = SceneNameMain
+		shr.afterLoadEnd = SceneNameMain
+	case SceneProfileScript:
+		// loadend is always needed since loading game ends with it.
+		shr.scenes = map[string]Scene{
+			SceneNameLoadEnd: newLoadEndScene(sf),
+		}
+	default:
+		shr.scenes = map[string]Scene{
+			SceneNameTitle:    newTitleScene(sf),
+			SceneNameNewGame:  newNewGameScene(sf),
+			SceneNameAutosave: newAutosaveScene(sf),
+			SceneNameBase:     newBaseScene(sf),
+			SceneNameShop:     newShopScene(sf),
+			SceneNameTrain:    newTrainScene(sf),
+			SceneNameAblUp:    newAblUpScene(sf),
+			SceneNameTrainEnd: newTrainEndScene(sf),
+			SceneNameTurnEnd:  newTurnEndScene(sf),
+			SceneNameLoadEnd:  newLoadEndScene(sf),
+		}
+		shr.afterNewGame = SceneNameAutosave
+		shr.afterLoadEnd = SceneNameBase
 	}
 	return shr
 }
@@ -88,6 +125,59 @@ func (sh *sceneHolder) SetNextByName(name string) error {
 	return nil
 }
 
+// PushNextByName pushes the caller scene into the scene stack and
+// sets next scene by name.
+// It returns error if the stack depth exceeds MaxSceneStackDepth.
+func (sh *sceneHolder) PushNextByName(caller Scene, name string) error {
+	if caller == nil {
+		return fmt.Errorf("can not call scene %q without current scene", name)
+	}
+	if len(sh.stack) >= MaxSceneStackDepth {
+		return fmt.Errorf("calling scene %q from %q, depth must be <= %d, %s: %w",
+			name, caller.Name(), MaxSceneStackDepth, sh.stackString(), ErrorSceneStackOverflow)
+	}
+	scene, err := sh.GetScene(name)
+	if err != nil {
+		return err
+	}
+	sh.stack = append(sh.stack, caller)
+	sh.SetNext(scene)
+	return nil
+}
+
+// PopNext pops the scene from the scene stack and sets it as next scene.
+// It returns error if the scene stack is empty.
+func (sh *sceneHolder) PopNext() error {
+	if len(sh.stack) == 0 {
+		return fmt.Errorf("no scene to return, %s: %w", sh.stackString(), ErrorSceneStackEmpty)
+	}
+	last := len(sh.stack) - 1
+	scene := sh.stack[last]
+	sh.stack[last] = nil
+	sh.stack = sh.stack[:last]
+	sh.SetNext(scene)
+	return nil
+}
+
+// StackNames returns names of the scenes in the scene stack,
+// ordered from bottom to top.
+func (sh sceneHolder) StackNames() []string {
+	names := make([]string, 0, len(sh.stack))
+	for _, s := range sh.stack {
+		names = append(names, s.Name())
+	}
+	return names
+}
+
+// ClearStack removes all scenes in the scene stack.
+func (sh *sceneHolder) ClearStack() {
+	sh.stack = nil
+}
+
+func (sh sceneHolder) stackString() string {
+	return "scene stack [" + strings.Join(sh.StackNames(), " -> ") + "]"
+}
+
 func (sh *sceneHolder) SetPrev(s Scene) Scene {
 	sh.prev = s
 	return sh.prev
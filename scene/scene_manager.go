@@ -4,9 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 
 	"github.com/mzki/erago/state"
 	"github.com/mzki/erago/state/csv"
+	"github.com/mzki/erago/uiadapter/event/input"
 	"github.com/mzki/erago/util/log"
 )
 
@@ -20,23 +22,38 @@ import (
 type SceneManager struct {
 	sf *sceneFields
 
-	currentScene Scene
+	currentScene  Scene
+	journal       *transitionJournal
+	requester     ControlRequester
+	deferNotified bool // user is notified that the requests are deferred.
+}
+
+// ControlRequester provides control requests by user, input.ControlQuickSave,
+// input.ControlQuickLoad and input.ControlRewind.
+type ControlRequester interface {
+	// PopControlRequest removes the oldest request and returns it.
+	// It returns input.ControlNone if no request exists.
+	PopControlRequest() input.ControlType
+	// HasControlRequest returns whether any request exists.
+	HasControlRequest() bool
 }
 
 func NewSceneManager(game IOController, scr Scripter, state *state.GameState, config Config) *SceneManager {
 	sf := &sceneFields{
-		callbacker:  callBacker{&loggedScripter{scr}, game},
 		io:          game,
 		conf:        config,
 		state:       state,
 		replaceText: ConfigReplaceText{},
+		autosaver:   newAutoSaver(),
 	}
+	sf.callbacker = callBacker{&loggedScripter{scr, &sf.scriptCalls}, game}
 
 	sh := newSceneHolder(sf)
 	sf.scenes = sh // NOTE: cross referene
 
 	sm := &SceneManager{
-		sf: sf,
+		sf:      sf,
+		journal: newTransitionJournal(),
 	}
 	return sm
 }
@@ -70,12 +87,14 @@ func (sm *SceneManager) Run(ctx context.Context, start_scene string) (err error)
 		log.Debug("SceneManager.Run(): starting scene ", sm.currentScene.Name())
 
 		next, err := sm.currentScene.Next()
+		cause := TransitionCauseCallback
 
 		switch {
 		case err == nil:
 			// no error, do nothing.
 		case errors.Is(err, ErrorSceneNext):
 			// indicates force moving to next scene.
+			cause = TransitionCauseSceneNext
 			next = sm.sf.Scenes().Next()
 			if next == nil {
 				return fmt.Errorf("SceneManager.Run(): got going to next scene, but next scene does not set: %w", ErrorRunNextSceneNotFound)
@@ -96,9 +115,40 @@ func (sm *SceneManager) Run(ctx context.Context, start_scene string) (err error)
 			return fmt.Errorf("SceneManager.Run(): scene %v returns nil as next scene: cause by %w", sm.currentScene.Name(), ErrorRunNextSceneNotFound)
 		}
 
+		// control requests by user are processed here, where no script is running.
+		if _, err := sm.processControlRequests(); err != nil {
+			return err
+		}
+
+		nextBy := sceneHolder.nextBy
+		if cause == TransitionCauseCallback {
+			if len(nextBy) > 0 && next == sceneHolder.Next() {
+				cause = TransitionCauseFlowAPI
+			} else {
+				nextBy = ""
+			}
+		}
+		sm.journal.record(sm.currentScene.Name(), next.Name(), cause, nextBy)
+
+		if err := sm.sf.Script().dispatchChanges(); err != nil {
+			return err
+		}
+
+		if sm.sf.Config().RewindSnapshot {
+			if err := sm.sf.State().TakeSnapshot(next.Name()); err != nil {
+				return err
+			}
+		}
+		if sm.sf.autosaver.shouldSaveOnTransition(sm.sf.Config(), sm.currentScene.Name(), next.Name()) {
+			if err := autoSaveProcess(sm.sf); err != nil {
+				return err
+			}
+		}
+
 		sceneHolder.SetPrev(sm.currentScene)
 		sm.currentScene = next
 		sceneHolder.SetNext(nil)
+		sceneHolder.nextBy = ""
 	}
 	// panic("never reached")
 }
@@ -117,7 +167,10 @@ func (sm SceneManager) DoLoadGameScene() error {
 		return err
 	}
 	if next != nil {
+		// scenes called before loading are no longer valid.
+		sm.sf.scenes.ClearStack()
 		sm.sf.scenes.SetNext(next)
+		sm.sf.scenes.nextBy = "loadScene"
 		return ErrorSceneNext
 	}
 	return nil
@@ -129,7 +182,10 @@ func (sm SceneManager) DoTrainsScene(commands []int64) error {
 		return fmt.Errorf("do train can not be called from scene %s", current)
 	}
 
-	scene_train := sm.sf.Scenes().scenes[SceneNameTrain].(*trainScene)
+	scene_train, ok := sm.sf.Scenes().scenes[SceneNameTrain].(*trainScene)
+	if !ok {
+		return fmt.Errorf("do train is not available for scene profile %q", sm.sf.Config().SceneProfile)
+	}
 	train_names := sm.sf.state.CSV.MustConst(csv.BuiltinTrainName).Names
 
 	for _, cmd_no := range commands {
@@ -148,6 +204,12 @@ func (sm SceneManager) DoTrainsScene(commands []int64) error {
 	return nil
 }
 
+// EntrySceneName returns the scene name to start after booting.
+// It depends on Config.SceneProfile and Config.EntryScene.
+func (sm *SceneManager) EntrySceneName() string {
+	return sm.sf.Config().entrySceneName()
+}
+
 // Set ConfigReplaceText to replace text in the builtin scene flow.
 // It is concurrency unsafe.
 func (sm *SceneManager) SetReplaceText(config ConfigReplaceText) error {
@@ -161,7 +223,141 @@ func (sm *SceneManager) SetReplaceText(config ConfigReplaceText) error {
 // Set Next Scene using sence name, if scene name is
 // not found return error.
 func (sm SceneManager) SetNextSceneByName(scene_name string) error {
-	return sm.sf.scenes.SetNextByName(scene_name)
+	return sm.setNextBy("setNextScene", sm.sf.scenes.SetNextByName(scene_name))
+}
+
+// setNextBy records flow API name which sets next scene if no error.
+func (sm SceneManager) setNextBy(api string, err error) error {
+	if err == nil {
+		sm.sf.scenes.nextBy = api
+	}
+	return err
+}
+
+// CallSceneByName pushes current scene into the scene stack and
+// sets next scene using scene name. The pushed scene is restarted
+// by ReturnScene. It returns error if scene name is not found or
+// the scene stack exceeds MaxSceneStackDepth.
+func (sm *SceneManager) CallSceneByName(scene_name string) error {
+	return sm.setNextBy("callScene", sm.sf.scenes.PushNextByName(sm.currentScene, scene_name))
+}
+
+// ReturnScene pops the scene from the scene stack and sets it as next scene.
+// It returns error if the scene stack is empty.
+func (sm *SceneManager) ReturnScene() error {
+	return sm.setNextBy("returnScene", sm.sf.scenes.PopNext())
+}
+
+// SceneStackNames returns scene names in the scene stack,
+// ordered from bottom to top.
+func (sm *SceneManager) SceneStackNames() []string {
+	return sm.sf.scenes.StackNames()
+}
+
+// TransitionHistory returns recent scene transitions ordered from oldest to newest.
+// At most TransitionHistorySize transitions are kept.
+func (sm *SceneManager) TransitionHistory() []Transition {
+	return sm.journal.history()
+}
+
+// TransitionHistoryDump returns recent scene transitions as human readable text.
+// It is useful for crash reports.
+func (sm *SceneManager) TransitionHistoryDump() string {
+	return sm.journal.dump()
+}
+
+// WriteTransitionGraph writes the scene graph observed in scene transitions
+// as Graphviz dot format.
+func (sm *SceneManager) WriteTransitionGraph(w io.Writer) error {
+	return sm.journal.writeGraph(w)
+}
+
+// SetControlRequester sets the source of control requests by user.
+// The requests are processed at the scene transitions and at the input points
+// by HandleControlRequests. nil means no request.
+// It is concurrency unsafe.
+func (sm *SceneManager) SetControlRequester(r ControlRequester) {
+	sm.requester = r
+}
+
+// HandleControlRequests processes the control requests at the input point.
+// It is intended to be called by the input APIs before and while waiting user input.
+//
+// While script is running, the requests are deferred until the next input point
+// out of script or the next scene transition, since the script may hold the game state.
+// The user is notified that the requests are deferred.
+// If the game state is restored by the requests, it returns ErrorSceneNext to restart
+// the current scene, since the scene may hold the game state before restoring.
+func (sm *SceneManager) HandleControlRequests() error {
+	if sm.requester == nil || sm.currentScene == nil {
+		return nil
+	}
+	if sm.sf.scriptCalls > 0 {
+		if sm.deferNotified || !sm.requester.HasControlRequest() {
+			return nil
+		}
+		sm.deferNotified = true
+		return sm.sf.IO().PrintL(DefaultOrString("Deferred until script ends.", sm.sf.ReplaceText().ControlDeferred))
+	}
+	restored, err := sm.processControlRequests()
+	if err != nil || !restored {
+		return err
+	}
+	sm.sf.scenes.SetNext(sm.currentScene)
+	sm.sf.scenes.nextBy = "controlRequest"
+	return ErrorSceneNext
+}
+
+// processControlRequests processes all of the control requests.
+// It returns whether the game state is restored by the requests.
+// The scene flow is not changed by it.
+func (sm *SceneManager) processControlRequests() (bool, error) {
+	if sm.requester == nil {
+		return false, nil
+	}
+	sm.deferNotified = false
+	restored := false
+	for {
+		switch ctrl := sm.requester.PopControlRequest(); ctrl {
+		case input.ControlNone:
+			return restored, nil
+		case input.ControlQuickSave:
+			if err := quickSaveProcess(sm.sf); err != nil {
+				return false, err
+			}
+		case input.ControlQuickLoad:
+			loaded, err := quickLoadProcess(sm.sf)
+			if err != nil {
+				return false, err
+			}
+			restored = restored || loaded
+		case input.ControlRewind:
+			replace := sm.sf.ReplaceText()
+			msg := DefaultOrString("Rewound.", replace.Rewound)
+			_, err := rewindProcess(sm.sf, 1)
+			switch {
+			case errors.Is(err, state.ErrNoSnapshot):
+				// user can request it at any time, so that no snapshot is not error.
+				msg = DefaultOrString("No snapshot to rewind.", replace.NoSnapshot)
+			case err != nil:
+				return false, err
+			default:
+				restored = true
+			}
+			if err := sm.sf.IO().PrintL(msg); err != nil {
+				return false, err
+			}
+		default:
+			log.Debugf("SceneManager: unknown control request %v, ignored", ctrl)
+		}
+	}
+}
+
+// Rewind restores game state from n-th latest snapshot, where n starts from 1,
+// and returns label of the snapshot. It does not change the scene flow.
+// It returns error wrapping state.ErrNoSnapshot if the number of snapshots is less than n.
+func (sm *SceneManager) Rewind(n int) (string, error) {
+	return rewindProcess(sm.sf, n)
 }
 
 // NextSceneName returns next scene name. If next scene is not set, return empty string.
@@ -191,6 +387,32 @@ func (sm SceneManager) RegisterSceneFunc(name string, next_func func() (string,
 	sm.RegisterScene(new_scene)
 }
 
+// register script-defined scene flow into scene transition
+// using scene name and next function.
+// Unlike RegisterSceneFunc, the scene has start handling same as builtin scenes,
+// and empty name returned by next function means next scene set by script.
+// It returns error if the name is empty or used by the scene which is not defined by script.
+//
+// +scene: [custom]
+// era.flow.registerScene によってスクリプトで定義されたシーンです。
+// [custom] は登録したシーン名に置き換えてください。
+// 組み込みのシーンと同様に、シーンの始めに [custom]_event_start イベントが発行され、
+// [custom]_scene および [custom]_event_start が定義されていれば呼び出されます。
+// その後、登録した next 関数が呼び出され、その戻り値のシーン名が次のシーンになります。
+// next 関数が nil を返した場合は、era.flow.setNextScene で設定されたシーンが次のシーンになります。
+func (sm SceneManager) RegisterScriptSceneFunc(name string, next_func func() (string, error)) error {
+	if len(name) == 0 {
+		return fmt.Errorf("scene name must not be empty")
+	}
+	if s, err := sm.sf.scenes.GetScene(name); err == nil {
+		if _, ok := s.(*scriptScene); !ok {
+			return fmt.Errorf("scene %q is already registered by system", name)
+		}
+	}
+	sm.RegisterScene(newScriptScene(name, NextFunc(next_func), sm.sf))
+	return nil
+}
+
 // unregister Scene from scene trainsition.
 // if not registered scene name is passed do nothing.
 func (sm SceneManager) UnRegisterScene(name string) {
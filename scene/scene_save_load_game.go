@@ -50,7 +50,7 @@ func loadGameSceneProcess(sf *sceneFields) (Scene, error) {
 		case 100 == input:
 			return nil, nil
 
-		case 0 <= input && input < 20 || input == 99:
+		case 0 <= input && input < 20 || input == quickSaveNumber || isAutoSaveSlot(sf.Config(), input):
 			gstate := sf.State()
 			if gstate.FileExists(input) {
 				if err := gstate.LoadSystem(input); err != nil {
@@ -142,6 +142,44 @@ func saveGameSceneProcess(No int, sf *sceneFields) error {
 	return sf.State().SaveSystem(No)
 }
 
+// quickSaveProcess saves the game to the quick save slot. Unlike saveGameSceneProcess,
+// it does not call script since it is called by user request out of script.
+func quickSaveProcess(sf *sceneFields) error {
+	gstate := sf.State()
+	gstate.SaveComment = "Quick " + time.Now().Format("2006/01/02 15:04:05")
+	if err := gstate.SaveSystem(quickSaveNumber); err != nil {
+		return err
+	}
+	return sf.IO().PrintL(DefaultOrString("Quick saved.", sf.ReplaceText().QuickSaved))
+}
+
+// quickLoadProcess loads the game from the quick save slot, and returns whether it is loaded.
+// Nothing is loaded if the quick save does not exist.
+// Unlike loadGameSceneProcess, it does not change the scene flow.
+func quickLoadProcess(sf *sceneFields) (bool, error) {
+	gstate := sf.State()
+	if !gstate.FileExists(quickSaveNumber) {
+		return false, sf.IO().PrintL(DefaultOrString("No quick save data.", sf.ReplaceText().NoQuickSave))
+	}
+	if err := gstate.LoadSystem(quickSaveNumber); err != nil {
+		return false, err
+	}
+	if err := sf.Script().reloadParams(); err != nil {
+		return false, err
+	}
+	return true, sf.IO().PrintL(DefaultOrString("Quick loaded.", sf.ReplaceText().QuickLoaded))
+}
+
+// rewindProcess restores the game from n-th latest snapshot and returns its label.
+// Like quickLoadProcess, the variables held by the script are reloaded.
+func rewindProcess(sf *sceneFields, n int) (string, error) {
+	label, err := sf.State().Rewind(n)
+	if err != nil {
+		return "", err
+	}
+	return label, sf.Script().reloadParams()
+}
+
 // * LOAD END SCENE
 type loadEndScene struct {
 	sceneCommon
@@ -166,8 +204,11 @@ func (ld *loadEndScene) Next() (Scene, error) {
 
 	if ss := ld.Scenes(); ss.HasNext() {
 		return ss.Next(), nil
+	} else if len(ss.afterLoadEnd) == 0 {
+		return nil, fmt.Errorf("%s: next scene must be set by script for scene profile %q: %w",
+			SceneNameLoadEnd, ld.Config().SceneProfile, ErrorRunNextSceneNotFound)
 	} else {
-		return ss.GetScene(SceneNameBase)
+		return ss.GetScene(ss.afterLoadEnd)
 	}
 }
 
@@ -177,13 +218,28 @@ func printSaveListsScene(sf *sceneFields) {
 	sf.IO().PrintL("[100] " + DefaultOrString("Back", sf.ReplaceText().ReturnMenu))
 }
 
+// quickSaveNumber is the slot dedicated to quick save.
+// It is separated from manual save and autosave slots.
+const quickSaveNumber = 89
+
+// autoSaveNumber is the first autosave slot. The rest of autosave slots
+// are numbered downward from it.
 const autoSaveNumber = 99
 
+func isAutoSaveSlot(conf Config, no int) bool {
+	for _, slot := range autoSaveSlots(conf) {
+		if slot == no {
+			return true
+		}
+	}
+	return false
+}
+
 func printSaveLists(sf *sceneFields) {
-	buildSaveTitle := func(list []*state.MetaData, i, no int) string {
+	buildSaveTitle := func(header *state.MetaData, no int) string {
 		save_title := fmt.Sprintf("[%2d] ", no)
 
-		if header := list[i]; header == nil {
+		if header == nil {
 			save_title += "----"
 		} else {
 			save_title += header.Title
@@ -191,16 +247,21 @@ func printSaveLists(sf *sceneFields) {
 		return save_title
 	}
 
-	list := buildHeaderLists(sf.State())
-	for i := 0; i < len(list)-1; i++ {
-		sf.IO().PrintL(buildSaveTitle(list, i, i))
+	extras := append([]int{quickSaveNumber}, autoSaveSlots(sf.Config())...)
+	list := buildHeaderLists(sf.State(), extras)
+	for i := 0; i < 20; i++ {
+		sf.IO().PrintL(buildSaveTitle(list[i], i))
+	}
+	// quick save and auto save numbers
+	for i, no := range extras {
+		sf.IO().PrintL(buildSaveTitle(list[20+i], no))
 	}
-	// auto save number
-	sf.IO().PrintL(buildSaveTitle(list, len(list)-1, autoSaveNumber))
 }
 
-func buildHeaderLists(gstate *state.GameState) []*state.MetaData {
-	list := make([]*state.MetaData, 21)
+// buildHeaderLists returns headers of the manual save slots 0-19,
+// followed by ones of the extra slots. nil header means no save data.
+func buildHeaderLists(gstate *state.GameState, extras []int) []*state.MetaData {
+	list := make([]*state.MetaData, 20+len(extras))
 
 	for i := 0; i < 20; i++ {
 		if gstate.FileExists(i) {
@@ -211,11 +272,13 @@ func buildHeaderLists(gstate *state.GameState) []*state.MetaData {
 			}
 		}
 	}
-	// auto save number
-	if header, err := gstate.LoadHeader(autoSaveNumber); err != nil {
-		log.Debug("load autosave header: ", header, err)
-	} else {
-		list[20] = header
+	// quick save and auto save numbers
+	for i, no := range extras {
+		if header, err := gstate.LoadHeader(no); err != nil {
+			log.Debug("load extra save header: ", header, err)
+		} else {
+			list[20+i] = header
+		}
 	}
 	return list
 }
@@ -3,9 +3,17 @@ package scene
 import (
 	"context"
 	"errors"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/mzki/erago/infra/repo"
+	"github.com/mzki/erago/state"
+	"github.com/mzki/erago/state/csv"
 	"github.com/mzki/erago/stub"
+	"github.com/mzki/erago/uiadapter/event/input"
 )
 
 func buildSceneManager() *SceneManager {
@@ -129,3 +137,963 @@ func TestSceneNextName(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// emitRecorder records emitted events.
+type emitRecorder struct {
+	Scripter
+	emitted []string
+}
+
+func (r *emitRecorder) EraEmit(name string, args ...int64) error {
+	r.emitted = append(r.emitted, name)
+	return nil
+}
+
+func TestSceneEmitStartEvent(t *testing.T) {
+	controller := stub.NewFlowGameController()
+	scripter := &emitRecorder{Scripter: stub.NewSceneScripter()}
+	state, err := stub.GetGameState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewSceneManager(controller, scripter, state, Config{})
+	defer m.Free()
+
+	turnend, err := m.sf.scenes.GetScene(SceneNameTurnEnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := turnend.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.Name() != SceneNameAutosave {
+		t.Errorf("invalid next scene, expect: %v, got: %v", SceneNameAutosave, next.Name())
+	}
+	if expect := []string{"turnend_event_start"}; !reflect.DeepEqual(scripter.emitted, expect) {
+		t.Errorf("invalid emitted events, expect: %v, got: %v", expect, scripter.emitted)
+	}
+}
+
+func TestSceneCallAndReturn(t *testing.T) {
+	m := buildSceneManager()
+	defer m.Free()
+
+	visited := []string{}
+	m.RegisterSceneFunc("caller", func() (string, error) {
+		visited = append(visited, "caller")
+		if len(visited) > 1 {
+			return "unknown scene name", nil
+		}
+		if err := m.CallSceneByName("menu"); err != nil {
+			return "", err
+		}
+		return "", ErrorSceneNext
+	})
+	m.RegisterSceneFunc("menu", func() (string, error) {
+		visited = append(visited, "menu")
+		if names := m.SceneStackNames(); !reflect.DeepEqual(names, []string{"caller"}) {
+			t.Errorf("invalid scene stack, expect: %v, got: %v", []string{"caller"}, names)
+		}
+		if err := m.ReturnScene(); err != nil {
+			return "", err
+		}
+		return "", ErrorSceneNext
+	})
+
+	err := m.Run(context.Background(), "caller")
+	if !errors.Is(err, ErrorRunNextSceneNotFound) {
+		t.Fatal(err)
+	}
+	if expect := []string{"caller", "menu", "caller"}; !reflect.DeepEqual(visited, expect) {
+		t.Errorf("invalid scene transition, expect: %v, got: %v", expect, visited)
+	}
+	if names := m.SceneStackNames(); len(names) != 0 {
+		t.Errorf("scene stack should be empty after return, got: %v", names)
+	}
+}
+
+func TestSceneStackErrors(t *testing.T) {
+	m := buildSceneManager()
+	defer m.Free()
+
+	if err := m.ReturnScene(); !errors.Is(err, ErrorSceneStackEmpty) {
+		t.Errorf("return with empty stack should be error, got: %v", err)
+	}
+
+	// current scene is not set before Run.
+	if err := m.CallSceneByName(SceneNameShop); err == nil {
+		t.Error("call scene without current scene should be error")
+	}
+
+	title, err := m.sf.scenes.GetScene(SceneNameTitle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.currentScene = title
+	if err := m.CallSceneByName("unknown scene name"); !errors.Is(err, ErrorSceneNameNotRegistered) {
+		t.Errorf("call unknown scene should be error, got: %v", err)
+	}
+	for i := 0; i < MaxSceneStackDepth; i++ {
+		if err := m.CallSceneByName(SceneNameShop); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = m.CallSceneByName(SceneNameShop)
+	if !errors.Is(err, ErrorSceneStackOverflow) {
+		t.Fatalf("call scene exceeding max depth should be error, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "scene stack [title -> title") {
+		t.Errorf("error should contain the stack contents, got: %v", err)
+	}
+}
+
+func TestSceneScriptScene(t *testing.T) {
+	controller := stub.NewFlowGameController()
+	scripter := &emitRecorder{Scripter: stub.NewSceneScripter()}
+	state, err := stub.GetGameState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewSceneManager(controller, scripter, state, Config{})
+	defer m.Free()
+
+	if err := m.RegisterScriptSceneFunc(SceneNameTitle, func() (string, error) { return "", nil }); err == nil {
+		t.Error("script scene should not replace builtin scene")
+	}
+	if err := m.RegisterScriptSceneFunc("menu", func() (string, error) { return SceneNameBase, nil }); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RegisterScriptSceneFunc("menu2", func() (string, error) {
+		return "", m.SetNextSceneByName("menu")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// script scene can be re-registered.
+	if err := m.RegisterScriptSceneFunc("menu", func() (string, error) { return SceneNameShop, nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, testcase := range []struct {
+		name string
+		next string
+	}{
+		{"menu", SceneNameShop},
+		{"menu2", "menu"},
+	} {
+		s, err := m.sf.scenes.GetScene(testcase.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		next, err := s.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if next.Name() != testcase.next {
+			t.Errorf("invalid next scene, expect: %v, got: %v", testcase.next, next.Name())
+		}
+	}
+	if expect := []string{"menu_event_start", "menu2_event_start"}; !reflect.DeepEqual(scripter.emitted, expect) {
+		t.Errorf("invalid emitted events, expect: %v, got: %v", expect, scripter.emitted)
+	}
+}
+
+func TestSceneProfiles(t *testing.T) {
+	state, err := stub.GetGameState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newManager := func(config Config) *SceneManager {
+		return NewSceneManager(stub.NewFlowGameController(), stub.NewSceneScripter(), state, config)
+	}
+
+	// minimal
+	m := newManager(Config{SceneProfile: SceneProfileMinimal})
+	defer m.Free()
+	for _, name := range []string{SceneNameTitle, SceneNameNewGame, SceneNameMain, SceneNameLoadEnd} {
+		if !m.SceneExists(name) {
+			t.Errorf("minimal profile must have the scene %s", name)
+		}
+	}
+	if m.SceneExists(SceneNameBase) {
+		t.Errorf("minimal profile must NOT have the scene %s", SceneNameBase)
+	}
+	for _, name := range []string{SceneNameNewGame, SceneNameLoadEnd} {
+		s, err := m.sf.scenes.GetScene(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		next, err := s.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if next.Name() != SceneNameMain {
+			t.Errorf("%s: invalid next scene, expect: %v, got: %v", name, SceneNameMain, next.Name())
+		}
+	}
+
+	// script
+	m = newManager(Config{SceneProfile: SceneProfileScript, EntryScene: "opening"})
+	defer m.Free()
+	if m.SceneExists(SceneNameTitle) {
+		t.Errorf("script profile must NOT have the scene %s", SceneNameTitle)
+	}
+	if got := m.EntrySceneName(); got != "opening" {
+		t.Errorf("invalid entry scene, expect: opening, got: %v", got)
+	}
+	if err := m.RegisterScriptSceneFunc(SceneNameTitle, func() (string, error) { return "", nil }); err != nil {
+		t.Errorf("script profile should accept script scene named %s, but %v", SceneNameTitle, err)
+	}
+	loadend, err := m.sf.scenes.GetScene(SceneNameLoadEnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadend.Next(); !errors.Is(err, ErrorRunNextSceneNotFound) {
+		t.Errorf("loadend without next scene should be ErrorRunNextSceneNotFound, got: %v", err)
+	}
+	m.SetNextSceneByName(SceneNameTitle)
+	if next, err := loadend.Next(); err != nil || next.Name() != SceneNameTitle {
+		t.Errorf("loadend should go to next scene set by script, got: %v, %v", next, err)
+	}
+
+	// eramaker by default
+	m = newManager(Config{})
+	defer m.Free()
+	if got := m.EntrySceneName(); got != SceneNameTitle {
+		t.Errorf("invalid entry scene, expect: %v, got: %v", SceneNameTitle, got)
+	}
+}
+
+func TestSceneTransitionHistory(t *testing.T) {
+	m := buildSceneManager()
+	defer m.Free()
+
+	m.RegisterSceneFunc("b", func() (string, error) { return "c", nil })
+	m.RegisterSceneFunc("c", func() (string, error) {
+		if err := m.CallSceneByName("a"); err != nil {
+			return "", err
+		}
+		return "", ErrorSceneNext
+	})
+	count := 0
+	m.RegisterSceneFunc("a", func() (string, error) {
+		count += 1
+		if count > 1 {
+			return "", ErrorQuit
+		}
+		return "b", m.SetNextSceneByName("b")
+	})
+	if err := m.Run(context.Background(), "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []Transition{
+		{From: "a", To: "b", Cause: TransitionCauseFlowAPI, Detail: "setNextScene"},
+		{From: "b", To: "c", Cause: TransitionCauseCallback},
+		{From: "c", To: "a", Cause: TransitionCauseSceneNext, Detail: "callScene"},
+	}
+	got := m.TransitionHistory()
+	for i := range got {
+		got[i].Time = time.Time{}
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("invalid history, expect: %v, got: %v", expect, got)
+	}
+
+	var sb strings.Builder
+	if err := m.WriteTransitionGraph(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(sb.String(), `"c" -> "a" [label="1"];`) {
+		t.Errorf("graph should contain observed edge, got: %v", sb.String())
+	}
+	if dump := m.TransitionHistoryDump(); !strings.Contains(dump, "c -> a (scene_next:callScene)") {
+		t.Errorf("dump should contain transition, got: %v", dump)
+	}
+}
+
+func TestTransitionJournalBounded(t *testing.T) {
+	j := newTransitionJournal()
+	for i := 0; i < TransitionHistorySize+10; i++ {
+		j.record(strconv.Itoa(i), strconv.Itoa(i+1), TransitionCauseCallback, "")
+	}
+	history := j.history()
+	if len(history) != TransitionHistorySize {
+		t.Fatalf("history must be bounded by %d, got: %d", TransitionHistorySize, len(history))
+	}
+	if first, last := history[0].From, history[len(history)-1].From; first != "10" || last != strconv.Itoa(TransitionHistorySize+9) {
+		t.Errorf("history must keep newest transitions in order, got first: %v, last: %v", first, last)
+	}
+}
+
+// priceScripter replaces item price by base price + 1.
+type priceScripter struct {
+	Scripter
+}
+
+func (s priceScripter) HasEraValue(name string) bool {
+	return name == ScrShopReplacePrice || s.Scripter.HasEraValue(name)
+}
+
+func (s priceScripter) EraCallIntArgInts(name string, args ...int64) (int64, error) {
+	if name != ScrShopReplacePrice {
+		return s.Scripter.EraCallIntArgInts(name, args...)
+	}
+	return args[1] + 1, nil
+}
+
+func TestSceneShopBuyAndSell(t *testing.T) {
+	controller := stub.NewFlowGameController()
+	state, err := stub.GetGameState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := Config{ShopBuyQuantity: true, ShopSellRate: 50, ShopLimitStock: true}
+	m := NewSceneManager(controller, priceScripter{stub.NewSceneScripter()}, state, config)
+	defer m.Free()
+
+	money, _ := state.SystemData.GetInt(csv.BuiltinMoneyName)
+	item, _ := state.SystemData.GetInt(csv.BuiltinItemName)
+	stock, _ := state.SystemData.GetInt(csv.BuiltinItemStockName)
+	const no = 0
+	defer func(m, i, s int64) {
+		money.Set(0, m)
+		item.Set(no, i)
+		stock.Set(no, s)
+	}(money.Get(0), item.Get(no), stock.Get(no))
+	money.Set(0, 100000)
+	item.Set(no, 0)
+	stock.Set(no, 5)
+
+	sc := m.sf.scenes.scenes[SceneNameShop].(*shopScene)
+	basePrice := state.CSV.ItemPrices[no]
+
+	// buy 3 items by replaced price.
+	controller.Append("3")
+	if _, err := sc.buyItem(no); err != nil {
+		t.Fatal(err)
+	}
+	buyPrice := basePrice + 1
+	if got, expect := money.Get(0), 100000-buyPrice*3; got != expect {
+		t.Errorf("invalid money after buy, expect: %v, got: %v", expect, got)
+	}
+	if item.Get(no) != 3 || stock.Get(no) != 2 {
+		t.Errorf("invalid item and stock after buy, got: %v, %v", item.Get(no), stock.Get(no))
+	}
+
+	// sell 2 items with sell rate.
+	controller.Append("2")
+	if _, err := sc.sellItem(no); err != nil {
+		t.Fatal(err)
+	}
+	sellPrice := basePrice*50/100 + 1
+	if got, expect := money.Get(0), 100000-buyPrice*3+sellPrice*2; got != expect {
+		t.Errorf("invalid money after sell, expect: %v, got: %v", expect, got)
+	}
+	if item.Get(no) != 1 || stock.Get(no) != 4 {
+		t.Errorf("invalid item and stock after sell, got: %v, %v", item.Get(no), stock.Get(no))
+	}
+
+	// canceled by quantity 0.
+	controller.Append("0")
+	if _, err := sc.buyItem(no); err != nil {
+		t.Fatal(err)
+	}
+	if item.Get(no) != 1 || stock.Get(no) != 4 {
+		t.Errorf("item and stock should not be changed by canceling, got: %v, %v", item.Get(no), stock.Get(no))
+	}
+
+	// out of stock.
+	stock.Set(no, 0)
+	if _, err := sc.buyItem(no); err != nil {
+		t.Fatal(err)
+	}
+	if item.Get(no) != 1 {
+		t.Errorf("item should not be bought without stock, got: %v", item.Get(no))
+	}
+
+	// sell with large negative money, which must not overflow the maximum quantity.
+	const negativeMoney = -(1 << 62)
+	money.Set(0, negativeMoney)
+	controller.Append("1")
+	if _, err := sc.sellItem(no); err != nil {
+		t.Fatal(err)
+	}
+	if got, expect := money.Get(0), negativeMoney+sellPrice; got != expect {
+		t.Errorf("invalid money after sell with negative money, expect: %v, got: %v", expect, got)
+	}
+	if item.Get(no) != 0 {
+		t.Errorf("item should be sold with negative money, got: %v", item.Get(no))
+	}
+}
+
+// definedScripter has the script functions defined.
+type definedScripter struct {
+	Scripter
+	defined map[string]bool
+}
+
+func (s *definedScripter) HasEraValue(name string) bool { return s.defined[name] }
+
+func TestSceneAblUpBuiltin(t *testing.T) {
+	controller := stub.NewFlowGameController()
+	state, err := stub.GetGameState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewSceneManager(controller, stub.NewSceneScripter(), state, Config{})
+	defer m.Free()
+
+	sysdata := state.SystemData
+	target := sysdata.Chara.AddEmptyCharacter()
+	defer sysdata.Chara.Clear()
+	if err := sysdata.Target.Set(0, target); err != nil {
+		t.Fatal(err)
+	}
+	defer sysdata.Target.Clear()
+
+	juels, _ := target.GetInt(csv.BuiltinJuelName)
+	abls, _ := target.GetInt(csv.BuiltinAblName)
+	juels.Set(0, 15)
+
+	sc := m.sf.scenes.scenes[SceneNameAblUp].(*ablUpScene)
+
+	// requires Juel[0] = 10 to Abl[0] Lv1.
+	if used, err := sc.selectBuiltin(0); err != nil || !used {
+		t.Fatalf("ablup should be done, got: %v, %v", used, err)
+	}
+	if abls.Get(0) != 1 || juels.Get(0) != 5 {
+		t.Errorf("invalid abl and juel after ablup, got: %v, %v", abls.Get(0), juels.Get(0))
+	}
+	// Lv2 requires more juels, exp and talent.
+	if used, err := sc.selectBuiltin(0); err != nil || used {
+		t.Errorf("ablup should not be done, got: %v, %v", used, err)
+	}
+	// Abl[1] requires not to have Talent[1].
+	juels.Set(1, 10)
+	talents, _ := target.GetInt(csv.BuiltinTalentName)
+	talents.Set(1, 1)
+	if used, _ := sc.selectBuiltin(1); used {
+		t.Error("ablup should not be done with the talent")
+	}
+	talents.Set(1, 0)
+	if used, _ := sc.selectBuiltin(1); !used || abls.Get(1) != 1 {
+		t.Errorf("ablup should be done without the talent, got abl: %v", abls.Get(1))
+	}
+
+	// same juel required twice is consumed by the sum of amounts.
+	defer func(ablups []csv.AblUpRequirement) { state.CSV.AblUps = ablups }(state.CSV.AblUps)
+	state.CSV.AblUps = []csv.AblUpRequirement{{
+		Abl: 2, Level: 1,
+		Juels: []csv.AmountRequirement{{Index: 2, Amount: 6}, {Index: 2, Amount: 6}},
+	}}
+	juels.Set(2, 10)
+	if used, err := sc.selectBuiltin(2); err != nil || used {
+		t.Errorf("ablup should not be done with less than the sum of juels, got: %v, %v", used, err)
+	}
+	if abls.Get(2) != 0 || juels.Get(2) != 10 {
+		t.Errorf("abl and juel should not be changed, got: %v, %v", abls.Get(2), juels.Get(2))
+	}
+	juels.Set(2, 12)
+	if used, err := sc.selectBuiltin(2); err != nil || !used {
+		t.Errorf("ablup should be done with the sum of juels, got: %v, %v", used, err)
+	}
+	if abls.Get(2) != 1 || juels.Get(2) != 0 {
+		t.Errorf("invalid abl and juel after ablup, got: %v, %v", abls.Get(2), juels.Get(2))
+	}
+
+	// menu callbacks are used as a pair.
+	if !sc.useBuiltinMenu() {
+		t.Error("builtin menu should be used without the menu callbacks")
+	}
+	scripter := &definedScripter{Scripter: stub.NewSceneScripter(), defined: map[string]bool{ScrAblUpUserShowMenu: true}}
+	m2 := NewSceneManager(controller, scripter, state, Config{})
+	defer m2.Free()
+	if m2.sf.scenes.scenes[SceneNameAblUp].(*ablUpScene).useBuiltinMenu() {
+		t.Error("builtin menu should not be used with one of the menu callbacks")
+	}
+
+	// back to turnend.
+	if used, err := sc.selectBuiltin(ablUpInputBack); err != nil || !used {
+		t.Fatalf("back should be handled, got: %v, %v", used, err)
+	}
+	if got := m.NextSceneName(); got != SceneNameTurnEnd {
+		t.Errorf("invalid next scene, expect: %v, got: %v", SceneNameTurnEnd, got)
+	}
+}
+
+func TestParseTrainCommands(t *testing.T) {
+	for _, testcase := range []struct {
+		Input   string
+		HasLast bool
+		Expect  []int64
+		IsErr   bool
+	}{
+		{"3", false, []int64{3}, false},
+		{"3 3 5", false, []int64{3, 3, 5}, false},
+		{"3,5", false, []int64{3, 5}, false},
+		{"3x3", false, []int64{3, 3, 3}, false},
+		{"repeat", true, []int64{7}, false},
+		{"repeat 3x", true, []int64{7, 7, 7}, false},
+		{"1 REPEAT 2X", true, []int64{1, 7, 7}, false},
+		{"repeat", false, nil, true},
+		{"", false, nil, true},
+		{"abc", false, nil, true},
+		{"3xa", false, nil, true},
+		{"3x0", false, nil, true},
+		{"1 2 3 4 5", false, nil, true},
+		{"3x5", false, nil, true},
+	} {
+		got, err := parseTrainCommands(testcase.Input, 7, testcase.HasLast, 4)
+		if testcase.IsErr != (err != nil) {
+			t.Errorf("%q: unexpected error state, expect error: %v, got: %v", testcase.Input, testcase.IsErr, err)
+			continue
+		}
+		if !testcase.IsErr && !reflect.DeepEqual(got, testcase.Expect) {
+			t.Errorf("%q: expect: %v, got: %v", testcase.Input, testcase.Expect, got)
+		}
+	}
+}
+
+// trainScripter executes any train command except cmdDisabled.
+type trainScripter struct {
+	Scripter
+	cmdDisabled int64
+	executed    []int64
+}
+
+func (s *trainScripter) HasEraValue(name string) bool {
+	return name == ScrTrainReplaceCmdAble || name == ScrTrainUserCmd || name == ScrTrainUserCheckSource
+}
+
+func (s *trainScripter) EraCallBoolArgInt(name string, n int64) (bool, error) {
+	switch name {
+	case ScrTrainReplaceCmdAble:
+		return n != s.cmdDisabled, nil
+	case ScrTrainUserCmd:
+		s.executed = append(s.executed, n)
+		return true, nil
+	}
+	return false, nil
+}
+
+func TestSceneTrainQueue(t *testing.T) {
+	controller := stub.NewFlowGameController()
+	scripter := &trainScripter{Scripter: stub.NewSceneScripter(), cmdDisabled: 5}
+	state, err := stub.GetGameState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewSceneManager(controller, scripter, state, Config{TrainQueueLimit: 10})
+	defer m.Free()
+
+	ts := m.sf.scenes.scenes[SceneNameTrain].(*trainScene)
+	ts.setCanDoTrain(true)
+	if err := ts.CheckAllTrainCommands(); err != nil {
+		t.Fatal(err)
+	}
+
+	// stops at disabled command 5.
+	controller.Append("3 1 5 2")
+	commands, err := ts.inputCommands()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.doTrainQueue(commands); err != nil {
+		t.Fatal(err)
+	}
+	if expect := []int64{3, 1}; !reflect.DeepEqual(scripter.executed, expect) {
+		t.Errorf("invalid executed commands, expect: %v, got: %v", expect, scripter.executed)
+	}
+
+	// repeat last executed command.
+	scripter.executed = nil
+	controller.Append("repeat 2x")
+	commands, err = ts.inputCommands()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.doTrainQueue(commands); err != nil {
+		t.Fatal(err)
+	}
+	if expect := []int64{1, 1}; !reflect.DeepEqual(scripter.executed, expect) {
+		t.Errorf("invalid executed commands, expect: %v, got: %v", expect, scripter.executed)
+	}
+}
+
+// calendarScripter records occurred calendar events.
+type calendarScripter struct {
+	Scripter
+	events []int64
+}
+
+func (s *calendarScripter) HasEraValue(name string) bool {
+	return name == ScrTurnEndEventCalendar
+}
+
+func (s *calendarScripter) EraCallBoolArgInt(name string, n int64) (bool, error) {
+	if name == ScrTurnEndEventCalendar {
+		s.events = append(s.events, n)
+	}
+	return false, nil
+}
+
+func TestSceneTurnEndCalendar(t *testing.T) {
+	controller := stub.NewFlowGameController()
+	scripter := &calendarScripter{Scripter: stub.NewSceneScripter()}
+	state, err := stub.GetGameState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewSceneManager(controller, scripter, state, Config{})
+	defer m.Free()
+
+	cal := state.Calendar()
+	defer cal.SetTime(cal.Time())
+	// the night before the first morning of day 1.
+	cal.SetTime(3)
+
+	tes := m.sf.scenes.scenes[SceneNameTurnEnd].(*turnEndScene)
+	next, err := tes.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next == nil || next.Name() != SceneNameAutosave {
+		t.Errorf("next scene should be autosave, got: %v", next)
+	}
+	if cal.Time() != 4 || cal.SlotName() != "朝" {
+		t.Errorf("calendar should advance to the morning, got time: %v", cal.Time())
+	}
+	// event 0 occurs every morning.
+	if expect := []int64{0}; !reflect.DeepEqual(scripter.events, expect) {
+		t.Errorf("invalid calendar events, expect: %v, got: %v", expect, scripter.events)
+	}
+}
+
+func TestSceneAutoSaveRotation(t *testing.T) {
+	csvm, err := stub.GetCSV()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gamestate := state.NewGameState(csvm, repo.NewFileRepository(csvm, repo.Config{SaveFileDir: t.TempDir()}))
+	controller := stub.NewFlowGameController()
+	m := NewSceneManager(controller, stub.NewSceneScripter(), gamestate, Config{
+		CanAutoSave:        true,
+		AutoSaveSlots:      3,
+		AutoSavePolicy:     AutoSavePolicyTurn,
+		AutoSaveEveryTurns: 2,
+	})
+	defer m.Free()
+
+	sh := m.sf.Scenes()
+	sc := sh.scenes[SceneNameAutosave]
+	sh.SetPrev(sh.scenes[SceneNameTurnEnd])
+
+	// saves at 2nd and 4th turn.
+	for i := 0; i < 4; i++ {
+		if _, err := sc.Next(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for no, exist := range map[int]bool{99: true, 98: true, 97: false} {
+		if got := gamestate.FileExists(no); got != exist {
+			t.Errorf("autosave slot %d: expect exist %v, got %v", no, exist, got)
+		}
+	}
+	// 3rd and 4th autosave rotate to slot 97 and 99.
+	for i := 0; i < 4; i++ {
+		if _, err := sc.Next(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !gamestate.FileExists(97) || gamestate.FileExists(96) {
+		t.Error("autosave should rotate only in 3 slots")
+	}
+	if m.sf.autosaver.rotation != 1 {
+		t.Errorf("next autosave should be the second slot, got: %v", m.sf.autosaver.rotation)
+	}
+
+	// autosave slots are listed in the load scene.
+	controller.Append("98")
+	next, err := loadGameSceneProcess(m.sf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next == nil || next.Name() != SceneNameLoadEnd {
+		t.Errorf("autosave slot should be loaded, got next scene: %v", next)
+	}
+}
+
+func TestAutoSaverPolicy(t *testing.T) {
+	as := newAutoSaver()
+	now := time.Unix(1000, 0)
+	as.now = func() time.Time { return now }
+
+	conf := Config{AutoSavePolicy: AutoSavePolicyScene, AutoSaveScenes: "base, shop"}
+	if !as.shouldSaveOnTransition(conf, SceneNameBase, SceneNameShop) {
+		t.Error("should save when the shop scene starts")
+	}
+	if as.shouldSaveOnTransition(conf, SceneNameLoadEnd, SceneNameShop) || as.shouldSaveOnTransition(conf, SceneNameBase, SceneNameTrain) {
+		t.Error("should not save after loadend or for other scenes")
+	}
+
+	conf = Config{AutoSavePolicy: AutoSavePolicyInterval, AutoSaveIntervalSec: 60}
+	turnend := newTurnEndScene(&sceneFields{})
+	as.lastSave = now
+	now = now.Add(30 * time.Second)
+	if as.shouldSaveInScene(conf, turnend) {
+		t.Error("should not save before the interval")
+	}
+	now = now.Add(30 * time.Second)
+	if !as.shouldSaveInScene(conf, turnend) {
+		t.Error("should save after the interval")
+	}
+}
+
+// controlQueue queues the control requests for test.
+type controlQueue []input.ControlType
+
+func (q *controlQueue) PopControlRequest() input.ControlType {
+	if len(*q) == 0 {
+		return input.ControlNone
+	}
+	ctrl := (*q)[0]
+	*q = (*q)[1:]
+	return ctrl
+}
+
+func (q *controlQueue) HasControlRequest() bool {
+	return len(*q) > 0
+}
+
+// printRecorder records the printed lines.
+type printRecorder struct {
+	IOController
+	lines []string
+}
+
+func (r *printRecorder) PrintL(s string) error {
+	r.lines = append(r.lines, s)
+	return nil
+}
+
+func TestSceneQuickSaveLoad(t *testing.T) {
+	csvm, err := stub.GetCSV()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gamestate := state.NewGameState(csvm, repo.NewFileRepository(csvm, repo.Config{SaveFileDir: t.TempDir()}))
+	controller := stub.NewFlowGameController()
+	m := NewSceneManager(controller, stub.NewSceneScripter(), gamestate, Config{})
+	defer m.Free()
+	requests := &controlQueue{}
+	m.SetControlRequester(requests)
+
+	money, _ := gamestate.SystemData.GetInt(csv.BuiltinMoneyName)
+	m.RegisterSceneFunc("a", func() (string, error) {
+		money.Set(0, 100)
+		// no quick save yet.
+		*requests = append(*requests, input.ControlQuickLoad, input.ControlQuickSave)
+		return "b", nil
+	})
+	m.RegisterSceneFunc("b", func() (string, error) {
+		money.Set(0, 50)
+		*requests = append(*requests, input.ControlQuickLoad)
+		return "c", nil
+	})
+	m.RegisterSceneFunc("c", func() (string, error) {
+		money, _ := gamestate.SystemData.GetInt(csv.BuiltinMoneyName)
+		if got := money.Get(0); got != 100 {
+			t.Errorf("money should be restored by quick load, expect: %v, got: %v", 100, got)
+		}
+		return "", ErrorQuit
+	})
+	if err := m.Run(context.Background(), "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	// quick load does not change the scene flow.
+	history := m.TransitionHistory()
+	last := history[len(history)-1]
+	if last.From != "b" || last.To != "c" || last.Cause != TransitionCauseCallback {
+		t.Errorf("quick load should not change the scene flow, got: %+v", last)
+	}
+
+	// quick save slot is listed in the load scene.
+	controller.Append(strconv.Itoa(quickSaveNumber))
+	next, err := loadGameSceneProcess(m.sf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next == nil || next.Name() != SceneNameLoadEnd {
+		t.Errorf("quick save slot should be loaded, got next scene: %v", next)
+	}
+}
+
+func TestSceneControlRequestAtInput(t *testing.T) {
+	csvm, err := stub.GetCSV()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gamestate := state.NewGameState(csvm, repo.NewFileRepository(csvm, repo.Config{SaveFileDir: t.TempDir()}))
+	recorder := &printRecorder{IOController: stub.NewFlowGameController()}
+	m := NewSceneManager(recorder, stub.NewSceneScripter(), gamestate, Config{})
+	defer m.Free()
+	requests := &controlQueue{}
+	m.SetControlRequester(requests)
+
+	// HandleControlRequests is called at the input point in the scene.
+	started := 0
+	m.RegisterSceneFunc("a", func() (string, error) {
+		started++
+		money, _ := gamestate.SystemData.GetInt(csv.BuiltinMoneyName)
+		if started > 1 {
+			if got := money.Get(0); got != 10 {
+				t.Errorf("money should be restored by quick load, expect: %v, got: %v", 10, got)
+			}
+			return "", ErrorQuit
+		}
+
+		// deferred while script is running.
+		money.Set(0, 10)
+		*requests = append(*requests, input.ControlQuickSave)
+		m.sf.scriptCalls++
+		for i := 0; i < 2; i++ {
+			if err := m.HandleControlRequests(); err != nil {
+				return "", err
+			}
+		}
+		m.sf.scriptCalls--
+		if len(*requests) != 1 || gamestate.FileExists(quickSaveNumber) {
+			t.Error("quick save should be deferred while script is running")
+		}
+		if len(recorder.lines) != 1 || recorder.lines[0] != "Deferred until script ends." {
+			t.Errorf("user should be notified once that the request is deferred, got: %v", recorder.lines)
+		}
+
+		// processed at the input point out of script, without changing the scene flow.
+		if err := m.HandleControlRequests(); err != nil {
+			return "", err
+		}
+		if !gamestate.FileExists(quickSaveNumber) {
+			t.Error("quick save should be processed at the input point")
+		}
+
+		// the scene is restarted by loading.
+		money.Set(0, 20)
+		*requests = append(*requests, input.ControlQuickLoad)
+		return "b", m.HandleControlRequests()
+	})
+	m.RegisterSceneFunc("b", func() (string, error) {
+		t.Error("scene b should not be started after quick load")
+		return "", ErrorQuit
+	})
+	if err := m.Run(context.Background(), "a"); err != nil {
+		t.Fatal(err)
+	}
+	if started != 2 {
+		t.Errorf("scene should be restarted by quick load, started %v times", started)
+	}
+	history := m.TransitionHistory()
+	if last := history[len(history)-1]; last.From != "a" || last.To != "a" || last.Detail != "controlRequest" {
+		t.Errorf("scene a should be restarted, got: %+v", last)
+	}
+}
+
+func TestSceneRewindSnapshot(t *testing.T) {
+	csvm, err := stub.GetCSV()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gamestate := state.NewGameState(csvm, repo.NewFileRepository(csvm, repo.Config{SaveFileDir: t.TempDir()}))
+	m := NewSceneManager(stub.NewFlowGameController(), stub.NewSceneScripter(), gamestate, Config{RewindSnapshot: true})
+	defer m.Free()
+
+	money, _ := gamestate.SystemData.GetInt(csv.BuiltinMoneyName)
+	m.RegisterSceneFunc("a", func() (string, error) {
+		money.Set(0, 10)
+		return "b", nil
+	})
+	m.RegisterSceneFunc("b", func() (string, error) {
+		money.Set(0, 20)
+		return "c", nil
+	})
+	m.RegisterSceneFunc("c", func() (string, error) {
+		money.Set(0, 30)
+		return "", ErrorQuit
+	})
+	if err := m.Run(context.Background(), "a"); err != nil {
+		t.Fatal(err)
+	}
+	if labels := gamestate.SnapshotLabels(); !reflect.DeepEqual(labels, []string{"b", "c"}) {
+		t.Errorf("snapshots should be taken at scene transitions, got: %v", labels)
+	}
+
+	// rewind to the start of scene b.
+	if label, err := m.Rewind(2); err != nil {
+		t.Fatal(err)
+	} else if label != "b" {
+		t.Errorf("invalid rewound label, expect: b, got: %v", label)
+	}
+	money, _ = gamestate.SystemData.GetInt(csv.BuiltinMoneyName)
+	if got := money.Get(0); got != 10 {
+		t.Errorf("money should be rewound, expect: %v, got: %v", 10, got)
+	}
+	if _, err := m.Rewind(2); !errors.Is(err, state.ErrNoSnapshot) {
+		t.Errorf("no more snapshot to rewind should be error, got: %v", err)
+	}
+
+	// rewind requested by user is processed at the transition.
+	requests := &controlQueue{}
+	m.SetControlRequester(requests)
+	m.RegisterSceneFunc("d", func() (string, error) {
+		money, _ := gamestate.SystemData.GetInt(csv.BuiltinMoneyName)
+		money.Set(0, 40)
+		*requests = append(*requests, input.ControlRewind)
+		return "e", nil
+	})
+	m.RegisterSceneFunc("e", func() (string, error) {
+		money, _ := gamestate.SystemData.GetInt(csv.BuiltinMoneyName)
+		if got := money.Get(0); got != 10 {
+			t.Errorf("money should be rewound before scene e, expect: %v, got: %v", 10, got)
+		}
+		return "", ErrorQuit
+	})
+	if err := m.Run(context.Background(), "d"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// dispatchRecorder records the scene at dispatching changes.
+type dispatchRecorder struct {
+	Scripter
+	m          *SceneManager
+	dispatched []string
+}
+
+func (r *dispatchRecorder) EraDispatchChanges() error {
+	r.dispatched = append(r.dispatched, r.m.currentScene.Name())
+	return nil
+}
+
+func TestSceneDispatchChanges(t *testing.T) {
+	scripter := &dispatchRecorder{Scripter: stub.NewSceneScripter()}
+	state, err := stub.GetGameState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewSceneManager(stub.NewFlowGameController(), scripter, state, Config{})
+	defer m.Free()
+	scripter.m = m
+
+	m.RegisterSceneFunc("a", func() (string, error) { return "b", nil })
+	m.RegisterSceneFunc("b", func() (string, error) { return "c", nil })
+	m.RegisterSceneFunc("c", func() (string, error) { return "", ErrorQuit })
+	if err := m.Run(context.Background(), "a"); err != nil {
+		t.Fatal(err)
+	}
+	// changes are dispatched at every scene transition.
+	if expect := []string{"a", "b"}; !reflect.DeepEqual(scripter.dispatched, expect) {
+		t.Errorf("invalid dispatched points, expect: %v, got: %v", expect, scripter.dispatched)
+	}
+}
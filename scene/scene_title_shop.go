@@ -46,6 +46,8 @@ func (scene *titleScene) Next() (Scene, error) {
 	if next, err := scene.atStart(); next != nil || err != nil {
 		return next, err
 	}
+	// returning to title ends the game, rewinding into it is not allowed.
+	scene.State().ClearSnapshots()
 
 	io := scene.IO()
 	io.SetSingleLayout(io.GetCurrentViewName())
@@ -142,7 +144,7 @@ func (fs *newGameScene) Next() (Scene, error) {
 	if sc := fs.Scenes(); sc.HasNext() {
 		return sc.Next(), nil
 	} else {
-		return sc.GetScene(SceneNameAutosave)
+		return sc.GetScene(sc.afterNewGame)
 	}
 }
 
@@ -207,6 +209,42 @@ func (bs *baseScene) inputLoop() error {
 	}
 }
 
+// MAIN SCENE
+type mainScene struct {
+	sceneCommon
+}
+
+func newMainScene(sf *sceneFields) *mainScene {
+	return &mainScene{newSceneCommon(SceneNameMain, sf)}
+}
+
+func (ms *mainScene) Name() string { return SceneNameMain }
+
+// +scene: main
+// SceneProfile が "minimal" の場合のみ使われる、ゲームのメインループのシーンです。
+// newgame, loadend シーンの後に遷移します。
+// 次のシーンが設定されるまで、main_user_loop を繰り返し呼び出します。
+const (
+	// +callback: {{.Name}}()
+	// mainシーンにおける、ゲームの1ステップを処理します。
+	// この関数は、次のシーンの遷移先が決まるまで繰り返し呼ばれます。
+	ScrMainUserLoop = "main_user_loop"
+)
+
+func (ms *mainScene) Next() (Scene, error) {
+	if next, err := ms.atStart(); next != nil || err != nil {
+		return next, err
+	}
+
+	scenes := ms.Scenes()
+	for !scenes.HasNext() {
+		if err := ms.Script().cautionCall(ScrMainUserLoop); err != nil {
+			return nil, err
+		}
+	}
+	return scenes.Next(), nil
+}
+
 // * SHOP SCENE
 type shopScene struct {
 	sceneCommon
@@ -221,6 +259,12 @@ func (sc *shopScene) Name() string { return SceneNameShop }
 // +scene: shop
 // お店での売買を行うシーンです。
 // ここでItemの購入処理を行うことを想定しています。
+// Item.csv に str_category フィールドがある場合、Itemはカテゴリごとにまとめて表示されます。
+// Config の ShopBuyQuantity が有効な場合、売買する個数を入力できます。
+// ShopSellRate が正の場合、[-2] から所持しているItemを売却できます。
+// 売却価格は、価格に ShopSellRate を百分率として掛けたものです。
+// ShopLimitStock が有効な場合、ItemStock を在庫数として扱い、
+// 購入すると減少し、売却すると増加します。
 const (
 	// +callback: {{.Name}}()
 	// Itemの一覧を表示する処理を置き換える。
@@ -246,6 +290,26 @@ const (
 	// この関数が定義されていない場合、戻り値 false を返した場合と同様に
 	// ユーザー入力から繰り返します。
 	ScrShopEventBuyItem = "shop_event_buy_item"
+
+	// +callback: done: boolean = {{.Name}}(sell_item_number: integer)
+	// 売却処理の後に呼ばれます。売却に成功した場合、sell_item_number
+	// には正数が渡されます。失敗していた場合は、-1 が渡されます。
+	// 戻り値として true を返し、次のシーンが決まっていれば遷移します。
+	// それ以外の場合は、売却するItemの一覧の表示から繰り返します。
+	ScrShopEventSellItem = "shop_event_sell_item"
+
+	// +callback: price: integer = {{.Name}}(item_number: integer, base_price: integer, is_sell: integer)
+	// Itemの価格を置き換えます。表示、購入、売却の際に呼ばれます。
+	// base_price には、CSVで定義された価格が渡されます。売却の場合は、
+	// ShopSellRate を掛けた価格が渡されます。
+	// is_sell は、売却の場合 1 、購入の場合 0 です。
+	// 戻り値として、実際に使われる価格を返してください。負の価格はエラーになります。
+	ScrShopReplacePrice = "shop_replace_price"
+)
+
+const (
+	shopInputBack = -1
+	shopInputSell = -2
 )
 
 func (sc *shopScene) Next() (Scene, error) {
@@ -267,14 +331,16 @@ func (sc *shopScene) Next() (Scene, error) {
 				itemFormat = "[%d] %s (" + replaceText.MoneyFormat + ")"
 			}
 
-			money, _ := sc.State().SystemData.GetInt(csv.BuiltinMoneyName)
-			io.PrintL(fmt.Sprintf("Money: %d", money.Get(0)))
+			sc.printMoney()
 			io.PrintLine(DefaultLineSymbol)
 			if err := sc.ShowItems(itemFormat); err != nil {
 				return nil, err
 			}
 			io.PrintLine(DefaultLineSymbol)
-			io.PrintC("[-1] "+DefaultOrString("Back", replaceText.ReturnMenu), DefaultPrintCWidth)
+			io.PrintC(fmt.Sprintf("[%d] ", shopInputBack)+DefaultOrString("Back", replaceText.ReturnMenu), DefaultPrintCWidth)
+			if sc.canSell() {
+				io.PrintC(fmt.Sprintf("[%d] ", shopInputSell)+DefaultOrString("Sell", replaceText.SellItem), DefaultPrintCWidth)
+			}
 			io.PrintL("")
 		}
 
@@ -304,12 +370,17 @@ func (sc *shopScene) inputLoop() error {
 		}
 
 		// default item prints: [-1] Back
-		if input == -1 {
+		if input == shopInputBack {
 			s := sc.Scenes()
 			s.SetNext(s.Prev())
 			return nil
 		}
 
+		// [-2] Sell
+		if input == shopInputSell && sc.canSell() {
+			return sc.sellLoop()
+		}
+
 		// default buy items
 		succeeded, err := sc.buyItem(input)
 		if succeeded || err != nil {
@@ -318,6 +389,54 @@ func (sc *shopScene) inputLoop() error {
 	}
 }
 
+func (sc *shopScene) printMoney() {
+	money, _ := sc.State().SystemData.GetInt(csv.BuiltinMoneyName)
+	sc.IO().PrintL(fmt.Sprintf("Money: %d", money.Get(0)))
+}
+
+func (sc *shopScene) canSell() bool { return sc.Config().ShopSellRate > 0 }
+
+// itemPrice returns price of the item. For selling, the price is multiplied by ShopSellRate.
+// The price can be replaced by script.
+func (sc *shopScene) itemPrice(input int, sell bool) (int64, error) {
+	price := sc.State().CSV.ItemPrices[input]
+	var isSell int64 = 0
+	if sell {
+		price = price * int64(sc.Config().ShopSellRate) / 100
+		isSell = 1
+	}
+	called, replaced, err := sc.Script().checkCallIntArgInts(ScrShopReplacePrice, int64(input), price, isSell)
+	if err != nil {
+		return 0, err
+	}
+	if called {
+		if replaced < 0 {
+			return 0, fmt.Errorf("%s: price must be >= 0, but %d for item %d", ScrShopReplacePrice, replaced, input)
+		}
+		price = replaced
+	}
+	return price, nil
+}
+
+// inputQuantity returns quantity of buying or selling item in range [0:max].
+// 0 means canceled. It returns 1 without input if ShopBuyQuantity is disabled.
+func (sc *shopScene) inputQuantity(max int64) (int64, error) {
+	if !sc.Config().ShopBuyQuantity || max <= 1 {
+		return 1, nil
+	}
+	const intmax = int64(^uint(0) >> 1)
+	if max > intmax {
+		max = intmax
+	}
+	io := sc.IO()
+	prompt := DefaultOrString("Quantity? (0: Cancel)", sc.ReplaceText().InputQuantity)
+	if err := io.PrintL(fmt.Sprintf("%s [1-%d]", prompt, max)); err != nil {
+		return 0, err
+	}
+	n, err := io.CommandNumberRange(context.Background(), 0, int(max))
+	return int64(n), err
+}
+
 func (sc *shopScene) buyItem(input int) (succeeded bool, err error) {
 	const int64max = (1 << 63) - 1
 	// these are builtin values, no error
@@ -330,6 +449,9 @@ func (sc *shopScene) buyItem(input int) (succeeded bool, err error) {
 	// argument of the event is changed to positive after buy item is succeeded
 	var eventInput int64 = -1
 	defer func() {
+		if err != nil {
+			return
+		}
 		succeeded, err = sc.Script().maybeCallBoolArgInt(ScrShopEventBuyItem, eventInput)
 	}()
 
@@ -343,23 +465,164 @@ func (sc *shopScene) buyItem(input int) (succeeded bool, err error) {
 		return
 	}
 
-	CSV := sc.State().CSV
-	itemPrices := CSV.ItemPrices
+	price, err := sc.itemPrice(input, false)
+	if err != nil {
+		return
+	}
 
-	var afterBoughtMoney int64 = money.Get(0) - itemPrices[input]
-	if afterBoughtMoney < 0 {
+	// maximum quantity is limited by money, stock and overflow.
+	var maxQuantity int64 = int64max - item.Get(input)
+	if price > 0 && money.Get(0)/price < maxQuantity {
+		maxQuantity = money.Get(0) / price
+	}
+	if sc.Config().ShopLimitStock && itemStocks.Get(input) < maxQuantity {
+		maxQuantity = itemStocks.Get(input)
+	}
+	if maxQuantity < 1 {
 		return
 	}
 
+	quantity, err := sc.inputQuantity(maxQuantity)
+	if err != nil || quantity <= 0 {
+		return
+	}
+
+	// check all of the values before changing, so that buying is done
+	// completely or not at all.
+	newMoney, newItem := money.Get(0)-price*quantity, item.Get(input)+quantity
+	if err = money.CheckValue(0, newMoney); err != nil {
+		return
+	}
+	if err = item.CheckValue(input, newItem); err != nil {
+		return
+	}
+	limitStock := sc.Config().ShopLimitStock
+	newStock := itemStocks.Get(input) - quantity
+	if limitStock {
+		if err = itemStocks.CheckValue(input, newStock); err != nil {
+			return
+		}
+	}
+
 	// buying item is available
 	eventInput = int64(input)
-	money.Set(0, afterBoughtMoney)
-	item.Set(input, item.Get(input)+1)
+	money.Set(0, newMoney)
+	item.Set(input, newItem)
+	if limitStock {
+		itemStocks.Set(input, newStock)
+	}
+	return
+}
+
+// sellLoop shows owned items and sells selected item until user selects back.
+func (sc *shopScene) sellLoop() error {
+	io := sc.IO()
+	replaceText := sc.ReplaceText()
+	var itemFormat string = DefaultShowSellItemFormat
+	if moneyFormat := replaceText.MoneyFormat; len(moneyFormat) > 0 {
+		itemFormat = "[%d] %s x%d (" + replaceText.MoneyFormat + ")"
+	}
+	for {
+		sc.printMoney()
+		io.PrintLine(DefaultLineSymbol)
+		if err := sc.showSellItems(itemFormat); err != nil {
+			return err
+		}
+		io.PrintLine(DefaultLineSymbol)
+		io.PrintC(fmt.Sprintf("[%d] ", shopInputBack)+DefaultOrString("Back", replaceText.ReturnMenu), DefaultPrintCWidth)
+		io.PrintL("")
+
+		input, err := io.CommandNumber()
+		if err != nil {
+			return err
+		}
+		if input == shopInputBack {
+			return nil
+		}
+		done, err := sc.sellItem(input)
+		if err != nil {
+			return err
+		}
+		if done && sc.Scenes().HasNext() {
+			return nil
+		}
+	}
+}
+
+func (sc *shopScene) sellItem(input int) (done bool, err error) {
+	const int64max = (1 << 63) - 1
+	// these are builtin values, no error
+	State := sc.State()
+	itemStocks, _ := State.SystemData.GetInt(csv.BuiltinItemStockName)
+	item, _ := State.SystemData.GetInt(csv.BuiltinItemName)
+	money, _ := State.SystemData.GetInt(csv.BuiltinMoneyName)
+
+	var eventInput int64 = -1
+	defer func() {
+		if err != nil {
+			return
+		}
+		done, err = sc.Script().maybeCallBoolArgInt(ScrShopEventSellItem, eventInput)
+	}()
+
+	if input < 0 || input >= item.Len() || input >= len(State.CSV.ItemPrices) {
+		return
+	}
+	if item.Get(input) <= 0 {
+		return
+	}
+
+	price, err := sc.itemPrice(input, true)
+	if err != nil {
+		return
+	}
+
+	// maximum quantity is limited by owned items and overflow.
+	// negative money is treated as 0 so that the room for money does not overflow.
+	var maxQuantity int64 = item.Get(input)
+	room := int64max - max(money.Get(0), 0)
+	if price > 0 && room/price < maxQuantity {
+		maxQuantity = room / price
+	}
+	if maxQuantity < 1 {
+		return
+	}
+
+	quantity, err := sc.inputQuantity(maxQuantity)
+	if err != nil || quantity <= 0 {
+		return
+	}
+
+	// check all of the values before changing, same as buyItem.
+	newMoney, newItem := money.Get(0)+price*quantity, item.Get(input)-quantity
+	if err = money.CheckValue(0, newMoney); err != nil {
+		return
+	}
+	if err = item.CheckValue(input, newItem); err != nil {
+		return
+	}
+	restock := sc.Config().ShopLimitStock && input < itemStocks.Len()
+	var newStock int64
+	if restock {
+		newStock = itemStocks.Get(input) + quantity
+		if err = itemStocks.CheckValue(input, newStock); err != nil {
+			return
+		}
+	}
 
+	eventInput = int64(input)
+	money.Set(0, newMoney)
+	item.Set(input, newItem)
+	if restock {
+		itemStocks.Set(input, newStock)
+	}
 	return
 }
 
-const DefaultShowItemFormat = "[%d] %s ($%d)"
+const (
+	DefaultShowItemFormat     = "[%d] %s ($%d)"
+	DefaultShowSellItemFormat = "[%d] %s x%d ($%d)"
+)
 
 func (sc shopScene) ShowItems(fmtStr string) error {
 	if fmtStr == "" {
@@ -367,7 +630,6 @@ func (sc shopScene) ShowItems(fmtStr string) error {
 	}
 	CSV := sc.State().CSV
 	itemNames := CSV.Item.Names
-	itemPrices := CSV.ItemPrices
 	itemStocks, _ := sc.State().SystemData.GetInt(csv.BuiltinItemStockName)
 
 	// itemNames and ItemPrices must have same length.
@@ -377,6 +639,66 @@ func (sc shopScene) ShowItems(fmtStr string) error {
 		maxLen = itemStocks.Len()
 	}
 
+	indexes := make([]int, 0, maxLen)
+	for i, item := range itemNames[:maxLen] {
+		if len(item) == 0 {
+			continue
+		}
+		if itemStocks.Get(i) < 1 {
+			continue
+		}
+		indexes = append(indexes, i)
+	}
+	return sc.printItemsByCategory(indexes, func(i int) (string, error) {
+		price, err := sc.itemPrice(i, false)
+		return fmt.Sprintf(fmtStr, i, itemNames[i], price), err
+	})
+}
+
+func (sc shopScene) showSellItems(fmtStr string) error {
+	CSV := sc.State().CSV
+	itemNames := CSV.Item.Names
+	item, _ := sc.State().SystemData.GetInt(csv.BuiltinItemName)
+
+	var maxLen = itemNames.Len()
+	if maxLen > item.Len() {
+		maxLen = item.Len()
+	}
+
+	indexes := make([]int, 0, maxLen)
+	for i, name := range itemNames[:maxLen] {
+		if len(name) == 0 || item.Get(i) < 1 {
+			continue
+		}
+		indexes = append(indexes, i)
+	}
+	return sc.printItemsByCategory(indexes, func(i int) (string, error) {
+		price, err := sc.itemPrice(i, true)
+		return fmt.Sprintf(fmtStr, i, itemNames[i], item.Get(i), price), err
+	})
+}
+
+// printItemsByCategory prints items as buttons in columns.
+// If the items have categories, these are grouped by category
+// in order of first appearance and items without category are printed first.
+func (sc shopScene) printItemsByCategory(indexes []int, itemText func(int) (string, error)) error {
+	categories := sc.State().CSV.ItemCategories
+	categoryOf := func(i int) string {
+		if i < len(categories) {
+			return categories[i]
+		}
+		return ""
+	}
+	order := []string{""}
+	groups := map[string][]int{}
+	for _, i := range indexes {
+		c := categoryOf(i)
+		if _, ok := groups[c]; !ok && len(c) > 0 {
+			order = append(order, c)
+		}
+		groups[c] = append(groups[c], i)
+	}
+
 	io := sc.IO()
 	maxRuneWidth, err := io.WindowRuneWidth()
 	if err != nil {
@@ -384,25 +706,30 @@ func (sc shopScene) ShowItems(fmtStr string) error {
 	}
 	nColumn := maxRuneWidth / DefaultPrintCWidth
 
-	cc := 0 // current column
-	for i, item := range itemNames[:maxLen] {
-		if len(item) == 0 {
+	for _, category := range order {
+		group := groups[category]
+		if len(group) == 0 {
 			continue
 		}
-		if itemStocks.Get(i) < 1 {
-			continue
+		if len(category) > 0 {
+			io.PrintL("< " + category + " >")
 		}
-
-		text := fmt.Sprintf(fmtStr, i, item, itemPrices[i])
-		io.PrintC(text, DefaultPrintCWidth)
-		cc += 1
-		if cc == nColumn {
-			cc = 0
+		cc := 0 // current column
+		for _, i := range group {
+			text, err := itemText(i)
+			if err != nil {
+				return err
+			}
+			io.PrintC(text, DefaultPrintCWidth)
+			cc += 1
+			if cc == nColumn {
+				cc = 0
+				io.PrintL("")
+			}
+		}
+		if cc != 0 {
 			io.PrintL("")
 		}
 	}
-	if cc != 0 {
-		io.PrintL("")
-	}
 	return nil
 }
@@ -4,7 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/mzki/erago/state/csv"
 )
@@ -24,6 +26,10 @@ type trainScene struct {
 
 	// whether user shows other commands?
 	user_shown_other_commands bool
+
+	// last executed train command, used for repeat input.
+	last_command     int64
+	has_last_command bool
 }
 
 var errorExternalDoTrainNotAllowed = errors.New("It is allowed only in train scene")
@@ -50,6 +56,10 @@ func (ts *trainScene) setCanDoTrain(ok bool) { ts.can_do_train = ok }
 // +scene: train
 // 調教のシーンです。
 // ここでは、調教コマンドの実行およびコマンドの結果の反映を行うことを想定しています。
+// Config の TrainQueueLimit が 2 以上の場合、"3 3 5" のように複数のコマンドを一度に入力できます。
+// "3x2" は 3 を 2 回、"repeat" は直前に実行したコマンドを、"repeat 3x" はそれを 3 回実行します。
+// 2つ目以降のコマンドは実行前に train_replace_cmd_able で確認され、
+// 実行できない場合や次のシーンが決まった場合は、残りのコマンドは実行されません。
 const (
 	// +callback: {{.Name}}()
 	// 調教対象のステータスの表示をこの関数で行います。
@@ -98,6 +108,7 @@ func (ts *trainScene) Next() (Scene, error) {
 	if next, err := ts.atStart(); next != nil || err != nil {
 		return next, err
 	}
+	ts.has_last_command = false
 	return ts.trainCycle()
 }
 
@@ -131,19 +142,142 @@ func (ts *trainScene) trainCycle() (Scene, error) {
 		ts.user_shown_other_commands = userShownOtherCommands
 
 		// get user command
-		num, err := ts.IO().CommandNumber()
+		commands, err := ts.inputCommands()
 		if err != nil {
 			return nil, err
 		}
 
 		// executes command
-		if err := ts.DoTrain(int64(num)); err != nil {
+		if err := ts.doTrainQueue(commands); err != nil {
 			return nil, err
 		}
 	}
 	return ts.Scenes().Next(), nil
 }
 
+// inputCommands returns train commands entered by user.
+// It returns only one command unless TrainQueueLimit is larger than 1.
+// Invalid input results in empty commands.
+func (ts *trainScene) inputCommands() ([]int64, error) {
+	limit := ts.Config().TrainQueueLimit
+	if limit <= 1 {
+		num, err := ts.IO().CommandNumber()
+		return []int64{int64(num)}, err
+	}
+	input, err := ts.IO().Command()
+	if err != nil {
+		return nil, err
+	}
+	commands, err := parseTrainCommands(input, ts.last_command, ts.has_last_command, limit)
+	if err != nil {
+		ts.IO().PrintL(err.Error())
+		return nil, nil
+	}
+	return commands, nil
+}
+
+// doTrainQueue executes train commands in order. Commands after the first one
+// are checked whether executable before execution, and not executable command
+// stops execution of the rest of commands.
+func (ts *trainScene) doTrainQueue(commands []int64) error {
+	for i, cmd_no := range commands {
+		if i > 0 {
+			if ts.Scenes().HasNext() {
+				break
+			}
+			if cmd_no < 0 || int(cmd_no) >= len(ts.command_names) {
+				break
+			}
+			if err := ts.CheckTrainCommand(int(cmd_no), ts.command_names[cmd_no]); err != nil {
+				return err
+			}
+			if !ts.isExecutable(int(cmd_no)) {
+				break
+			}
+		}
+		if ts.isExecutable(int(cmd_no)) {
+			ts.last_command, ts.has_last_command = cmd_no, true
+		}
+		if err := ts.DoTrain(cmd_no); err != nil {
+			return err
+		}
+		if err := ts.Script().dispatchChanges(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const trainRepeatKeyword = "repeat"
+
+// parseTrainCommands parses user input into train commands.
+// The input is space or comma separated tokens, each of which is
+// a command number "N", repeated command "NxK" or repeat of the last
+// command "repeat" which may be followed by the count "Kx".
+// The number of commands must be <= limit.
+func parseTrainCommands(input string, last int64, hasLast bool, limit int) ([]int64, error) {
+	tokens := strings.Fields(strings.ReplaceAll(input, ",", " "))
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+	commands := make([]int64, 0, len(tokens))
+	appendN := func(cmd int64, n int) error {
+		if n < 1 || len(commands)+n > limit {
+			return fmt.Errorf("number of commands must be in 1-%d", limit)
+		}
+		for i := 0; i < n; i++ {
+			commands = append(commands, cmd)
+		}
+		return nil
+	}
+	parseCount := func(s string) (int, error) {
+		n, err := strconv.Atoi(strings.TrimSuffix(strings.ToLower(s), "x"))
+		if err != nil {
+			return 0, fmt.Errorf("invalid repeat count %q", s)
+		}
+		return n, nil
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		if strings.EqualFold(token, trainRepeatKeyword) {
+			if !hasLast {
+				return nil, fmt.Errorf("no command to repeat")
+			}
+			count := 1
+			if i+1 < len(tokens) && strings.HasSuffix(strings.ToLower(tokens[i+1]), "x") {
+				n, err := parseCount(tokens[i+1])
+				if err != nil {
+					return nil, err
+				}
+				count = n
+				i += 1
+			}
+			if err := appendN(last, count); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		cmd, count := token, 1
+		if parts := strings.SplitN(strings.ToLower(token), "x", 2); len(parts) == 2 {
+			n, err := parseCount(parts[1])
+			if err != nil {
+				return nil, err
+			}
+			cmd, count = parts[0], n
+		}
+		num, err := strconv.ParseInt(cmd, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid command %q", token)
+		}
+		if err := appendN(num, count); err != nil {
+			return nil, err
+		}
+	}
+	return commands, nil
+}
+
 // check weather all train commands can be performed?
 // checked result is stored into trainScene to use later.
 func (ts *trainScene) CheckAllTrainCommands() error {
@@ -307,29 +441,44 @@ func (aus *ablUpScene) Name() string { return SceneNameAblUp }
 // +scene: ablup
 // 能力上昇のシーンです。
 // Trainシーンの実行結果を、成長の結果として反映することを想定しています。
+// CSVに _AblUp.csv が存在する場合、定義されていないコールバックは組み込みの処理で置き換えられます。
+// 組み込みの処理では、era.target[0] の珠を表示し、_AblUp.csv で定義された条件を満たす能力を
+// 選択肢として表示します。選択された能力は、必要な珠を消費してレベルが上がります。
+// [-1] を選択すると turnend シーンに遷移します。
+// ただし、選択肢の表示と選択は組として扱われ、ablup_user_show_menu と
+// ablup_user_menu_selected のどちらか一方でも定義されている場合、組み込みの選択肢は使われません。
 const (
 	// +callback: {{.Name}}()
+	// 珠の一覧を表示します。
 	ScrAblUpUserShowJuel = "ablup_user_show_juel"
 
 	// +callback: {{.Name}}()
+	// 上昇させる能力の選択肢を表示します。
 	ScrAblUpUserShowMenu = "ablup_user_show_menu"
 
 	// +callback: ok: boolean = {{.Name}}(input_num: integer)
+	// 入力番号input_numと共に呼ばれ、それに対する処理を行います。
+	// 何らかの処理を行った場合は true を返してください。
 	ScrAblUpUserMenuSelected = "ablup_user_menu_selected" // +number -> bool
 )
 
+const ablUpInputBack = -1
+
 func (aus *ablUpScene) Next() (Scene, error) {
 	if next, err := aus.atStart(); next != nil || err != nil {
 		return next, err
 	}
 
-	script := aus.Script()
 	for !aus.Scenes().HasNext() {
-		if err := script.cautionCall(ScrAblUpUserShowJuel); err != nil {
+		if err := aus.callOrBuiltin(ScrAblUpUserShowJuel, aus.showJuels); err != nil {
 			return nil, err
 		}
 
-		if err := script.cautionCall(ScrAblUpUserShowMenu); err != nil {
+		if aus.useBuiltinMenu() {
+			if err := aus.showMenu(); err != nil {
+				return nil, err
+			}
+		} else if err := aus.Script().cautionCall(ScrAblUpUserShowMenu); err != nil {
 			return nil, err
 		}
 
@@ -340,6 +489,32 @@ func (aus *ablUpScene) Next() (Scene, error) {
 	return aus.Scenes().Next(), nil
 }
 
+// useBuiltin returns whether builtin ablup flow is available.
+func (aus *ablUpScene) useBuiltin() bool {
+	return aus.State().CSV.AblUps != nil
+}
+
+// callOrBuiltin calls script function if exists. Otherwise builtin is called if available.
+func (aus *ablUpScene) callOrBuiltin(fn_name string, builtin func() error) error {
+	if !aus.useBuiltin() {
+		return aus.Script().cautionCall(fn_name)
+	}
+	if called, err := aus.Script().checkCall(fn_name); called {
+		return err
+	}
+	return builtin()
+}
+
+// useBuiltinMenu returns whether builtin menu is used instead of the script.
+// The menu callbacks are used as a pair, so that the input is always handled
+// by the one showing the menu.
+func (aus *ablUpScene) useBuiltinMenu() bool {
+	script := aus.Script()
+	return aus.useBuiltin() &&
+		!script.HasEraValue(ScrAblUpUserShowMenu) &&
+		!script.HasEraValue(ScrAblUpUserMenuSelected)
+}
+
 func (aus *ablUpScene) inputLoop() error {
 	for {
 		input, err := aus.IO().CommandNumber()
@@ -347,13 +522,177 @@ func (aus *ablUpScene) inputLoop() error {
 			return err
 		}
 
-		used, err := aus.Script().cautionCallBoolArgInt(ScrAblUpUserMenuSelected, int64(input))
+		var used bool
+		if aus.useBuiltinMenu() {
+			used, err = aus.selectBuiltin(input)
+		} else {
+			used, err = aus.Script().cautionCallBoolArgInt(ScrAblUpUserMenuSelected, int64(input))
+		}
 		if used || err != nil {
 			return err
 		}
 	}
 }
 
+// nextAblUp returns requirement to level up the ability of the target.
+// It returns false if no requirement found.
+func (aus *ablUpScene) nextAblUp(abl int) (csv.AblUpRequirement, bool) {
+	target := aus.State().SystemData.Target.First()
+	if target == nil {
+		return csv.AblUpRequirement{}, false
+	}
+	abls, _ := target.GetInt(csv.BuiltinAblName)
+	if abl < 0 || abl >= abls.Len() {
+		return csv.AblUpRequirement{}, false
+	}
+	for _, req := range aus.State().CSV.AblUps {
+		if req.Abl == abl && req.Level == abls.Get(abl)+1 {
+			return req, true
+		}
+	}
+	return csv.AblUpRequirement{}, false
+}
+
+// ablUpAvailable returns whether the target satisfies the requirement.
+func (aus *ablUpScene) ablUpAvailable(req csv.AblUpRequirement) bool {
+	target := aus.State().SystemData.Target.First()
+	if target == nil {
+		return false
+	}
+	juels, _ := target.GetInt(csv.BuiltinJuelName)
+	exps, _ := target.GetInt(csv.BuiltinExpName)
+	talents, _ := target.GetInt(csv.BuiltinTalentName)
+	for _, j := range juelCosts(req) {
+		if j.Index >= juels.Len() || juels.Get(j.Index) < j.Amount {
+			return false
+		}
+	}
+	for _, e := range req.Exps {
+		if e.Index >= exps.Len() || exps.Get(e.Index) < e.Amount {
+			return false
+		}
+	}
+	for _, t := range req.Talents {
+		if t.Index >= talents.Len() || (talents.Get(t.Index) != 0) != t.Has {
+			return false
+		}
+	}
+	return true
+}
+
+// juelCosts returns juel amounts of the requirement, summed up for each index
+// since same juel may be required more than once.
+func juelCosts(req csv.AblUpRequirement) []csv.AmountRequirement {
+	costs := make([]csv.AmountRequirement, 0, len(req.Juels))
+	at := make(map[int]int, len(req.Juels))
+	for _, j := range req.Juels {
+		if i, ok := at[j.Index]; ok {
+			costs[i].Amount += j.Amount
+			continue
+		}
+		at[j.Index] = len(costs)
+		costs = append(costs, j)
+	}
+	return costs
+}
+
+// ablUpTargets returns ability indexes which have requirement to level up, in order of Abl.
+func (aus *ablUpScene) ablUpTargets() []int {
+	seen := make(map[int]bool)
+	abls := make([]int, 0, 16)
+	for _, req := range aus.State().CSV.AblUps {
+		if !seen[req.Abl] {
+			seen[req.Abl] = true
+			abls = append(abls, req.Abl)
+		}
+	}
+	sort.Ints(abls)
+	return abls
+}
+
+func (aus *ablUpScene) showJuels() error {
+	io := aus.IO()
+	target := aus.State().SystemData.Target.First()
+	if target == nil {
+		return io.PrintL("No target")
+	}
+	io.PrintL(target.Name)
+	juels, _ := target.GetInt(csv.BuiltinJuelName)
+	names := aus.State().CSV.MustConst(csv.BuiltinJuelName).Names
+	cc := 0
+	for i := 0; i < juels.Len() && i < names.Len(); i++ {
+		if v := juels.Get(i); v != 0 && len(names[i]) > 0 {
+			io.PrintC(fmt.Sprintf("%s: %d", names[i], v), DefaultPrintCWidth)
+			if cc += 1; cc == 3 {
+				cc = 0
+				io.PrintL("")
+			}
+		}
+	}
+	if cc != 0 {
+		io.PrintL("")
+	}
+	return io.PrintLine(DefaultLineSymbol)
+}
+
+func (aus *ablUpScene) showMenu() error {
+	io := aus.IO()
+	target := aus.State().SystemData.Target.First()
+	if target != nil {
+		abls, _ := target.GetInt(csv.BuiltinAblName)
+		names := aus.State().CSV.MustConst(csv.BuiltinAblName).Names
+		for _, abl := range aus.ablUpTargets() {
+			req, ok := aus.nextAblUp(abl)
+			if !ok {
+				continue
+			}
+			text := fmt.Sprintf("%s Lv%d -> Lv%d", names.Get(abl), abls.Get(abl), req.Level)
+			if aus.ablUpAvailable(req) {
+				io.PrintL(fmt.Sprintf("[%d] %s", abl, text))
+			} else {
+				io.PrintL("    " + text)
+			}
+		}
+	}
+	io.PrintLine(DefaultLineSymbol)
+	return io.PrintL(fmt.Sprintf("[%d] ", ablUpInputBack) + DefaultOrString("Back", aus.ReplaceText().ReturnMenu))
+}
+
+// selectBuiltin levels up the ability selected by input, consuming juels.
+// [-1] sets next scene to turnend.
+func (aus *ablUpScene) selectBuiltin(input int) (bool, error) {
+	if input == ablUpInputBack {
+		return true, aus.Scenes().SetNextByName(SceneNameTurnEnd)
+	}
+	req, ok := aus.nextAblUp(input)
+	if !ok || !aus.ablUpAvailable(req) {
+		return false, nil
+	}
+	target := aus.State().SystemData.Target.First()
+	juels, _ := target.GetInt(csv.BuiltinJuelName)
+	abls, _ := target.GetInt(csv.BuiltinAblName)
+	// check all of the values before changing, so that juels are not
+	// consumed without level up.
+	if err := abls.CheckValue(req.Abl, req.Level); err != nil {
+		return false, err
+	}
+	costs := juelCosts(req)
+	for _, j := range costs {
+		if err := juels.CheckValue(j.Index, juels.Get(j.Index)-j.Amount); err != nil {
+			return false, err
+		}
+	}
+	for _, j := range costs {
+		if err := juels.Set(j.Index, juels.Get(j.Index)-j.Amount); err != nil {
+			return false, err
+		}
+	}
+	if err := abls.Set(req.Abl, req.Level); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // * TURN END SCENE
 type turnEndScene struct {
 	sceneCommon
@@ -368,9 +707,17 @@ func (tes *turnEndScene) Name() string { return SceneNameTurnEnd }
 // +scene: turnend
 // 1ターンの終了シーンです。
 // ここでは、ゲームとしての１ターンが終了したときの処理を実施することを想定しています。
+// また、カレンダーの時刻を1時間帯進め、予定されたイベントを発生させます。
 const (
-// ScrSceneTurnEnd = "scene_turnend"
-// ScrEventTurnEnd = "event_turnend"
+	// ScrSceneTurnEnd = "scene_turnend"
+	// ScrEventTurnEnd = "event_turnend"
+
+	// +callback: {{.Name}}(event_id: integer)
+	// カレンダーの時刻を進めた結果、_Calendar.csv で予定されたイベントが
+	// 発生したときに呼ばれます。event_id はイベントの定義順の番号で、
+	// era.calendar.eventName(event_id) により名前を得られます。
+	// 同時に複数のイベントが発生した場合、定義順に呼ばれます。
+	ScrTurnEndEventCalendar = "turnend_event_calendar"
 )
 
 func (tes *turnEndScene) Next() (Scene, error) {
@@ -378,6 +725,16 @@ func (tes *turnEndScene) Next() (Scene, error) {
 		return next, err
 	}
 
+	events, err := tes.State().Calendar().Advance(1)
+	if err != nil {
+		return nil, err
+	}
+	for _, ev := range events {
+		if err := tes.Script().maybeCallArgInt(ScrTurnEndEventCalendar, int64(ev)); err != nil {
+			return nil, err
+		}
+	}
+
 	if ss := tes.Scenes(); ss.HasNext() {
 		return ss.Next(), nil
 	} else {
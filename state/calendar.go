@@ -0,0 +1,164 @@
+package state
+
+import (
+	"fmt"
+
+	"github.com/mzki/erago/state/csv"
+)
+
+// MaxCalendarAdvance is the maximum time slots advanced by Calendar.Advance
+// at once, since the scheduled events are checked at each time slot.
+// Use Calendar.SetTime to move the time far away without the events.
+const MaxCalendarAdvance = 1 << 16
+
+// Calendar is a view of the game time based on csv.CalendarConfig.
+// The game time is stored as elapsed time slots in the builtin system
+// variable, csv.BuiltinCalendarTimeName, so that it is saved and
+// loaded with the other system data.
+type Calendar struct {
+	config *csv.CalendarConfig
+	time   IntParam
+}
+
+// Calendar returns the game calendar.
+func (state *GameState) Calendar() Calendar {
+	time, _ := state.SystemData.GetInt(csv.BuiltinCalendarTimeName)
+	return Calendar{config: &state.CSV.Calendar, time: time}
+}
+
+// Config returns definition of the calendar.
+func (c Calendar) Config() *csv.CalendarConfig { return c.config }
+
+// Time returns elapsed time slots from the start of the calendar.
+func (c Calendar) Time() int64 { return c.time.Get(0) }
+
+// SetTime sets elapsed time slots. Negative value is treated as 0.
+// Scheduled events are not occurred by this.
+// It returns error if the time is rejected by the bound of the variable.
+func (c Calendar) SetTime(t int64) error {
+	if t < 0 {
+		t = 0
+	}
+	return c.time.Set(0, t)
+}
+
+// Advance advances the time by n time slots and returns indexes of
+// csv.CalendarConfig.Events which occur at each time passed.
+// Events at the same time are ordered by its definition.
+// Nothing is changed and error is returned if n is out of range
+// [0, MaxCalendarAdvance] or the time is rejected by the bound of the variable.
+func (c Calendar) Advance(n int64) ([]int, error) {
+	if n < 0 || n > MaxCalendarAdvance {
+		return nil, fmt.Errorf("calendar: advancing time slots must be in [0, %d], but %d", MaxCalendarAdvance, n)
+	}
+	t := c.Time()
+	if err := c.time.CheckValue(0, t+n); err != nil {
+		return nil, err
+	}
+	var occurred []int
+	for i := int64(0); i < n; i++ {
+		t++
+		for idx, ev := range c.config.Events {
+			if c.match(ev, t) {
+				occurred = append(occurred, idx)
+			}
+		}
+	}
+	return occurred, c.SetTime(t)
+}
+
+func (c Calendar) match(ev csv.CalendarEvent, t int64) bool {
+	day, slot := c.dayAt(t), c.slotAt(t)
+	switch {
+	case ev.Slot >= 0 && ev.Slot != slot:
+		return false
+	case ev.Slot < 0 && slot != 0:
+		return false
+	case ev.Day >= 0 && ev.Day != day:
+		return false
+	case ev.Every > 0 && day%ev.Every != 0:
+		return false
+	case ev.Weekday >= 0 && ev.Weekday != c.weekdayAt(day):
+		return false
+	case ev.Season >= 0 && ev.Season != c.seasonAt(day):
+		return false
+	case ev.DayOfSeason >= 0 && ev.DayOfSeason != c.dayOfSeasonAt(day):
+		return false
+	}
+	return true
+}
+
+func (c Calendar) dayLength() int64 { return int64(len(c.config.TimeSlots)) }
+
+func (c Calendar) dayAt(t int64) int64 { return t / c.dayLength() }
+
+func (c Calendar) slotAt(t int64) int { return int(t % c.dayLength()) }
+
+func (c Calendar) weekdayAt(day int64) int {
+	if n := int64(len(c.config.Weekdays)); n > 0 {
+		return int(day % n)
+	}
+	return 0
+}
+
+func (c Calendar) seasonAt(day int64) int {
+	if n := int64(len(c.config.Seasons)); n > 0 {
+		return int((day / int64(c.config.SeasonLength)) % n)
+	}
+	return 0
+}
+
+func (c Calendar) dayOfSeasonAt(day int64) int64 {
+	if len(c.config.Seasons) > 0 {
+		return day % int64(c.config.SeasonLength)
+	}
+	return day
+}
+
+// Day returns elapsed days from the start of the calendar.
+func (c Calendar) Day() int64 { return c.dayAt(c.Time()) }
+
+// Slot returns index of the current time slot in a day.
+func (c Calendar) Slot() int { return c.slotAt(c.Time()) }
+
+// SlotName returns name of the current time slot.
+func (c Calendar) SlotName() string { return c.config.TimeSlots[c.Slot()] }
+
+// Weekday returns index of the current weekday. It is always 0
+// if weekdays are not defined.
+func (c Calendar) Weekday() int { return c.weekdayAt(c.Day()) }
+
+// WeekdayName returns name of the current weekday. It is empty
+// if weekdays are not defined.
+func (c Calendar) WeekdayName() string {
+	if len(c.config.Weekdays) == 0 {
+		return ""
+	}
+	return c.config.Weekdays[c.Weekday()]
+}
+
+// Season returns index of the current season. It is always 0
+// if seasons are not defined.
+func (c Calendar) Season() int { return c.seasonAt(c.Day()) }
+
+// SeasonName returns name of the current season. It is empty
+// if seasons are not defined.
+func (c Calendar) SeasonName() string {
+	if len(c.config.Seasons) == 0 {
+		return ""
+	}
+	return c.config.Seasons[c.Season()]
+}
+
+// Year returns elapsed years, which is a cycle of all seasons.
+// It is always 0 if seasons are not defined.
+func (c Calendar) Year() int64 {
+	if n := int64(len(c.config.Seasons)); n > 0 {
+		return c.Day() / (int64(c.config.SeasonLength) * n)
+	}
+	return 0
+}
+
+// DayOfSeason returns elapsed days in the current season.
+// It is same as Day if seasons are not defined.
+func (c Calendar) DayOfSeason() int64 { return c.dayOfSeasonAt(c.Day()) }
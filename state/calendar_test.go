@@ -0,0 +1,51 @@
+package state
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCalendarAdvance(t *testing.T) {
+	gamestate := NewGameState(CSVDB, Repo)
+	cal := gamestate.Calendar()
+
+	// 朝礼 occurs every morning, and 夏祭り occurs at the night of day 3.
+	occurred, err := cal.Advance(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := []int{0, 0, 0, 2, 0}; !reflect.DeepEqual(occurred, expect) {
+		t.Errorf("different occurred events, expect: %v, got: %v", expect, occurred)
+	}
+
+	for _, testcase := range []struct {
+		Name   string
+		Got    interface{}
+		Expect interface{}
+	}{
+		{"Time", cal.Time(), int64(16)},
+		{"Day", cal.Day(), int64(4)},
+		{"SlotName", cal.SlotName(), "朝"},
+		{"WeekdayName", cal.WeekdayName(), "金"},
+		{"SeasonName", cal.SeasonName(), "秋"},
+		{"DayOfSeason", cal.DayOfSeason(), int64(0)},
+		{"Year", cal.Year(), int64(0)},
+	} {
+		if testcase.Got != testcase.Expect {
+			t.Errorf("%s: expect %v, got %v", testcase.Name, testcase.Expect, testcase.Got)
+		}
+	}
+
+	// 休日 occurs at noon of sunday.
+	cal.SetTime(6*4 + 0)
+	if occurred, _ := cal.Advance(1); !reflect.DeepEqual(occurred, []int{1}) {
+		t.Errorf("休日 should occur, got: %v", occurred)
+	}
+	if _, err := cal.Advance(MaxCalendarAdvance + 1); err == nil {
+		t.Error("advancing over MaxCalendarAdvance should be error")
+	}
+	cal.SetTime(8 * 4)
+	if year := cal.Year(); year != 1 {
+		t.Errorf("year should be 1 after all seasons, got: %v", year)
+	}
+}
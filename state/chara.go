@@ -56,6 +56,13 @@ func (c *Character) CsvInitialize(csv_chara *csv.Character) {
 	for k, csv_vars := range csv_chara.GetIntMap() {
 		copy(c.IntMap[k].Values, csv_vars)
 	}
+	for k, csv_vars := range csv_chara.GetFloatMap() {
+		copy(c.FloatMap[k].Values, csv_vars)
+	}
+	for k, csv_vars := range csv_chara.GetBoolMap() {
+		copy(c.BoolMap[k].Values, csv_vars)
+	}
+	c.derivedData.invalidate()
 }
 
 // To marshall object, the field needs to be exported.
@@ -72,6 +79,13 @@ type Characters struct {
 	List          characters
 	CountNewChara uint64 // count of call newCharacters()
 	csv           *csv.CsvManager
+
+	// uidIndex is lookup table from UID to index of List.
+	// It is maintained by all of operations modifying List.
+	uidIndex map[uint64]int
+
+	// onRemove is called with UID of the removed Character if not nil.
+	onRemove func(uid uint64)
 }
 
 // Characters's list has capacity at least minListCapacity.
@@ -82,23 +96,27 @@ func newCharacters(csv *csv.CsvManager) *Characters {
 		List:          make([]*Character, 0, minListCapacity),
 		csv:           csv,
 		CountNewChara: 0,
+		uidIndex:      make(map[uint64]int, minListCapacity),
 	}
 }
 
 func (cs *Characters) refine(csvM *csv.CsvManager) {
 	cs.csv = csvM
+	cs.rebuildUIDIndex()
 
-	intVSpecs := intVariableSpecs(csvM.IntVariableSpecs(csv.ScopeChara))
-	strVSpecs := strVariableSpecs(csvM.StrVariableSpecs(csv.ScopeChara))
+	vspecs := newUserVariableSpecs(csvM, csv.ScopeChara)
 	constants := csvM.Constants()
+	shapes := csvM.Shapes()
+	bounds := csvM.VariableBounds()
+	derived := csvM.DerivedVariables(csv.ScopeChara)
 
 	for index, c := range cs.List {
 		csvC, ok := csvM.CharaMap[c.ID]
 		if ok {
-			c.UserVariables.refineByCsvChara(constants, intVSpecs, strVSpecs, csvC)
+			c.UserVariables.refineByCsvChara(constants, shapes, bounds, derived, vspecs, csvC)
 		} else {
 			log.Infof("Chara index %v: unknown character ID (%v) exist", index, c.ID)
-			c.UserVariables.refine(constants, intVSpecs, strVSpecs)
+			c.UserVariables.refine(constants, shapes, bounds, derived, vspecs)
 		}
 	}
 }
@@ -112,9 +130,16 @@ func (cs Characters) Get(i int) *Character {
 }
 
 // like array access charas[i] = a_chara
+// The replaced Character is treated as removed, same as Remove().
 func (cs Characters) Set(i int, c *Character) {
-	if cs.inRange(i) {
+	if cs.inRange(i) && c != nil {
+		old := cs.List[i].UID
+		delete(cs.uidIndex, old)
 		cs.List[i] = c
+		cs.uidIndex[c.UID] = i
+		if old != c.UID && cs.onRemove != nil {
+			cs.onRemove(old)
+		}
 	}
 	// TODO: error message required?
 }
@@ -132,6 +157,8 @@ func (cs Characters) Len() int {
 // i-th character and j-th character.
 func (cs Characters) Swap(i, j int) {
 	cs.List[i], cs.List[j] = cs.List[j], cs.List[i]
+	cs.uidIndex[cs.List[i].UID] = i
+	cs.uidIndex[cs.List[j].UID] = j
 }
 
 // implement sort.Interface. default compare Character's ID.
@@ -197,6 +224,7 @@ func (cs *Characters) append(c *Character) {
 		cs.List = new_charas
 	}
 	cs.List = append(cs.List, c)
+	cs.uidIndex[c.UID] = len(cs.List) - 1
 }
 
 // Remove Character at index and return IsRemoved.
@@ -206,11 +234,19 @@ func (cs *Characters) Remove(idx int) bool {
 	if !cs.inRange(idx) {
 		return false
 	}
+	uid := cs.List[idx].UID
+	delete(cs.uidIndex, uid)
 	copy(cs.List[idx:], cs.List[idx+1:])
 	last := cs.Len() - 1
 	cs.List[last] = nil
 	cs.List = cs.List[:last]
+	for i := idx; i < last; i++ {
+		cs.uidIndex[cs.List[i].UID] = i
+	}
 	cs.compaction()
+	if cs.onRemove != nil {
+		cs.onRemove(uid)
+	}
 	return true
 }
 
@@ -236,7 +272,22 @@ func (cs *Characters) compaction() {
 
 // clear all chara
 func (cs *Characters) Clear() {
+	if cs.onRemove != nil {
+		for _, c := range cs.List {
+			cs.onRemove(c.UID)
+		}
+	}
 	cs.List = make([]*Character, 0, minListCapacity)
+	cs.uidIndex = make(map[uint64]int, minListCapacity)
+}
+
+// rebuildUIDIndex builds lookup table from UID to index, requiring after
+// List is replaced such as unmarshal.
+func (cs *Characters) rebuildUIDIndex() {
+	cs.uidIndex = make(map[uint64]int, len(cs.List))
+	for i, c := range cs.List {
+		cs.uidIndex[c.UID] = i
+	}
 }
 
 // sort by "less" function, which returns true
@@ -278,10 +329,48 @@ func (cs Characters) FindIndexBy(f func(*Character) bool) int {
 }
 
 // find index by compairing UID.
+// if not found, return -1
 func (cs Characters) FindIndexByUID(uid uint64) int {
-	return cs.FindIndexBy(func(c *Character) bool {
-		return c.UID == uid
-	})
+	if i, ok := cs.uidIndex[uid]; ok {
+		return i
+	}
+	return -1
+}
+
+// find Character by compairing UID.
+// if not found, return nil
+func (cs Characters) FindByUID(uid uint64) *Character {
+	return cs.Get(cs.FindIndexByUID(uid))
+}
+
+// ErrCharaRemoved indicates the character referred by CharaHandle
+// is removed from the character list.
+var ErrCharaRemoved = errors.New("state: character is removed from the character list")
+
+// CharaHandle is a stable reference to a Character in Characters.
+// Unlike *Character, it refers the Character by UID, so that
+// it keeps pointing the same Character after the list is sorted,
+// other Characters are removed, or the game is reloaded.
+type CharaHandle struct {
+	uid uint64
+	src *Characters
+}
+
+// Handle returns CharaHandle referring c.
+func (cs *Characters) Handle(c *Character) CharaHandle {
+	return CharaHandle{uid: c.UID, src: cs}
+}
+
+// UID returns UID of the referred Character.
+func (h CharaHandle) UID() uint64 { return h.uid }
+
+// Get returns the referred Character. It returns ErrCharaRemoved
+// if the Character is not found in the character list.
+func (h CharaHandle) Get() (*Character, error) {
+	if c := h.src.FindByUID(h.uid); c != nil {
+		return c, nil
+	}
+	return nil, fmt.Errorf("%w: uid %d", ErrCharaRemoved, h.uid)
 }
 
 // lessFunc converts its interface to sort.Interface.
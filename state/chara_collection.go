@@ -0,0 +1,188 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mzki/erago/state/csv"
+)
+
+// CharaCollection is a named list of Characters declared in VariableSpec.csv
+// with the scope CharaList, such as members of a party or prisoners.
+//
+// It refers Characters in SystemData.Chara by UID, so that a Character can
+// belong to multiple collections, and the collection keeps pointing the same
+// Characters after the original list is sorted or the game is reloaded.
+// Characters removed from the original list are also removed from the collection.
+type CharaCollection struct {
+	UIDs []uint64
+
+	name     string
+	capacity int // 0 means no limit.
+	src      *Characters
+}
+
+func newCharaCollection(name string, capacity int, src *Characters) *CharaCollection {
+	return &CharaCollection{
+		UIDs:     make([]uint64, 0, 4),
+		name:     name,
+		capacity: capacity,
+		src:      src,
+	}
+}
+
+// newCharaCollections returns collections declared in csv.
+func newCharaCollections(csvM *csv.CsvManager, src *Characters) map[string]*CharaCollection {
+	specs := csvM.CharaListSpecs()
+	collections := make(map[string]*CharaCollection, len(specs))
+	for _, vs := range specs {
+		collections[vs.VarName] = newCharaCollection(vs.VarName, int(vs.Size), src)
+	}
+	return collections
+}
+
+// refine recovers unexported fields, requiring after unmarshal.
+// Characters not found in src and exceeding capacity are removed.
+func (cc *CharaCollection) refine(name string, capacity int, src *Characters) {
+	cc.name = name
+	cc.capacity = capacity
+	cc.src = src
+	cc.prune()
+	if cc.capacity > 0 && len(cc.UIDs) > cc.capacity {
+		cc.UIDs = cc.UIDs[:cc.capacity]
+	}
+}
+
+// prune removes UIDs of the Characters removed from the original list.
+func (cc *CharaCollection) prune() {
+	uids := cc.UIDs[:0]
+	for _, uid := range cc.UIDs {
+		if cc.src.FindIndexByUID(uid) >= 0 {
+			uids = append(uids, uid)
+		}
+	}
+	cc.UIDs = uids
+}
+
+// removeUID removes the Character of uid from the collection.
+// It is called when the Character is removed from the original list.
+func (cc *CharaCollection) removeUID(uid uint64) {
+	for i, u := range cc.UIDs {
+		if u == uid {
+			cc.UIDs = append(cc.UIDs[:i], cc.UIDs[i+1:]...)
+			return
+		}
+	}
+}
+
+// Name returns name of the collection defined in csv.
+func (cc *CharaCollection) Name() string { return cc.name }
+
+// Cap returns maximum number of Characters in the collection. 0 means no limit.
+func (cc *CharaCollection) Cap() int { return cc.capacity }
+
+// Len returns number of Characters in the collection.
+func (cc *CharaCollection) Len() int {
+	return len(cc.UIDs)
+}
+
+// get character at i, as like chara = collection[i].
+// if i is out of range, return nil.
+func (cc *CharaCollection) Get(i int) *Character {
+	if i < 0 || i >= len(cc.UIDs) {
+		return nil
+	}
+	return cc.src.FindByUID(cc.UIDs[i])
+}
+
+// Charas returns Characters in the collection as new slice.
+func (cc *CharaCollection) Charas() []*Character {
+	charas := make([]*Character, 0, len(cc.UIDs))
+	for _, uid := range cc.UIDs {
+		charas = append(charas, cc.src.FindByUID(uid))
+	}
+	return charas
+}
+
+// IndexOf returns index of c in the collection. if not found, return -1.
+func (cc *CharaCollection) IndexOf(c *Character) int {
+	for i, uid := range cc.UIDs {
+		if uid == c.UID {
+			return i
+		}
+	}
+	return -1
+}
+
+// Has returns whether c is in the collection.
+func (cc *CharaCollection) Has(c *Character) bool {
+	return cc.IndexOf(c) >= 0
+}
+
+// Add appends c into the end of the collection. It does nothing if c is already in the collection.
+// It returns error if c is not found in the original chara list, or the collection is full.
+func (cc *CharaCollection) Add(c *Character) error {
+	if c == nil {
+		return errors.New("CharaCollection.Add: nil character is not accepted")
+	}
+	if cc.src.FindIndexByUID(c.UID) < 0 {
+		return fmt.Errorf("CharaCollection.Add: %v is not found in current Chara list", c.Name)
+	}
+	if cc.Has(c) {
+		return nil
+	}
+	if cc.capacity > 0 && len(cc.UIDs) >= cc.capacity {
+		return fmt.Errorf("CharaCollection.Add: %s is full, capacity %d", cc.name, cc.capacity)
+	}
+	cc.UIDs = append(cc.UIDs, c.UID)
+	return nil
+}
+
+// Remove removes Character at i from the collection and returns IsRemoved.
+// the Character itself remains in the original chara list.
+func (cc *CharaCollection) Remove(i int) bool {
+	if i < 0 || i >= len(cc.UIDs) {
+		return false
+	}
+	cc.UIDs = append(cc.UIDs[:i], cc.UIDs[i+1:]...)
+	return true
+}
+
+// RemoveChara removes c from the collection and returns IsRemoved.
+func (cc *CharaCollection) RemoveChara(c *Character) bool {
+	return cc.Remove(cc.IndexOf(c))
+}
+
+// Clear removes all Characters from the collection.
+func (cc *CharaCollection) Clear() {
+	cc.UIDs = cc.UIDs[:0]
+}
+
+// Select returns Characters in the collection matched to the query.
+// See CharaQuery for details.
+func (cc *CharaCollection) Select(q CharaQuery) ([]*Character, error) {
+	return cc.src.selectIn(cc.Charas(), q)
+}
+
+// Count returns number of Characters in the collection satisfying all of the conditions.
+func (cc *CharaCollection) Count(where ...string) (int, error) {
+	return cc.src.countIn(cc.Charas(), where)
+}
+
+// GroupBy groups Characters in the collection satisfying all of the conditions by the value of key.
+func (cc *CharaCollection) GroupBy(key string, where ...string) ([]CharaGroup, error) {
+	return cc.src.groupByIn(cc.Charas(), key, where)
+}
+
+// SortByKeys sorts Characters in the collection by the sort keys.
+// The original chara list is not changed.
+func (cc *CharaCollection) SortByKeys(keys ...string) error {
+	sorted, err := cc.src.selectIn(cc.Charas(), CharaQuery{OrderBy: keys})
+	if err != nil {
+		return err
+	}
+	for i, c := range sorted {
+		cc.UIDs[i] = c.UID
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package state
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCharaCollection(t *testing.T) {
+	gamestate := NewGameState(CSVDB, Repo)
+	sysdata := gamestate.SystemData
+
+	party, ok := sysdata.Collection("Party")
+	if !ok {
+		t.Fatal("Party collection is not found")
+	}
+	if party.Name() != "Party" || party.Cap() != 4 {
+		t.Errorf("different Party collection, name: %v, cap: %v", party.Name(), party.Cap())
+	}
+	if _, ok := sysdata.Collection("Unknown"); ok {
+		t.Error("undeclared collection should not be found")
+	}
+
+	cs := make([]*Character, 0, 5)
+	for i := 0; i < 5; i++ {
+		c, err := sysdata.Chara.AddID(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cs = append(cs, c)
+	}
+
+	for _, c := range cs[:4] {
+		if err := party.Add(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := party.Add(cs[0]); err != nil {
+		t.Errorf("adding member again should do nothing, got error: %v", err)
+	}
+	if err := party.Add(cs[4]); err == nil {
+		t.Error("adding to full collection should be error")
+	}
+	if err := party.Add(newEmptyCharacter(100, CSVDB)); err == nil {
+		t.Error("adding character not in Chara list should be error")
+	}
+
+	// removing from the collection keeps the character in the original list.
+	if !party.RemoveChara(cs[1]) {
+		t.Error("can not remove member")
+	}
+	if party.Has(cs[1]) || sysdata.Chara.FindIndexByUID(cs[1].UID) < 0 {
+		t.Error("removing member should remove only from the collection")
+	}
+
+	// removing from the original list removes from the collection too.
+	sysdata.Chara.Remove(sysdata.Chara.FindIndexByUID(cs[2].UID))
+	if expect := []*Character{cs[0], cs[3]}; !reflect.DeepEqual(party.Charas(), expect) {
+		t.Errorf("different members, got: %v, expect: %v", party.Charas(), expect)
+	}
+	if party.Len() != 2 || party.Get(1) != cs[3] || party.Get(2) != nil {
+		t.Errorf("different members by index, len: %v", party.Len())
+	}
+
+	// sorting the collection keeps the original list.
+	base, _ := cs[3].GetInt("Base")
+	base.Set(0, 1)
+	if err := party.SortByKeys("Base.体力"); err != nil {
+		t.Fatal(err)
+	}
+	if expect := []*Character{cs[3], cs[0]}; !reflect.DeepEqual(party.Charas(), expect) {
+		t.Errorf("different sorted members, got: %v, expect: %v", party.Charas(), expect)
+	}
+	if sysdata.Chara.Get(0) != cs[0] {
+		t.Error("sorting the collection should not change the original list")
+	}
+	if n, err := party.Count("Base.体力 > 1"); err != nil || n != 1 {
+		t.Errorf("different count, got: %v, err: %v", n, err)
+	}
+
+	// a character can belong to multiple collections.
+	prison, _ := sysdata.Collection("Prison")
+	if err := prison.Add(cs[0]); err != nil {
+		t.Fatal(err)
+	}
+	if !prison.Has(cs[0]) || !party.Has(cs[0]) {
+		t.Error("character should belong to both collections")
+	}
+
+	// replacing by Set removes the replaced character from collections.
+	replaced := sysdata.Chara.FindIndexByUID(cs[0].UID)
+	sysdata.Chara.Set(replaced, sysdata.Chara.newEmptyCharacter())
+	if prison.Has(cs[0]) || party.Has(cs[0]) || prison.Len() != 0 {
+		t.Error("replaced character should be removed from collections")
+	}
+	for _, c := range party.Charas() {
+		if c == nil {
+			t.Errorf("collection should not contain nil, got: %v", party.Charas())
+		}
+	}
+
+	sysdata.Chara.Clear()
+	if party.Len() != 0 || prison.Len() != 0 {
+		t.Error("clearing the original list should clear collections")
+	}
+}
+
+func TestCharaCollectionRewind(t *testing.T) {
+	gamestate := NewGameState(CSVDB, Repo)
+	sysdata := gamestate.SystemData
+
+	c, err := sysdata.Chara.AddID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	party, _ := sysdata.Collection("Party")
+	if err := party.Add(c); err != nil {
+		t.Fatal(err)
+	}
+	if err := gamestate.TakeSnapshot("added"); err != nil {
+		t.Fatal(err)
+	}
+	party.Clear()
+	if _, err := gamestate.Rewind(1); err != nil {
+		t.Fatal(err)
+	}
+
+	party, _ = gamestate.SystemData.Collection("Party")
+	if party.Len() != 1 || party.Get(0).UID != c.UID {
+		t.Errorf("collection is not restored, got: %v", party.UIDs)
+	}
+	if party.Name() != "Party" || party.Cap() != 4 {
+		t.Errorf("unexported fields are not restored, name: %v, cap: %v", party.Name(), party.Cap())
+	}
+	if err := party.Add(c); err != nil {
+		t.Errorf("restored collection can not be used: %v", err)
+	}
+	gamestate.SystemData.Chara.Remove(0)
+	if party.Len() != 0 {
+		t.Errorf("removed character should be removed from restored collection, got: %v", party.UIDs)
+	}
+}
@@ -0,0 +1,487 @@
+package state
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mzki/erago/state/csv"
+)
+
+// CharaQuery selects characters in Characters by conditions.
+//
+// The condition is a string "Key Op Value" or "Key". Key is a character field,
+// such as "id", "uid" and "name", or a character variable with its index,
+// "Var.Index", where Index is a CSV name or a number. "Var" without Index
+// means index 0. Multi-dimensional variable takes index for each dimension,
+// "Var.Index1.Index2", and omitted indexes mean 0. Op is one of ==, !=, <, <=,
+// > and >=. The condition "Key" means Key is not 0, not empty string or true.
+// Value of Bool variable is true or false, and false is less than true.
+// For example,
+//
+//	Talent.処女
+//	Abl.従順 >= 3
+//	BaseRate.体力 < 0.5
+//	name == "美鈴"
+//
+// The sort key is also Key, and "-" prefixed Key means descending order.
+type CharaQuery struct {
+	Where   []string // conditions, all of which must be satisfied.
+	OrderBy []string // sort keys. characters are ordered by the list if empty.
+	Limit   int      // max number of results. 0 means no limit.
+}
+
+// CharaGroup is a group of characters having the same value of the key.
+type CharaGroup struct {
+	Value  interface{} // int64, string, float64 or bool
+	Charas []*Character
+}
+
+// Select returns characters matched to the query.
+func (cs Characters) Select(q CharaQuery) ([]*Character, error) {
+	return cs.selectIn(cs.List, q)
+}
+
+// selectIn is Select for the charas, which are a part of cs.
+func (cs Characters) selectIn(charas []*Character, q CharaQuery) ([]*Character, error) {
+	match, err := cs.compileConditions(q.Where)
+	if err != nil {
+		return nil, err
+	}
+	less, err := cs.compileSortKeys(q.OrderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make([]*Character, 0, 8)
+	for _, c := range charas {
+		if match(c) {
+			selected = append(selected, c)
+		}
+	}
+	if less != nil {
+		sort.SliceStable(selected, func(i, j int) bool { return less(selected[i], selected[j]) })
+	}
+	if q.Limit > 0 && len(selected) > q.Limit {
+		selected = selected[:q.Limit]
+	}
+	return selected, nil
+}
+
+// Count returns number of characters satisfying all of the conditions.
+func (cs Characters) Count(where ...string) (int, error) {
+	return cs.countIn(cs.List, where)
+}
+
+func (cs Characters) countIn(charas []*Character, where []string) (int, error) {
+	match, err := cs.compileConditions(where)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, c := range charas {
+		if match(c) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// GroupBy groups characters satisfying all of the conditions by the value of key.
+// Groups are ordered by the value ascending, and characters in a group are ordered by the list.
+func (cs Characters) GroupBy(key string, where ...string) ([]CharaGroup, error) {
+	return cs.groupByIn(cs.List, key, where)
+}
+
+func (cs Characters) groupByIn(charas []*Character, key string, where []string) ([]CharaGroup, error) {
+	acc, err := cs.compileKey(key)
+	if err != nil {
+		return nil, err
+	}
+	match, err := cs.compileConditions(where)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]CharaGroup, 0, 8)
+	position := make(map[interface{}]int, 8)
+	for _, c := range charas {
+		if !match(c) {
+			continue
+		}
+		v := acc.value(c)
+		pos, ok := position[v]
+		if !ok {
+			pos = len(groups)
+			position[v] = pos
+			groups = append(groups, CharaGroup{Value: v})
+		}
+		groups[pos].Charas = append(groups[pos].Charas, c)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return acc.compare(groups[i].Value, groups[j].Value) < 0
+	})
+	return groups, nil
+}
+
+// SortByKeys sorts characters by the sort keys in place.
+// The sort is stable, so characters with same keys keep their order.
+func (cs Characters) SortByKeys(keys ...string) error {
+	less, err := cs.compileSortKeys(keys)
+	if err != nil {
+		return err
+	}
+	if less != nil {
+		cs.SortBy(less)
+	}
+	return nil
+}
+
+// kinds of value obtained by charaAccessor.
+type accessorKind int
+
+const (
+	accessInt accessorKind = iota
+	accessStr
+	accessFloat
+	accessBool
+)
+
+// charaAccessor gets a value of the character specified by the key.
+type charaAccessor struct {
+	kind    accessorKind
+	intOf   func(*Character) int64
+	strOf   func(*Character) string
+	floatOf func(*Character) float64
+	boolOf  func(*Character) bool
+}
+
+func (acc charaAccessor) value(c *Character) interface{} {
+	switch acc.kind {
+	case accessStr:
+		return acc.strOf(c)
+	case accessFloat:
+		return acc.floatOf(c)
+	case accessBool:
+		return acc.boolOf(c)
+	default:
+		return acc.intOf(c)
+	}
+}
+
+// compare returns negative, 0 or positive if a is less than, equal to or greater than b.
+func (acc charaAccessor) compare(a, b interface{}) int {
+	switch acc.kind {
+	case accessStr:
+		return strings.Compare(a.(string), b.(string))
+	case accessFloat:
+		return compareOrdered(a.(float64), b.(float64))
+	case accessBool:
+		return compareBool(a.(bool), b.(bool))
+	default:
+		return compareOrdered(a.(int64), b.(int64))
+	}
+}
+
+// compareChara compares values of the characters a and b.
+func (acc charaAccessor) compareChara(a, b *Character) int {
+	switch acc.kind {
+	case accessStr:
+		return strings.Compare(acc.strOf(a), acc.strOf(b))
+	case accessFloat:
+		return compareOrdered(acc.floatOf(a), acc.floatOf(b))
+	case accessBool:
+		return compareBool(acc.boolOf(a), acc.boolOf(b))
+	default:
+		return compareOrdered(acc.intOf(a), acc.intOf(b))
+	}
+}
+
+func compareOrdered[T int64 | float64](x, y T) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// compareBool compares booleans as false < true.
+func compareBool(x, y bool) int {
+	switch {
+	case x == y:
+		return 0
+	case y:
+		return -1
+	default:
+		return 1
+	}
+}
+
+var charaIntFields = map[string]func(*Character) int64{
+	"id":      func(c *Character) int64 { return c.ID },
+	"uid":     func(c *Character) int64 { return int64(c.UID) },
+	"is_assi": func(c *Character) int64 { return c.IsAssi },
+}
+
+var charaStrFields = map[string]func(*Character) string{
+	"name":        func(c *Character) string { return c.Name },
+	"call_name":   func(c *Character) string { return c.CallName },
+	"nick_name":   func(c *Character) string { return c.NickName },
+	"master_name": func(c *Character) string { return c.MasterName },
+}
+
+func (cs Characters) compileKey(key string) (charaAccessor, error) {
+	key = strings.TrimSpace(key)
+	if f, ok := charaIntFields[key]; ok {
+		return charaAccessor{kind: accessInt, intOf: f}, nil
+	}
+	if f, ok := charaStrFields[key]; ok {
+		return charaAccessor{kind: accessStr, strOf: f}, nil
+	}
+
+	varname, indexKey := key, ""
+	if i := strings.Index(key, "."); i >= 0 {
+		varname, indexKey = key[:i], key[i+1:]
+	}
+	var vspec csv.VariableSpec
+	var kind accessorKind
+	var found bool
+	for _, specs := range []struct {
+		kind  accessorKind
+		specs []csv.VariableSpec
+	}{
+		{accessInt, cs.csv.IntVariableSpecs(csv.ScopeChara)},
+		{accessStr, cs.csv.StrVariableSpecs(csv.ScopeChara)},
+		{accessFloat, cs.csv.FloatVariableSpecs(csv.ScopeChara)},
+		{accessBool, cs.csv.BoolVariableSpecs(csv.ScopeChara)},
+	} {
+		for _, vs := range specs.specs {
+			if vs.VarName == varname {
+				vspec, kind, found = vs, specs.kind, true
+			}
+		}
+	}
+	if dv, ok := cs.csv.DerivedVariables(csv.ScopeChara)[varname]; ok && !found {
+		return compileDerivedKey(dv, cs.csv, indexKey)
+	}
+	if !found {
+		return charaAccessor{}, fmt.Errorf("state: unknown character field or variable %q", varname)
+	}
+
+	index := 0
+	if shape, ok := cs.csv.Shape(varname); ok {
+		// multi-dimensional variable has index for each dimension, "Var.i.j".
+		keys := []string{}
+		if len(indexKey) > 0 {
+			keys = strings.Split(indexKey, ".")
+		}
+		indexes := make([]int, len(shape.Dims))
+		for axis, key := range keys {
+			if axis >= len(indexes) {
+				indexes = nil // too many indexes
+				break
+			}
+			indexes[axis] = parseQueryIndex(shape.Names[axis], key)
+		}
+		index = shape.FlatIndex(indexes...)
+	} else if len(indexKey) > 0 {
+		c, _ := cs.csv.Const(varname)
+		index = parseQueryIndex(c, indexKey)
+	}
+	if index < 0 || uint64(index) >= vspec.Size {
+		return charaAccessor{}, fmt.Errorf("state: invalid index %q for %s", indexKey, varname)
+	}
+
+	switch kind {
+	case accessStr:
+		return charaAccessor{kind: kind, strOf: func(c *Character) string {
+			if vars, ok := c.StrMap[varname]; ok && index < len(vars.Values) {
+				return vars.Values[index]
+			}
+			return ""
+		}}, nil
+	case accessFloat:
+		return charaAccessor{kind: kind, floatOf: func(c *Character) float64 {
+			if vars, ok := c.FloatMap[varname]; ok && index < len(vars.Values) {
+				return vars.Values[index]
+			}
+			return 0
+		}}, nil
+	case accessBool:
+		return charaAccessor{kind: kind, boolOf: func(c *Character) bool {
+			if vars, ok := c.BoolMap[varname]; ok && index < len(vars.Values) {
+				return vars.Values[index]
+			}
+			return false
+		}}, nil
+	default:
+		return charaAccessor{kind: kind, intOf: func(c *Character) int64 {
+			if vars, ok := c.IntMap[varname]; ok && index < len(vars.Values) {
+				return vars.Values[index]
+			}
+			return 0
+		}}, nil
+	}
+}
+
+// compileDerivedKey returns accessor for the derived variable, "Var.i".
+func compileDerivedKey(dv csv.DerivedVariable, cm *csv.CsvManager, indexKey string) (charaAccessor, error) {
+	index := 0
+	if len(indexKey) > 0 {
+		c, _ := cm.Const(dv.Source)
+		index = parseQueryIndex(c, indexKey)
+	}
+	if index < 0 || index >= dv.Size {
+		return charaAccessor{}, fmt.Errorf("state: invalid index %q for %s", indexKey, dv.VarName)
+	}
+	varname := dv.VarName
+	return charaAccessor{kind: accessInt, intOf: func(c *Character) int64 {
+		return c.derivedValue(varname, index)
+	}}, nil
+}
+
+// parseQueryIndex returns index for the key, which is a number or a name of c.
+func parseQueryIndex(c csv.Constant, key string) int {
+	if n, err := strconv.Atoi(key); err == nil {
+		return n
+	}
+	return c.GetIndex(key)
+}
+
+// operators for the condition. longer one must be first to match.
+var charaQueryOps = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+// splitCondition splits the condition into key, operator and value
+// at the first operator. op is empty if no operator is found.
+func splitCondition(cond string) (key, op, value string) {
+	pos := -1
+	for _, o := range charaQueryOps {
+		if i := strings.Index(cond, o); i >= 0 && (pos < 0 || i < pos) {
+			pos, op = i, o
+		}
+	}
+	if pos < 0 {
+		return cond, "", ""
+	}
+	return cond[:pos], op, strings.TrimSpace(cond[pos+len(op):])
+}
+
+func (cs Characters) compileCondition(cond string) (func(*Character) bool, error) {
+	key, op, value := splitCondition(cond)
+	acc, err := cs.compileKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("%w in condition %q", err, cond)
+	}
+
+	if len(op) == 0 {
+		switch acc.kind {
+		case accessStr:
+			return func(c *Character) bool { return len(acc.strOf(c)) > 0 }, nil
+		case accessFloat:
+			return func(c *Character) bool { return acc.floatOf(c) != 0 }, nil
+		case accessBool:
+			return acc.boolOf, nil
+		default:
+			return func(c *Character) bool { return acc.intOf(c) != 0 }, nil
+		}
+	}
+
+	// compare without boxing values, since it is called for every character.
+	var compare func(*Character) int
+	switch acc.kind {
+	case accessStr:
+		expect := strings.Trim(value, `"'`)
+		compare = func(c *Character) int { return strings.Compare(acc.strOf(c), expect) }
+	case accessFloat:
+		expect, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("state: value must be number in condition %q", cond)
+		}
+		compare = func(c *Character) int { return compareOrdered(acc.floatOf(c), expect) }
+	case accessBool:
+		expect, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("state: value must be true or false in condition %q", cond)
+		}
+		compare = func(c *Character) int { return compareBool(acc.boolOf(c), expect) }
+	default:
+		expect, err := strconv.ParseInt(value, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("state: value must be number in condition %q", cond)
+		}
+		compare = func(c *Character) int { return compareOrdered(acc.intOf(c), expect) }
+	}
+	var test func(int) bool
+	switch op {
+	case "==":
+		test = func(cmp int) bool { return cmp == 0 }
+	case "!=":
+		test = func(cmp int) bool { return cmp != 0 }
+	case "<":
+		test = func(cmp int) bool { return cmp < 0 }
+	case "<=":
+		test = func(cmp int) bool { return cmp <= 0 }
+	case ">":
+		test = func(cmp int) bool { return cmp > 0 }
+	case ">=":
+		test = func(cmp int) bool { return cmp >= 0 }
+	}
+	return func(c *Character) bool { return test(compare(c)) }, nil
+}
+
+// compileConditions returns a function which tests all of the conditions.
+func (cs Characters) compileConditions(where []string) (func(*Character) bool, error) {
+	tests := make([]func(*Character) bool, 0, len(where))
+	for _, cond := range where {
+		test, err := cs.compileCondition(cond)
+		if err != nil {
+			return nil, err
+		}
+		tests = append(tests, test)
+	}
+	return func(c *Character) bool {
+		for _, test := range tests {
+			if !test(c) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// compileSortKeys returns less function for the sort keys. It returns nil if no keys.
+func (cs Characters) compileSortKeys(keys []string) (func(*Character, *Character) bool, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	type sortKey struct {
+		acc  charaAccessor
+		desc bool
+	}
+	sortKeys := make([]sortKey, 0, len(keys))
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		desc := strings.HasPrefix(key, "-")
+		acc, err := cs.compileKey(strings.TrimPrefix(key, "-"))
+		if err != nil {
+			return nil, fmt.Errorf("%w in sort key %q", err, key)
+		}
+		sortKeys = append(sortKeys, sortKey{acc, desc})
+	}
+	return func(a, b *Character) bool {
+		for _, k := range sortKeys {
+			cmp := k.acc.compareChara(a, b)
+			if k.desc {
+				cmp = -cmp
+			}
+			if cmp != 0 {
+				return cmp < 0
+			}
+		}
+		return false
+	}, nil
+}
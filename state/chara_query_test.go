@@ -0,0 +1,191 @@
+package state
+
+import (
+	"reflect"
+	"testing"
+)
+
+// newQueryCharacters returns characters whose Base.体力 are given values,
+// and names are "c" + index.
+func newQueryCharacters(t *testing.T, hps ...int64) (*Characters, []*Character) {
+	t.Helper()
+	charas := newCharacters(CSVDB)
+	added := make([]*Character, 0, len(hps))
+	for i, hp := range hps {
+		c, err := charas.AddID(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		base, _ := c.GetInt("Base")
+		base.SetByStr("体力", hp)
+		c.Name = "c" + string(rune('0'+i))
+		added = append(added, c)
+	}
+	return charas, added
+}
+
+func TestCharactersSelect(t *testing.T) {
+	charas, cs := newQueryCharacters(t, 100, 300, 200, 300)
+	talent, _ := cs[3].GetInt("Talent")
+	talent.Set(0, 0)
+
+	for _, test := range []struct {
+		Name   string
+		Query  CharaQuery
+		Expect []*Character
+	}{
+		{"no condition", CharaQuery{}, cs},
+		{"int condition", CharaQuery{Where: []string{"Base.体力 >= 200"}}, []*Character{cs[1], cs[2], cs[3]}},
+		{"number index", CharaQuery{Where: []string{"Base.0<200"}}, []*Character{cs[0]}},
+		{"truthy", CharaQuery{Where: []string{"Talent.0"}}, []*Character{cs[0], cs[1], cs[2]}},
+		{"and", CharaQuery{Where: []string{"Talent.0", "Base.体力 == 300"}}, []*Character{cs[1]}},
+		{"str condition", CharaQuery{Where: []string{`name != "c1"`}}, []*Character{cs[0], cs[2], cs[3]}},
+		{"sort", CharaQuery{OrderBy: []string{"-Base.体力", "-uid"}}, []*Character{cs[3], cs[1], cs[2], cs[0]}},
+		{"limit", CharaQuery{OrderBy: []string{"Base.体力"}, Limit: 2}, []*Character{cs[0], cs[2]}},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			got, err := charas.Select(test.Query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.Expect) {
+				t.Errorf("different result, got: %v, expect: %v", got, test.Expect)
+			}
+		})
+	}
+
+	for _, q := range []CharaQuery{
+		{Where: []string{"Unknown.0"}},
+		{Where: []string{"Base.存在しない > 0"}},
+		{Where: []string{"Base.体力 >= abc"}},
+		{OrderBy: []string{"-unknown"}},
+	} {
+		if _, err := charas.Select(q); err == nil {
+			t.Errorf("invalid query %v should be error", q)
+		}
+	}
+}
+
+func TestCharactersSelectDerived(t *testing.T) {
+	charas, cs := newQueryCharacters(t, 100, 200)
+	param, _ := cs[1].GetInt("Param")
+	param.Set(1, 600)
+
+	// declared in _DerivedVariables.csv as "ParamLv, Param, paramlv(Param)"
+	got, err := charas.Select(CharaQuery{Where: []string{"ParamLv.1 > 1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := []*Character{cs[1]}; !reflect.DeepEqual(got, expect) {
+		t.Errorf("different result, got: %v, expect: %v", got, expect)
+	}
+	if _, err := charas.Select(CharaQuery{Where: []string{"ParamLv.-1 > 0"}}); err == nil {
+		t.Error("invalid index of derived variable should be error")
+	}
+}
+
+func TestCharactersCountAndGroupBy(t *testing.T) {
+	charas, cs := newQueryCharacters(t, 100, 300, 200, 300)
+
+	if n, err := charas.Count("Base.体力 > 100"); err != nil {
+		t.Fatal(err)
+	} else if n != 3 {
+		t.Errorf("different count, got: %v, expect: %v", n, 3)
+	}
+
+	groups, err := charas.GroupBy("Base.体力", "Base.体力 > 100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []CharaGroup{
+		{Value: int64(200), Charas: []*Character{cs[2]}},
+		{Value: int64(300), Charas: []*Character{cs[1], cs[3]}},
+	}
+	if !reflect.DeepEqual(groups, expect) {
+		t.Errorf("different groups, got: %v, expect: %v", groups, expect)
+	}
+}
+
+func TestCharactersSortByKeys(t *testing.T) {
+	charas, cs := newQueryCharacters(t, 100, 300, 200)
+	if err := charas.SortByKeys("-Base.体力"); err != nil {
+		t.Fatal(err)
+	}
+	if expect := []*Character{cs[1], cs[2], cs[0]}; !reflect.DeepEqual([]*Character(charas.List), expect) {
+		t.Errorf("different order, got: %v, expect: %v", charas.List, expect)
+	}
+	// lookup by UID should follow the sort.
+	for i, c := range charas.List {
+		if got := charas.FindIndexByUID(c.UID); got != i {
+			t.Errorf("different index for uid %v, got: %v, expect: %v", c.UID, got, i)
+		}
+	}
+}
+
+func TestCharactersSelectMultiDim(t *testing.T) {
+	charas, cs := newQueryCharacters(t, 100, 200)
+	history, _ := cs[1].GetInt("BaseHistory")
+	history.Sub(1).SetByStr("体力", 3)
+
+	for _, test := range []struct {
+		Where  string
+		Expect []*Character
+	}{
+		{"BaseHistory.1.体力 == 3", []*Character{cs[1]}},
+		{"BaseHistory.2.気力 == 5", cs}, // initialized by csv
+		{"BaseHistory.1", []*Character{cs[1]}},
+	} {
+		got, err := charas.Select(CharaQuery{Where: []string{test.Where}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, test.Expect) {
+			t.Errorf("%s: different result, got: %v, expect: %v", test.Where, got, test.Expect)
+		}
+	}
+
+	for _, where := range []string{"BaseHistory.3", "BaseHistory.0.存在しない", "BaseHistory.0.0.0"} {
+		if _, err := charas.Select(CharaQuery{Where: []string{where}}); err == nil {
+			t.Errorf("invalid index %q should be error", where)
+		}
+	}
+}
+
+func TestCharactersSelectFloatBool(t *testing.T) {
+	charas, cs := newQueryCharacters(t, 100, 200, 300)
+	for i, c := range cs {
+		rate, _ := c.GetFloat("BaseRate")
+		rate.SetByStr("体力", 0.25*float64(i))
+	}
+	known, _ := cs[1].GetBool("Known")
+	known.Set(0, true)
+
+	for _, test := range []struct {
+		Query  CharaQuery
+		Expect []*Character
+	}{
+		{CharaQuery{Where: []string{"BaseRate.体力 >= 0.25"}}, []*Character{cs[1], cs[2]}},
+		{CharaQuery{Where: []string{"Known"}}, []*Character{cs[1]}},
+		{CharaQuery{Where: []string{"Known == false"}}, []*Character{cs[0], cs[2]}},
+		{CharaQuery{OrderBy: []string{"-Known", "-BaseRate.体力"}}, []*Character{cs[1], cs[2], cs[0]}},
+	} {
+		got, err := charas.Select(test.Query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, test.Expect) {
+			t.Errorf("%v: different result, got: %v, expect: %v", test.Query, got, test.Expect)
+		}
+	}
+
+	if _, err := charas.Select(CharaQuery{Where: []string{"Known == 2"}}); err == nil {
+		t.Error("non boolean value for Bool variable should be error")
+	}
+	groups, err := charas.GroupBy("Known.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || groups[0].Value != true {
+		t.Errorf("different groups, got: %v", groups)
+	}
+}
@@ -8,43 +8,99 @@ import (
 // It has references for *Character.
 // Its capacity of array is a number of csv.Character.
 //
-// The reference is actually index of []*Character.
-// So the reference is not always equal to *Character,
-// which case is occurred if []*Character is sorted,
-// and relation between index and *Character are changed.
+// The reference is UID of the Character, so that the reference
+// keeps pointing the same Character after []*Character is sorted,
+// some Characters are removed, or the game is reloaded.
+// UID 0 means no reference since UID of Character starts from 1.
+// No reference refers the first Character in the list, as same as
+// older versions in which the reference is index 0 by default.
 type CharaReferences struct {
-	Indexes []int
-	src     *Characters
+	UIDs []uint64
+
+	// Indexes are references as index of []*Character used by older save data.
+	// These are converted into UIDs on loading and are not saved anymore.
+	Indexes []int `json:",omitempty"`
+
+	src *Characters
 }
 
 // n_chara is expected to a number of csv.Character's.
 func newCharaReferences(n_chara int, src *Characters) *CharaReferences {
 	return &CharaReferences{
-		Indexes: make([]int, n_chara),
-		src:     src,
+		UIDs: make([]uint64, n_chara),
+		src:  src,
+	}
+}
+
+// refine converts references of older data into UIDs, requiring after unmarshal.
+func (cref *CharaReferences) refine() {
+	if len(cref.Indexes) == 0 {
+		return
+	}
+	cref.UIDs = make([]uint64, len(cref.Indexes))
+	for i, idx := range cref.Indexes {
+		if c := cref.src.Get(idx); c != nil {
+			cref.UIDs[i] = c.UID
+		}
+	}
+	cref.Indexes = nil
+}
+
+// resize changes length of the references into n, keeping references in range.
+func (cref *CharaReferences) resize(n int) {
+	if len(cref.UIDs) == n {
+		return
 	}
+	uids := make([]uint64, n)
+	copy(uids, cref.UIDs)
+	cref.UIDs = uids
 }
 
 // get character using idx, as like chara = reference[i],
-// exception that if index out of range return nil.
+// exception that if index out of range, no Character in the list or
+// the referenced character is removed, return nil.
 func (cref CharaReferences) GetChara(idx int) *Character {
 	if !cref.checkRange(idx) {
 		return nil
 	}
-	srcI := cref.GetIndex(idx)
-	return cref.src.Get(srcI)
+	return cref.src.FindByUID(cref.GetUID(idx))
 }
 
-// get index of Character at i. if i is out of range return -1.
+// get current index of Character at i in the original chara list.
+// if i is out of range or the Character is not found, return -1.
 func (cref CharaReferences) GetIndex(i int) int {
 	if !cref.checkRange(i) {
 		return -1
 	}
-	return cref.Indexes[i]
+	return cref.src.FindIndexByUID(cref.GetUID(i))
+}
+
+// get UID of Character at i. For no reference, UID of the first Character is returned.
+// if i is out of range or no reference with empty Character list, return 0.
+func (cref CharaReferences) GetUID(i int) uint64 {
+	if !cref.checkRange(i) {
+		return 0
+	}
+	if uid := cref.UIDs[i]; uid != 0 {
+		return uid
+	}
+	if first := cref.src.Get(0); first != nil {
+		return first.UID
+	}
+	return 0
+}
+
+// get CharaHandle of Character at i. if GetUID(i) returns 0, return false.
+// the handle is returned even if the Character is removed.
+func (cref CharaReferences) GetHandle(i int) (CharaHandle, bool) {
+	if uid := cref.GetUID(i); uid != 0 {
+		return CharaHandle{uid: uid, src: cref.src}, true
+	}
+	return CharaHandle{}, false
 }
 
 func (cref CharaReferences) checkRange(idx int) bool {
-	return 0 <= idx && idx < len(cref.Indexes)
+	return 0 <= idx && idx < len(cref.UIDs)
 }
 
 // set character with index. same as reference[i] = chara.
@@ -57,11 +113,10 @@ func (cref *CharaReferences) Set(i int, c *Character) error {
 		return errors.New("CharaReferences.Set: index out of range")
 	}
 
-	chara_i := cref.src.FindIndexByUID(c.UID)
-	if chara_i < 0 {
+	if cref.src.FindIndexByUID(c.UID) < 0 {
 		return fmt.Errorf("CharaReferences.Set: %v is not found in current Chara list", c.Name)
 	}
-	cref.Indexes[i] = chara_i
+	cref.UIDs[i] = c.UID
 	return nil
 }
 
@@ -72,12 +127,12 @@ func (cref CharaReferences) First() *Character {
 
 // return its array size.
 func (cref CharaReferences) Len() int {
-	return len(cref.Indexes)
+	return len(cref.UIDs)
 }
 
-// all Indexes are cleared by zero.
+// all references are cleared, that is no reference which refers the first Character.
 func (cref *CharaReferences) Clear() {
-	for i, _ := range cref.Indexes {
-		cref.Indexes[i] = 0
+	for i := range cref.UIDs {
+		cref.UIDs[i] = 0
 	}
 }
@@ -1,6 +1,7 @@
 package state
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -162,7 +163,7 @@ func TestCharaReferences_Set(t *testing.T) {
 		{"ref any, chara Not found error", args{0}, func(cref *CharaReferences, charas *Characters) (int, *Character) {
 			c := charas.AddEmptyCharacter()
 			charas.Remove(0)
-			return 0, c
+			return -1, c
 		}, true},
 	}
 	for _, tt := range tests {
@@ -173,8 +174,8 @@ func TestCharaReferences_Set(t *testing.T) {
 			if err := cref.Set(tt.args.i, argC); (err != nil) != tt.wantErr {
 				t.Errorf("CharaReferences.Set() error = %v, wantErr %v", err, tt.wantErr)
 			}
-			if got := cref.Indexes[tt.args.i]; got != want {
-				t.Errorf("CharaReferences.Set() internal idx = %v, want %v", got, want)
+			if got := cref.GetIndex(tt.args.i); got != want {
+				t.Errorf("CharaReferences.Set() referenced idx = %v, want %v", got, want)
 			}
 		})
 	}
@@ -189,8 +190,17 @@ func TestCharaReferences_First(t *testing.T) {
 			charas.AddEmptyCharacter()
 			charas.AddEmptyCharacter()
 			c := charas.Get(0)
+			cref.Set(0, c)
 			return c
 		}},
+		{"no reference refers first character", func(cref *CharaReferences, charas *Characters) *Character {
+			charas.AddEmptyCharacter()
+			charas.AddEmptyCharacter()
+			return charas.Get(0)
+		}},
+		{"no reference with empty list", func(cref *CharaReferences, charas *Characters) *Character {
+			return nil
+		}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -251,7 +261,7 @@ func TestCharaReferences_Clear(t *testing.T) {
 			cref := newCharaReferences(10, charas)
 			tt.preconditionAndWant(cref, charas)
 			cref.Clear()
-			for i, v := range cref.Indexes {
+			for i, v := range cref.UIDs {
 				if v != 0 {
 					t.Errorf("CharaReferences.Clear(); Not cleared values at %v, value = %v, expect = 0", i, v)
 				}
@@ -259,3 +269,108 @@ func TestCharaReferences_Clear(t *testing.T) {
 		})
 	}
 }
+
+func TestCharaReferences_StableReference(t *testing.T) {
+	charas := newCharacters(CSVDB)
+	cref := newCharaReferences(10, charas)
+	c0 := charas.AddEmptyCharacter()
+	c1 := charas.AddEmptyCharacter()
+	c2 := charas.AddEmptyCharacter()
+	if err := cref.Set(0, c2); err != nil {
+		t.Fatal(err)
+	}
+	if err := cref.Set(1, c0); err != nil {
+		t.Fatal(err)
+	}
+
+	charas.RevSortBy(func(a, b *Character) bool { return a.UID < b.UID })
+	if got := cref.GetChara(0); got != c2 {
+		t.Errorf("reference should be kept after sort, got: %v, want: %v", got, c2)
+	}
+	if got := cref.GetIndex(0); got != 0 {
+		t.Errorf("index should follow sort, got: %v, want: %v", got, 0)
+	}
+
+	// removes c1 at index 1, and c0 is shifted.
+	if !charas.Remove(charas.FindIndexByUID(c1.UID)) {
+		t.Fatal("can not remove character")
+	}
+	if got := cref.GetChara(1); got != c0 {
+		t.Errorf("reference should be kept after removing other, got: %v, want: %v", got, c0)
+	}
+	if got := cref.GetIndex(1); got != 1 {
+		t.Errorf("index should follow removal, got: %v, want: %v", got, 1)
+	}
+
+	charas.Remove(charas.FindIndexByUID(c2.UID))
+	if got := cref.GetChara(0); got != nil {
+		t.Errorf("reference to removed character should be nil, got: %v", got)
+	}
+	if got := cref.GetUID(0); got != c2.UID {
+		t.Errorf("UID of removed character should be remained, got: %v, want: %v", got, c2.UID)
+	}
+}
+
+func TestCharaReferences_refineOlderData(t *testing.T) {
+	charas := newCharacters(CSVDB)
+	c0 := charas.AddEmptyCharacter()
+	c1 := charas.AddEmptyCharacter()
+	cref := &CharaReferences{Indexes: []int{1, 0, 5}, src: charas}
+	cref.refine()
+	if cref.Indexes != nil {
+		t.Errorf("Indexes should be dropped after refine, got: %v", cref.Indexes)
+	}
+	if got, want := cref.UIDs, []uint64{c1.UID, c0.UID, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("invalid converted UIDs, got: %v, want: %v", got, want)
+	}
+}
+
+func TestSystemData_DeclaredReferences(t *testing.T) {
+	gamestate := NewGameState(CSVDB, Repo)
+	sysdata := gamestate.SystemData
+
+	expectNames := []string{"Assi", "Master", "Player", "Rival", "Target"}
+	if got := sysdata.ReferenceNames(); !reflect.DeepEqual(got, expectNames) {
+		t.Errorf("different reference names, got: %v, expect: %v", got, expectNames)
+	}
+	if target, _ := sysdata.Reference("Target"); target != sysdata.Target {
+		t.Error("builtin reference should be same as the field")
+	}
+	if _, ok := sysdata.References["Target"]; ok {
+		t.Error("builtin reference should not be in References")
+	}
+	rival, ok := sysdata.Reference("Rival")
+	if !ok || rival != sysdata.References["Rival"] {
+		t.Fatal("declared reference is not found")
+	}
+	if rival.Len() != 3 {
+		t.Errorf("different size of declared reference, got: %v, expect: %v", rival.Len(), 3)
+	}
+
+	c, err := sysdata.Chara.AddID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rival.Set(2, c); err != nil {
+		t.Fatal(err)
+	}
+	if err := gamestate.TakeSnapshot("set"); err != nil {
+		t.Fatal(err)
+	}
+	rival.Clear()
+	if _, err := gamestate.Rewind(1); err != nil {
+		t.Fatal(err)
+	}
+	// the reference held before is restored.
+	if got := rival.GetChara(2); got == nil || got.UID != c.UID {
+		t.Errorf("declared reference is not restored, got: %v", rival.UIDs)
+	}
+	if got, _ := sysdata.Reference("Rival"); got != rival {
+		t.Error("declared reference should be kept same after restoring")
+	}
+
+	sysdata.Clear()
+	if rival.GetUID(2) != 0 {
+		t.Error("declared reference should be cleared")
+	}
+}
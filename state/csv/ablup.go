@@ -0,0 +1,134 @@
+package csv
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ablUpFileName is an optional file which defines requirements for abilities level up.
+//
+// Each record is:
+//
+//	ability, level, juels, exps, talents
+//
+// where ability is a name or number in Abl.csv, level is the level reached by
+// the level up. juels and exps are "/" separated list of "name=amount",
+// and talents is "/" separated list of the talent name. Prefix "!" to
+// the talent name means the talent must not be had. juels are consumed
+// by the level up, while exps and talents are only checked.
+//
+// Example:
+//
+//	Ｃ感覚, 1, 快Ｃ=100/潤滑=50, , 処女
+//	Ｃ感覚, 2, 快Ｃ=500, 絶頂経験=5, !処女
+const ablUpFileName = "_AblUp.csv"
+
+// AblUpRequirement is a requirement to level up an ability.
+type AblUpRequirement struct {
+	Abl     int   // index of Abl.
+	Level   int64 // level reached by this level up.
+	Juels   []AmountRequirement
+	Exps    []AmountRequirement
+	Talents []TalentRequirement
+}
+
+// AmountRequirement requires that the value at Index is larger than or equal to Amount.
+type AmountRequirement struct {
+	Index  int
+	Amount int64
+}
+
+// TalentRequirement requires that the talent at Index is had or not.
+type TalentRequirement struct {
+	Index int
+	Has   bool
+}
+
+// readAblUps reads ablup file and resolves names by constants.
+func readAblUps(file string, constants map[string]Constant) ([]AblUpRequirement, error) {
+	indexOf := func(varname, key string) (int, error) {
+		c, ok := constants[varname]
+		if !ok {
+			return 0, fmt.Errorf("%s is not defined", varname)
+		}
+		if i := c.NameIndex.GetIndex(key); i != IndexNotFound {
+			return i, nil
+		}
+		if i, err := strconv.Atoi(key); err == nil && c.Names.InRange(i) {
+			return i, nil
+		}
+		return 0, fmt.Errorf("%s does not have %q", varname, key)
+	}
+	amountsOf := func(varname, field string) ([]AmountRequirement, error) {
+		var ret []AmountRequirement
+		for _, req := range splitAblUpField(field) {
+			kv := strings.SplitN(req, "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("%s requirement must be name=amount, but %q", varname, req)
+			}
+			idx, err := indexOf(varname, strings.TrimSpace(kv[0]))
+			if err != nil {
+				return nil, err
+			}
+			amount, err := strconv.ParseInt(strings.TrimSpace(kv[1]), 0, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s requirement has invalid amount %q", varname, req)
+			}
+			ret = append(ret, AmountRequirement{Index: idx, Amount: amount})
+		}
+		return ret, nil
+	}
+
+	ablups := make([]AblUpRequirement, 0, 32)
+	err := ReadFileFunc(file, func(record []string) error {
+		for len(record) < 5 {
+			record = append(record, "")
+		}
+		abl, err := indexOf(BuiltinAblName, record[0])
+		if err != nil {
+			return err
+		}
+		level, err := strconv.ParseInt(record[1], 0, 64)
+		if err != nil || level < 1 {
+			return fmt.Errorf("level must be positive number, but %q", record[1])
+		}
+		juels, err := amountsOf(BuiltinJuelName, record[2])
+		if err != nil {
+			return err
+		}
+		exps, err := amountsOf(BuiltinExpName, record[3])
+		if err != nil {
+			return err
+		}
+		var talents []TalentRequirement
+		for _, req := range splitAblUpField(record[4]) {
+			has := !strings.HasPrefix(req, "!")
+			idx, err := indexOf(BuiltinTalentName, strings.TrimSpace(strings.TrimPrefix(req, "!")))
+			if err != nil {
+				return err
+			}
+			talents = append(talents, TalentRequirement{Index: idx, Has: has})
+		}
+		ablups = append(ablups, AblUpRequirement{
+			Abl:     abl,
+			Level:   level,
+			Juels:   juels,
+			Exps:    exps,
+			Talents: talents,
+		})
+		return nil
+	})
+	return ablups, err
+}
+
+func splitAblUpField(field string) []string {
+	if len(field) == 0 {
+		return nil
+	}
+	reqs := strings.Split(field, "/")
+	for i, req := range reqs {
+		reqs[i] = strings.TrimSpace(req)
+	}
+	return reqs
+}
@@ -0,0 +1,120 @@
+package csv
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// variableBoundsFileName is an optional file which defines default value and
+// range of the Int user variables.
+//
+// Each record is:
+//
+//	variable, default, min, max, on_violation
+//
+// where variable is a name of the Int variable with scope System, Share or Chara.
+// Empty default, min or max is not specified. Unspecified default is 0 limited into
+// the range. on_violation is "clamp" or "error". "clamp", which is also used
+// for empty, limits a value out of range into the range, and "error" rejects it.
+// The bound is applied to all of elements of the variable.
+//
+// Example:
+//
+//	Base, , 0, , clamp
+//	Stain, 10, 0, 100, error
+const variableBoundsFileName = "_VariableBounds.csv"
+
+const (
+	boundOnViolationClamp = "clamp"
+	boundOnViolationError = "error"
+)
+
+// VariableBound is a default value and range of an Int variable.
+type VariableBound struct {
+	Default int64 // initial value of elements.
+	Min     int64 // valid only if HasMin.
+	Max     int64 // valid only if HasMax.
+	HasMin  bool
+	HasMax  bool
+	Clamp   bool // limits a value out of range into the range instead of rejecting it.
+}
+
+// Contains returns whether val is in the range.
+func (b VariableBound) Contains(val int64) bool {
+	return !(b.HasMin && val < b.Min) && !(b.HasMax && val > b.Max)
+}
+
+// Limit returns val limited into the range.
+func (b VariableBound) Limit(val int64) int64 {
+	if b.HasMin && val < b.Min {
+		return b.Min
+	}
+	if b.HasMax && val > b.Max {
+		return b.Max
+	}
+	return val
+}
+
+// readVariableBounds reads bounds file for Int variables defined in vspecs.
+func readVariableBounds(file string, vspecs variableSpecInternalMap) (map[string]VariableBound, error) {
+	parseOptionalInt := func(field string) (int64, bool, error) {
+		field = strings.TrimSpace(field)
+		if len(field) == 0 {
+			return 0, false, nil
+		}
+		i, err := strconv.ParseInt(field, 0, 64)
+		return i, true, err
+	}
+
+	bounds := make(map[string]VariableBound)
+	err := ReadFileFunc(file, func(record []string) error {
+		const numFields = 5
+		for len(record) < numFields {
+			record = append(record, "")
+		}
+		vname := strings.TrimSpace(record[0])
+		vs, ok := vspecs[vname]
+		if !ok {
+			return fmt.Errorf("%s is not defined", vname)
+		}
+		if vs.DataType != dTypeInt || (vs.Scope != scopeSystem && vs.Scope != scopeShare && vs.Scope != scopeChara) {
+			return fmt.Errorf("%s must be Int variable with scope System, Share or Chara", vname)
+		}
+		if _, dup := bounds[vname]; dup {
+			return fmt.Errorf("%s is already defined", vname)
+		}
+
+		var b VariableBound
+		def, hasDef, err := parseOptionalInt(record[1])
+		if err != nil {
+			return fmt.Errorf("%s: invalid default %q", vname, record[1])
+		}
+		if b.Min, b.HasMin, err = parseOptionalInt(record[2]); err != nil {
+			return fmt.Errorf("%s: invalid min %q", vname, record[2])
+		}
+		if b.Max, b.HasMax, err = parseOptionalInt(record[3]); err != nil {
+			return fmt.Errorf("%s: invalid max %q", vname, record[3])
+		}
+		if b.HasMin && b.HasMax && b.Min > b.Max {
+			return fmt.Errorf("%s: min %d is larger than max %d", vname, b.Min, b.Max)
+		}
+		if hasDef && !b.Contains(def) {
+			return fmt.Errorf("%s: default %d is out of range", vname, def)
+		}
+		b.Default = b.Limit(def)
+
+		switch onViolation := strings.TrimSpace(record[4]); onViolation {
+		case "", boundOnViolationClamp:
+			b.Clamp = true
+		case boundOnViolationError:
+			b.Clamp = false
+		default:
+			return fmt.Errorf("%s: on_violation must be %q or %q, but %q",
+				vname, boundOnViolationClamp, boundOnViolationError, onViolation)
+		}
+		bounds[vname] = b
+		return nil
+	})
+	return bounds, err
+}
@@ -0,0 +1,168 @@
+package csv
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// calendarFileName is an optional file which defines the calendar.
+//
+// Each record is a key and its values:
+//
+//	DayLength, 4              ; number of time slots in a day. default is 1.
+//	TimeSlots, 朝/昼/夕/夜     ; names of time slots. it overrides DayLength.
+//	Weekdays, 月/火/水/木/金/土/日
+//	Seasons, 春/夏/秋/冬
+//	SeasonLength, 30          ; number of days in a season.
+//	Event, 夏祭り, season=夏/dayofseason=10/slot=夜
+//
+// Event is a scheduled event which has a name and "/" separated conditions.
+// The condition is one of day=N, every=N, weekday=NAME, season=NAME,
+// dayofseason=N and slot=NAME, where days are counted from 0.
+// Event without slot condition occurs at the first time slot of the day.
+const calendarFileName = "_Calendar.csv"
+
+// Builtin system variable which holds elapsed time slots from the start of the calendar.
+const BuiltinCalendarTimeName = "CalendarTime" // Scope System
+
+// CalendarConfig is a definition of the calendar.
+type CalendarConfig struct {
+	TimeSlots    []string // names of time slots. its length is the day length.
+	Weekdays     []string // names of weekdays. empty means no weekday.
+	Seasons      []string // names of seasons. empty means no season.
+	SeasonLength int      // days in a season.
+	Events       []CalendarEvent
+}
+
+// CalendarEvent is a scheduled event. Negative value of the condition means
+// the condition is not specified.
+type CalendarEvent struct {
+	Name        string
+	Day         int64
+	Every       int64
+	DayOfSeason int64
+	Weekday     int
+	Season      int
+	Slot        int
+}
+
+func newCalendarConfig() CalendarConfig {
+	return CalendarConfig{TimeSlots: []string{""}}
+}
+
+func readCalendarConfig(file string) (CalendarConfig, error) {
+	cc := newCalendarConfig()
+	eventRecords := make([][]string, 0, 8)
+	err := ReadFileFunc(file, func(record []string) error {
+		var err error
+		switch value := record[1]; record[0] {
+		case "DayLength":
+			var n int
+			if n, err = strconv.Atoi(value); err != nil || n < 1 {
+				return fmt.Errorf("DayLength must be positive number, but %q", value)
+			}
+			cc.TimeSlots = make([]string, n)
+		case "TimeSlots":
+			cc.TimeSlots = splitCalendarNames(value)
+		case "Weekdays":
+			cc.Weekdays = splitCalendarNames(value)
+		case "Seasons":
+			cc.Seasons = splitCalendarNames(value)
+		case "SeasonLength":
+			if cc.SeasonLength, err = strconv.Atoi(value); err != nil || cc.SeasonLength < 1 {
+				return fmt.Errorf("SeasonLength must be positive number, but %q", value)
+			}
+		case "Event":
+			// resolve later since names in conditions may be defined after it.
+			eventRecords = append(eventRecords, record)
+		default:
+			return fmt.Errorf("unknown record name %v", record)
+		}
+		return nil
+	})
+	if err != nil {
+		return cc, err
+	}
+	if len(cc.TimeSlots) == 0 {
+		return cc, fmt.Errorf("%s: TimeSlots must not be empty", file)
+	}
+	if len(cc.Seasons) > 0 && cc.SeasonLength == 0 {
+		return cc, fmt.Errorf("%s: SeasonLength is required for Seasons", file)
+	}
+	for _, record := range eventRecords {
+		ev, err := cc.parseEvent(record)
+		if err != nil {
+			return cc, fmt.Errorf("%s: %v", file, err)
+		}
+		cc.Events = append(cc.Events, ev)
+	}
+	return cc, nil
+}
+
+func (cc CalendarConfig) parseEvent(record []string) (CalendarEvent, error) {
+	ev := CalendarEvent{Name: record[1], Day: -1, Every: -1, DayOfSeason: -1, Weekday: -1, Season: -1, Slot: -1}
+	if len(ev.Name) == 0 {
+		return ev, fmt.Errorf("event name must not be empty")
+	}
+	if len(record) < 3 {
+		return ev, nil
+	}
+	indexOf := func(names []string, key, name string) (int, error) {
+		for i, n := range names {
+			if n == name {
+				return i, nil
+			}
+		}
+		if i, err := strconv.Atoi(name); err == nil && 0 <= i && i < len(names) {
+			return i, nil
+		}
+		return 0, fmt.Errorf("event %s: %s %q is not defined", ev.Name, key, name)
+	}
+	for _, cond := range splitCalendarNames(record[2]) {
+		kv := strings.SplitN(cond, "=", 2)
+		if len(kv) != 2 {
+			return ev, fmt.Errorf("event %s: condition must be key=value, but %q", ev.Name, cond)
+		}
+		key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		var err error
+		switch key {
+		case "day", "every", "dayofseason":
+			var n int64
+			if n, err = strconv.ParseInt(value, 0, 64); err != nil || n < 0 || (key == "every" && n == 0) {
+				return ev, fmt.Errorf("event %s: invalid %s %q", ev.Name, key, value)
+			}
+			switch key {
+			case "day":
+				ev.Day = n
+			case "every":
+				ev.Every = n
+			default:
+				ev.DayOfSeason = n
+			}
+		case "weekday":
+			ev.Weekday, err = indexOf(cc.Weekdays, key, value)
+		case "season":
+			ev.Season, err = indexOf(cc.Seasons, key, value)
+		case "slot":
+			ev.Slot, err = indexOf(cc.TimeSlots, key, value)
+		default:
+			return ev, fmt.Errorf("event %s: unknown condition %q", ev.Name, key)
+		}
+		if err != nil {
+			return ev, err
+		}
+	}
+	return ev, nil
+}
+
+func splitCalendarNames(s string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	names := strings.Split(s, "/")
+	for i, name := range names {
+		names[i] = strings.TrimSpace(name)
+	}
+	return names
+}
@@ -43,6 +43,9 @@ const (
 	// exceptional custom field which must exist on Item constant
 	// after successful of the csv initialization.
 	HeaderFieldItemPrice = "price"
+
+	// optional custom field on Item constant to group items.
+	HeaderFieldItemCategory = "category"
 )
 
 // read CSV file that defines names and custom fields for each variable,
@@ -274,6 +277,14 @@ func (ns Names) InRange(index int) bool {
 	return 0 <= index && index < len(ns)
 }
 
+// return name of the index. if index is out of range return empty string.
+func (ns Names) GetName(i int) string {
+	if !ns.InRange(i) {
+		return ""
+	}
+	return ns[i]
+}
+
 // NameIndex holds indexes corresponding to each Name defined in CSV.
 type NameIndex map[string]int
 
@@ -5,6 +5,7 @@ package csv
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/mzki/erago/filesystem"
@@ -37,6 +38,12 @@ const (
 	BuiltinItemName      = "Item"      // Scope System
 	BuiltinItemPriceName = "ItemPrice" // Scope CSV
 
+	// character references. its size is a number of csv characters by default.
+	BuiltinTargetName = "Target" // Scope CharaRef
+	BuiltinMasterName = "Master" // Scope CharaRef
+	BuiltinPlayerName = "Player" // Scope CharaRef
+	BuiltinAssiName   = "Assi"   // Scope CharaRef
+
 	exceptItemName      = BuiltinItemName
 	exceptItemPriceName = BuiltinItemPriceName
 )
@@ -61,9 +68,28 @@ type CsvManager struct {
 	// user defined csv names and indexes.
 	constants map[string]Constant
 
+	// shapes of multi-dimensional variables.
+	shapes map[string]Shape
+
+	// bounds of Int variables defined by _VariableBounds.csv.
+	bounds map[string]VariableBound
+
+	// derived variables defined by _DerivedVariables.csv.
+	derived map[string]DerivedVariable
+
 	// the exceptional constants for reading csv file.
 	Item       Constant
 	ItemPrices []int64
+	// ItemCategories is category names of items. It is nil if Item.csv does not have `str_category` field.
+	ItemCategories []string
+
+	// AblUps is requirements for abilities level up defined by _AblUp.csv.
+	// It is nil if the file does not exist.
+	AblUps []AblUpRequirement
+
+	// Calendar is a definition of the calendar by _Calendar.csv.
+	// It has a day of one time slot without names if the file does not exist.
+	Calendar CalendarConfig
 
 	// default definition of the characters.
 	// These are defined by "Chara/*.csv".
@@ -74,8 +100,10 @@ type CsvManager struct {
 	vspecs variableSpecInternalMap
 
 	// these are cached since character variables are referenced frequently.
-	vspecsCharaInt variableSpecInternalMap
-	vspecsCharaStr variableSpecInternalMap
+	vspecsCharaInt   variableSpecInternalMap
+	vspecsCharaStr   variableSpecInternalMap
+	vspecsCharaFloat variableSpecInternalMap
+	vspecsCharaBool  variableSpecInternalMap
 
 	// some optional data, GameBase, Replace, and aliasMap,
 	// are loaded from _{filename}.csv to configure
@@ -152,13 +180,18 @@ const (
 	ScopeShare = VarScope(scopeShare)
 	// Chara variables is character specific variables.
 	ScopeChara = VarScope(scopeChara)
+	// CharaList is named list of characters, which has no data type.
+	ScopeCharaList = VarScope(scopeCharaList)
+	// CharaRef is named fixed-size array of character references, which has no data type.
+	ScopeCharaRef = VarScope(scopeCharaRef)
 )
 
 // VariableSpec defines the spec of user defined varables.
 type VariableSpec struct {
 	VarName string
 	Scope   VarScope
-	Size    uint64
+	Size    uint64 // number of all values, that is product of Dims for multi-dimensional variable.
+	Dims    []int  // sizes of each dimension for multi-dimensional variable, nil for 1-D variable.
 }
 
 // IntVariableSpecs returns slice of VariableSpecs which mathces
@@ -173,6 +206,18 @@ func (cm *CsvManager) StrVariableSpecs(where VarScope) []VariableSpec {
 	return cm.selectVariableSpecs(where, dTypeStr)
 }
 
+// FloatVariableSpecs returns slice of VariableSpecs which mathces
+// given VarScope and data type Float.
+func (cm *CsvManager) FloatVariableSpecs(where VarScope) []VariableSpec {
+	return cm.selectVariableSpecs(where, dTypeFloat)
+}
+
+// BoolVariableSpecs returns slice of VariableSpecs which mathces
+// given VarScope and data type Bool.
+func (cm *CsvManager) BoolVariableSpecs(where VarScope) []VariableSpec {
+	return cm.selectVariableSpecs(where, dTypeBool)
+}
+
 func (cm *CsvManager) selectVariableSpecs(where VarScope, dtype vspecIdent) []VariableSpec {
 	vs := cm.vspecs.selectByScopeAndDType(vspecIdent(where), dtype)
 	vspecs := make([]VariableSpec, len(vs))
@@ -181,22 +226,63 @@ func (cm *CsvManager) selectVariableSpecs(where VarScope, dtype vspecIdent) []Va
 		vspecs[i] = VariableSpec{
 			VarName: v.VarName,
 			Scope:   VarScope(v.Scope),
-			Size:    uint64(v.Size[0]),
+			Size:    uint64(v.totalSize()),
+		}
+		if v.isMultiDim() {
+			vspecs[i].Dims = append([]int{}, v.Size...)
 		}
 		i++
 	}
 	return vspecs
 }
 
+// CharaListSpecs returns slice of VariableSpecs declared with scope CharaList,
+// sorted by VarName. Its Size is the maximum number of characters in the list,
+// and 0 means no limit.
+func (cm *CsvManager) CharaListSpecs() []VariableSpec {
+	return cm.selectNoDataSpecs(scopeCharaList)
+}
+
+// CharaRefSpecs returns slice of VariableSpecs declared with scope CharaRef,
+// including builtin Target, Master, Player and Assi, sorted by VarName.
+// Its Size is the length of the references, which is a number of csv characters
+// if not specified.
+func (cm *CsvManager) CharaRefSpecs() []VariableSpec {
+	vspecs := cm.selectNoDataSpecs(scopeCharaRef)
+	for i, v := range vspecs {
+		if v.Size == 0 {
+			vspecs[i].Size = uint64(len(cm.CharaMap))
+		}
+	}
+	return vspecs
+}
+
+// selectNoDataSpecs returns VariableSpecs of scope which has no data type, sorted by VarName.
+func (cm *CsvManager) selectNoDataSpecs(scope vspecIdent) []VariableSpec {
+	vs := cm.vspecs.selectBy(func(v variableSpecInternal) bool {
+		return v.Scope == scope
+	})
+	vspecs := make([]VariableSpec, 0, len(vs))
+	for _, v := range vs {
+		vspecs = append(vspecs, VariableSpec{
+			VarName: v.VarName,
+			Scope:   VarScope(v.Scope),
+			Size:    uint64(v.Size[0]),
+		})
+	}
+	sort.Slice(vspecs, func(i, j int) bool { return vspecs[i].VarName < vspecs[j].VarName })
+	return vspecs
+}
+
 // return variable maps, which type are DataType string and
 // scope where, where = {System, Share}.
 // It allocates new valiables every call.
 func (cm *CsvManager) BuildStrUserVars(where VarScope) map[string][]string {
 	if scope := vspecIdent(where); scope == scopeChara {
-		return newStrMapByVSpecs(cm.vspecsCharaStr)
+		return newMapByVSpecs[string](cm.vspecsCharaStr)
 	} else {
 		vs := cm.vspecs.selectByScopeAndDType(scope, dTypeStr)
-		return newStrMapByVSpecs(vs)
+		return newMapByVSpecs[string](vs)
 	}
 }
 
@@ -205,27 +291,56 @@ func (cm *CsvManager) BuildStrUserVars(where VarScope) map[string][]string {
 // It allocates new valiables every call.
 func (cm *CsvManager) BuildIntUserVars(where VarScope) map[string][]int64 {
 	if scope := vspecIdent(where); scope == scopeChara {
-		return newIntMapByVSpecs(cm.vspecsCharaInt)
+		return cm.fillDefaults(newMapByVSpecs[int64](cm.vspecsCharaInt))
 	} else {
 		vs := cm.vspecs.selectByScopeAndDType(scope, dTypeInt)
-		return newIntMapByVSpecs(vs)
+		return cm.fillDefaults(newMapByVSpecs[int64](vs))
 	}
 }
 
-func newIntMapByVSpecs(vspecs variableSpecInternalMap) map[string][]int64 {
-	int_map := make(map[string][]int64, len(vspecs))
-	for _, vs := range vspecs {
-		int_map[vs.VarName] = make([]int64, vs.Size[0])
+// fillDefaults sets default values defined by bounds into m.
+func (cm *CsvManager) fillDefaults(m map[string][]int64) map[string][]int64 {
+	for vname, b := range cm.bounds {
+		if values, ok := m[vname]; ok && b.Default != 0 {
+			for i := range values {
+				values[i] = b.Default
+			}
+		}
+	}
+	return m
+}
+
+// return variable maps, which type are DataType float64 and
+// scope where, where = {System, Share}.
+// It allocates new valiables every call.
+func (cm *CsvManager) BuildFloatUserVars(where VarScope) map[string][]float64 {
+	if scope := vspecIdent(where); scope == scopeChara {
+		return newMapByVSpecs[float64](cm.vspecsCharaFloat)
+	} else {
+		vs := cm.vspecs.selectByScopeAndDType(scope, dTypeFloat)
+		return newMapByVSpecs[float64](vs)
+	}
+}
+
+// return variable maps, which type are DataType bool and
+// scope where, where = {System, Share}.
+// It allocates new valiables every call.
+func (cm *CsvManager) BuildBoolUserVars(where VarScope) map[string][]bool {
+	if scope := vspecIdent(where); scope == scopeChara {
+		return newMapByVSpecs[bool](cm.vspecsCharaBool)
+	} else {
+		vs := cm.vspecs.selectByScopeAndDType(scope, dTypeBool)
+		return newMapByVSpecs[bool](vs)
 	}
-	return int_map
 }
 
-func newStrMapByVSpecs(vspecs variableSpecInternalMap) map[string][]string {
-	str_map := make(map[string][]string, len(vspecs))
+// newMapByVSpecs allocates zero values of type T for each variable.
+func newMapByVSpecs[T any](vspecs variableSpecInternalMap) map[string][]T {
+	m := make(map[string][]T, len(vspecs))
 	for _, vs := range vspecs {
-		str_map[vs.VarName] = make([]string, vs.Size[0])
+		m[vs.VarName] = make([]T, vs.totalSize())
 	}
-	return str_map
+	return m
 }
 
 // initialize by reading csv files.
@@ -266,6 +381,11 @@ func (cm *CsvManager) Initialize(config Config) (err error) {
 			cm.NumberConstants = *numbers
 			errs.Add(err)
 		}
+		cm.Calendar = newCalendarConfig()
+		if file := config.filepath(calendarFileName); FileExists(file) {
+			cm.Calendar, err = readCalendarConfig(file)
+			errs.Add(err)
+		}
 		if err = errs.Err(); err != nil {
 			return err
 		}
@@ -310,6 +430,9 @@ func (cm *CsvManager) Initialize(config Config) (err error) {
 		// TODO Remove struct Field Item and ItemPrices?
 		cm.Item = *newConst
 		cm.ItemPrices = newConst.CustomFields.MustInts(HeaderFieldItemPrice).data
+		if categories, ok := newConst.CustomFields.Strings(HeaderFieldItemCategory); ok {
+			cm.ItemCategories = categories.Names
+		}
 
 		// Publish as Constant so that it is used in
 		// the same mannar as the other variables.
@@ -319,14 +442,56 @@ func (cm *CsvManager) Initialize(config Config) (err error) {
 
 	// fit variable size by Constant.Names one.
 	new_vspecs := cm.vspecs.Map(func(v variableSpecInternal) variableSpecInternal {
+		v.Size = append([]int{}, v.Size...) // not to modify shared sizes such as builtin's.
 		if v.Size[0] <= 0 {
 			v.Size[0] = cm.constants[v.VarName].Names.Len()
 		}
+		if shape, ok := cm.shapes[v.VarName]; ok {
+			for axis := 1; axis < len(v.Size); axis++ {
+				if v.Size[axis] <= 0 {
+					v.Size[axis] = shape.Names[axis].Names.Len()
+				}
+			}
+			shape.Dims = v.Size
+			cm.shapes[v.VarName] = shape
+		}
 		return v
 	})
+	for vname, shape := range cm.shapes {
+		for axis, size := range shape.Dims {
+			if size <= 0 {
+				return fmt.Errorf("csv: size of dimension %d of %s is not determined, specify it or its csv file", axis+1, vname)
+			}
+		}
+	}
 	cm.vspecs = new_vspecs
 	cm.vspecsCharaInt = new_vspecs.selectByScopeAndDType(scopeChara, dTypeInt)
 	cm.vspecsCharaStr = new_vspecs.selectByScopeAndDType(scopeChara, dTypeStr)
+	cm.vspecsCharaFloat = new_vspecs.selectByScopeAndDType(scopeChara, dTypeFloat)
+	cm.vspecsCharaBool = new_vspecs.selectByScopeAndDType(scopeChara, dTypeBool)
+
+	// load bounds of Int variables.
+	cm.bounds = make(map[string]VariableBound)
+	if file := config.filepath(variableBoundsFileName); FileExists(file) {
+		if cm.bounds, err = readVariableBounds(file, cm.vspecs); err != nil {
+			return fmt.Errorf("csv: can not be initialized: %v", err)
+		}
+	}
+
+	// load derived variables which refer other variables.
+	cm.derived = make(map[string]DerivedVariable)
+	if file := config.filepath(derivedVariablesFileName); FileExists(file) {
+		if cm.derived, err = readDerivedVariables(file, cm); err != nil {
+			return fmt.Errorf("csv: can not be initialized: %v", err)
+		}
+	}
+
+	// load ablup requirements which refer names of constants.
+	if file := config.filepath(ablUpFileName); FileExists(file) {
+		if cm.AblUps, err = readAblUps(file, cm.constants); err != nil {
+			return fmt.Errorf("csv: can not be initialized: %v", err)
+		}
+	}
 
 	// read character
 	return cm.initCharacters(config.charaPattern())
@@ -350,33 +515,57 @@ func (cm *CsvManager) initVariableSpecs(all_vspecs variableSpecInternalMap) erro
 // build Names of given variableSpecs
 func (cm *CsvManager) buildConstants(vspecs variableSpecInternalMap) error {
 	cm.constants = make(map[string]Constant, len(vspecs)*2)
+	cm.shapes = make(map[string]Shape)
 
-	for _, vs := range vspecs {
-		fname := vs.FileName
-		if len(fname) == 0 { // ignore empty file name
-			continue
+	// load csv file or get already loaded one.
+	loadConstant := func(fname string) (Constant, error) {
+		if constant, has := cm.constants[fname]; has {
+			return constant, nil
+		}
+		newConst, err := readConstantFile(
+			cm.config.filepath(fname),
+			cm.readIntBuffer,
+			cm.readStringBuffer,
+		)
+		if err != nil {
+			return Constant{}, err
 		}
+		cm.constants[fname] = *newConst // csv file name is also remindered.
+		return *newConst, nil
+	}
+
+	for _, vs := range vspecs {
+		varname := vs.VarName
+		fnames := vs.fileNames()
 
-		constant, has := cm.constants[fname]
-		if !has {
-			// load new csv file
-			newConst, err := readConstantFile(
-				cm.config.filepath(fname),
-				cm.readIntBuffer,
-				cm.readStringBuffer,
-			)
+		// names for the first dimension are published as Constant of the variable.
+		if fname := fnames[0]; len(fname) > 0 { // ignore empty file name
+			constant, err := loadConstant(fname)
 			if err != nil {
 				return err
 			}
-			constant = *newConst
+			// register Names and its indexes.
+			if _, has := cm.constants[varname]; has {
+				return fmt.Errorf("csv: duplicate VarName (%s)", varname)
+			}
+			cm.constants[varname] = constant
 		}
-		// register Names and its indexes.
-		varname := vs.VarName
-		if _, has := cm.constants[varname]; has {
-			return fmt.Errorf("csv: duplicate VarName (%s)", varname)
+
+		if !vs.isMultiDim() {
+			continue
+		}
+		shape := Shape{Names: make([]Constant, len(vs.Size))}
+		for axis, fname := range fnames {
+			if len(fname) == 0 {
+				continue
+			}
+			constant, err := loadConstant(fname)
+			if err != nil {
+				return err
+			}
+			shape.Names[axis] = constant
 		}
-		cm.constants[varname] = constant
-		cm.constants[fname] = constant // csv file name is also remindered.
+		cm.shapes[varname] = shape
 	}
 
 	// remove csv file names from constants map, which are never accessed.
@@ -389,6 +578,66 @@ func (cm *CsvManager) buildConstants(vspecs variableSpecInternalMap) error {
 	return nil
 }
 
+// Shape is sizes and names of each dimension of multi-dimensional variable.
+type Shape struct {
+	Dims  []int      // sizes of each dimension.
+	Names []Constant // names of each dimension. It has no names if the dimension has no csv file.
+}
+
+// FlatIndex returns index of the flatten values for the indexes of each dimension.
+// It returns IndexNotFound if number of indexes is not match or some index is out of range.
+func (s Shape) FlatIndex(indexes ...int) int {
+	if len(indexes) != len(s.Dims) {
+		return IndexNotFound
+	}
+	flat := 0
+	for axis, i := range indexes {
+		if i < 0 || i >= s.Dims[axis] {
+			return IndexNotFound
+		}
+		flat = flat*s.Dims[axis] + i
+	}
+	return flat
+}
+
+// Shape returns shape of the multi-dimensional variable.
+// It returns false if the variable is not found or has only one dimension.
+func (cm *CsvManager) Shape(vname string) (Shape, bool) {
+	s, ok := cm.shapes[vname]
+	return s, ok
+}
+
+// Shapes returns all of shapes of the multi-dimensional variables.
+// The returned map must not be modified.
+func (cm *CsvManager) Shapes() map[string]Shape {
+	return cm.shapes
+}
+
+// VariableBound returns default value and range of the Int variable named vname.
+// It returns false if vname has no bound.
+func (cm *CsvManager) VariableBound(vname string) (VariableBound, bool) {
+	b, ok := cm.bounds[vname]
+	return b, ok
+}
+
+// VariableBounds returns all of bounds of the Int variables.
+// The returned map must not be modified.
+func (cm *CsvManager) VariableBounds() map[string]VariableBound {
+	return cm.bounds
+}
+
+// DerivedVariables returns derived variables in the scope where, where = {System, Share, Chara}.
+// It allocates new map every call.
+func (cm *CsvManager) DerivedVariables(where VarScope) map[string]DerivedVariable {
+	m := make(map[string]DerivedVariable)
+	for vname, dv := range cm.derived {
+		if dv.Scope == where {
+			m[vname] = dv
+		}
+	}
+	return m
+}
+
 // read all csv characters files matched to given pattern.
 func (csv *CsvManager) initCharacters(pattern string) error {
 	files, err := filesystem.Glob(pattern)
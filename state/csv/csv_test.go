@@ -2,6 +2,9 @@ package csv
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -110,6 +113,7 @@ func TestBuiltinVariables(t *testing.T) {
 		BuiltinItemName,
 		BuiltinItemStockName,
 		BuiltinMoneyName,
+		BuiltinCalendarTimeName,
 	}
 	for _, name := range intNames {
 		if _, ok := intMap[name]; !ok {
@@ -159,6 +163,7 @@ func TestBuildVariables(t *testing.T) {
 		BuiltinItemName,
 		BuiltinItemStockName,
 		BuiltinMoneyName,
+		BuiltinCalendarTimeName,
 		"Number",
 	}
 	if len(intMap) != len(intNames) {
@@ -173,6 +178,7 @@ func TestBuildVariables(t *testing.T) {
 	strMap := cm.BuildStrUserVars(ScopeSystem)
 	strNames := []string{
 		"Str",
+		"Memo",
 	}
 	if len(strMap) != len(strNames) {
 		t.Errorf("different str usr variable size, expect %v, got %v", len(strNames), len(strMap))
@@ -184,6 +190,174 @@ func TestBuildVariables(t *testing.T) {
 	}
 }
 
+func TestCharaListSpecs(t *testing.T) {
+	cm, err := newCsvManagerInited()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []VariableSpec{
+		{VarName: "Party", Scope: ScopeCharaList, Size: 4},
+		{VarName: "Prison", Scope: ScopeCharaList, Size: 0},
+	}
+	if got := cm.CharaListSpecs(); !reflect.DeepEqual(got, expect) {
+		t.Errorf("different CharaList specs, expect %v, got %v", expect, got)
+	}
+	for _, scope := range []VarScope{ScopeSystem, ScopeShare, ScopeChara} {
+		if _, ok := cm.BuildIntUserVars(scope)["Party"]; ok {
+			t.Errorf("CharaList should not be built as variable of scope %v", scope)
+		}
+	}
+
+	for _, vspec := range []string{
+		"CharaList, , Party, Party.csv, ",
+		"CharaList, , , , ",
+	} {
+		if _, err := readVariableSpecs(strings.NewReader(vspec)); err == nil {
+			t.Errorf("invalid CharaList spec %q should be error", vspec)
+		}
+	}
+}
+
+func TestCharaRefSpecs(t *testing.T) {
+	cm, err := newCsvManagerInited()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nChara := uint64(len(cm.CharaMap))
+	expect := []VariableSpec{
+		{VarName: BuiltinAssiName, Scope: ScopeCharaRef, Size: nChara},
+		{VarName: BuiltinMasterName, Scope: ScopeCharaRef, Size: nChara},
+		{VarName: BuiltinPlayerName, Scope: ScopeCharaRef, Size: nChara},
+		{VarName: "Rival", Scope: ScopeCharaRef, Size: 3},
+		{VarName: BuiltinTargetName, Scope: ScopeCharaRef, Size: nChara},
+	}
+	if got := cm.CharaRefSpecs(); !reflect.DeepEqual(got, expect) {
+		t.Errorf("different CharaRef specs, expect %v, got %v", expect, got)
+	}
+
+	// builtin CharaRef can be redefined to change its size, but not by other scope.
+	specs, err := readVariableSpecs(strings.NewReader("CharaRef, , Target, , 2\nCharaList, , Assi, , "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	notAppended := appendBuiltinVSpecs(specs)
+	if !reflect.DeepEqual(notAppended, []string{BuiltinAssiName}) {
+		t.Errorf("only Assi should not be appended, got: %v", notAppended)
+	}
+	if size := specs[BuiltinTargetName].Size[0]; size != 2 {
+		t.Errorf("redefined Target size is not used, got: %v", size)
+	}
+}
+
+func TestMultiDimVariables(t *testing.T) {
+	cm, err := newCsvManagerInited()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nBase := cm.MustConst("Base").Names.Len()
+	shape, ok := cm.Shape("BaseHistory")
+	if !ok {
+		t.Fatal("BaseHistory should be multi-dimensional")
+	}
+	if expect := []int{3, nBase}; !reflect.DeepEqual(shape.Dims, expect) {
+		t.Errorf("different dims, expect %v, got %v", expect, shape.Dims)
+	}
+	if i := shape.Names[1].GetIndex("気力"); i != 1 {
+		t.Errorf("names of 2nd dimension is not Base.csv, got index %v", i)
+	}
+	if _, ok := cm.Shape("Base"); ok {
+		t.Error("1-D variable should not have shape")
+	}
+	if got := len(cm.BuildIntUserVars(ScopeChara)["BaseHistory"]); got != 3*nBase {
+		t.Errorf("different allocated size, expect %v, got %v", 3*nBase, got)
+	}
+	for _, vs := range cm.IntVariableSpecs(ScopeChara) {
+		if vs.VarName == "BaseHistory" && (vs.Size != uint64(3*nBase) || !reflect.DeepEqual(vs.Dims, shape.Dims)) {
+			t.Errorf("different VariableSpec, got %v", vs)
+		}
+	}
+
+	// indexes of each dimension in csv character.
+	history := cm.CharaMap[1].GetIntMap()["BaseHistory"]
+	if got := history[shape.FlatIndex(2, 1)]; got != 5 {
+		t.Errorf("BaseHistory 2:気力 of chara 1 is not parsed, got %v", got)
+	}
+
+	for _, test := range []struct {
+		Indexes []int
+		Expect  int
+	}{
+		{[]int{0, 0}, 0},
+		{[]int{1, 2}, nBase + 2},
+		{[]int{3, 0}, IndexNotFound},
+		{[]int{0}, IndexNotFound},
+	} {
+		if got := shape.FlatIndex(test.Indexes...); got != test.Expect {
+			t.Errorf("FlatIndex%v: expect %v, got %v", test.Indexes, test.Expect, got)
+		}
+	}
+
+	for _, vspec := range []string{
+		"CSV, Int, Grid, , 2, 3",
+		"System, Int, Grid, , 2, x",
+	} {
+		if _, err := readVariableSpecs(strings.NewReader(vspec)); err == nil {
+			t.Errorf("invalid spec %q should be error", vspec)
+		}
+	}
+}
+
+func TestFloatBoolVariables(t *testing.T) {
+	cm, err := newCsvManagerInited()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nBase := cm.MustConst("Base").Names.Len()
+	for _, test := range []struct {
+		Specs  []VariableSpec
+		Name   string
+		Expect uint64
+	}{
+		{cm.FloatVariableSpecs(ScopeChara), "BaseRate", uint64(nBase)},
+		{cm.BoolVariableSpecs(ScopeChara), "Known", 2},
+		{cm.FloatVariableSpecs(ScopeSystem), "Difficulty", 1},
+		{cm.BoolVariableSpecs(ScopeShare), "Cleared", 1},
+	} {
+		if len(test.Specs) != 1 || test.Specs[0].VarName != test.Name || test.Specs[0].Size != test.Expect {
+			t.Errorf("different specs for %s, got %v", test.Name, test.Specs)
+		}
+	}
+	if got := len(cm.BuildFloatUserVars(ScopeSystem)["Difficulty"]); got != 1 {
+		t.Errorf("different allocated float size, got %v", got)
+	}
+	if got := len(cm.BuildBoolUserVars(ScopeShare)["Cleared"]); got != 1 {
+		t.Errorf("different allocated bool size, got %v", got)
+	}
+
+	// initial values in csv character.
+	chara := cm.CharaMap[1]
+	if got := chara.GetFloatMap()["BaseRate"][1]; got != 0.5 {
+		t.Errorf("BaseRate 気力 of chara 1 is not parsed, got %v", got)
+	}
+	if got := chara.GetBoolMap()["Known"]; !reflect.DeepEqual(got, []bool{false, true}) {
+		t.Errorf("Known of chara 1 is not parsed, got %v", got)
+	}
+
+	p := newParameter(cm)
+	for _, record := range [][]string{
+		{"BaseRate", "体力", "abc"},
+		{"Known", "0", "maybe"},
+	} {
+		if err := parseUserField(&p, cm, record[0], record); err == nil {
+			t.Errorf("invalid value %v should be error", record)
+		}
+	}
+}
+
 func TestDuplicateBuildinVariables(t *testing.T) {
 	VSPEC := fmt.Sprintf(`
 CSV,Int,%s, ,100
@@ -203,3 +377,256 @@ Chara,Int,%s, ,100
 		t.Error("duplicate builtin but not detected")
 	}
 }
+
+func TestAblUpRequirements(t *testing.T) {
+	cm, err := newCsvManagerInited()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []AblUpRequirement{
+		{Abl: 0, Level: 1, Juels: []AmountRequirement{{0, 10}}},
+		{Abl: 0, Level: 2,
+			Juels:   []AmountRequirement{{0, 100}, {1, 50}},
+			Exps:    []AmountRequirement{{1, 5}},
+			Talents: []TalentRequirement{{1, true}}},
+		{Abl: 1, Level: 1, Juels: []AmountRequirement{{1, 10}}, Talents: []TalentRequirement{{1, false}}},
+	}
+	if !reflect.DeepEqual(cm.AblUps, expect) {
+		t.Errorf("invalid ablup requirements, expect: %+v, got: %+v", expect, cm.AblUps)
+	}
+}
+
+func TestReadAblUpsError(t *testing.T) {
+	dir := t.TempDir()
+	constants := map[string]Constant{}
+	for _, name := range []string{BuiltinAblName, BuiltinJuelName, BuiltinExpName, BuiltinTalentName} {
+		names := Names{"a", "b"}
+		constants[name] = Constant{Names: names, NameIndex: newNameIndex(names)}
+	}
+	for _, testcase := range []struct {
+		Content  string
+		ErrorMsg string
+	}{
+		{"x, 1, a=1", "Abl does not have"},
+		{"a, 0, a=1", "level must be positive"},
+		{"a, 1, a", "must be name=amount"},
+		{"a, 1, a=x", "invalid amount"},
+		{"a, 1, , , !c", "Talent does not have"},
+	} {
+		file := filepath.Join(dir, ablUpFileName)
+		if err := os.WriteFile(file, []byte(testcase.Content+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := readAblUps(file, constants)
+		if err == nil || !strings.Contains(err.Error(), testcase.ErrorMsg) {
+			t.Errorf("%q: error should contain %q, got: %v", testcase.Content, testcase.ErrorMsg, err)
+		}
+	}
+}
+
+func TestVariableBounds(t *testing.T) {
+	cm, err := newCsvManagerInited()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for vname, expect := range map[string]VariableBound{
+		"Base":  {Min: 0, HasMin: true, Clamp: true},
+		"Stain": {Default: 10, Min: 0, Max: 100, HasMin: true, HasMax: true},
+	} {
+		if got, ok := cm.VariableBound(vname); !ok || got != expect {
+			t.Errorf("invalid bound of %s, expect: %+v, got: %+v", vname, expect, got)
+		}
+	}
+	if _, ok := cm.VariableBound("Abl"); ok {
+		t.Error("Abl should have no bound")
+	}
+
+	// default values are used unless the csv character defines.
+	for _, v := range cm.BuildIntUserVars(ScopeChara)["Stain"] {
+		if v != 10 {
+			t.Fatalf("Stain should be filled by default, got %v", v)
+		}
+	}
+	p := newParameter(cm)
+	if err := parseUserField(&p, cm, "Base", []string{"Base", "0", "-1"}); err == nil {
+		t.Error("value out of range in csv character should be error")
+	}
+
+	b := VariableBound{Min: -5, Max: 5, HasMin: true, HasMax: true}
+	for _, testcase := range []struct{ In, Limited int64 }{{-6, -5}, {0, 0}, {5, 5}, {100, 5}} {
+		if got := b.Limit(testcase.In); got != testcase.Limited {
+			t.Errorf("Limit(%v): expect %v, got %v", testcase.In, testcase.Limited, got)
+		}
+		if contains := testcase.In == testcase.Limited; b.Contains(testcase.In) != contains {
+			t.Errorf("Contains(%v): expect %v", testcase.In, contains)
+		}
+	}
+}
+
+func TestReadVariableBoundsError(t *testing.T) {
+	vspecs := variableSpecInternalMap{
+		"Base": {Scope: scopeChara, DataType: dTypeInt, VarName: "Base"},
+		"Name": {Scope: scopeChara, DataType: dTypeStr, VarName: "Name"},
+		"Item": {Scope: scopeCSV, DataType: dTypeInt, VarName: "Item"},
+	}
+	dir := t.TempDir()
+	for _, testcase := range []struct {
+		Content  string
+		ErrorMsg string
+	}{
+		{"Unknown, 0", "Unknown is not defined"},
+		{"Name, 0", "must be Int variable"},
+		{"Item, 0", "must be Int variable"},
+		{"Base, x", "invalid default"},
+		{"Base, , 10, 0", "larger than max"},
+		{"Base, 20, 0, 10", "out of range"},
+		{"Base, , , , ignore", "on_violation must be"},
+		{"Base, 0\nBase, 1", "already defined"},
+	} {
+		file := filepath.Join(dir, variableBoundsFileName)
+		if err := os.WriteFile(file, []byte(testcase.Content+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := readVariableBounds(file, vspecs)
+		if err == nil || !strings.Contains(err.Error(), testcase.ErrorMsg) {
+			t.Errorf("%q: error should contain %q, got: %v", testcase.Content, testcase.ErrorMsg, err)
+		}
+	}
+}
+
+func TestCalendarConfig(t *testing.T) {
+	cm, err := newCsvManagerInited()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc := cm.Calendar
+	if len(cc.TimeSlots) != 4 || len(cc.Weekdays) != 7 || len(cc.Seasons) != 4 || cc.SeasonLength != 2 {
+		t.Errorf("invalid calendar definition: %+v", cc)
+	}
+	expect := CalendarEvent{Name: "夏祭り", Day: -1, Every: -1, DayOfSeason: 1, Weekday: -1, Season: 1, Slot: 3}
+	if len(cc.Events) != 3 || cc.Events[2] != expect {
+		t.Errorf("invalid calendar events, expect last: %+v, got: %+v", expect, cc.Events)
+	}
+}
+
+func TestReadCalendarConfigError(t *testing.T) {
+	dir := t.TempDir()
+	for _, testcase := range []struct {
+		Content  string
+		ErrorMsg string
+	}{
+		{"DayLength, 0", "DayLength must be positive"},
+		{"Seasons, a/b", "SeasonLength is required"},
+		{"Unknown, 1", "unknown record"},
+		{"Event, a, weekday=月", "weekday \"月\" is not defined"},
+		{"Event, a, every=0", "invalid every"},
+		{"Event, a, hour=1", "unknown condition"},
+	} {
+		file := filepath.Join(dir, calendarFileName)
+		if err := os.WriteFile(file, []byte(testcase.Content+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := readCalendarConfig(file)
+		if err == nil || !strings.Contains(err.Error(), testcase.ErrorMsg) {
+			t.Errorf("%q: error should contain %q, got: %v", testcase.Content, testcase.ErrorMsg, err)
+		}
+	}
+}
+
+func TestDerivedVariables(t *testing.T) {
+	cm, err := newCsvManagerInited()
+	if err != nil {
+		t.Fatal(err)
+	}
+	charaDerived := cm.DerivedVariables(ScopeChara)
+	if len(charaDerived) != 2 {
+		t.Fatalf("different number of chara derived variables, got %v", charaDerived)
+	}
+	bonus := charaDerived["ParamLvBonus"]
+	if expect := []string{"Base", "ParamLv"}; !reflect.DeepEqual(bonus.Deps, expect) {
+		t.Errorf("different deps, expect %v, got %v", expect, bonus.Deps)
+	}
+	if nParam := cm.MustConst("Param").Names.Len(); bonus.Size != nParam || bonus.Source != "Param" {
+		t.Errorf("size and source should be taken from Param, got %+v", bonus)
+	}
+	if _, ok := cm.DerivedVariables(ScopeSystem)["NumberDouble"]; !ok {
+		t.Error("NumberDouble should be system derived variable")
+	}
+
+	values := map[string][]int64{"Number": {21}, "ParamLv": {3, 4}, "Base": {0, 550}}
+	value := func(varname string, index int) int64 { return values[varname][index] }
+	if got := cm.DerivedVariables(ScopeSystem)["NumberDouble"].Eval(0, value); got != 42 {
+		t.Errorf("different NumberDouble, got %v", got)
+	}
+	if got := bonus.Eval(1, value); got != 45 {
+		t.Errorf("different ParamLvBonus, got %v", got)
+	}
+	if got := charaDerived["ParamLv"].Eval(0, func(string, int) int64 { return 600 }); got != cm.ParamLv(600) {
+		t.Errorf("different ParamLv, got %v", got)
+	}
+}
+
+func TestDerivedExpression(t *testing.T) {
+	cm, err := newCsvManagerInited()
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := DerivedVariable{VarName: "X", Source: "Number", Scope: ScopeSystem, Size: 1}
+	for _, testcase := range []struct {
+		Expr   string
+		Expect int64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"-Number + 10", 7},
+		{"Number[数値１] % 2", 1},
+		{"7 / 0", 0},
+		{"1 < 2 == 1", 1},
+		{"Number >= 4", 0},
+		{"min(5, Number, 8) + max(1, 2) + abs(-4)", 9},
+	} {
+		p := &derivedParser{cm: cm, target: target, deps: map[string]bool{}}
+		expr, err := p.parse(testcase.Expr)
+		if err != nil {
+			t.Errorf("%q: %v", testcase.Expr, err)
+			continue
+		}
+		if got := expr.eval(0, func(string, int) int64 { return 3 }); got != testcase.Expect {
+			t.Errorf("%q: expect %v, got %v", testcase.Expr, testcase.Expect, got)
+		}
+	}
+}
+
+func TestReadDerivedVariablesError(t *testing.T) {
+	cm, err := newCsvManagerInited()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	for _, testcase := range []struct {
+		Content  string
+		ErrorMsg string
+	}{
+		{"Base, Param, 1", "already defined in VariableSpec.csv"},
+		{"X, Param, 1\nX, Param, 2", "already defined"},
+		{"X, BaseHistory, 1", "must be 1-D variable"},
+		{"X, Param, Str", "must be 1-D Int or derived variable"},
+		{"X, Number, Base", "must be 1-D Int or derived variable"},
+		{"X, Param, Base", "specify its index"},
+		{"X, Param, Base[unknown]", "does not have"},
+		{"X, Param, foo(1)", "unknown function"},
+		{"X, Param, abs(1, 2)", "invalid number of arguments"},
+		{"X, Param, (1 + 2", `")" is expected`},
+		{"X, Param, 1 2", "unexpected"},
+		{"X, Param, Y\nY, Param, X", "circularly"},
+	} {
+		file := filepath.Join(dir, derivedVariablesFileName)
+		if err := os.WriteFile(file, []byte(testcase.Content+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := readDerivedVariables(file, cm)
+		if err == nil || !strings.Contains(err.Error(), testcase.ErrorMsg) {
+			t.Errorf("%q: error should contain %q, got: %v", testcase.Content, testcase.ErrorMsg, err)
+		}
+	}
+}
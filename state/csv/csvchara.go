@@ -2,7 +2,9 @@ package csv
 
 import (
 	"fmt"
+	"math"
 	"strconv"
+	"strings"
 )
 
 // Character defined by csv
@@ -27,10 +29,12 @@ type CharaInfo struct {
 	MasterName string // call for you
 }
 
-// Parameter is variables which are numbers or strings.
+// Parameter is variables which are numbers, strings or booleans.
 type Parameter struct {
-	strMap map[string][]string
-	intMap map[string][]int64
+	strMap   map[string][]string
+	intMap   map[string][]int64
+	floatMap map[string][]float64
+	boolMap  map[string][]bool
 }
 
 // default Parameter instance
@@ -38,6 +42,8 @@ func newParameter(cm *CsvManager) Parameter {
 	p := Parameter{}
 	p.intMap = cm.BuildIntUserVars(ScopeChara)
 	p.strMap = cm.BuildStrUserVars(ScopeChara)
+	p.floatMap = cm.BuildFloatUserVars(ScopeChara)
+	p.boolMap = cm.BuildBoolUserVars(ScopeChara)
 	return p
 }
 
@@ -51,6 +57,16 @@ func (p Parameter) GetIntMap() map[string][]int64 {
 	return p.intMap
 }
 
+// return float64 variable map which can affect original values.
+func (p Parameter) GetFloatMap() map[string][]float64 {
+	return p.floatMap
+}
+
+// return bool variable map which can affect original values.
+func (p Parameter) GetBoolMap() map[string][]bool {
+	return p.boolMap
+}
+
 // it returns new data conserving contents pf given data.
 func CloneInt64(data []int64) []int64 {
 	new_data := make([]int64, len(data))
@@ -176,14 +192,31 @@ func parseStr2Field(character *CharaInfo, key string, record []string) error {
 
 func parseUserField(p *Parameter, cm *CsvManager, key string, record []string) error {
 	// parse string index for the variables specified by key
-	index := cm.NameIndexOf(key, record[1])
-	if index < 0 {
-		// string index is not defined, try parsing as int
-		i, err := strconv.ParseInt(record[1], 0, 64)
+	var index int
+	if shape, ok := cm.Shape(key); ok {
+		// multi-dimensional variable has indexes for each dimension, as like x:y.
+		// omitted indexes are 0.
+		fields := strings.Split(record[1], dimensionSeparator)
+		if len(fields) > len(shape.Dims) {
+			return fmt.Errorf("Second field(%v) has too many indexes for %v.", record[1], key)
+		}
+		indexes := make([]int, len(shape.Dims))
+		for axis, field := range fields {
+			i, err := parseIndexField(shape.Names[axis], field)
+			if err != nil {
+				return fmt.Errorf("Second field(%v) is not defined in %v.", record[1], key)
+			}
+			indexes[axis] = i
+		}
+		if index = shape.FlatIndex(indexes...); index < 0 {
+			return fmt.Errorf("Second field %v is invalid index (Dims %v).", record[1], shape.Dims)
+		}
+	} else {
+		i, err := parseIndexField(cm.constants[key], record[1])
 		if err != nil {
 			return fmt.Errorf("Second field(%v) is not defined in %v.", record[1], key)
 		}
-		index = int(i)
+		index = i
 	}
 
 	// set value for the variables specified by key.
@@ -202,8 +235,37 @@ func parseUserField(p *Parameter, cm *CsvManager, key string, record []string) e
 		if err != nil {
 			num = 1 // NOTE default data if no defined
 		}
+		if b, ok := cm.VariableBound(key); ok && !b.Contains(num) {
+			return fmt.Errorf("Third field %v is out of range for %v.", num, key)
+		}
 		vars[index] = num
 
+	} else if vars, has := p.floatMap[key]; has {
+		if index < 0 || index >= len(vars) {
+			return fmt.Errorf("Second field %v is invalid index (Max %v).", record[1], len(vars))
+		}
+		num := 1.0 // NOTE default data if no defined
+		if len(record[2]) > 0 {
+			var err error
+			if num, err = strconv.ParseFloat(record[2], 64); err != nil || math.IsNaN(num) || math.IsInf(num, 0) {
+				return fmt.Errorf("Third field %v must be a finite number for %v.", record[2], key)
+			}
+		}
+		vars[index] = num
+
+	} else if vars, has := p.boolMap[key]; has {
+		if index < 0 || index >= len(vars) {
+			return fmt.Errorf("Second field %v is invalid index (Max %v).", record[1], len(vars))
+		}
+		b := true // NOTE default data if no defined
+		if len(record[2]) > 0 {
+			var err error
+			if b, err = strconv.ParseBool(record[2]); err != nil {
+				return fmt.Errorf("Third field %v must be a boolean for %v.", record[2], key)
+			}
+		}
+		vars[index] = b
+
 	} else {
 		return fmt.Errorf("First field %v is not defined.", record[0])
 	}
@@ -219,3 +281,13 @@ func InRangeStr(data []string, index int) bool {
 func InRangeInt64(data []int64, index int) bool {
 	return 0 <= index && index < len(data)
 }
+
+// parseIndexField parses field as csv name of c or number.
+func parseIndexField(c Constant, field string) (int, error) {
+	if index := c.GetIndex(field); index >= 0 {
+		return index, nil
+	}
+	// string index is not defined, try parsing as int
+	i, err := strconv.ParseInt(field, 0, 64)
+	return int(i), err
+}
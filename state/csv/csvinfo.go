@@ -36,6 +36,25 @@ func newNumberConstants(file string) (*NumberConstants, error) {
 	return rp, err
 }
 
+// ParamLv returns level of the param value according to ParamLvs.
+func (nc NumberConstants) ParamLv(param int64) int64 {
+	return currentLv(param, nc.ParamLvs)
+}
+
+// ExpLv returns level of the exp value according to ExpLvs.
+func (nc NumberConstants) ExpLv(exp int64) int64 {
+	return currentLv(exp, nc.ExpLvs)
+}
+
+func currentLv(value int64, lvs []int64) int64 {
+	for lv, step := range lvs {
+		if value < step {
+			return int64(lv)
+		}
+	}
+	return 0
+}
+
 func levelsFrom(s string) ([]int64, error) {
 	fragments := strings.Split(s, "/")
 	lvs := make([]int64, len(fragments)+1)
@@ -0,0 +1,426 @@
+package csv
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// derivedVariablesFileName is an optional file which defines derived variables,
+// read-only Int variables computed from other Int variables.
+//
+// Each record is:
+//
+//	variable, source, expression
+//
+// where variable is a name of the derived variable, and source is a name of
+// the 1-D variable with scope System, Share or Chara whose scope, size and
+// names are taken over by the derived variable.
+//
+// expression computes each element of the derived variable. A variable name
+// in it refers the element at the same index, and name[key] refers the element
+// at key, name or number, of the variable. Referred variables must be Int or
+// derived variables in the same scope. The expression supports integer literals,
+// +, -, *, /, %, comparisons which result 1 or 0, parentheses and the functions:
+// paramlv(x), explv(x), min(x, ...), max(x, ...) and abs(x).
+// Division by zero results 0.
+//
+// Example:
+//
+//	ParamLv, Param, paramlv(Param)
+//	Fatigue, Base, max(100 - Base * 100 / Base[体力], 0)
+const derivedVariablesFileName = "_DerivedVariables.csv"
+
+// DerivedVariable is a read-only Int variable whose values are computed
+// by the expression from other variables.
+type DerivedVariable struct {
+	VarName string
+	Source  string // variable which the scope, size and names are taken from.
+	Scope   VarScope
+	Size    int
+	Expr    string   // expression text.
+	Deps    []string // variables referred by the expression, sorted.
+
+	expr derivedExpr
+}
+
+// Eval computes the value at index i. value returns the value of the variable
+// varname at index, which is one of the Deps.
+func (dv DerivedVariable) Eval(i int, value func(varname string, index int) int64) int64 {
+	return dv.expr.eval(i, value)
+}
+
+// readDerivedVariables reads derived variables file and resolves
+// referred variables by cm.
+func readDerivedVariables(file string, cm *CsvManager) (map[string]DerivedVariable, error) {
+	type record struct {
+		varname, source, expr string
+	}
+	var records []record
+	if err := ReadFileFunc(file, func(fields []string) error {
+		if len(fields) < 3 {
+			return fmt.Errorf("derived variable must be defined by variable, source and expression")
+		}
+		// expression may contain separators.
+		records = append(records, record{fields[0], fields[1], strings.Join(fields[2:], Separator+" ")})
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	derived := make(map[string]DerivedVariable, len(records))
+	for _, r := range records {
+		if len(r.varname) == 0 {
+			return nil, fmt.Errorf("%s: variable name is empty", file)
+		}
+		if _, ok := cm.vspecs[r.varname]; ok {
+			return nil, fmt.Errorf("%s: %s is already defined in %s", file, r.varname, variableSpecFile)
+		}
+		if _, ok := derived[r.varname]; ok {
+			return nil, fmt.Errorf("%s: %s is already defined", file, r.varname)
+		}
+		vs, ok := cm.vspecs[r.source]
+		if !ok || vs.isMultiDim() || (vs.Scope != scopeSystem && vs.Scope != scopeShare && vs.Scope != scopeChara) {
+			return nil, fmt.Errorf("%s: %s: source %s must be 1-D variable with scope System, Share or Chara", file, r.varname, r.source)
+		}
+		derived[r.varname] = DerivedVariable{
+			VarName: r.varname,
+			Source:  r.source,
+			Scope:   VarScope(vs.Scope),
+			Size:    vs.totalSize(),
+			Expr:    r.expr,
+		}
+	}
+
+	for _, r := range records {
+		dv := derived[r.varname]
+		p := &derivedParser{cm: cm, derived: derived, target: dv, deps: make(map[string]bool)}
+		expr, err := p.parse(r.expr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %v", file, r.varname, err)
+		}
+		dv.expr = expr
+		for dep := range p.deps {
+			dv.Deps = append(dv.Deps, dep)
+		}
+		slices.Sort(dv.Deps)
+		derived[r.varname] = dv
+	}
+
+	// detect circular reference among derived variables.
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(derived))
+	var visit func(vname string) error
+	visit = func(vname string) error {
+		switch state[vname] {
+		case visiting:
+			return fmt.Errorf("%s: %s refers itself circularly", file, vname)
+		case visited:
+			return nil
+		}
+		state[vname] = visiting
+		for _, dep := range derived[vname].Deps {
+			if _, ok := derived[dep]; ok {
+				if err := visit(dep); err != nil {
+					return err
+				}
+			}
+		}
+		state[vname] = visited
+		return nil
+	}
+	for _, r := range records {
+		if err := visit(r.varname); err != nil {
+			return nil, err
+		}
+	}
+	return derived, nil
+}
+
+// derivedExpr is a node of the parsed expression.
+type derivedExpr interface {
+	eval(i int, value func(string, int) int64) int64
+}
+
+type numberExpr int64
+
+func (e numberExpr) eval(int, func(string, int) int64) int64 { return int64(e) }
+
+// varExpr refers the variable at the fixed index, or at the evaluating index if index < 0.
+type varExpr struct {
+	varname string
+	index   int
+}
+
+func (e varExpr) eval(i int, value func(string, int) int64) int64 {
+	if e.index >= 0 {
+		i = e.index
+	}
+	return value(e.varname, i)
+}
+
+type negExpr struct{ x derivedExpr }
+
+func (e negExpr) eval(i int, value func(string, int) int64) int64 { return -e.x.eval(i, value) }
+
+type binaryExpr struct {
+	op   string
+	l, r derivedExpr
+}
+
+func (e binaryExpr) eval(i int, value func(string, int) int64) int64 {
+	l, r := e.l.eval(i, value), e.r.eval(i, value)
+	b2i := func(b bool) int64 {
+		if b {
+			return 1
+		}
+		return 0
+	}
+	switch e.op {
+	case "+":
+		return l + r
+	case "-":
+		return l - r
+	case "*":
+		return l * r
+	case "/":
+		if r == 0 {
+			return 0
+		}
+		return l / r
+	case "%":
+		if r == 0 {
+			return 0
+		}
+		return l % r
+	case "<":
+		return b2i(l < r)
+	case "<=":
+		return b2i(l <= r)
+	case ">":
+		return b2i(l > r)
+	case ">=":
+		return b2i(l >= r)
+	case "==":
+		return b2i(l == r)
+	case "!=":
+		return b2i(l != r)
+	}
+	panic("unknown operator " + e.op)
+}
+
+type callExpr struct {
+	fn   func([]int64) int64
+	args []derivedExpr
+}
+
+func (e callExpr) eval(i int, value func(string, int) int64) int64 {
+	args := make([]int64, len(e.args))
+	for n, arg := range e.args {
+		args[n] = arg.eval(i, value)
+	}
+	return e.fn(args)
+}
+
+// derivedParser parses the expression of the target derived variable by recursive descent.
+type derivedParser struct {
+	cm      *CsvManager
+	derived map[string]DerivedVariable
+	target  DerivedVariable
+	deps    map[string]bool
+
+	src string
+	pos int
+}
+
+func (p *derivedParser) parse(src string) (derivedExpr, error) {
+	p.src, p.pos = src, 0
+	expr, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos < len(p.src) {
+		return nil, fmt.Errorf("unexpected %q at %d", p.src[p.pos:], p.pos)
+	}
+	return expr, nil
+}
+
+func (p *derivedParser) skipSpace() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// consume advances position if the source at the position starts with one of ops,
+// and returns the consumed op.
+func (p *derivedParser) consume(ops ...string) (string, bool) {
+	p.skipSpace()
+	for _, op := range ops {
+		if strings.HasPrefix(p.src[p.pos:], op) {
+			p.pos += len(op)
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *derivedParser) expect(op string) error {
+	if _, ok := p.consume(op); !ok {
+		return fmt.Errorf("%q is expected at %d", op, p.pos)
+	}
+	return nil
+}
+
+func (p *derivedParser) parseBinary(next func() (derivedExpr, error), ops ...string) (derivedExpr, error) {
+	l, err := next()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.consume(ops...)
+		if !ok {
+			return l, nil
+		}
+		r, err := next()
+		if err != nil {
+			return nil, err
+		}
+		l = binaryExpr{op: op, l: l, r: r}
+	}
+}
+
+func (p *derivedParser) parseComparison() (derivedExpr, error) {
+	// longer operators first to not be consumed as shorter ones.
+	return p.parseBinary(p.parseAdditive, "<=", ">=", "==", "!=", "<", ">")
+}
+
+func (p *derivedParser) parseAdditive() (derivedExpr, error) {
+	return p.parseBinary(p.parseMultiplicative, "+", "-")
+}
+
+func (p *derivedParser) parseMultiplicative() (derivedExpr, error) {
+	return p.parseBinary(p.parseUnary, "*", "/", "%")
+}
+
+func (p *derivedParser) parseUnary() (derivedExpr, error) {
+	if _, ok := p.consume("-"); ok {
+		x, err := p.parseUnary()
+		return negExpr{x}, err
+	}
+	return p.parsePrimary()
+}
+
+func (p *derivedParser) parsePrimary() (derivedExpr, error) {
+	if _, ok := p.consume("("); ok {
+		x, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		return x, p.expect(")")
+	}
+
+	start := p.pos
+	for p.pos < len(p.src) {
+		r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		if !(r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			break
+		}
+		p.pos += size
+	}
+	word := p.src[start:p.pos]
+	switch {
+	case len(word) == 0:
+		return nil, fmt.Errorf("operand is expected at %d", p.pos)
+	case unicode.IsDigit(rune(word[0])):
+		n, err := strconv.ParseInt(word, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", word)
+		}
+		return numberExpr(n), nil
+	}
+
+	if _, ok := p.consume("("); ok {
+		return p.parseCall(word)
+	}
+	return p.parseVariable(word)
+}
+
+func (p *derivedParser) parseCall(name string) (derivedExpr, error) {
+	var args []derivedExpr
+	if _, ok := p.consume(")"); !ok {
+		for {
+			arg, err := p.parseComparison()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if _, ok := p.consume(","); !ok {
+				break
+			}
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+	}
+
+	var fn func([]int64) int64
+	nargs := 1
+	switch name {
+	case "paramlv":
+		fn = func(args []int64) int64 { return p.cm.ParamLv(args[0]) }
+	case "explv":
+		fn = func(args []int64) int64 { return p.cm.ExpLv(args[0]) }
+	case "abs":
+		fn = func(args []int64) int64 { return max(args[0], -args[0]) }
+	case "min":
+		fn, nargs = slices.Min[[]int64], -1
+	case "max":
+		fn, nargs = slices.Max[[]int64], -1
+	default:
+		return nil, fmt.Errorf("unknown function %s", name)
+	}
+	if (nargs < 0 && len(args) == 0) || (nargs >= 0 && len(args) != nargs) {
+		return nil, fmt.Errorf("invalid number of arguments for %s", name)
+	}
+	return callExpr{fn: fn, args: args}, nil
+}
+
+func (p *derivedParser) parseVariable(varname string) (derivedExpr, error) {
+	var (
+		size  int
+		names Constant
+	)
+	if dv, ok := p.derived[varname]; ok && dv.Scope == p.target.Scope {
+		size, names = dv.Size, p.cm.constants[dv.Source]
+	} else if vs, ok := p.cm.vspecs[varname]; ok && vspecIdent(p.target.Scope) == vs.Scope && vs.DataType == dTypeInt && !vs.isMultiDim() {
+		size, names = vs.totalSize(), p.cm.constants[varname]
+	} else {
+		return nil, fmt.Errorf("%s must be 1-D Int or derived variable in the same scope", varname)
+	}
+	p.deps[varname] = true
+
+	if _, ok := p.consume("["); !ok {
+		if size != p.target.Size {
+			return nil, fmt.Errorf("size of %s (%d) is different from %s (%d), specify its index", varname, size, p.target.Source, p.target.Size)
+		}
+		return varExpr{varname: varname, index: -1}, nil
+	}
+	end := strings.Index(p.src[p.pos:], "]")
+	if end < 0 {
+		return nil, fmt.Errorf("%q is expected for %s", "]", varname)
+	}
+	key := strings.TrimSpace(p.src[p.pos : p.pos+end])
+	p.pos += end + 1
+	index, err := parseIndexField(names, key)
+	if err != nil || index < 0 || index >= size {
+		return nil, fmt.Errorf("%s does not have %q", varname, key)
+	}
+	return varExpr{varname: varname, index: index}, nil
+}
@@ -17,10 +17,33 @@ type variableSpecInternal struct {
 	Scope    vspecIdent // where the variable is used.
 	DataType vspecIdent // type of the variable.
 	VarName  string     // name for the variable.
-	FileName string     // file to read each name of the variables.
-	Size     []int      // DEPLECATED: auto-detected by csv file. the variable sizes
+	FileName string     // file to read each name of the variables. files for each dimension are separated by ':'.
+	Size     []int      // sizes of each dimension. 0 means auto-detected by csv file of the dimension.
 }
 
+// fileNames returns csv file names for each dimension. empty name means no csv file.
+func (vs variableSpecInternal) fileNames() []string {
+	return strings.Split(vs.FileName, dimensionSeparator)
+}
+
+// isMultiDim returns whether the variable has more than one dimension.
+func (vs variableSpecInternal) isMultiDim() bool {
+	return len(vs.Size) > 1
+}
+
+// totalSize returns number of values of the variable.
+func (vs variableSpecInternal) totalSize() int {
+	total := 1
+	for _, size := range vs.Size {
+		total *= size
+	}
+	return total
+}
+
+// dimensionSeparator separates csv file names and indexes for each dimension,
+// as like CFlag:x:y.
+const dimensionSeparator = ":"
+
 // identifer for variableSpec
 type vspecIdent uint8
 
@@ -29,23 +52,31 @@ const (
 	scopeShare
 	scopeChara
 	scopeCSV
+	scopeCharaList
+	scopeCharaRef
 )
 
 const (
-	dTypeInt vspecIdent = scopeCSV + 1 + iota
+	dTypeInt vspecIdent = scopeCharaRef + 1 + iota
 	dTypeStr
+	dTypeFloat
+	dTypeBool
 )
 
 var parseScopeMap = map[string]vspecIdent{
-	"System": scopeSystem,
-	"Share":  scopeShare,
-	"Chara":  scopeChara,
-	"CSV":    scopeCSV,
+	"System":    scopeSystem,
+	"Share":     scopeShare,
+	"Chara":     scopeChara,
+	"CSV":       scopeCSV,
+	"CharaList": scopeCharaList,
+	"CharaRef":  scopeCharaRef,
 }
 
 var parseDTypeMap = map[string]vspecIdent{
-	"Int": dTypeInt,
-	"Str": dTypeStr,
+	"Int":   dTypeInt,
+	"Str":   dTypeStr,
+	"Float": dTypeFloat,
+	"Bool":  dTypeBool,
 }
 
 var builtinVSpecs = variableSpecInternalMap{
@@ -67,7 +98,14 @@ var builtinVSpecs = variableSpecInternalMap{
 	BuiltinItemStockName: {scopeSystem, dTypeInt, BuiltinItemStockName, BuiltinItemName + ".csv", []int{0}},
 
 	// System scope, with no csv
-	BuiltinMoneyName: {scopeSystem, dTypeInt, BuiltinMoneyName, "", []int{1}},
+	BuiltinMoneyName:        {scopeSystem, dTypeInt, BuiltinMoneyName, "", []int{1}},
+	BuiltinCalendarTimeName: {scopeSystem, dTypeInt, BuiltinCalendarTimeName, "", []int{1}},
+
+	// CharaRef scope, dType is not used. size 0 means a number of csv characters.
+	BuiltinTargetName: {scopeCharaRef, 0, BuiltinTargetName, "", []int{0}},
+	BuiltinMasterName: {scopeCharaRef, 0, BuiltinMasterName, "", []int{0}},
+	BuiltinPlayerName: {scopeCharaRef, 0, BuiltinPlayerName, "", []int{0}},
+	BuiltinAssiName:   {scopeCharaRef, 0, BuiltinAssiName, "", []int{0}},
 }
 
 // read new specs of user variables from file, "VariableSpec.csv".
@@ -105,10 +143,14 @@ func readVariableSpecs(r io.Reader) (variableSpecInternalMap, error) {
 // Some buitin vspec are not appended if the variable name for these
 // already exist in the given vspec map.
 // It returns appended number of builtin variable spec and the maximum.
+// The builtin CharaRef can be overwritten by the user's CharaRef to change its size,
+// and it is not counted as not appended.
 func appendBuiltinVSpecs(vspecs variableSpecInternalMap) []string {
 	notApeendedKeys := make([]string, 0, 4)
 	for vname, v := range builtinVSpecs {
-		if _, has := vspecs[vname]; has {
+		if user, has := vspecs[vname]; has && v.Scope == scopeCharaRef && user.Scope == scopeCharaRef {
+			continue
+		} else if has {
 			notApeendedKeys = append(notApeendedKeys, vname)
 		} else {
 			vspecs[vname] = v
@@ -128,25 +170,44 @@ func parseVariableSpec(record []string) (variableSpecInternal, error) {
 	merr := errutil.NewMultiError()
 	scope, err := parseIdent(record[0], parseScopeMap)
 	merr.Add(err)
-	dtype, err := parseIdent(record[1], parseDTypeMap)
-	merr.Add(err)
+	var dtype vspecIdent
+	if scope == scopeCharaList || scope == scopeCharaRef {
+		// CharaList and CharaRef scope have no data, so DataType is not used and may be empty.
+		if len(record[3]) > 0 {
+			merr.Add(fmt.Errorf("%s(%s) must not have FileName", record[0], record[2]))
+		}
+	} else {
+		dtype, err = parseIdent(record[1], parseDTypeMap)
+		merr.Add(err)
+	}
 
 	if len(record[2]) == 0 {
 		if len(record[3]) == 0 {
 			return vspec, errors.New("VarName or FileName must not be empty.")
 		}
-		record[2] = basenameWithoutExt(record[3])
+		record[2] = basenameWithoutExt(strings.Split(record[3], dimensionSeparator)[0])
 	}
 
-	// TODO: dimention := len(record[4:])
-	// cover 2D or 3D array
-	var var_size int
-	if record[4] == "" {
-		var_size = 0
-	} else {
-		var_size, err = strconv.Atoi(record[4])
+	// sizes for each dimension, Size, Size2, ... and trailing empty sizes are ignored.
+	// Number of dimensions is also increased by number of csv files.
+	sizeFields := record[4:]
+	for len(sizeFields) > 1 && sizeFields[len(sizeFields)-1] == "" {
+		sizeFields = sizeFields[:len(sizeFields)-1]
+	}
+	if n_files := len(strings.Split(record[3], dimensionSeparator)); n_files > len(sizeFields) {
+		sizeFields = append(sizeFields, make([]string, n_files-len(sizeFields))...)
+	}
+	var_sizes := make([]int, len(sizeFields))
+	for i, field := range sizeFields {
+		if field == "" {
+			continue
+		}
+		var_sizes[i], err = strconv.Atoi(field)
 		merr.Add(err)
 	}
+	if len(var_sizes) > 1 && scope != scopeSystem && scope != scopeShare && scope != scopeChara {
+		merr.Add(fmt.Errorf("%s(%s) can not have multiple dimensions", record[0], record[2]))
+	}
 
 	if err := merr.Err(); err != nil {
 		return vspec, err
@@ -157,7 +218,7 @@ func parseVariableSpec(record []string) (variableSpecInternal, error) {
 		DataType: dtype,
 		VarName:  record[2],
 		FileName: record[3],
-		Size:     []int{var_size},
+		Size:     var_sizes,
 	}, nil
 }
 
@@ -0,0 +1,140 @@
+package state
+
+import "github.com/mzki/erago/state/csv"
+
+// derivedData holds cached values of the derived variables and version counters
+// of the Int variables they depend on. A counter is bumped by IntParam.Set() and
+// Fill(), and a cache is valid while the counters of its dependencies are same as
+// those at the computation. Modifying IntParam.Values directly is not tracked,
+// and such place must call invalidate() instead.
+//
+// It is shared by copies of UserVariables, and replaced by refine() so that
+// the loaded or rewound values are not hidden by old caches.
+type derivedData struct {
+	versions map[string]*uint64
+	caches   map[string]*derivedCache
+}
+
+// derivedCache holds values of a derived variable with versions of its dependencies.
+type derivedCache struct {
+	values []int64 // nil if not computed yet.
+	deps   []*uint64
+	seen   []uint64 // versions of deps when values are computed.
+}
+
+func newDerivedData(derived map[string]csv.DerivedVariable) *derivedData {
+	d := &derivedData{
+		versions: make(map[string]*uint64),
+		caches:   make(map[string]*derivedCache, len(derived)),
+	}
+	for vname := range derived {
+		cache := &derivedCache{}
+		for dep := range baseDeps(derived, vname, map[string]bool{}) {
+			if _, ok := d.versions[dep]; !ok {
+				d.versions[dep] = new(uint64)
+			}
+			cache.deps = append(cache.deps, d.versions[dep])
+		}
+		cache.seen = make([]uint64, len(cache.deps))
+		d.caches[vname] = cache
+	}
+	return d
+}
+
+// baseDeps collects the non-derived variables which vname depends on,
+// including dependencies of the derived dependencies, into found.
+// circular reference is rejected by csv.
+func baseDeps(derived map[string]csv.DerivedVariable, vname string, found map[string]bool) map[string]bool {
+	for _, dep := range derived[vname].Deps {
+		if _, ok := derived[dep]; ok {
+			baseDeps(derived, dep, found)
+		} else {
+			found[dep] = true
+		}
+	}
+	return found
+}
+
+// version returns the version counter of varname, or nil if no derived variable depends on it.
+func (d *derivedData) version(varname string) *uint64 {
+	if d == nil {
+		return nil
+	}
+	return d.versions[varname]
+}
+
+// invalidate discards all of the cached values.
+func (d *derivedData) invalidate() {
+	if d == nil {
+		return
+	}
+	for _, cache := range d.caches {
+		cache.values = nil
+	}
+}
+
+func (cache *derivedCache) valid() bool {
+	if cache.values == nil {
+		return false
+	}
+	for i, v := range cache.deps {
+		if *v != cache.seen[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// newDerivedParam returns read-only IntParam of the derived variable.
+// It must be called with existing varname in derivedMap.
+func (usr_vars UserVariables) newDerivedParam(varname string) IntParam {
+	dv := usr_vars.derivedMap[varname]
+	indexer, _ := usr_vars.nameIndexer(dv.Source)
+	p := NewIntParam(usr_vars.derivedValues(varname), indexer)
+	p.bound = &paramBound{varName: varname, readOnly: true}
+	return p
+}
+
+// derivedValues returns current values of the derived variable.
+// The values are cached until some of its dependencies are changed.
+// The returned values are shared with the cache and must not be modified.
+func (usr_vars UserVariables) derivedValues(varname string) []int64 {
+	cache := usr_vars.derivedData.caches[varname]
+	if cache.valid() {
+		return cache.values
+	}
+
+	// versions are taken before the evaluation, which never changes them.
+	for i, v := range cache.deps {
+		cache.seen[i] = *v
+	}
+	dv := usr_vars.derivedMap[varname]
+	values := make([]int64, dv.Size)
+	for i := range values {
+		values[i] = dv.Eval(i, usr_vars.derivedDepValue)
+	}
+	cache.values = values
+	return values
+}
+
+// derivedValue returns the value at index of the derived variable.
+// It returns 0 if index is out of range.
+func (usr_vars UserVariables) derivedValue(varname string, index int) int64 {
+	if values := usr_vars.derivedValues(varname); index >= 0 && index < len(values) {
+		return values[index]
+	}
+	return 0
+}
+
+// derivedDepValue returns the value at index of the dependency of the derived variable.
+// It returns 0 if index is out of range.
+func (usr_vars UserVariables) derivedDepValue(dep string, index int) int64 {
+	if vars, ok := usr_vars.IntMap[dep]; ok {
+		if index < 0 || index >= len(vars.Values) {
+			return 0
+		}
+		return vars.Values[index]
+	}
+	// circular reference is rejected by csv.
+	return usr_vars.derivedValue(dep, index)
+}
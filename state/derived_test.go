@@ -0,0 +1,84 @@
+package state
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDerivedVariables(t *testing.T) {
+	gamestate := NewGameState(CSVDB, Repo)
+	c, err := gamestate.SystemData.Chara.AddID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// declared in _DerivedVariables.csv as "ParamLv, Param, paramlv(Param)"
+	param, _ := c.GetInt("Param")
+	param.Set(1, 600)
+	lv, ok := c.GetInt("ParamLv")
+	if !ok {
+		t.Fatal("derived variable ParamLv is not found")
+	}
+	if lv.Len() != param.Len() || lv.GetName(1) != param.GetName(1) {
+		t.Errorf("derived variable should take over size and names of Param")
+	}
+	if expect := CSVDB.ParamLv(600); lv.Get(1) != expect {
+		t.Errorf("different derived value, expect %v, got %v", expect, lv.Get(1))
+	}
+
+	// cached while dependencies are not changed.
+	if again, _ := c.GetInt("ParamLv"); &again.Values[0] != &lv.Values[0] {
+		t.Error("derived values should be cached")
+	}
+	param.Set(1, 0)
+	updated, _ := c.GetInt("ParamLv")
+	if &updated.Values[0] == &lv.Values[0] || updated.Get(1) != CSVDB.ParamLv(0) {
+		t.Errorf("derived value should be updated by dependency change, got %v", updated.Get(1))
+	}
+	param.Slice(1, 2).Fill(600)
+	if filled, _ := c.GetInt("ParamLv"); filled.Get(1) != CSVDB.ParamLv(600) {
+		t.Errorf("derived value should be updated by filling dependency, got %v", filled.Get(1))
+	}
+
+	// derived from other derived variable.
+	base, _ := c.GetInt("Base")
+	base.SetByStr("気力", 1000)
+	param.Set(2, 600)
+	bonus, _ := c.GetInt("ParamLvBonus")
+	if expect := CSVDB.ParamLv(600)*10 + 10; bonus.Get(2) != expect {
+		t.Errorf("different chained derived value, expect %v, got %v", expect, bonus.Get(2))
+	}
+
+	var roErr *ReadOnlyError
+	if err := lv.Set(0, 1); !errors.As(err, &roErr) || roErr.VarName != "ParamLv" {
+		t.Errorf("derived variable should be read-only, got %v", err)
+	}
+	if err := lv.Fill(1); err == nil {
+		t.Error("derived variable should not be filled")
+	}
+
+	// system scope
+	number, _ := gamestate.SystemData.GetInt("Number")
+	number.Set(0, 21)
+	if double, ok := gamestate.SystemData.GetInt("NumberDouble"); !ok || double.Get(0) != 42 {
+		t.Errorf("different system derived value, got %v", double.Values)
+	}
+	if _, ok := gamestate.SystemData.IntMap["NumberDouble"]; ok {
+		t.Error("derived variable should not be stored")
+	}
+
+	// caches are discarded by replacing whole data.
+	if err := gamestate.TakeSnapshot("snapshot"); err != nil {
+		t.Fatal(err)
+	}
+	number.Set(0, 5)
+	if double, _ := gamestate.SystemData.GetInt("NumberDouble"); double.Get(0) != 10 {
+		t.Errorf("different system derived value, got %v", double.Values)
+	}
+	if _, err := gamestate.Rewind(1); err != nil {
+		t.Fatal(err)
+	}
+	if double, _ := gamestate.SystemData.GetInt("NumberDouble"); double.Get(0) != 42 {
+		t.Errorf("derived value should be restored by rewind, got %v", double.Values)
+	}
+}
@@ -2,6 +2,7 @@ package state
 
 import (
 	"context"
+	"sort"
 
 	"github.com/mzki/erago/state/csv"
 )
@@ -15,7 +16,9 @@ type GameState struct {
 
 	*SaveInfo
 
-	repo Repository
+	repo      Repository
+	snapshots *snapshotHistory
+	watcher   *changeWatcher
 }
 
 // consturct gameState with CSV Manager and config.
@@ -27,14 +30,18 @@ func NewGameState(csvdb *csv.CsvManager, repo Repository) *GameState {
 		ShareData:  &shareData,
 		SaveInfo:   newSaveInfo(),
 		repo:       repo,
+		snapshots:  newSnapshotHistory(),
+		watcher:    newChangeWatcher(),
 	}
 	return state
 }
 
 // clear all data, including system and share, using 0 and empty string.
+// The snapshots are also removed since those are of the previous game.
 func (state *GameState) Clear() {
 	state.SystemData.Clear()
 	state.ShareData.Clear()
+	state.ClearSnapshots()
 }
 
 // save game system state to save[No.].
@@ -61,6 +68,9 @@ func (state *GameState) LoadSystem(no int) error {
 	}
 	// require to recover unexported fields
 	state.SystemData.refine(state.CSV)
+	state.resetWatches()
+	// snapshots before loading are of another game, must not be rewound.
+	state.ClearSnapshots()
 	return nil
 }
 
@@ -77,9 +87,15 @@ func (state *GameState) LoadShare() error {
 		return err
 	}
 	// require to recover unexported fields
-	intVSpecs := intVariableSpecs(state.CSV.IntVariableSpecs(csv.ScopeShare))
-	strVSpecs := strVariableSpecs(state.CSV.StrVariableSpecs(csv.ScopeShare))
-	state.ShareData.refine(state.CSV.Constants(), intVSpecs, strVSpecs)
+	vspecs := newUserVariableSpecs(state.CSV, csv.ScopeShare)
+	state.ShareData.refine(
+		state.CSV.Constants(),
+		state.CSV.Shapes(),
+		state.CSV.VariableBounds(),
+		state.CSV.DerivedVariables(csv.ScopeShare),
+		vspecs,
+	)
+	state.resetWatches()
 	return nil
 }
 
@@ -106,8 +122,16 @@ type intData struct {
 type strData struct {
 	Values []string
 }
+type floatData struct {
+	Values []float64
+}
+type boolData struct {
+	Values []bool
+}
 type intParamMap map[string]intData
 type strParamMap map[string]strData
+type floatParamMap map[string]floatData
+type boolParamMap map[string]boolData
 
 func (v intParamMap) addEntry(k string, values []int64) {
 	v[k] = intData{values}
@@ -115,85 +139,179 @@ func (v intParamMap) addEntry(k string, values []int64) {
 func (v strParamMap) addEntry(k string, values []string) {
 	v[k] = strData{values}
 }
+func (v floatParamMap) addEntry(k string, values []float64) {
+	v[k] = floatData{values}
+}
+func (v boolParamMap) addEntry(k string, values []bool) {
+	v[k] = boolData{values}
+}
 
 // define types so that VariableSpec with specific data type has explicitly type safety.
 type intVariableSpecs []csv.VariableSpec
 type strVariableSpecs []csv.VariableSpec
+type floatVariableSpecs []csv.VariableSpec
+type boolVariableSpecs []csv.VariableSpec
+
+// userVariableSpecs is a set of VariableSpecs for each data type.
+type userVariableSpecs struct {
+	Int   intVariableSpecs
+	Str   strVariableSpecs
+	Float floatVariableSpecs
+	Bool  boolVariableSpecs
+}
+
+func newUserVariableSpecs(cm *csv.CsvManager, where csv.VarScope) userVariableSpecs {
+	return userVariableSpecs{
+		Int:   intVariableSpecs(cm.IntVariableSpecs(where)),
+		Str:   strVariableSpecs(cm.StrVariableSpecs(where)),
+		Float: floatVariableSpecs(cm.FloatVariableSpecs(where)),
+		Bool:  boolVariableSpecs(cm.BoolVariableSpecs(where)),
+	}
+}
 
 // UserVariables defines user defined values from csv data base.
 // Its contents are accessed via API such as GetInt(varname) or GetStr(varname).
 type UserVariables struct {
 	// exported to marshall/unmarshall object. user should not
 	// access this field directory
-	IntMap intParamMap
-	StrMap strParamMap
+	IntMap   intParamMap
+	StrMap   strParamMap
+	FloatMap floatParamMap
+	BoolMap  boolParamMap
 
 	// unexported to not marshall/unmarshall object.
 	constantMap map[string]csv.Constant
+	shapeMap    map[string]csv.Shape         // shapes of multi-dimensional variables.
+	boundMap    map[string]csv.VariableBound // bounds of Int variables.
+	derivedMap  map[string]csv.DerivedVariable
+	derivedData *derivedData // cached values of the derived variables.
+}
+
+// userValueMaps is a set of values for each data type, used to construct UserVariables.
+type userValueMaps struct {
+	Int   map[string][]int64
+	Str   map[string][]string
+	Float map[string][]float64
+	Bool  map[string][]bool
+}
+
+func newUserValueMaps(cm *csv.CsvManager, where csv.VarScope) userValueMaps {
+	return userValueMaps{
+		Int:   cm.BuildIntUserVars(where),
+		Str:   cm.BuildStrUserVars(where),
+		Float: cm.BuildFloatUserVars(where),
+		Bool:  cm.BuildBoolUserVars(where),
+	}
 }
 
-// NOTE: slice of given imap and smap are taken over UserVariables.
-// be sure to not pass shared imap and smap.
-func newUserVariablesByMap(imap map[string][]int64, smap map[string][]string, cmap map[string]csv.Constant) UserVariables {
-	intMap := make(intParamMap, len(imap))
-	for k, v := range imap {
+// NOTE: slice of given maps are taken over UserVariables.
+// be sure to not pass shared maps.
+func newUserVariablesByMap(
+	maps userValueMaps,
+	cmap map[string]csv.Constant,
+	shapes map[string]csv.Shape,
+	bounds map[string]csv.VariableBound,
+	derived map[string]csv.DerivedVariable,
+) UserVariables {
+	intMap := make(intParamMap, len(maps.Int))
+	for k, v := range maps.Int {
 		intMap[k] = intData{v}
 	}
 
-	strMap := make(strParamMap, len(smap))
-	for k, v := range smap {
+	strMap := make(strParamMap, len(maps.Str))
+	for k, v := range maps.Str {
 		strMap[k] = strData{v}
 	}
 
+	floatMap := make(floatParamMap, len(maps.Float))
+	for k, v := range maps.Float {
+		floatMap[k] = floatData{v}
+	}
+
+	boolMap := make(boolParamMap, len(maps.Bool))
+	for k, v := range maps.Bool {
+		boolMap[k] = boolData{v}
+	}
+
 	uv := UserVariables{
 		IntMap:      intMap,
 		StrMap:      strMap,
+		FloatMap:    floatMap,
+		BoolMap:     boolMap,
 		constantMap: cmap,
+		shapeMap:    shapes,
+		boundMap:    bounds,
+		derivedMap:  derived,
+		derivedData: newDerivedData(derived),
 	}
 	return uv
 }
 
 func newUserVariablesSystem(cm *csv.CsvManager) UserVariables {
 	return newUserVariablesByMap(
-		cm.BuildIntUserVars(csv.ScopeSystem),
-		cm.BuildStrUserVars(csv.ScopeSystem),
+		newUserValueMaps(cm, csv.ScopeSystem),
 		cm.Constants(),
+		cm.Shapes(),
+		cm.VariableBounds(),
+		cm.DerivedVariables(csv.ScopeSystem),
 	)
 }
 
 func newUserVariablesShare(cm *csv.CsvManager) UserVariables {
 	return newUserVariablesByMap(
-		cm.BuildIntUserVars(csv.ScopeShare),
-		cm.BuildStrUserVars(csv.ScopeShare),
+		newUserValueMaps(cm, csv.ScopeShare),
 		cm.Constants(),
+		cm.Shapes(),
+		cm.VariableBounds(),
+		cm.DerivedVariables(csv.ScopeShare),
 	)
 }
 
 func newUserVariablesChara(cm *csv.CsvManager) UserVariables {
 	return newUserVariablesByMap(
-		cm.BuildIntUserVars(csv.ScopeChara),
-		cm.BuildStrUserVars(csv.ScopeChara),
+		newUserValueMaps(cm, csv.ScopeChara),
 		cm.Constants(),
+		cm.Shapes(),
+		cm.VariableBounds(),
+		cm.DerivedVariables(csv.ScopeChara),
 	)
 }
 
 // clear contents of UserVariables.
-// Int vars are cleared by 0, and Str vars are empty string.
+// Int vars are cleared by the default values, which are 0 if not specified.
+// Float vars are cleared by 0, Str vars are empty string,
+// and Bool vars are false.
 func (uvars UserVariables) Clear() {
-	for _, v := range uvars.IntMap {
-		ZeroClear(v.Values)
+	for k, v := range uvars.IntMap {
+		if b, ok := uvars.boundMap[k]; ok && b.Default != 0 {
+			for i := range v.Values {
+				v.Values[i] = b.Default
+			}
+		} else {
+			ZeroClear(v.Values)
+		}
 	}
 	for _, v := range uvars.StrMap {
 		StrClear(v.Values)
 	}
+	for _, v := range uvars.FloatMap {
+		clear(v.Values)
+	}
+	for _, v := range uvars.BoolMap {
+		clear(v.Values)
+	}
+	uvars.derivedData.invalidate()
 }
 
 // Get IntParam queried by varname.
 // return IntParam, found.
+// The derived variable is also returned as read-only IntParam.
 func (usr_vars UserVariables) GetInt(varname string) (IntParam, bool) {
 	if vars, ok := usr_vars.IntMap[varname]; ok {
-		indexer, _ := usr_vars.nameIndexer(varname)
-		return NewIntParam(vars.Values, indexer), true
+		return usr_vars.newIntParam(varname, vars.Values), true
+	}
+	if _, ok := usr_vars.derivedMap[varname]; ok {
+		return usr_vars.newDerivedParam(varname), true
 	}
 	return IntParam{}, false
 }
@@ -202,164 +320,269 @@ func (usr_vars UserVariables) GetInt(varname string) (IntParam, bool) {
 // return StrParam, found.
 func (usr_vars UserVariables) GetStr(varname string) (StrParam, bool) {
 	if vars, ok := usr_vars.StrMap[varname]; ok {
-		indexer, _ := usr_vars.nameIndexer(varname)
-		return NewStrParam(vars.Values, indexer), true
+		return newUserParam(usr_vars, varname, vars.Values), true
 	}
 	return StrParam{}, false
 }
 
+// Get []float64 variable queried by varname.
+// return FloatParam, found.
+func (usr_vars UserVariables) GetFloat(varname string) (FloatParam, bool) {
+	if vars, ok := usr_vars.FloatMap[varname]; ok {
+		return newUserParam(usr_vars, varname, vars.Values), true
+	}
+	return FloatParam{}, false
+}
+
+// Get []bool variable queried by varname.
+// return BoolParam, found.
+func (usr_vars UserVariables) GetBool(varname string) (BoolParam, bool) {
+	if vars, ok := usr_vars.BoolMap[varname]; ok {
+		return newUserParam(usr_vars, varname, vars.Values), true
+	}
+	return BoolParam{}, false
+}
+
+func (usr_vars UserVariables) newIntParam(varname string, values []int64) IntParam {
+	indexer, _ := usr_vars.nameIndexer(varname)
+	p := NewIntParam(values, indexer)
+	if shape, ok := usr_vars.shapeMap[varname]; ok {
+		p.shape = newParamShape(shape)
+	}
+	if b, ok := usr_vars.boundMap[varname]; ok {
+		p.bound = &paramBound{varName: varname, VariableBound: b}
+	}
+	p.version = usr_vars.derivedData.version(varname)
+	return p
+}
+
+// newUserParam returns Param of the variable varname with the values.
+// It is a function since a method can not have type parameters.
+func newUserParam[T any](usr_vars UserVariables, varname string, values []T) Param[T] {
+	indexer, _ := usr_vars.nameIndexer(varname)
+	p := newParam(values, indexer)
+	if shape, ok := usr_vars.shapeMap[varname]; ok {
+		p.shape = newParamShape(shape)
+	}
+	return p
+}
+
 func (usr_vars *UserVariables) nameIndexer(varname string) (NameIndexer, bool) {
 	if c, ok := usr_vars.constantMap[varname]; ok {
-		return c.NameIndex, ok
+		return c, ok
 	} else {
 		return NoneNameIndexer{}, false
 	}
 }
 
 func (uvars *UserVariables) dropExtra() {
-	dropKeys := make([]string, 0)
-	for k := range uvars.IntMap {
-		if _, ok := uvars.constantMap[k]; !ok {
-			dropKeys = append(dropKeys, k)
-		}
-	}
-	for _, k := range dropKeys {
-		delete(uvars.IntMap, k)
-	}
+	dropExtraKeys(uvars.IntMap, uvars.constantMap)
+	dropExtraKeys(uvars.StrMap, uvars.constantMap)
+	dropExtraKeys(uvars.FloatMap, uvars.constantMap)
+	dropExtraKeys(uvars.BoolMap, uvars.constantMap)
+}
 
-	dropKeys = dropKeys[:0]
-	for k := range uvars.StrMap {
-		if _, ok := uvars.constantMap[k]; !ok {
-			dropKeys = append(dropKeys, k)
+// dropExtraKeys deletes entries of m whose keys are not found in cmap.
+func dropExtraKeys[V any](m map[string]V, cmap map[string]csv.Constant) {
+	for k := range m {
+		if _, ok := cmap[k]; !ok {
+			delete(m, k)
 		}
 	}
-	for _, k := range dropKeys {
-		delete(uvars.StrMap, k)
-	}
 }
 
 // This methods is used for technical reason:
 // UserVariables after unmarshaling has no constantMap since it is unexported,
 // therefore, requiring re-set csv relationship.
-func (usr_vars *UserVariables) refine(cmap map[string]csv.Constant, intVSpecs intVariableSpecs, strVSpecs strVariableSpecs) {
+func (usr_vars *UserVariables) refine(
+	cmap map[string]csv.Constant,
+	shapes map[string]csv.Shape,
+	bounds map[string]csv.VariableBound,
+	derived map[string]csv.DerivedVariable,
+	vspecs userVariableSpecs,
+) {
+	usr_vars.refineMaps(cmap, shapes, bounds, derived)
+
+	for _, v := range vspecs.Int {
+		ivalues, ok := usr_vars.IntMap[v.VarName]
+		usr_vars.IntMap.addEntry(v.VarName, refineValues(ivalues.Values, ok, v, defaultValues(v, bounds)))
+	}
+	for _, v := range vspecs.Str {
+		svalues, ok := usr_vars.StrMap[v.VarName]
+		usr_vars.StrMap.addEntry(v.VarName, refineValues(svalues.Values, ok, v, nil))
+	}
+	for _, v := range vspecs.Float {
+		fvalues, ok := usr_vars.FloatMap[v.VarName]
+		usr_vars.FloatMap.addEntry(v.VarName, refineValues(fvalues.Values, ok, v, nil))
+	}
+	for _, v := range vspecs.Bool {
+		bvalues, ok := usr_vars.BoolMap[v.VarName]
+		usr_vars.BoolMap.addEntry(v.VarName, refineValues(bvalues.Values, ok, v, nil))
+	}
+}
+
+// refineMaps sets csv relationship and allocates the maps which may be nil
+// if the saved data has no such data type.
+func (usr_vars *UserVariables) refineMaps(
+	cmap map[string]csv.Constant,
+	shapes map[string]csv.Shape,
+	bounds map[string]csv.VariableBound,
+	derived map[string]csv.DerivedVariable,
+) {
 	usr_vars.constantMap = cmap
+	usr_vars.shapeMap = shapes
+	usr_vars.boundMap = bounds
+	usr_vars.derivedMap = derived
+	usr_vars.derivedData = newDerivedData(derived)
+	if usr_vars.IntMap == nil {
+		usr_vars.IntMap = make(intParamMap)
+	}
+	if usr_vars.StrMap == nil {
+		usr_vars.StrMap = make(strParamMap)
+	}
+	if usr_vars.FloatMap == nil {
+		usr_vars.FloatMap = make(floatParamMap)
+	}
+	if usr_vars.BoolMap == nil {
+		usr_vars.BoolMap = make(boolParamMap)
+	}
+}
 
-	for _, v := range intVSpecs {
-		key := v.VarName
-		ivalues, ok := usr_vars.IntMap[key]
-		var newValues []int64
-		switch {
-		case !ok:
-			// missing csv defined values
-			newValues = make([]int64, v.Size)
-		case uint64(len(ivalues.Values)) < v.Size:
-			// smaller than csv defined
-			tail := make([]int64, v.Size-uint64(len(ivalues.Values)))
-			newValues = append(ivalues.Values, tail...)
-		case uint64(len(ivalues.Values)) > v.Size:
-			// larger than csv defined
-			newValues = ivalues.Values[:v.Size]
-		default:
-			newValues = ivalues.Values
+// refineValues fits the values to size of the variable v.
+// ok is whether the values exist. Missing values are filled by
+// the corresponding values in csvValues, or zero value if csvValues is nil.
+func refineValues[T any](values []T, ok bool, v csv.VariableSpec, csvValues []T) []T {
+	fill := func(from int) []T {
+		if csvValues != nil {
+			return csvValues[from:]
 		}
-		usr_vars.IntMap.addEntry(key, newValues)
-	}
-
-	for _, v := range strVSpecs {
-		key := v.VarName
-		svalues, ok := usr_vars.StrMap[key]
-		var newValues []string
-		switch {
-		case !ok:
-			// missing csv defined values
-			newValues = make([]string, v.Size)
-		case uint64(len(svalues.Values)) < v.Size:
-			// smaller than csv defined
-			tail := make([]string, v.Size-uint64(len(svalues.Values)))
-			newValues = append(svalues.Values, tail...)
-		case uint64(len(svalues.Values)) > v.Size:
-			// larger than csv defined
-			newValues = svalues.Values[:v.Size]
-		default:
-			newValues = svalues.Values
-		}
-		usr_vars.StrMap.addEntry(key, newValues)
+		return make([]T, int(v.Size)-from)
+	}
+	switch {
+	case ok && isOneDimValues(len(values), v):
+		// saved before the variable became multi-dimensional.
+		return toMultiDimValues(values, v.Dims)
+	case !ok:
+		// missing csv defined values
+		return append([]T{}, fill(0)...)
+	case uint64(len(values)) < v.Size:
+		// smaller than csv defined
+		return append(values, fill(len(values))...)
+	case uint64(len(values)) > v.Size:
+		// larger than csv defined
+		// TODO: Is it OK to shrink older data?
+		return values[:v.Size]
+	default:
+		return values
+	}
+}
+
+// defaultValues returns default values of the Int variable v,
+// or nil if v has no default value.
+func defaultValues(v csv.VariableSpec, bounds map[string]csv.VariableBound) []int64 {
+	b, ok := bounds[v.VarName]
+	if !ok || b.Default == 0 {
+		return nil
+	}
+	values := make([]int64, v.Size)
+	for i := range values {
+		values[i] = b.Default
+	}
+	return values
+}
+
+// isOneDimValues returns whether the values of size n are saved as 1-D variable,
+// while the variable v is multi-dimensional now.
+func isOneDimValues(n int, v csv.VariableSpec) bool {
+	return len(v.Dims) > 1 && n == v.Dims[0] && uint64(n) != v.Size
+}
+
+// toMultiDimValues returns multi-dimensional values of dims whose
+// the first elements of each sub-arrays are the 1-D values, as like
+// new[i][0] = old[i], and the other elements are zero values.
+func toMultiDimValues[T any](values []T, dims []int) []T {
+	stride := 1
+	for _, size := range dims[1:] {
+		stride *= size
 	}
+	newValues := make([]T, dims[0]*stride)
+	for i, v := range values {
+		newValues[i*stride] = v
+	}
+	return newValues
 }
 
 // similar with refine() but use csv.Character as initialize source.
 func (usr_vars *UserVariables) refineByCsvChara(
 	cmap map[string]csv.Constant,
-	intVSpecs intVariableSpecs,
-	strVSpecs strVariableSpecs,
+	shapes map[string]csv.Shape,
+	bounds map[string]csv.VariableBound,
+	derived map[string]csv.DerivedVariable,
+	vspecs userVariableSpecs,
 	csvC *csv.Character,
 ) {
-	usr_vars.constantMap = cmap
-
 	csvIntMap := csvC.GetIntMap()
 	csvStrMap := csvC.GetStrMap()
-
-	for _, v := range intVSpecs {
-		key := v.VarName
-		ivalues, ok := usr_vars.IntMap[key]
-		csvValues, csvOk := csvIntMap[key]
-		var newValues []int64
-		switch {
-		case !csvOk:
-			panic("inconsistent user value map for key(" + key + ")")
-		case !ok:
-			// missing csv defined values
-			newValues = append([]int64{}, csvValues...)
-		case uint64(len(ivalues.Values)) < v.Size:
-			// smaller than csv defined
-			newValues = append(ivalues.Values, csvValues[len(ivalues.Values):]...)
-		case uint64(len(ivalues.Values)) > v.Size:
-			// larger than csv defined
-			// TODO: Is it OK to shrink older data?
-			newValues = ivalues.Values[:v.Size]
-		default:
-			newValues = ivalues.Values
-		}
-		usr_vars.IntMap.addEntry(key, newValues)
-	}
-	for _, v := range strVSpecs {
-		key := v.VarName
-		svalues, ok := usr_vars.StrMap[key]
-		csvValues, csvOk := csvStrMap[key]
-		var newValues []string
-		switch {
-		case !csvOk:
+	csvFloatMap := csvC.GetFloatMap()
+	csvBoolMap := csvC.GetBoolMap()
+	mustCsvValues := func(key string, found bool) {
+		if !found {
 			panic("inconsistent user value map for key(" + key + ")")
-		case !ok:
-			// missing csv defined values
-			newValues = append([]string{}, csvValues...)
-		case uint64(len(svalues.Values)) < v.Size:
-			// smaller than csv defined
-			newValues = append(svalues.Values, csvValues[len(svalues.Values):]...)
-		case uint64(len(svalues.Values)) > v.Size:
-			// larger than csv defined
-			// TODO: Is it OK to shrink older data?
-			newValues = svalues.Values[:v.Size]
-		default:
-			newValues = svalues.Values
 		}
-		usr_vars.StrMap.addEntry(key, newValues)
+	}
+
+	usr_vars.refineMaps(cmap, shapes, bounds, derived)
+	for _, v := range vspecs.Int {
+		ivalues, ok := usr_vars.IntMap[v.VarName]
+		csvValues, csvOk := csvIntMap[v.VarName]
+		mustCsvValues(v.VarName, csvOk)
+		usr_vars.IntMap.addEntry(v.VarName, refineValues(ivalues.Values, ok, v, csvValues))
+	}
+	for _, v := range vspecs.Str {
+		svalues, ok := usr_vars.StrMap[v.VarName]
+		csvValues, csvOk := csvStrMap[v.VarName]
+		mustCsvValues(v.VarName, csvOk)
+		usr_vars.StrMap.addEntry(v.VarName, refineValues(svalues.Values, ok, v, csvValues))
+	}
+	for _, v := range vspecs.Float {
+		fvalues, ok := usr_vars.FloatMap[v.VarName]
+		csvValues, csvOk := csvFloatMap[v.VarName]
+		mustCsvValues(v.VarName, csvOk)
+		usr_vars.FloatMap.addEntry(v.VarName, refineValues(fvalues.Values, ok, v, csvValues))
+	}
+	for _, v := range vspecs.Bool {
+		bvalues, ok := usr_vars.BoolMap[v.VarName]
+		csvValues, csvOk := csvBoolMap[v.VarName]
+		mustCsvValues(v.VarName, csvOk)
+		usr_vars.BoolMap.addEntry(v.VarName, refineValues(bvalues.Values, ok, v, csvValues))
 	}
 }
 
 // iteration of each int parameters.
 func (usr_vars UserVariables) ForEachIntParam(f func(string, IntParam)) {
 	for key, vars := range usr_vars.IntMap {
-		indexer, _ := usr_vars.nameIndexer(key)
-		f(key, NewIntParam(vars.Values, indexer))
+		f(key, usr_vars.newIntParam(key, vars.Values))
 	}
 }
 
 // iteration of each str parameters.
 func (usr_vars UserVariables) ForEachStrParam(f func(string, StrParam)) {
 	for key, vars := range usr_vars.StrMap {
-		indexer, _ := usr_vars.nameIndexer(key)
-		f(key, NewStrParam(vars.Values, indexer))
+		f(key, newUserParam(usr_vars, key, vars.Values))
+	}
+}
+
+// iteration of each float parameters.
+func (usr_vars UserVariables) ForEachFloatParam(f func(string, FloatParam)) {
+	for key, vars := range usr_vars.FloatMap {
+		f(key, newUserParam(usr_vars, key, vars.Values))
+	}
+}
+
+// iteration of each bool parameters.
+func (usr_vars UserVariables) ForEachBoolParam(f func(string, BoolParam)) {
+	for key, vars := range usr_vars.BoolMap {
+		f(key, newUserParam(usr_vars, key, vars.Values))
 	}
 }
 
@@ -374,54 +597,194 @@ type SystemData struct {
 	Player *CharaReferences
 	Assi   *CharaReferences
 
+	// references of Chara declared in csv with scope CharaRef,
+	// except builtin Target, Master, Player and Assi.
+	References map[string]*CharaReferences
+
+	// named collections of Chara, declared in csv with scope CharaList.
+	Collections map[string]*CharaCollection
+
 	UserVariables
+
+	// references in References are kept same across loading,
+	// since these may be held by the user.
+	refs map[string]*CharaReferences
 }
 
 func newSystemData(csvM *csv.CsvManager) *SystemData {
 	charas := newCharacters(csvM)
-	n_csv_chara := len(csvM.CharaMap)
+	refs := make(map[string]*CharaReferences, 4)
+	for _, vs := range csvM.CharaRefSpecs() {
+		refs[vs.VarName] = newCharaReferences(int(vs.Size), charas)
+	}
 	sysdata := &SystemData{
 		Chara:         charas,
-		Target:        newCharaReferences(n_csv_chara, charas),
-		Master:        newCharaReferences(n_csv_chara, charas),
-		Player:        newCharaReferences(n_csv_chara, charas),
-		Assi:          newCharaReferences(n_csv_chara, charas),
+		Target:        refs[csv.BuiltinTargetName],
+		Master:        refs[csv.BuiltinMasterName],
+		Player:        refs[csv.BuiltinPlayerName],
+		Assi:          refs[csv.BuiltinAssiName],
+		References:    make(map[string]*CharaReferences, len(refs)),
+		Collections:   newCharaCollections(csvM, charas),
 		UserVariables: newUserVariablesSystem(csvM),
+		refs:          refs,
 	}
+	for name, cref := range refs {
+		if !isBuiltinReference(name) {
+			sysdata.References[name] = cref
+		}
+	}
+	charas.onRemove = sysdata.removeFromCollections
 	return sysdata
 }
 
+// removeFromCollections removes the Character of uid from all of collections.
+func (sysdata *SystemData) removeFromCollections(uid uint64) {
+	for _, cc := range sysdata.Collections {
+		cc.removeUID(uid)
+	}
+}
+
+func isBuiltinReference(name string) bool {
+	switch name {
+	case csv.BuiltinTargetName, csv.BuiltinMasterName, csv.BuiltinPlayerName, csv.BuiltinAssiName:
+		return true
+	default:
+		return false
+	}
+}
+
 // clear all data using 0, empty string and nil.
 func (sysdata *SystemData) Clear() {
 	sysdata.Chara.Clear()
 
-	sysdata.Target.Clear()
-	sysdata.Master.Clear()
-	sysdata.Player.Clear()
-	sysdata.Assi.Clear()
+	for _, cref := range sysdata.refs {
+		cref.Clear()
+	}
+
+	for _, cc := range sysdata.Collections {
+		cc.Clear()
+	}
 
 	sysdata.UserVariables.Clear()
 }
 
+// Reference returns references of Chara declared in csv, including
+// builtin Target, Master, Player and Assi.
+func (sysdata *SystemData) Reference(name string) (*CharaReferences, bool) {
+	cref, ok := sysdata.refs[name]
+	return cref, ok
+}
+
+// ReferenceNames returns names of references of Chara declared in csv,
+// including builtin Target, Master, Player and Assi, sorted by the name.
+func (sysdata *SystemData) ReferenceNames() []string {
+	names := make([]string, 0, len(sysdata.refs))
+	for name := range sysdata.refs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Collection returns named collection of Chara declared in csv.
+// The collection may be replaced by loading the game, so that
+// user should not hold it across loading.
+func (sysdata *SystemData) Collection(name string) (*CharaCollection, bool) {
+	cc, ok := sysdata.Collections[name]
+	return cc, ok
+}
+
 // dropExtra drops extra values which are not found in csv database.
 // requiring before unmarshal.
 func (sysdata *SystemData) dropExtra() {
 	for _, c := range sysdata.Chara.List {
 		c.UserVariables.dropExtra()
 	}
+	// older data may not have collections and references, in that case these should be empty.
+	for _, cc := range sysdata.Collections {
+		cc.Clear()
+	}
+	for _, cref := range sysdata.References {
+		cref.Clear()
+	}
 	sysdata.UserVariables.dropExtra()
 }
 
 // refine csv relationship for internally, requiring after unmarshal.
 func (sysdata *SystemData) refine(csvM *csv.CsvManager) {
 	sysdata.Chara.refine(csvM)
+	sysdata.Chara.onRemove = sysdata.removeFromCollections
+	sysdata.refineReferences(csvM)
+	sysdata.refineCollections(csvM)
 
 	// TODO: constants with only system scope is required for
 	// UserVariables existent test. But not perform since it's less occurs.
 	constants := csvM.Constants()
-	intVSpecs := intVariableSpecs(csvM.IntVariableSpecs(csv.ScopeSystem))
-	strVSpecs := strVariableSpecs(csvM.StrVariableSpecs(csv.ScopeSystem))
-	sysdata.UserVariables.refine(constants, intVSpecs, strVSpecs)
+	vspecs := newUserVariableSpecs(csvM, csv.ScopeSystem)
+	sysdata.UserVariables.refine(
+		constants,
+		csvM.Shapes(),
+		csvM.VariableBounds(),
+		csvM.DerivedVariables(csv.ScopeSystem),
+		vspecs,
+	)
+}
+
+// refineReferences restores loaded references into the references kept by SystemData,
+// and fits these sizes to csv.
+func (sysdata *SystemData) refineReferences(csvM *csv.CsvManager) {
+	loaded := map[string]*CharaReferences{
+		csv.BuiltinTargetName: sysdata.Target,
+		csv.BuiltinMasterName: sysdata.Master,
+		csv.BuiltinPlayerName: sysdata.Player,
+		csv.BuiltinAssiName:   sysdata.Assi,
+	}
+	for name, cref := range sysdata.References {
+		loaded[name] = cref
+	}
+	if sysdata.refs == nil {
+		sysdata.refs = make(map[string]*CharaReferences, len(loaded))
+	}
+
+	for _, vs := range csvM.CharaRefSpecs() {
+		cref, ok := sysdata.refs[vs.VarName]
+		if !ok {
+			cref = newCharaReferences(int(vs.Size), sysdata.Chara)
+			sysdata.refs[vs.VarName] = cref
+		}
+		if l := loaded[vs.VarName]; l != nil && l != cref {
+			cref.UIDs, cref.Indexes = l.UIDs, l.Indexes
+		}
+		cref.src = sysdata.Chara
+		cref.refine()
+		cref.resize(int(vs.Size))
+	}
+
+	sysdata.Target = sysdata.refs[csv.BuiltinTargetName]
+	sysdata.Master = sysdata.refs[csv.BuiltinMasterName]
+	sysdata.Player = sysdata.refs[csv.BuiltinPlayerName]
+	sysdata.Assi = sysdata.refs[csv.BuiltinAssiName]
+	sysdata.References = make(map[string]*CharaReferences, len(sysdata.refs))
+	for name, cref := range sysdata.refs {
+		if !isBuiltinReference(name) {
+			sysdata.References[name] = cref
+		}
+	}
+}
+
+// refineCollections recovers collections declared in csv and drops undeclared ones.
+func (sysdata *SystemData) refineCollections(csvM *csv.CsvManager) {
+	specs := csvM.CharaListSpecs()
+	collections := make(map[string]*CharaCollection, len(specs))
+	for _, vs := range specs {
+		cc, ok := sysdata.Collections[vs.VarName]
+		if !ok || cc == nil {
+			cc = newCharaCollection(vs.VarName, int(vs.Size), sysdata.Chara)
+		}
+		cc.refine(vs.VarName, int(vs.Size), sysdata.Chara)
+		collections[vs.VarName] = cc
+	}
+	sysdata.Collections = collections
 }
 
 // SaveInfo has information isolated save and load.
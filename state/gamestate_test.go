@@ -3,6 +3,7 @@ package state
 import (
 	"encoding/json"
 	"os"
+	"reflect"
 	"testing"
 
 	"github.com/mzki/erago/state/csv"
@@ -357,7 +358,7 @@ func newTestUserVariable() UserVariables {
 		StrKey: constant,
 	}
 
-	return newUserVariablesByMap(imap, smap, cmap)
+	return newUserVariablesByMap(userValueMaps{Int: imap, Str: smap}, cmap, nil, nil, nil)
 }
 
 func TestUserVariable(t *testing.T) {
@@ -415,7 +416,7 @@ func TestUserVariableMarshal(t *testing.T) {
 		t.Fatal(err)
 	}
 	// reuiring call refine() after unmarshal.
-	newUV.refine(uv.constantMap, testDataIntVSpecs, testDataStrVSpecs)
+	newUV.refine(uv.constantMap, nil, nil, nil, userVariableSpecs{Int: testDataIntVSpecs, Str: testDataStrVSpecs})
 
 	vars, _ = newUV.GetInt(IntKey)
 	if v, ok := vars.GetByStr(DataKey); !ok {
@@ -432,7 +433,7 @@ func TestUserVariableForEach(t *testing.T) {
 
 	// Int
 	{
-		const expectLen = 4
+		const expectLen = 5
 		keys := make([]string, 0, expectLen)
 		intparams := make([]IntParam, 0, expectLen)
 		gamestate.SystemData.ForEachIntParam(func(k string, v IntParam) {
@@ -447,7 +448,7 @@ func TestUserVariableForEach(t *testing.T) {
 
 	// String
 	{
-		const expectLen = 1
+		const expectLen = 2
 		keys := make([]string, 0, expectLen)
 		strparams := make([]StrParam, 0, expectLen)
 		gamestate.SystemData.ForEachStrParam(func(k string, v StrParam) {
@@ -460,3 +461,62 @@ func TestUserVariableForEach(t *testing.T) {
 		}
 	}
 }
+
+func TestUserVariableRefineToMultiDim(t *testing.T) {
+	const key = "History"
+	vspecs := intVariableSpecs{
+		csv.VariableSpec{VarName: key, Scope: csv.ScopeSystem, Size: 6, Dims: []int{3, 2}},
+	}
+	shapes := map[string]csv.Shape{key: {Dims: []int{3, 2}, Names: make([]csv.Constant, 2)}}
+
+	// saved as 1-D variable before.
+	uv := newUserVariablesByMap(userValueMaps{Int: map[string][]int64{key: {1, 2, 3}}}, nil, nil, nil, nil)
+	uv.refine(nil, shapes, nil, nil, userVariableSpecs{Int: vspecs})
+
+	history, _ := uv.GetInt(key)
+	if expect := []int64{1, 0, 2, 0, 3, 0}; !reflect.DeepEqual(history.Values, expect) {
+		t.Errorf("different migrated values, got: %v, expect: %v", history.Values, expect)
+	}
+	if v := history.Sub(2).Get(0); v != 3 {
+		t.Errorf("different value at [2][0], got: %v", v)
+	}
+}
+
+func TestUserVariableFloatBool(t *testing.T) {
+	gamestate := NewGameState(CSVDB, Repo)
+
+	difficulty, ok := gamestate.SystemData.GetFloat("Difficulty")
+	if !ok {
+		t.Fatal("float variable Difficulty is not found")
+	}
+	difficulty.SetByStr("数値１", 1.5)
+	cleared, ok := gamestate.ShareData.GetBool("Cleared")
+	if !ok {
+		t.Fatal("bool variable Cleared is not found")
+	}
+	cleared.Set(0, true)
+
+	c, err := gamestate.SystemData.Chara.AddID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rate, _ := c.GetFloat("BaseRate"); rate.Values[1] != 0.5 {
+		t.Errorf("float variable is not initialized by csv, got %v", rate.Values)
+	}
+	if known, _ := c.GetBool("Known"); !known.Get(1) {
+		t.Errorf("bool variable is not initialized by csv, got %v", known.Values)
+	}
+
+	// saved data before Float and Bool are introduced has no such maps.
+	uv := gamestate.SystemData.UserVariables
+	old := UserVariables{IntMap: uv.IntMap, StrMap: uv.StrMap}
+	old.refine(uv.constantMap, uv.shapeMap, uv.boundMap, uv.derivedMap, newUserVariableSpecs(CSVDB, csv.ScopeSystem))
+	if v, ok := old.GetFloat("Difficulty"); !ok || v.Len() != 1 || v.Get(0) != 0 {
+		t.Errorf("missing float variable should be allocated, got %v", v.Values)
+	}
+
+	gamestate.SystemData.Clear()
+	if v := difficulty.Get(0); v != 0 {
+		t.Errorf("float variable should be cleared, got %v", v)
+	}
+}
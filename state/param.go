@@ -1,7 +1,10 @@
 package state
 
 import (
+	"fmt"
+
 	"github.com/mzki/erago/state/csv"
+	"github.com/mzki/erago/util/strutil"
 )
 
 // IndexNotFound is a value implying index is not found.
@@ -14,6 +17,9 @@ type NameIndexer interface {
 	// get index by querying string name
 	// if not found return csv.IndexNotFound
 	GetIndex(string) int
+	// get name by index, the reverse of GetIndex.
+	// if not found return empty string.
+	GetName(int) string
 }
 
 // it always returns index -1 for any key.
@@ -24,6 +30,11 @@ func (n NoneNameIndexer) GetIndex(key string) int {
 	return IndexNotFound
 }
 
+// it always returns empty string for any index.
+func (n NoneNameIndexer) GetName(i int) string {
+	return ""
+}
+
 // LimitedRangeNameIndexer limits the index range from original NameIndexer
 // to the specified range [from:to) and maps the specified range into 0-based range, [0:to-from).
 // It returns index -1 when the original NameIndexer returns out bounds of the specified range.
@@ -49,11 +60,123 @@ func (n LimitedRangeNameIndexer) GetIndex(key string) int {
 	return i - n.from
 }
 
+func (n LimitedRangeNameIndexer) GetName(i int) string {
+	// [0:to-from) maps into [from:to) space
+	if i < 0 || i >= n.to-n.from {
+		return ""
+	}
+	return n.original.GetName(i + n.from)
+}
+
+// NearestName returns the name which is nearest to the key
+// in the names of the indexes [0:size).
+// It returns false if no similar name is found.
+func NearestName(indexer NameIndexer, size int, key string) (string, bool) {
+	names := make([]string, 0, size)
+	for i := 0; i < size; i++ {
+		if name := indexer.GetName(i); len(name) > 0 {
+			names = append(names, name)
+		}
+	}
+	return strutil.Nearest(key, names, strutil.SuggestDist(key))
+}
+
+// paramShape is the shape of multi-dimensional param.
+// The zero value means 1-D param.
+type paramShape struct {
+	dims     []int         // sizes of each dimension.
+	indexers []NameIndexer // name indexers for dims[1:].
+}
+
+func newParamShape(s csv.Shape) paramShape {
+	indexers := make([]NameIndexer, 0, len(s.Names)-1)
+	for _, c := range s.Names[1:] {
+		indexers = append(indexers, c)
+	}
+	return paramShape{dims: s.Dims, indexers: indexers}
+}
+
+func (s paramShape) isMultiDim() bool { return len(s.dims) > 1 }
+
+// stride returns number of values in a sub-array of the first dimension.
+func (s paramShape) stride() int {
+	stride := 1
+	for _, size := range s.dims[1:] {
+		stride *= size
+	}
+	return stride
+}
+
+// sub returns shape and name indexer of the sub-array of the first dimension.
+func (s paramShape) sub() (paramShape, NameIndexer) {
+	if len(s.dims) == 2 {
+		return paramShape{}, s.indexers[0]
+	}
+	return paramShape{dims: s.dims[1:], indexers: s.indexers[1:]}, s.indexers[0]
+}
+
+// flatIndex returns index of the flatten values. see csv.Shape.FlatIndex.
+func (s paramShape) flatIndex(indexes []int) int {
+	return csv.Shape{Dims: s.dims}.FlatIndex(indexes...)
+}
+
 // IntParam can be treated as []int64.
 // And can use string key.
+//
+// IntParam of multi-dimensional variable is treated as array of
+// sub-arrays of the first dimension, such as [][]int64. Its Values are
+// flatten in row-major order, and Sub() returns the sub-array.
 type IntParam struct {
 	Values      []int64     // it must be exported to marshall object.
 	nameIndexer NameIndexer // it must not be exported to marshall.
+	shape       paramShape
+	bound       *paramBound // nil if the variable has no bound.
+	version     *uint64     // bumped by Set and Fill. nil if no derived variable depends on it.
+}
+
+// paramBound is a bound of the values with the variable name to report violation.
+type paramBound struct {
+	varName  string
+	readOnly bool // rejects any value, e.g. derived variable.
+	csv.VariableBound
+}
+
+// ReadOnlyError is an error for setting a value to the read-only variable.
+type ReadOnlyError struct {
+	VarName string
+}
+
+func (e *ReadOnlyError) Error() string {
+	return e.VarName + ": read-only variable can not be modified"
+}
+
+// BoundError is an error for setting a value out of range of the variable.
+type BoundError struct {
+	VarName string
+	Index   int    // index of the flatten values. It is negative for all of values, e.g. Fill().
+	Key     string // name of the Index. It may be empty.
+	Value   int64
+	csv.VariableBound
+}
+
+func (e *BoundError) Error() string {
+	var index string
+	switch {
+	case e.Index < 0:
+		index = ""
+	case len(e.Key) > 0:
+		index = fmt.Sprintf("[%d(%s)]", e.Index, e.Key)
+	default:
+		index = fmt.Sprintf("[%d]", e.Index)
+	}
+	min, max := "-inf", "+inf"
+	if e.HasMin {
+		min = fmt.Sprint(e.Min)
+	}
+	if e.HasMax {
+		max = fmt.Sprint(e.Max)
+	}
+	return fmt.Sprintf("%s%s: value %d is out of range [%s, %s]", e.VarName, index, e.Value, min, max)
 }
 
 func NewIntParam(vars []int64, indexer NameIndexer) IntParam {
@@ -63,19 +186,114 @@ func NewIntParam(vars []int64, indexer NameIndexer) IntParam {
 	}
 }
 
-// return size of its values.
+// return size of its values. For multi-dimensional one,
+// return size of the first dimension.
 func (ip IntParam) Len() int {
+	if ip.shape.isMultiDim() {
+		return ip.shape.dims[0]
+	}
 	return len(ip.Values)
 }
 
+// Dims returns sizes of each dimension.
+func (ip IntParam) Dims() []int {
+	if ip.shape.isMultiDim() {
+		return ip.shape.dims
+	}
+	return []int{len(ip.Values)}
+}
+
+// IsMultiDim returns whether IntParam has more than one dimension.
+func (ip IntParam) IsMultiDim() bool {
+	return ip.shape.isMultiDim()
+}
+
+// Sub returns i-th sub-array of the first dimension, which shares values with ip.
+// The name indexer of the sub-array is one for the next dimension.
+// It panics if ip is not multi-dimensional.
+func (ip IntParam) Sub(i int) IntParam {
+	stride := ip.shape.stride()
+	shape, indexer := ip.shape.sub()
+	return IntParam{
+		Values:      ip.Values[i*stride : (i+1)*stride],
+		nameIndexer: indexer,
+		shape:       shape,
+		bound:       ip.bound,
+		version:     ip.version,
+	}
+}
+
+// FlatIndex returns index of Values for indexes of each dimension.
+// It returns IndexNotFound if the indexes are invalid.
+func (ip IntParam) FlatIndex(indexes ...int) int {
+	if !ip.shape.isMultiDim() {
+		if len(indexes) != 1 || indexes[0] < 0 || indexes[0] >= len(ip.Values) {
+			return IndexNotFound
+		}
+		return indexes[0]
+	}
+	return ip.shape.flatIndex(indexes)
+}
+
 // it is same as ip.Values[i].
+// For multi-dimensional one, i is the index of flatten values.
 func (ip IntParam) Get(i int) int64 {
 	return ip.Values[i]
 }
 
 // it is same as ip.Values[i] = val.
-func (ip IntParam) Set(i int, val int64) {
+// For multi-dimensional one, i is the index of flatten values.
+// If the variable has a bound, val out of range is limited into the range,
+// or the value is not changed and *BoundError is returned.
+// For the read-only variable, it always returns *ReadOnlyError.
+func (ip IntParam) Set(i int, val int64) error {
+	val, err := ip.boundValue(i, val)
+	if err != nil {
+		return err
+	}
 	ip.Values[i] = val
+	ip.touch()
+	return nil
+}
+
+// touch bumps the version to invalidate the derived variables depending on ip.
+func (ip IntParam) touch() {
+	if ip.version != nil {
+		*ip.version++
+	}
+}
+
+// CheckValue returns the error if Set(i, val) will fail.
+func (ip IntParam) CheckValue(i int, val int64) error {
+	_, err := ip.boundValue(i, val)
+	return err
+}
+
+// boundValue returns val to be stored at i.
+func (ip IntParam) boundValue(i int, val int64) (int64, error) {
+	if ip.bound == nil {
+		return val, nil
+	}
+	if ip.bound.readOnly {
+		return val, &ReadOnlyError{VarName: ip.bound.varName}
+	}
+	if ip.bound.Contains(val) {
+		return val, nil
+	}
+	if ip.bound.Clamp {
+		return ip.bound.Limit(val), nil
+	}
+	key := ""
+	if !ip.shape.isMultiDim() {
+		key = ip.GetName(i)
+	}
+	return val, &BoundError{
+		VarName:       ip.bound.varName,
+		Index:         i,
+		Key:           key,
+		Value:         val,
+		VariableBound: ip.bound.VariableBound,
+	}
 }
 
 // get index by string key.
@@ -83,109 +301,234 @@ func (ip IntParam) GetIndex(key string) int {
 	return ip.nameIndexer.GetIndex(key)
 }
 
+// get name by index. return empty string if the index has no name.
+func (ip IntParam) GetName(i int) string {
+	return ip.nameIndexer.GetName(i)
+}
+
 // same as io.Values[i] but i is obtained by
-// using string key,
+// using string key. It returns false for multi-dimensional one,
+// use Sub() instead.
 func (ip IntParam) GetByStr(key string) (int64, bool) {
 	i := ip.GetIndex(key)
-	if i == IndexNotFound {
+	if i == IndexNotFound || ip.shape.isMultiDim() {
 		return -1, false
 	}
 	return ip.Values[i], true
 }
 
 // same as io.Values[i] = val but i is obtained by
-// using string key,
+// using string key. It returns false for multi-dimensional one,
+// use Sub() instead. It also returns false if Set() fails.
 func (ip IntParam) SetByStr(key string, val int64) bool {
 	i := ip.GetIndex(key)
-	if i == IndexNotFound {
+	if i == IndexNotFound || ip.shape.isMultiDim() {
 		return false
 	}
-	ip.Values[i] = val
-	return true
+	return ip.Set(i, val) == nil
 }
 
 // same as []int[from:to], but taking over nameIndexer.
+// For multi-dimensional one, the first dimension is sliced.
 func (ip IntParam) Slice(from, to int) IntParam {
-	return NewIntParam(ip.Values[from:to], LimitedRangeNameIndexer{
+	indexer := LimitedRangeNameIndexer{
 		from:     from,
 		to:       to,
 		original: ip.nameIndexer,
-	})
+	}
+	if !ip.shape.isMultiDim() {
+		sliced := NewIntParam(ip.Values[from:to], indexer)
+		sliced.bound = ip.bound
+		sliced.version = ip.version
+		return sliced
+	}
+	stride := ip.shape.stride()
+	shape := ip.shape
+	shape.dims = append([]int{to - from}, shape.dims[1:]...)
+	return IntParam{
+		Values:      ip.Values[from*stride : to*stride],
+		nameIndexer: indexer,
+		shape:       shape,
+		bound:       ip.bound,
+		version:     ip.version,
+	}
 }
 
 // It fills by given value to all values contained in IntParam.
-func (ip IntParam) Fill(value int64) {
+// The value is checked by the bound as same as Set().
+func (ip IntParam) Fill(value int64) error {
+	if len(ip.Values) == 0 {
+		return nil
+	}
+	value, err := ip.boundValue(0, value)
+	if err != nil {
+		if err, ok := err.(*BoundError); ok {
+			err.Index, err.Key = IndexNotFound, ""
+		}
+		return err
+	}
 	for i := 0; i < len(ip.Values); i++ {
 		ip.Values[i] = value
 	}
+	ip.touch()
+	return nil
 }
 
-// StrParam can be treated as []string.
+// Param can be treated as []T.
 // And can use string key.
-type StrParam struct {
-	Values      []string
-	nameIndexer NameIndexer
+//
+// Param of multi-dimensional variable is treated as same as IntParam.
+// Unlike IntParam, it has no bound so that setting a value always succeeds.
+type Param[T any] struct {
+	Values      []T         // it must be exported to marshall object.
+	nameIndexer NameIndexer // it must not be exported to marshall.
+	shape       paramShape
 }
 
+// StrParam can be treated as []string.
+type StrParam = Param[string]
+
+// FloatParam can be treated as []float64.
+type FloatParam = Param[float64]
+
+// BoolParam can be treated as []bool.
+type BoolParam = Param[bool]
+
 func NewStrParam(vars []string, indexer NameIndexer) StrParam {
-	return StrParam{
+	return newParam(vars, indexer)
+}
+
+func NewFloatParam(vars []float64, indexer NameIndexer) FloatParam {
+	return newParam(vars, indexer)
+}
+
+func NewBoolParam(vars []bool, indexer NameIndexer) BoolParam {
+	return newParam(vars, indexer)
+}
+
+func newParam[T any](vars []T, indexer NameIndexer) Param[T] {
+	return Param[T]{
 		Values:      vars,
 		nameIndexer: indexer,
 	}
 }
 
-// return size of its values.
-func (ip StrParam) Len() int {
+// return size of its values. For multi-dimensional one,
+// return size of the first dimension.
+func (ip Param[T]) Len() int {
+	if ip.shape.isMultiDim() {
+		return ip.shape.dims[0]
+	}
 	return len(ip.Values)
 }
 
+// Dims returns sizes of each dimension.
+func (ip Param[T]) Dims() []int {
+	if ip.shape.isMultiDim() {
+		return ip.shape.dims
+	}
+	return []int{len(ip.Values)}
+}
+
+// IsMultiDim returns whether Param has more than one dimension.
+func (ip Param[T]) IsMultiDim() bool {
+	return ip.shape.isMultiDim()
+}
+
+// Sub returns i-th sub-array of the first dimension, which shares values with ip.
+// The name indexer of the sub-array is one for the next dimension.
+// It panics if ip is not multi-dimensional.
+func (ip Param[T]) Sub(i int) Param[T] {
+	stride := ip.shape.stride()
+	shape, indexer := ip.shape.sub()
+	return Param[T]{
+		Values:      ip.Values[i*stride : (i+1)*stride],
+		nameIndexer: indexer,
+		shape:       shape,
+	}
+}
+
+// FlatIndex returns index of Values for indexes of each dimension.
+// It returns IndexNotFound if the indexes are invalid.
+func (ip Param[T]) FlatIndex(indexes ...int) int {
+	if !ip.shape.isMultiDim() {
+		if len(indexes) != 1 || indexes[0] < 0 || indexes[0] >= len(ip.Values) {
+			return IndexNotFound
+		}
+		return indexes[0]
+	}
+	return ip.shape.flatIndex(indexes)
+}
+
 // it is same as ip.Values[i].
-func (ip StrParam) Get(i int) string {
+// For multi-dimensional one, i is the index of flatten values.
+func (ip Param[T]) Get(i int) T {
 	return ip.Values[i]
 }
 
 // it is same as ip.Values[i] = val.
-func (ip StrParam) Set(i int, val string) {
+// For multi-dimensional one, i is the index of flatten values.
+func (ip Param[T]) Set(i int, val T) {
 	ip.Values[i] = val
 }
 
 // get index by string key.
-func (ip StrParam) GetIndex(key string) int {
+func (ip Param[T]) GetIndex(key string) int {
 	return ip.nameIndexer.GetIndex(key)
 }
 
+// get name by index. return empty string if the index has no name.
+func (ip Param[T]) GetName(i int) string {
+	return ip.nameIndexer.GetName(i)
+}
+
 // same as io.Values[i] but i is obtained by
-// using string key,
-func (ip StrParam) GetByStr(key string) (string, bool) {
+// using string key. It returns zero value and false if the key is not found,
+// or for multi-dimensional one, use Sub() instead.
+func (ip Param[T]) GetByStr(key string) (T, bool) {
 	i := ip.GetIndex(key)
-	if i == IndexNotFound {
-		return "", false
+	if i == IndexNotFound || ip.shape.isMultiDim() {
+		var zero T
+		return zero, false
 	}
 	return ip.Values[i], true
 }
 
 // same as io.Values[i] = val but i is obtained by
-// using string key,
-func (ip StrParam) SetByStr(key string, val string) bool {
+// using string key. It returns false for multi-dimensional one,
+// use Sub() instead.
+func (ip Param[T]) SetByStr(key string, val T) bool {
 	i := ip.GetIndex(key)
-	if i == IndexNotFound {
+	if i == IndexNotFound || ip.shape.isMultiDim() {
 		return false
 	}
 	ip.Values[i] = val
 	return true
 }
 
-// same as []string[from:to], but taking over nameIndexer.
-func (ip StrParam) Slice(from, to int) StrParam {
-	return NewStrParam(ip.Values[from:to], LimitedRangeNameIndexer{
+// same as []T[from:to], but taking over nameIndexer.
+// For multi-dimensional one, the first dimension is sliced.
+func (ip Param[T]) Slice(from, to int) Param[T] {
+	indexer := LimitedRangeNameIndexer{
 		from:     from,
 		to:       to,
 		original: ip.nameIndexer,
-	})
+	}
+	if !ip.shape.isMultiDim() {
+		return newParam(ip.Values[from:to], indexer)
+	}
+	stride := ip.shape.stride()
+	shape := ip.shape
+	shape.dims = append([]int{to - from}, shape.dims[1:]...)
+	return Param[T]{
+		Values:      ip.Values[from*stride : to*stride],
+		nameIndexer: indexer,
+		shape:       shape,
+	}
 }
 
-// It fills by given value to all values contained in IntParam.
-func (ip StrParam) Fill(value string) {
+// It fills by given value to all values contained in Param.
+func (ip Param[T]) Fill(value T) {
 	for i := 0; i < len(ip.Values); i++ {
 		ip.Values[i] = value
 	}
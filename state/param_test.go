@@ -1,6 +1,8 @@
 package state
 
 import (
+	"errors"
+	"strings"
 	"testing"
 )
 
@@ -14,6 +16,15 @@ func (n NameIndex) GetIndex(k string) int {
 	return i
 }
 
+func (n NameIndex) GetName(i int) string {
+	for k, v := range n {
+		if v == i {
+			return k
+		}
+	}
+	return ""
+}
+
 var defaultNameIndexer = NameIndex{
 	"a": 0,
 	"b": 1,
@@ -104,6 +115,30 @@ func TestIntParamSliceNest(t *testing.T) {
 
 	// StrParamSliceNest is tested too.
 }
+
+func TestIntParamGetNameAndSuggest(t *testing.T) {
+	vars := []int64{0, 1, 2, 3, 4}
+	intparam := NewIntParam(vars, NameIndex{"hp": 0, "mp": 1, "power": 2})
+	sliced := intparam.Slice(1, 3)
+
+	if name := sliced.GetName(0); name != "mp" {
+		t.Errorf("sliced.GetName() returns invalid name, got %v expect %v", name, "mp")
+	}
+	if name := sliced.GetName(2); name != "" {
+		t.Errorf("sliced.GetName() out of range should return empty, got %v", name)
+	}
+
+	if name, ok := NearestName(intparam, intparam.Len(), "powr"); !ok || name != "power" {
+		t.Errorf("NearestName() returns invalid name, got %v, %v expect %v", name, ok, "power")
+	}
+	// hp is out of sliced range.
+	if name, ok := NearestName(sliced, sliced.Len(), "hq"); ok && name == "hp" {
+		t.Errorf("NearestName() should not suggest name out of range, got %v", name)
+	}
+	if _, ok := NearestName(intparam, intparam.Len(), "unknown"); ok {
+		t.Error("NearestName() should not suggest for far key")
+	}
+}
 func BenchmarkIntParamSliceNestN(b *testing.B) {
 	vars := []int64{0, 1, 2, 3, 4}
 	intparam := NewIntParam(vars, defaultNameIndexer)
@@ -182,3 +217,115 @@ func TestStrParamSlice(t *testing.T) {
 		}
 	}
 }
+
+func TestFloatParamGetByStr(t *testing.T) {
+	floatparam := NewFloatParam([]float64{0.5, 1.5, 2.5, 3.5, 4.5}, defaultNameIndexer)
+	if val, ok := floatparam.GetByStr("b"); !ok || val != 1.5 {
+		t.Errorf("GetByStr() returns invalid value, got %v, %v expect %v", val, ok, 1.5)
+	}
+	if val, ok := floatparam.Slice(2, 4).GetByStr("b"); ok || val != 0 {
+		t.Errorf("GetByStr(invalid key) should return zero value and false, got %v, %v", val, ok)
+	}
+}
+
+func TestIntParamMultiDim(t *testing.T) {
+	gamestate := NewGameState(CSVDB, Repo)
+	c, err := gamestate.SystemData.Chara.AddID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history, ok := c.GetInt("BaseHistory")
+	if !ok {
+		t.Fatal("BaseHistory is not found")
+	}
+	if !history.IsMultiDim() || history.Len() != 3 {
+		t.Fatalf("different shape, dims: %v, len: %v", history.Dims(), history.Len())
+	}
+	nBase := history.Dims()[1]
+
+	sub := history.Sub(2)
+	if sub.IsMultiDim() || sub.Len() != nBase {
+		t.Fatalf("different sub shape, dims: %v", sub.Dims())
+	}
+	if v, ok := sub.GetByStr("気力"); !ok || v != 5 {
+		t.Errorf("different csv initialized value, got: %v, ok: %v", v, ok)
+	}
+	if i := history.FlatIndex(2, 1); history.Get(i) != 5 {
+		t.Errorf("different value by flat index %v", i)
+	}
+	if i := history.FlatIndex(3, 0); i != IndexNotFound {
+		t.Errorf("out of range index should not be found, got: %v", i)
+	}
+	if _, ok := history.GetByStr("気力"); ok {
+		t.Error("multi-dimensional param should not be accessed by string key")
+	}
+
+	// sub param shares values with original.
+	history.Sub(1).Set(0, 10)
+	if v := history.Values[nBase]; v != 10 {
+		t.Errorf("sub param should share values, got: %v", v)
+	}
+
+	sliced := history.Slice(1, 3)
+	if sliced.Len() != 2 || sliced.Sub(0).Get(0) != 10 || sliced.Sub(1).Get(1) != 5 {
+		t.Errorf("different sliced param, dims: %v", sliced.Dims())
+	}
+
+	memo, _ := gamestate.SystemData.GetStr("Memo")
+	if dims := memo.Dims(); len(dims) != 2 || dims[1] != nBase {
+		t.Errorf("different dims of Memo, got: %v", dims)
+	}
+	memo.Sub(0).SetByStr("体力", "memo")
+	if got := memo.Values[0]; got != "memo" {
+		t.Errorf("different str value, got: %v", got)
+	}
+}
+
+func TestIntParamBound(t *testing.T) {
+	gamestate := NewGameState(CSVDB, Repo)
+	c, err := gamestate.SystemData.Chara.AddID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Base is bounded as min 0 with clamp by _VariableBounds.csv.
+	base, _ := c.GetInt("Base")
+	if err := base.Set(0, -10); err != nil || base.Get(0) != 0 {
+		t.Errorf("value should be clamped, got: %v, err: %v", base.Get(0), err)
+	}
+	if !base.SetByStr("気力", -1) || base.Get(1) != 0 {
+		t.Errorf("value by string key should be clamped, got: %v", base.Get(1))
+	}
+	if err := base.Slice(1, 2).Fill(-3); err != nil || base.Get(1) != 0 {
+		t.Errorf("filled value should be clamped, got: %v, err: %v", base.Get(1), err)
+	}
+
+	// Stain is bounded as default 10 and range [0, 100] with error.
+	stain, _ := c.GetInt("Stain")
+	if stain.Get(0) != 10 {
+		t.Errorf("Stain should be initialized by default, got: %v", stain.Get(0))
+	}
+	err = stain.Set(0, 101)
+	var boundErr *BoundError
+	if !errors.As(err, &boundErr) || boundErr.VarName != "Stain" || boundErr.Value != 101 {
+		t.Fatalf("out of range should be BoundError, got: %v", err)
+	}
+	if stain.Get(0) != 10 {
+		t.Errorf("value should not be changed on error, got: %v", stain.Get(0))
+	}
+	if err := stain.CheckValue(0, 100); err != nil {
+		t.Errorf("value in range should be valid, got: %v", err)
+	}
+	if err := stain.Fill(-1); err == nil || !strings.HasPrefix(err.Error(), "Stain: ") {
+		t.Errorf("Fill should be error without index, got: %v", err)
+	}
+	if stain.SetByStr(stain.GetName(0), -1) {
+		t.Error("SetByStr should fail for out of range")
+	}
+
+	stain.Set(0, 50)
+	c.Clear()
+	if stain.Get(0) != 10 {
+		t.Errorf("Clear should reset to default, got: %v", stain.Get(0))
+	}
+}
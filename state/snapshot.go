@@ -0,0 +1,115 @@
+package state
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/ugorji/go/codec"
+)
+
+// SnapshotHistorySize is the maximum number of snapshots kept for rewinding.
+const SnapshotHistorySize = 32
+
+// ErrNoSnapshot indicates the snapshot history does not have enough snapshots to rewind.
+var ErrNoSnapshot = errors.New("state: no snapshot to rewind")
+
+// snapshotCodec encodes SystemData by msgpack, same as the save files.
+// Canonical is required to compare the encoded data with the latest snapshot,
+// since the order of map keys is random.
+var snapshotCodec = &codec.MsgpackHandle{BasicHandle: codec.BasicHandle{EncodeOptions: codec.EncodeOptions{Canonical: true}}}
+
+// snapshot is an encoded SystemData at a point of the game.
+type snapshot struct {
+	label string
+	data  []byte
+}
+
+// snapshotHistory holds snapshots ordered from oldest to newest.
+// Its length is bounded by SnapshotHistorySize.
+type snapshotHistory struct {
+	records []snapshot
+}
+
+func newSnapshotHistory() *snapshotHistory {
+	return &snapshotHistory{records: make([]snapshot, 0, SnapshotHistorySize)}
+}
+
+// latest returns n-th latest snapshot, where n starts from 1.
+func (h *snapshotHistory) latest(n int) (snapshot, bool) {
+	if n < 1 || n > len(h.records) {
+		return snapshot{}, false
+	}
+	return h.records[len(h.records)-n], true
+}
+
+func (h *snapshotHistory) push(s snapshot) {
+	if len(h.records) >= SnapshotHistorySize {
+		copy(h.records, h.records[1:])
+		h.records = h.records[:len(h.records)-1]
+	}
+	h.records = append(h.records, s)
+}
+
+// drop removes n latest snapshots.
+func (h *snapshotHistory) drop(n int) {
+	if n > len(h.records) {
+		n = len(h.records)
+	}
+	h.records = h.records[:len(h.records)-n]
+}
+
+// TakeSnapshot stores current SystemData into the snapshot history with label,
+// such as the scene name. At most SnapshotHistorySize snapshots are kept, and
+// the oldest one is removed if exceeded. The snapshot is not stored if
+// SystemData is not changed since the latest snapshot.
+//
+// Each call encodes whole of SystemData and compares it with the latest snapshot,
+// so that its cost is proportional to the size of SystemData, same as saving
+// the game without writing a file. It is called only if enabled by the caller,
+// e.g. scene.Config.RewindSnapshot.
+func (state *GameState) TakeSnapshot(label string) error {
+	var data []byte
+	if err := codec.NewEncoderBytes(&data, snapshotCodec).Encode(state.SystemData); err != nil {
+		return fmt.Errorf("state: can not take snapshot: %w", err)
+	}
+	if last, ok := state.snapshots.latest(1); ok && bytes.Equal(last.data, data) {
+		return nil
+	}
+	state.snapshots.push(snapshot{label: label, data: data})
+	return nil
+}
+
+// Rewind restores SystemData from n-th latest snapshot, where n starts from 1,
+// and returns label of the snapshot. The snapshots newer than restored one are removed,
+// so that Rewind(1) restores same snapshot again.
+// It returns error wrapping ErrNoSnapshot if the history does not have n snapshots.
+func (state *GameState) Rewind(n int) (string, error) {
+	s, ok := state.snapshots.latest(n)
+	if !ok {
+		return "", fmt.Errorf("%w: can not rewind %d snapshots, only %d snapshots exist", ErrNoSnapshot, n, len(state.snapshots.records))
+	}
+	// same as LoadSystem, see it for details.
+	state.SystemData.dropExtra()
+	if err := codec.NewDecoderBytes(s.data, snapshotCodec).Decode(state.SystemData); err != nil {
+		return "", fmt.Errorf("state: can not restore snapshot: %w", err)
+	}
+	state.SystemData.refine(state.CSV)
+	state.resetWatches()
+	state.snapshots.drop(n - 1)
+	return s.label, nil
+}
+
+// SnapshotLabels returns labels of snapshots ordered from oldest to newest.
+func (state *GameState) SnapshotLabels() []string {
+	labels := make([]string, 0, len(state.snapshots.records))
+	for _, s := range state.snapshots.records {
+		labels = append(labels, s.label)
+	}
+	return labels
+}
+
+// ClearSnapshots removes all of snapshots.
+func (state *GameState) ClearSnapshots() {
+	state.snapshots = newSnapshotHistory()
+}
@@ -0,0 +1,101 @@
+package state
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSnapshotRewind(t *testing.T) {
+	gamestate := NewGameState(CSVDB, Repo)
+	const CharaID = 1 // it must exist
+
+	chara, err := gamestate.SystemData.Chara.AddID(CharaID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, _ := chara.GetInt("Base")
+	base.SetByStr("体力", 100)
+	if err := gamestate.TakeSnapshot("first"); err != nil {
+		t.Fatal(err)
+	}
+	// not changed, so not stored.
+	if err := gamestate.TakeSnapshot("same"); err != nil {
+		t.Fatal(err)
+	}
+
+	base.SetByStr("体力", 200)
+	if _, err := gamestate.SystemData.Chara.AddID(CharaID); err != nil {
+		t.Fatal(err)
+	}
+	if err := gamestate.TakeSnapshot("second"); err != nil {
+		t.Fatal(err)
+	}
+	if labels := gamestate.SnapshotLabels(); !reflect.DeepEqual(labels, []string{"first", "second"}) {
+		t.Errorf("invalid snapshot labels, got: %v", labels)
+	}
+
+	if _, err := gamestate.Rewind(3); !errors.Is(err, ErrNoSnapshot) {
+		t.Errorf("rewind over the history should be ErrNoSnapshot, got: %v", err)
+	}
+	label, err := gamestate.Rewind(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if label != "first" {
+		t.Errorf("invalid rewound label, expect: first, got: %v", label)
+	}
+	if l := gamestate.SystemData.Chara.Len(); l != 1 {
+		t.Errorf("expect 1 character after rewind, but %v", l)
+	}
+	newBase, _ := gamestate.SystemData.Chara.Get(0).GetInt("Base")
+	if v, _ := newBase.GetByStr("体力"); v != 100 {
+		t.Errorf("expect Base restored to 100, but %v", v)
+	}
+	if labels := gamestate.SnapshotLabels(); !reflect.DeepEqual(labels, []string{"first"}) {
+		t.Errorf("newer snapshots should be removed, got: %v", labels)
+	}
+}
+
+func TestSnapshotHistoryBounded(t *testing.T) {
+	h := newSnapshotHistory()
+	for i := 0; i < SnapshotHistorySize+5; i++ {
+		h.push(snapshot{data: []byte{byte(i)}})
+	}
+	if len(h.records) != SnapshotHistorySize {
+		t.Errorf("history should be bounded by %d, got: %d", SnapshotHistorySize, len(h.records))
+	}
+	if s, _ := h.latest(SnapshotHistorySize); s.data[0] != 5 {
+		t.Errorf("oldest snapshot should be 5, got: %v", s.data[0])
+	}
+}
+
+func TestSnapshotClearedByLoad(t *testing.T) {
+	gamestate := NewGameState(CSVDB, Repo)
+	if err := gamestate.SaveSystem(0); err != nil {
+		t.Fatal(err)
+	}
+
+	number, _ := gamestate.SystemData.GetInt("Number")
+	number.Set(0, 10)
+	if err := gamestate.TakeSnapshot("other game"); err != nil {
+		t.Fatal(err)
+	}
+	if err := gamestate.LoadSystem(0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gamestate.Rewind(1); !errors.Is(err, ErrNoSnapshot) {
+		t.Errorf("snapshot before loading should not be rewound, got: %v", err)
+	}
+	if number, _ := gamestate.SystemData.GetInt("Number"); number.Get(0) != 0 {
+		t.Errorf("expect loaded value 0, but %v", number.Get(0))
+	}
+
+	if err := gamestate.TakeSnapshot("this game"); err != nil {
+		t.Fatal(err)
+	}
+	gamestate.Clear()
+	if labels := gamestate.SnapshotLabels(); len(labels) != 0 {
+		t.Errorf("snapshots should be removed by clear, got: %v", labels)
+	}
+}
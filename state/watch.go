@@ -0,0 +1,231 @@
+package state
+
+import (
+	"fmt"
+
+	"github.com/mzki/erago/state/csv"
+)
+
+// VarChange is a change of the watched variable detected by GameState.PollChanges.
+type VarChange struct {
+	WatchID  int
+	VarName  string
+	CharaUID uint64 // UID of the changed character. 0 for system and share variables.
+	Index    int
+	Old, New interface{} // int64, string, float64 or bool for each data type of the variable.
+}
+
+// varWatch watches a variable. It holds values at the last poll for each
+// watched character, or for key 0 if the variable is not character's one.
+type varWatch struct {
+	id      int
+	varname string
+	chara   bool
+	uid     uint64 // target character. 0 means all characters.
+	last    map[uint64]interface{}
+}
+
+// watchedValues is current values of a variable owned by uid.
+type watchedValues struct {
+	uid    uint64
+	values interface{} // []int64 or []string
+}
+
+// changeWatcher holds watches ordered by registration.
+type changeWatcher struct {
+	watches []*varWatch
+	lastID  int
+}
+
+func newChangeWatcher() *changeWatcher {
+	return &changeWatcher{watches: make([]*varWatch, 0, 4)}
+}
+
+// Watch starts watching the system or share variable, and returns id of the watch.
+// Changes of the variable, including derived variable, are reported by PollChanges.
+// It returns error if the variable is not found.
+func (state *GameState) Watch(varname string) (int, error) {
+	if _, ok := state.globalVariables(varname); !ok {
+		return 0, fmt.Errorf("state: system or share variable %q is not found", varname)
+	}
+	return state.addWatch(&varWatch{varname: varname}), nil
+}
+
+// WatchChara starts watching the character variable, and returns id of the watch.
+// uid specifies the character to watch, and 0 means all of characters.
+// Changes of the variable are reported by PollChanges.
+// It returns error if the variable is not found.
+func (state *GameState) WatchChara(uid uint64, varname string) (int, error) {
+	if !state.isCharaVariable(varname) {
+		return 0, fmt.Errorf("state: character variable %q is not found", varname)
+	}
+	return state.addWatch(&varWatch{varname: varname, chara: true, uid: uid}), nil
+}
+
+func (state *GameState) addWatch(w *varWatch) int {
+	state.watcher.lastID += 1
+	w.id = state.watcher.lastID
+	w.last = state.currentValuesMap(w)
+	state.watcher.watches = append(state.watcher.watches, w)
+	return w.id
+}
+
+// Unwatch stops the watch specified by id. It returns whether the watch is removed.
+func (state *GameState) Unwatch(id int) bool {
+	ws := state.watcher.watches
+	for i, w := range ws {
+		if w.id == id {
+			state.watcher.watches = append(ws[:i:i], ws[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// ClearWatches removes all of watches.
+func (state *GameState) ClearWatches() {
+	state.watcher = newChangeWatcher()
+}
+
+// PollChanges returns changes of the watched variables since the last poll
+// or the start of watch. Changes are ordered by the watch, the character order
+// and the index. Characters added after the last poll are not reported, and
+// those values are watched from now.
+//
+// Values are compared at the poll rather than at each assignment, so that
+// the watch does not cost when it is not used. Thus a value changed and
+// restored between polls is not reported. Replacing the data by loading
+// or rewinding is not reported either.
+func (state *GameState) PollChanges() []VarChange {
+	var changes []VarChange
+	for _, w := range state.watcher.watches {
+		current := state.currentValues(w)
+		for _, cur := range current {
+			if last, ok := w.last[cur.uid]; ok {
+				changes = appendVarChanges(changes, w, cur.uid, last, cur.values)
+			}
+		}
+		w.last = toValuesMap(current)
+	}
+	return changes
+}
+
+// resetWatches discards changes until now. It is used when whole of
+// the data is replaced, such as loading, since those are not changes by the game.
+func (state *GameState) resetWatches() {
+	for _, w := range state.watcher.watches {
+		w.last = state.currentValuesMap(w)
+	}
+}
+
+func (state *GameState) globalVariables(varname string) (UserVariables, bool) {
+	for _, uv := range []UserVariables{state.SystemData.UserVariables, *state.ShareData} {
+		if uv.hasVariable(varname) {
+			return uv, true
+		}
+	}
+	return UserVariables{}, false
+}
+
+func (state *GameState) isCharaVariable(varname string) bool {
+	for _, specs := range [][]csv.VariableSpec{
+		state.CSV.IntVariableSpecs(csv.ScopeChara),
+		state.CSV.StrVariableSpecs(csv.ScopeChara),
+		state.CSV.FloatVariableSpecs(csv.ScopeChara),
+		state.CSV.BoolVariableSpecs(csv.ScopeChara),
+	} {
+		for _, vs := range specs {
+			if vs.VarName == varname {
+				return true
+			}
+		}
+	}
+	_, ok := state.CSV.DerivedVariables(csv.ScopeChara)[varname]
+	return ok
+}
+
+func (state *GameState) currentValues(w *varWatch) []watchedValues {
+	if !w.chara {
+		uv, _ := state.globalVariables(w.varname)
+		return []watchedValues{{0, uv.copyValues(w.varname)}}
+	}
+	current := make([]watchedValues, 0, state.SystemData.Chara.Len())
+	for _, c := range state.SystemData.Chara.List {
+		if w.uid != 0 && w.uid != c.UID {
+			continue
+		}
+		if values := c.copyValues(w.varname); values != nil {
+			current = append(current, watchedValues{c.UID, values})
+		}
+	}
+	return current
+}
+
+func (state *GameState) currentValuesMap(w *varWatch) map[uint64]interface{} {
+	return toValuesMap(state.currentValues(w))
+}
+
+func toValuesMap(current []watchedValues) map[uint64]interface{} {
+	m := make(map[uint64]interface{}, len(current))
+	for _, cur := range current {
+		m[cur.uid] = cur.values
+	}
+	return m
+}
+
+func appendVarChanges(changes []VarChange, w *varWatch, uid uint64, last, cur interface{}) []VarChange {
+	newChange := func(i int, old, new interface{}) VarChange {
+		return VarChange{WatchID: w.id, VarName: w.varname, CharaUID: uid, Index: i, Old: old, New: new}
+	}
+	switch cur := cur.(type) {
+	case []int64:
+		changes = appendChangedValues(changes, last.([]int64), cur, newChange)
+	case []string:
+		changes = appendChangedValues(changes, last.([]string), cur, newChange)
+	case []float64:
+		changes = appendChangedValues(changes, last.([]float64), cur, newChange)
+	case []bool:
+		changes = appendChangedValues(changes, last.([]bool), cur, newChange)
+	}
+	return changes
+}
+
+// appendChangedValues appends changes for each index whose value is different between last and cur.
+func appendChangedValues[T comparable](changes []VarChange, last, cur []T, newChange func(int, interface{}, interface{}) VarChange) []VarChange {
+	for i := 0; i < len(cur) && i < len(last); i++ {
+		if last[i] != cur[i] {
+			changes = append(changes, newChange(i, last[i], cur[i]))
+		}
+	}
+	return changes
+}
+
+func (uvars UserVariables) hasVariable(varname string) bool {
+	_, hasInt := uvars.IntMap[varname]
+	_, hasStr := uvars.StrMap[varname]
+	_, hasFloat := uvars.FloatMap[varname]
+	_, hasBool := uvars.BoolMap[varname]
+	_, hasDerived := uvars.derivedMap[varname]
+	return hasInt || hasStr || hasFloat || hasBool || hasDerived
+}
+
+// copyValues returns copy of the variable values, []int64, []string, []float64 or []bool.
+// It returns nil if the variable is not found.
+func (uvars UserVariables) copyValues(varname string) interface{} {
+	if vars, ok := uvars.IntMap[varname]; ok {
+		return append([]int64(nil), vars.Values...)
+	}
+	if vars, ok := uvars.StrMap[varname]; ok {
+		return append([]string(nil), vars.Values...)
+	}
+	if vars, ok := uvars.FloatMap[varname]; ok {
+		return append([]float64(nil), vars.Values...)
+	}
+	if vars, ok := uvars.BoolMap[varname]; ok {
+		return append([]bool(nil), vars.Values...)
+	}
+	if _, ok := uvars.derivedMap[varname]; ok {
+		return append([]int64(nil), uvars.derivedValues(varname)...)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package state
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWatchSystemVariable(t *testing.T) {
+	gamestate := NewGameState(CSVDB, Repo)
+
+	if _, err := gamestate.Watch("Base"); err == nil {
+		t.Error("watching character variable as system variable should be error")
+	}
+	numberID, err := gamestate.Watch("Number")
+	if err != nil {
+		t.Fatal(err)
+	}
+	strID, err := gamestate.Watch("Str")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if changes := gamestate.PollChanges(); len(changes) != 0 {
+		t.Errorf("no changes are expected, got: %v", changes)
+	}
+
+	number, _ := gamestate.SystemData.GetInt("Number")
+	number.Set(0, 10)
+	str, _ := gamestate.SystemData.GetStr("Str")
+	str.Set(1, "restored")
+	str.Set(1, "") // restored before poll
+	str.Set(0, "changed")
+
+	expect := []VarChange{
+		{WatchID: numberID, VarName: "Number", Index: 0, Old: int64(0), New: int64(10)},
+		{WatchID: strID, VarName: "Str", Index: 0, Old: "", New: "changed"},
+	}
+	if changes := gamestate.PollChanges(); !reflect.DeepEqual(changes, expect) {
+		t.Errorf("different changes, expect: %v, got: %v", expect, changes)
+	}
+	if changes := gamestate.PollChanges(); len(changes) != 0 {
+		t.Errorf("changes should be reported only once, got: %v", changes)
+	}
+
+	if !gamestate.Unwatch(numberID) {
+		t.Error("can not unwatch")
+	}
+	number.Set(0, 20)
+	if changes := gamestate.PollChanges(); len(changes) != 0 {
+		t.Errorf("unwatched variable should not be reported, got: %v", changes)
+	}
+}
+
+func TestWatchCharaVariable(t *testing.T) {
+	gamestate := NewGameState(CSVDB, Repo)
+	const CharaID = 1 // it must exist
+
+	chara1, err := gamestate.SystemData.Chara.AddID(CharaID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gamestate.WatchChara(0, "Number"); err == nil {
+		t.Error("watching system variable as character variable should be error")
+	}
+	allID, err := gamestate.WatchChara(0, "Base")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oneID, err := gamestate.WatchChara(chara1.UID, "Base")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// added character is watched from next poll.
+	chara2, err := gamestate.SystemData.Chara.AddID(CharaID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base2, _ := chara2.GetInt("Base")
+	base2.Set(0, 3)
+	if changes := gamestate.PollChanges(); len(changes) != 0 {
+		t.Errorf("added character should not be reported, got: %v", changes)
+	}
+
+	base1, _ := chara1.GetInt("Base")
+	base1.Set(0, 5)
+	base2.Set(0, 4)
+	expect := []VarChange{
+		{WatchID: allID, VarName: "Base", CharaUID: chara1.UID, Index: 0, Old: int64(2000), New: int64(5)},
+		{WatchID: allID, VarName: "Base", CharaUID: chara2.UID, Index: 0, Old: int64(3), New: int64(4)},
+		{WatchID: oneID, VarName: "Base", CharaUID: chara1.UID, Index: 0, Old: int64(2000), New: int64(5)},
+	}
+	if changes := gamestate.PollChanges(); !reflect.DeepEqual(changes, expect) {
+		t.Errorf("different changes, expect: %v, got: %v", expect, changes)
+	}
+
+	// replacing whole data is not reported.
+	if err := gamestate.TakeSnapshot("snapshot"); err != nil {
+		t.Fatal(err)
+	}
+	base1.Set(0, 6)
+	if _, err := gamestate.Rewind(1); err != nil {
+		t.Fatal(err)
+	}
+	if changes := gamestate.PollChanges(); len(changes) != 0 {
+		t.Errorf("rewinding should not be reported, got: %v", changes)
+	}
+
+	gamestate.ClearWatches()
+	base1, _ = gamestate.SystemData.Chara.Get(0).GetInt("Base")
+	base1.Set(0, 7)
+	if changes := gamestate.PollChanges(); len(changes) != 0 {
+		t.Errorf("cleared watches should not report, got: %v", changes)
+	}
+}
+
+func TestWatchDerivedVariable(t *testing.T) {
+	gamestate := NewGameState(CSVDB, Repo)
+
+	// declared in _DerivedVariables.csv as "NumberDouble, Number, Number * 2"
+	id, err := gamestate.Watch("NumberDouble")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gamestate.WatchChara(0, "ParamLv"); err != nil {
+		t.Fatal(err)
+	}
+
+	number, _ := gamestate.SystemData.GetInt("Number")
+	number.Set(0, 21)
+	expect := []VarChange{
+		{WatchID: id, VarName: "NumberDouble", Index: 0, Old: int64(0), New: int64(42)},
+	}
+	if changes := gamestate.PollChanges(); !reflect.DeepEqual(changes, expect) {
+		t.Errorf("different changes, expect: %v, got: %v", expect, changes)
+	}
+}
@@ -21,6 +21,24 @@ func (ss sceneScripter) EraCallBoolArgInt(str string, n int64) (bool, error) {
 	return false, err
 }
 
+func (ss sceneScripter) EraCallIntArgInts(str string, args ...int64) (int64, error) {
+	_, err := fmt.Printf("scripter calls int = %s%v\n", str, args)
+	return 0, err
+}
+
 func (ss sceneScripter) HasEraValue(str string) bool {
 	return false
 }
+
+func (ss sceneScripter) EraEmit(str string, args ...int64) error {
+	_, err := fmt.Printf("scripter emits %s%v\n", str, args)
+	return err
+}
+
+func (ss sceneScripter) EraDispatchChanges() error {
+	return nil
+}
+
+func (ss sceneScripter) EraReloadParams() error {
+	return nil
+}
@@ -4,32 +4,40 @@ import (
 	"github.com/mzki/erago/state"
 )
 
-// implemnts script.GameController
-type scriptGameController struct {
+// implemnts script.GameController except for the scene transition history,
+// which requires the package scene depending on this package in its tests.
+type ScriptGameController struct {
 	*state.GameState
 	*sceneIOController
 }
 
-func NewScriptGameController() *scriptGameController {
+func NewScriptGameController() *ScriptGameController {
 	gstate, err := GetGameState()
 	if err != nil {
 		panic(err)
 	}
-	return &scriptGameController{gstate, NewFlowGameController()}
+	return &ScriptGameController{gstate, NewFlowGameController()}
 }
 
-// GetInputQueue gets internal scriptInputQueuer from scriptGameController.
+// GetInputQueue gets internal scriptInputQueuer from ScriptGameController.
 // The internal scriptInputQueuer affects the result of calling input APIs such as
 // RawInputXXX and CommandXXX.
-func GetInputQueue(ui *scriptGameController) *scriptInputQueuer {
+func GetInputQueue(ui *ScriptGameController) *scriptInputQueuer {
 	return ui.sceneIOController.scriptInputQueuer
 }
 
-func (ui scriptGameController) DoTrainsScene(cmds []int64) error                                { return nil }
-func (ui scriptGameController) DoLoadGameScene() error                                          { return nil }
-func (ui scriptGameController) DoSaveGameScene() error                                          { return nil }
-func (ui scriptGameController) SetNextSceneByName(name string) error                            { return nil }
-func (ui scriptGameController) CurrentSceneName() string                                        { return "dummy_current_snene" }
-func (ui scriptGameController) NextSceneName() string                                           { return "dummy_next_snene" }
-func (ui scriptGameController) RegisterSceneFunc(name string, next_func func() (string, error)) {}
-func (ui scriptGameController) UnRegisterScene(name string)                                     {}
+func (ui ScriptGameController) DoTrainsScene(cmds []int64) error                                { return nil }
+func (ui ScriptGameController) DoLoadGameScene() error                                          { return nil }
+func (ui ScriptGameController) DoSaveGameScene() error                                          { return nil }
+func (ui ScriptGameController) SetNextSceneByName(name string) error                            { return nil }
+func (ui ScriptGameController) CurrentSceneName() string                                        { return "dummy_current_snene" }
+func (ui ScriptGameController) NextSceneName() string                                           { return "dummy_next_snene" }
+func (ui ScriptGameController) CallSceneByName(name string) error                               { return nil }
+func (ui ScriptGameController) ReturnScene() error                                              { return nil }
+func (ui ScriptGameController) SceneStackNames() []string                                       { return []string{} }
+func (ui ScriptGameController) RegisterSceneFunc(name string, next_func func() (string, error)) {}
+func (ui ScriptGameController) UnRegisterScene(name string)                                     {}
+
+func (ui ScriptGameController) RegisterScriptSceneFunc(name string, next_func func() (string, error)) error {
+	return nil
+}
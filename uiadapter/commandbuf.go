@@ -13,12 +13,17 @@ var ErrorPipelineClosed = errors.New("pipeline is closed")
 // Error indicates XXXBuffer.Wait or Receive are cancelled
 var ErrorCommandWaitCanceled = errors.New("command Wait is cancelled")
 
+// errorCommandWaitInterrupted indicates XXXBuffer.Wait or Receive are interrupted
+// temporarily by control request, such as quick save. The waiting should be retried.
+var errorCommandWaitInterrupted = errors.New("command Wait is interrupted")
+
 // commandBuffer is buffer for input string command.
 type commandBuffer struct {
 	commands []string
 
-	closed  bool
-	waiting bool
+	closed      bool
+	waiting     bool
+	interrupted bool
 
 	mu     *sync.Mutex
 	cond   *sync.Cond
@@ -96,6 +101,7 @@ func (cbuf *commandBuffer) PrepareWaitReceive() {
 // wait any input or macro skip
 // It will return err as ErrorPipelineClosed when buffer is closed or
 // return as ErrorCommandWaitCanceled when buffer waiting is cancelled by external.
+// It also returns errorCommandWaitInterrupted when buffer waiting is interrupted.
 func (cbuf *commandBuffer) Wait() error {
 	cbuf.mu.Lock()
 	defer cbuf.mu.Unlock()
@@ -104,6 +110,11 @@ func (cbuf *commandBuffer) Wait() error {
 			return ErrorPipelineClosed
 		}
 
+		if cbuf.interrupted {
+			cbuf.interrupted = false
+			return errorCommandWaitInterrupted
+		}
+
 		if cbuf.macroQ.DequeUntilSkip() {
 			return nil
 		}
@@ -119,6 +130,7 @@ func (cbuf *commandBuffer) Wait() error {
 // receive input string from user input.
 // It will return err as ErrorPipelineClosed when buffer is closed or
 // return as ErrorCommandWaitCanceled when buffer waiting is cancelled by external.
+// It also returns errorCommandWaitInterrupted when buffer waiting is interrupted.
 func (cbuf *commandBuffer) Receive() (string, error) {
 	cbuf.mu.Lock()
 	defer cbuf.mu.Unlock()
@@ -127,6 +139,11 @@ func (cbuf *commandBuffer) Receive() (string, error) {
 			return "", ErrorPipelineClosed
 		}
 
+		if cbuf.interrupted {
+			cbuf.interrupted = false
+			return "", errorCommandWaitInterrupted
+		}
+
 		if cmd, ok := cbuf.macroQ.DequeCommand(); ok {
 			return cmd, nil
 		}
@@ -157,3 +174,12 @@ func (cbuf *commandBuffer) Cancel() {
 	cbuf.mu.Unlock()
 	cbuf.cond.Broadcast()
 }
+
+// interrupt current or next wait state temporarily.
+// Wait and Receive return errorCommandWaitInterrupted once.
+func (cbuf *commandBuffer) Interrupt() {
+	cbuf.mu.Lock()
+	cbuf.interrupted = true
+	cbuf.mu.Unlock()
+	cbuf.cond.Broadcast()
+}
@@ -39,6 +39,20 @@ const (
 
 	// stop current running macro.
 	ControlInterruptMacro
+
+	// save game state to the quick save slot. It is queued
+	// and processed at the next input point out of script or
+	// the next scene transition.
+	ControlQuickSave
+
+	// load game state from the quick save slot. It is queued
+	// and processed same as ControlQuickSave. The scene in progress
+	// is restarted with the loaded game state.
+	ControlQuickLoad
+
+	// rewind game state to the latest snapshot. It is queued
+	// and processed same as ControlQuickLoad.
+	ControlRewind
 )
 
 // make new InputEvent type EventCommand. cmd = "" means emitting command nothing.
@@ -32,9 +32,12 @@ type inputPort struct {
 
 	requestObservers [inputRequestTypeLen]RequestObserver
 
+	controlHandler ControlRequestHandler
+
 	// this mutex controls the fields below.
-	mu     *sync.Mutex
-	closed bool // the port is closed?
+	mu              *sync.Mutex
+	closed          bool                // the port is closed?
+	controlRequests []input.ControlType // control requests not processed yet.
 }
 
 func newInputPort(ls *lineSyncer) *inputPort {
@@ -84,6 +87,64 @@ const (
 	inputRequestTypeLen
 )
 
+// ControlRequestHandler handles control requests queued by user.
+// It is called in the context of input APIs, before waiting user input
+// and whenever new request is queued while waiting. The error returned
+// by the handler is returned by the input API.
+type ControlRequestHandler func() error
+
+// SetControlRequestHandler sets handler for the control requests.
+// nil handler leaves the requests in the queue. It can not use concurrently.
+func (port *inputPort) SetControlRequestHandler(h ControlRequestHandler) {
+	port.controlHandler = h
+}
+
+// requestControl queues control request, input.ControlQuickSave,
+// input.ControlQuickLoad or input.ControlRewind, and interrupts current
+// waiting for user input if exist. Same request as the last queued one
+// is ignored, since it has no effect to do it twice.
+func (port *inputPort) requestControl(ctrl input.ControlType) {
+	port.mu.Lock()
+	if n := len(port.controlRequests); n > 0 && port.controlRequests[n-1] == ctrl {
+		port.mu.Unlock()
+		return
+	}
+	port.controlRequests = append(port.controlRequests, ctrl)
+	port.mu.Unlock()
+	port.cbuf.Interrupt()
+}
+
+// handleControlRequests calls the handler for the control requests if set.
+// It must be called in the context of input APIs.
+func (port *inputPort) handleControlRequests() error {
+	if port.controlHandler == nil {
+		return nil
+	}
+	return port.controlHandler()
+}
+
+// HasControlRequest returns whether control requests not processed yet exist.
+func (port *inputPort) HasControlRequest() bool {
+	port.mu.Lock()
+	defer port.mu.Unlock()
+	return len(port.controlRequests) > 0
+}
+
+// PopControlRequest removes the oldest control request queued by user and returns it.
+// It returns input.ControlNone if no request exists.
+// The requests are processed by game flow through the handler, since
+// they change game state which may be used by running script.
+func (port *inputPort) PopControlRequest() input.ControlType {
+	port.mu.Lock()
+	defer port.mu.Unlock()
+	if len(port.controlRequests) == 0 {
+		return input.ControlNone
+	}
+	ctrl := port.controlRequests[0]
+	port.controlRequests = port.controlRequests[1:]
+	return ctrl
+}
+
 // It can not use concurrently.
 func (port *inputPort) RegisterRequestObserver(typ InputRequestType, o RequestObserver) {
 	if typ < InputRequestNone || typ >= inputRequestTypeLen {
@@ -140,6 +201,12 @@ func (p *inputPort) RunFilter(ctx context.Context) error {
 				doneCh <- struct{}{}
 				return
 			}
+			// control request is accepted at any state, and processed by game flow later.
+			if ev, ok := ev.(input.Event); ok && ev.Type == input.EventControl &&
+				(ev.Control == input.ControlQuickSave || ev.Control == input.ControlQuickLoad || ev.Control == input.ControlRewind) {
+				p.requestControl(ev.Control)
+				continue
+			}
 
 			// update macro state
 			macroNext := p.macroState.NextState(p, ev)
@@ -250,6 +317,18 @@ func (port *inputPort) WaitWithTimeout(ctx context.Context, timeout time.Duratio
 }
 
 func (port *inputPort) waitWithContext(ctx context.Context) error {
+	for {
+		if err := port.handleControlRequests(); err != nil {
+			return err
+		}
+		err := port.waitOnceWithContext(ctx)
+		if !errors.Is(err, errorCommandWaitInterrupted) {
+			return err
+		}
+	}
+}
+
+func (port *inputPort) waitOnceWithContext(ctx context.Context) error {
 	port.cbuf.PrepareWaitReceive()
 	errCh := make(chan error, 1)
 	go func() {
@@ -301,6 +380,18 @@ func (port *inputPort) CommandWithTimeout(ctx context.Context, timeout time.Dura
 }
 
 func (port *inputPort) commandWithContext(ctx context.Context) (string, error) {
+	for {
+		if err := port.handleControlRequests(); err != nil {
+			return "", err
+		}
+		cmd, err := port.commandOnceWithContext(ctx)
+		if !errors.Is(err, errorCommandWaitInterrupted) {
+			return cmd, err
+		}
+	}
+}
+
+func (port *inputPort) commandOnceWithContext(ctx context.Context) (string, error) {
 	port.cbuf.PrepareWaitReceive()
 	cmdCh := make(chan struct {
 		Cmd string
@@ -3,6 +3,7 @@ package uiadapter
 import (
 	"context"
 	"errors"
+	"reflect"
 	"testing"
 	"time"
 
@@ -404,3 +405,90 @@ func TestUnregisterAllRequestObserver(t *testing.T) {
 		}
 	}
 }
+
+func TestInputControlRequest(t *testing.T) {
+	port := newInputPort(newSyncer())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go port.RunFilter(ctx)
+
+	if got := port.PopControlRequest(); got != input.ControlNone {
+		t.Fatalf("no control request should exist, got: %v", got)
+	}
+
+	// control request does not break waiting command.
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		port.Send(input.NewEventControl(input.ControlQuickSave))
+		port.Send(input.NewEventControl(input.ControlQuickSave))
+		port.Send(input.NewEventControl(input.ControlQuickLoad))
+		time.Sleep(30 * time.Millisecond)
+		SendCommand(port, "10")
+	}()
+	num, err := port.CommandNumber()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if num != 10 {
+		t.Errorf("invalid got input; got:  %v, expect %v", num, 10)
+	}
+
+	// requests are queued in order, and the repeated request is merged.
+	for _, expect := range []input.ControlType{
+		input.ControlQuickSave,
+		input.ControlQuickLoad,
+		input.ControlNone,
+	} {
+		if got := port.PopControlRequest(); got != expect {
+			t.Errorf("invalid control request; got: %v, expect: %v", got, expect)
+		}
+	}
+}
+
+func TestInputControlRequestHandler(t *testing.T) {
+	port := newInputPort(newSyncer())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go port.RunFilter(ctx)
+
+	// requests are handled while waiting command, and waiting is continued.
+	var handled []input.ControlType
+	port.SetControlRequestHandler(func() error {
+		for ctrl := port.PopControlRequest(); ctrl != input.ControlNone; ctrl = port.PopControlRequest() {
+			handled = append(handled, ctrl)
+		}
+		return nil
+	})
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		port.Send(input.NewEventControl(input.ControlQuickSave))
+		time.Sleep(30 * time.Millisecond)
+		SendCommand(port, "10")
+	}()
+	num, err := port.CommandNumber()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if num != 10 {
+		t.Errorf("invalid got input; got:  %v, expect %v", num, 10)
+	}
+	if !reflect.DeepEqual(handled, []input.ControlType{input.ControlQuickSave}) {
+		t.Errorf("request should be handled while waiting, got: %v", handled)
+	}
+
+	// error by the handler is returned from the input API.
+	handlerErr := errors.New("handler error")
+	port.SetControlRequestHandler(func() error {
+		if port.PopControlRequest() != input.ControlNone {
+			return handlerErr
+		}
+		return nil
+	})
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		port.Send(input.NewEventControl(input.ControlQuickLoad))
+	}()
+	if err := port.Wait(); !errors.Is(err, handlerErr) {
+		t.Errorf("handler error should be returned, got: %v", err)
+	}
+}
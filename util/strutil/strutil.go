@@ -0,0 +1,62 @@
+package strutil
+
+// Distance returns the Levenshtein distance between a and b, counted by runes.
+func Distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 {
+		return len(rb)
+	}
+	if len(rb) == 0 {
+		return len(ra)
+	}
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// Nearest returns the candidate which is nearest to name by Distance.
+// Candidates whose distance exceeds maxDist are ignored.
+// If several candidates have the same distance, the first one is returned.
+// It returns false if no candidate is found.
+func Nearest(name string, candidates []string, maxDist int) (string, bool) {
+	var (
+		nearest string
+		found   = false
+		minDist = maxDist + 1
+	)
+	for _, c := range candidates {
+		if d := Distance(name, c); d < minDist {
+			nearest, minDist, found = c, d, true
+		}
+	}
+	return nearest, found
+}
+
+// SuggestDist returns a reasonable maximum distance for suggesting
+// a name similar to the given name.
+func SuggestDist(name string) int {
+	n := len([]rune(name))
+	switch {
+	case n <= 3:
+		return 1
+	case n <= 8:
+		return 2
+	default:
+		return 3
+	}
+}
@@ -0,0 +1,31 @@
+package strutil
+
+import "testing"
+
+func TestDistance(t *testing.T) {
+	for _, testcase := range []struct {
+		A, B   string
+		Expect int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"kitten", "sitting", 3},
+		{"title_scene", "titel_scene", 2},
+		{"体力", "気力", 1},
+	} {
+		if got := Distance(testcase.A, testcase.B); got != testcase.Expect {
+			t.Errorf("Distance(%q, %q): expect %d, got %d", testcase.A, testcase.B, testcase.Expect, got)
+		}
+	}
+}
+
+func TestNearest(t *testing.T) {
+	candidates := []string{"title_scene", "title_event_start", "turnend_scene"}
+	if got, ok := Nearest("titl_scene", candidates, 2); !ok || got != "title_scene" {
+		t.Errorf("expect title_scene, got %q, %v", got, ok)
+	}
+	if got, ok := Nearest("shop_scene", candidates, 2); ok {
+		t.Errorf("should not be found, got %q", got)
+	}
+}